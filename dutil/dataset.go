@@ -119,3 +119,48 @@ func (ds *MapDataset) DType() reflect.Type {
 
 // NOTE. To make this package agnostic, we don't add TensorDataset here.
 // A end-user can create a custom dataset by implementing `Item()` method.
+
+// SubsetDataset is a view of a dataset at given indices. It doesn't copy data.
+type SubsetDataset struct {
+	dataset Dataset
+	indices []int
+}
+
+// NewSubsetDataset creates a new SubsetDataset.
+func NewSubsetDataset(ds Dataset, indices []int) (*SubsetDataset, error) {
+	n := ds.Len()
+	for _, idx := range indices {
+		if idx < 0 || idx >= n {
+			err := fmt.Errorf("Invalid subset index %v: out of range [0, %v).", idx, n)
+			return nil, err
+		}
+	}
+
+	return &SubsetDataset{
+		dataset: ds,
+		indices: indices,
+	}, nil
+}
+
+// Item implements Dataset interface.
+func (ds *SubsetDataset) Item(idx int) (interface{}, error) {
+	if idx < 0 || idx >= len(ds.indices) {
+		err := fmt.Errorf("Idx is out of range.")
+		return nil, err
+	}
+
+	return ds.dataset.Item(ds.indices[idx])
+}
+
+func (ds *SubsetDataset) Len() int {
+	return len(ds.indices)
+}
+
+func (ds *SubsetDataset) DType() reflect.Type {
+	return ds.dataset.DType()
+}
+
+// Indices returns indices of subset in the underlying dataset.
+func (ds *SubsetDataset) Indices() []int {
+	return ds.indices
+}
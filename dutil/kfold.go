@@ -12,7 +12,7 @@ type KFold struct {
 	n       int
 	nfolds  int
 	shuffle bool
-	//seed int
+	rng     *rand.Rand
 }
 
 // Fold represents a partitions with
@@ -23,16 +23,26 @@ type Fold struct {
 }
 
 type KFoldOptions struct {
-	NFolds  int  // number of folds
-	Shuffle bool // whether suffling before splitting
+	NFolds       int        // number of folds
+	Shuffle      bool       // whether suffling before splitting
+	Rand         *rand.Rand // random generator for shuffling. Default=nil means `gotch.DefaultRand()`
+	NRepeats     int        // number of repetitions. Used in RepeatedKFold
+	TestSize     int        // size of test set. Used in TimeSeriesSplit. Default=0 means n/(nfolds+1)
+	MaxTrainSize int        // maximum size of train set. Used in TimeSeriesSplit. Default=0 means no limit
+	Gap          int        // number of samples between train and test sets. Used in TimeSeriesSplit
 }
 
 type KFoldOption func(*KFoldOptions)
 
 func NewKFoldOptions(options ...KFoldOption) KFoldOptions {
 	opts := KFoldOptions{
-		NFolds:  5,
-		Shuffle: false,
+		NFolds:       5,
+		Shuffle:      false,
+		Rand:         nil,
+		NRepeats:     10,
+		TestSize:     0,
+		MaxTrainSize: 0,
+		Gap:          0,
 	}
 
 	for _, o := range options {
@@ -54,6 +64,41 @@ func WithKFoldShuffle(shuffle bool) KFoldOption {
 	}
 }
 
+func WithKFoldRand(r *rand.Rand) KFoldOption {
+	return func(o *KFoldOptions) {
+		o.Rand = r
+	}
+}
+
+func WithNRepeats(nrepeats int) KFoldOption {
+	return func(o *KFoldOptions) {
+		o.NRepeats = nrepeats
+	}
+}
+
+func WithKFoldTestSize(size int) KFoldOption {
+	return func(o *KFoldOptions) {
+		o.TestSize = size
+	}
+}
+
+func WithKFoldMaxTrainSize(size int) KFoldOption {
+	return func(o *KFoldOptions) {
+		o.MaxTrainSize = size
+	}
+}
+
+func WithKFoldGap(gap int) KFoldOption {
+	return func(o *KFoldOptions) {
+		o.Gap = gap
+	}
+}
+
+// Splitter is implemented by cross-validators which split samples into folds.
+type Splitter interface {
+	Split() []Fold
+}
+
 // NewKFold creates a new KFold struct.
 func NewKFold(n int, opt ...KFoldOption) (*KFold, error) {
 	opts := NewKFoldOptions(opt...)
@@ -72,6 +117,7 @@ func NewKFold(n int, opt ...KFoldOption) (*KFold, error) {
 		n:       n,
 		nfolds:  opts.NFolds,
 		shuffle: opts.Shuffle,
+		rng:     opts.Rand,
 	}, nil
 }
 
@@ -81,7 +127,7 @@ func (kf *KFold) Split() []Fold {
 	fsize := nsamples / kf.nfolds
 	var indices []int
 
-	allIndices := rand.Perm(kf.n)
+	allIndices := randOrDefault(kf.rng).Perm(kf.n)
 	// Drop last odd-time elements
 	indices = allIndices[:nsamples]
 
@@ -123,6 +169,289 @@ func (kf *KFold) Split() []Fold {
 	return splits
 }
 
+// Subsets returns train and test sets of fold as views of dataset.
+func (f Fold) Subsets(ds Dataset) (train, test *SubsetDataset, err error) {
+	train, err = NewSubsetDataset(ds, f.Train)
+	if err != nil {
+		return nil, nil, err
+	}
+	test, err = NewSubsetDataset(ds, f.Test)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return train, test, nil
+}
+
+// complement returns sorted indices in range [0, n) which are not in given set.
+func complement(n int, set []int) []int {
+	in := make([]bool, n)
+	for _, idx := range set {
+		in[idx] = true
+	}
+	var rest []int
+	for i := 0; i < n; i++ {
+		if !in[i] {
+			rest = append(rest, i)
+		}
+	}
+
+	return rest
+}
+
+// StratifiedKFold splits data into folds which preserve the proportion of
+// samples of each class.
+type StratifiedKFold struct {
+	labels  []int
+	nfolds  int
+	shuffle bool
+	rng     *rand.Rand
+}
+
+// NewStratifiedKFold creates a new StratifiedKFold.
+//
+// labels: class label of each sample. See `TensorLabels()` for labels in a tensor.
+func NewStratifiedKFold(labels []int, opt ...KFoldOption) (*StratifiedKFold, error) {
+	opts := NewKFoldOptions(opt...)
+	n := len(labels)
+
+	if opts.NFolds < 2 {
+		err := fmt.Errorf("nfolds must be at least 2. Got: %v\n", opts.NFolds)
+		return nil, err
+	}
+
+	if opts.NFolds > n {
+		err := fmt.Errorf("nfolds cannot be greater than number of samples (%v). Got: %v\n", n, opts.NFolds)
+		return nil, err
+	}
+
+	return &StratifiedKFold{
+		labels:  labels,
+		nfolds:  opts.NFolds,
+		shuffle: opts.Shuffle,
+		rng:     opts.Rand,
+	}, nil
+}
+
+// Split implements Splitter interface.
+//
+// Samples of each class are dealt to folds in turn, continuing from the fold
+// where previous class ended, so that folds have balanced class counts and sizes.
+func (kf *StratifiedKFold) Split() []Fold {
+	classIndices := make(map[int][]int)
+	var classes []int
+	for i, label := range kf.labels {
+		if _, ok := classIndices[label]; !ok {
+			classes = append(classes, label)
+		}
+		classIndices[label] = append(classIndices[label], i)
+	}
+	sort.Ints(classes)
+
+	tests := make([][]int, kf.nfolds)
+	fold := 0
+	for _, c := range classes {
+		idxs := classIndices[c]
+		if kf.shuffle {
+			randOrDefault(kf.rng).Shuffle(len(idxs), func(i, j int) { idxs[i], idxs[j] = idxs[j], idxs[i] })
+		}
+		for _, idx := range idxs {
+			tests[fold] = append(tests[fold], idx)
+			fold = (fold + 1) % kf.nfolds
+		}
+	}
+
+	var splits []Fold
+	for _, test := range tests {
+		sort.Ints(test)
+		splits = append(splits, Fold{
+			Train: complement(len(kf.labels), test),
+			Test:  test,
+		})
+	}
+
+	return splits
+}
+
+// GroupKFold splits data into folds so that samples of a group are all in the
+// same fold, i.e. no group appears in both train and test sets.
+type GroupKFold struct {
+	groups []int
+	nfolds int
+}
+
+// NewGroupKFold creates a new GroupKFold.
+//
+// groups: group label of each sample.
+func NewGroupKFold(groups []int, opt ...KFoldOption) (*GroupKFold, error) {
+	opts := NewKFoldOptions(opt...)
+
+	if opts.NFolds < 2 {
+		err := fmt.Errorf("nfolds must be at least 2. Got: %v\n", opts.NFolds)
+		return nil, err
+	}
+
+	ngroups := make(map[int]struct{})
+	for _, g := range groups {
+		ngroups[g] = struct{}{}
+	}
+	if opts.NFolds > len(ngroups) {
+		err := fmt.Errorf("nfolds cannot be greater than number of groups (%v). Got: %v\n", len(ngroups), opts.NFolds)
+		return nil, err
+	}
+
+	return &GroupKFold{
+		groups: groups,
+		nfolds: opts.NFolds,
+	}, nil
+}
+
+// Split implements Splitter interface.
+//
+// Groups are assigned from the largest to the smallest to the fold with the
+// fewest samples so that folds have approximately the same size.
+func (kf *GroupKFold) Split() []Fold {
+	groupIndices := make(map[int][]int)
+	var groups []int
+	for i, g := range kf.groups {
+		if _, ok := groupIndices[g]; !ok {
+			groups = append(groups, g)
+		}
+		groupIndices[g] = append(groupIndices[g], i)
+	}
+	sort.Ints(groups)
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groupIndices[groups[i]]) > len(groupIndices[groups[j]])
+	})
+
+	tests := make([][]int, kf.nfolds)
+	for _, g := range groups {
+		lightest := 0
+		for f := range tests {
+			if len(tests[f]) < len(tests[lightest]) {
+				lightest = f
+			}
+		}
+		tests[lightest] = append(tests[lightest], groupIndices[g]...)
+	}
+
+	var splits []Fold
+	for _, test := range tests {
+		sort.Ints(test)
+		splits = append(splits, Fold{
+			Train: complement(len(kf.groups), test),
+			Test:  test,
+		})
+	}
+
+	return splits
+}
+
+// TimeSeriesSplit splits time-ordered data so that test indices are always
+// after train indices. Train set of each split is a superset of the previous
+// one unless a maximum train size is set.
+type TimeSeriesSplit struct {
+	n            int
+	nsplits      int
+	testSize     int
+	maxTrainSize int
+	gap          int
+}
+
+// NewTimeSeriesSplit creates a new TimeSeriesSplit with number of splits set by
+// `WithNFolds()`, and optional `WithKFoldTestSize()`, `WithKFoldMaxTrainSize()`
+// and `WithKFoldGap()`.
+func NewTimeSeriesSplit(n int, opt ...KFoldOption) (*TimeSeriesSplit, error) {
+	opts := NewKFoldOptions(opt...)
+
+	if opts.NFolds < 2 {
+		err := fmt.Errorf("nfolds must be at least 2. Got: %v\n", opts.NFolds)
+		return nil, err
+	}
+
+	testSize := opts.TestSize
+	if testSize <= 0 {
+		testSize = n / (opts.NFolds + 1)
+	}
+	if testSize < 1 || opts.Gap < 0 || n-opts.Gap-testSize*opts.NFolds < 1 {
+		err := fmt.Errorf("too many splits (%v) for number of samples (%v) with test size %v and gap %v", opts.NFolds, n, testSize, opts.Gap)
+		return nil, err
+	}
+
+	return &TimeSeriesSplit{
+		n:            n,
+		nsplits:      opts.NFolds,
+		testSize:     testSize,
+		maxTrainSize: opts.MaxTrainSize,
+		gap:          opts.Gap,
+	}, nil
+}
+
+// Split implements Splitter interface.
+func (s *TimeSeriesSplit) Split() []Fold {
+	var splits []Fold
+	for i := 0; i < s.nsplits; i++ {
+		testStart := s.n - (s.nsplits-i)*s.testSize
+		trainEnd := testStart - s.gap
+		trainStart := 0
+		if s.maxTrainSize > 0 && trainEnd > s.maxTrainSize {
+			trainStart = trainEnd - s.maxTrainSize
+		}
+
+		splits = append(splits, Fold{
+			Train: intRangeFrom(trainStart, trainEnd),
+			Test:  intRangeFrom(testStart, testStart+s.testSize),
+		})
+	}
+
+	return splits
+}
+
+func intRangeFrom(start, end int) []int {
+	var r []int
+	for i := start; i < end; i++ {
+		r = append(r, i)
+	}
+	return r
+}
+
+// RepeatedKFold repeats shuffled KFold a number of times with different
+// randomization in each repetition.
+type RepeatedKFold struct {
+	kfold    *KFold
+	nrepeats int
+}
+
+// NewRepeatedKFold creates a new RepeatedKFold with number of repetitions set
+// by `WithNRepeats()` (default=10). Shuffling is always on.
+func NewRepeatedKFold(n int, opt ...KFoldOption) (*RepeatedKFold, error) {
+	opts := NewKFoldOptions(opt...)
+	if opts.NRepeats < 1 {
+		err := fmt.Errorf("nrepeats must be at least 1. Got: %v\n", opts.NRepeats)
+		return nil, err
+	}
+
+	kf, err := NewKFold(n, append(opt, WithKFoldShuffle(true))...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RepeatedKFold{
+		kfold:    kf,
+		nrepeats: opts.NRepeats,
+	}, nil
+}
+
+// Split implements Splitter interface. It returns nrepeats * nfolds folds.
+func (kf *RepeatedKFold) Split() []Fold {
+	var splits []Fold
+	for i := 0; i < kf.nrepeats; i++ {
+		splits = append(splits, kf.kfold.Split()...)
+	}
+
+	return splits
+}
+
 func contains(data []int, item int) bool {
 	for _, el := range data {
 		if el == item {
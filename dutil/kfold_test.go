@@ -1,6 +1,8 @@
 package dutil_test
 
 import (
+	"math/rand"
+	"reflect"
 	"testing"
 
 	"github.com/sugarme/gotch/dutil"
@@ -54,3 +56,158 @@ func TestKFold_Split(t *testing.T) {
 		}
 	}
 }
+
+func checkFolds(t *testing.T, n int, splits []dutil.Fold) {
+	t.Helper()
+	for i, f := range splits {
+		if len(f.Train)+len(f.Test) != n {
+			t.Errorf("Fold %v: want %v samples. Got train %v and test %v\n", i, n, len(f.Train), len(f.Test))
+		}
+		test := make(map[int]bool)
+		for _, idx := range f.Test {
+			test[idx] = true
+		}
+		for _, idx := range f.Train {
+			if test[idx] {
+				t.Errorf("Fold %v: sample %v in both train and test sets.\n", i, idx)
+			}
+		}
+	}
+}
+
+func TestStratifiedKFold_Split(t *testing.T) {
+	// 9 samples of class 0 and 3 samples of class 1.
+	labels := []int{0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1}
+	kf, err := dutil.NewStratifiedKFold(labels, dutil.WithNFolds(3), dutil.WithKFoldShuffle(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	splits := kf.Split()
+	if len(splits) != 3 {
+		t.Fatalf("Want 3 folds. Got %v\n", len(splits))
+	}
+	checkFolds(t, len(labels), splits)
+	for i, f := range splits {
+		counts := make([]int, 2)
+		for _, idx := range f.Test {
+			counts[labels[idx]]++
+		}
+		if counts[0] != 3 || counts[1] != 1 {
+			t.Errorf("Fold %v: want class counts [3 1]. Got %v\n", i, counts)
+		}
+	}
+}
+
+func TestGroupKFold_Split(t *testing.T) {
+	groups := []int{1, 1, 1, 2, 2, 3, 3, 3, 3, 4}
+	kf, err := dutil.NewGroupKFold(groups, dutil.WithNFolds(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	splits := kf.Split()
+	checkFolds(t, len(groups), splits)
+	for i, f := range splits {
+		testGroups := make(map[int]bool)
+		for _, idx := range f.Test {
+			testGroups[groups[idx]] = true
+		}
+		for _, idx := range f.Train {
+			if testGroups[groups[idx]] {
+				t.Errorf("Fold %v: group %v in both train and test sets.\n", i, groups[idx])
+			}
+		}
+	}
+
+	_, err = dutil.NewGroupKFold(groups, dutil.WithNFolds(5))
+	if err == nil {
+		t.Errorf("Expected error: more folds than groups.")
+	}
+}
+
+func TestTimeSeriesSplit_Split(t *testing.T) {
+	kf, err := dutil.NewTimeSeriesSplit(6, dutil.WithNFolds(2), dutil.WithKFoldGap(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []dutil.Fold{
+		{Train: []int{0}, Test: []int{2, 3}},
+		{Train: []int{0, 1, 2}, Test: []int{4, 5}},
+	}
+	got := kf.Split()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestRepeatedKFold_Split(t *testing.T) {
+	kf, err := dutil.NewRepeatedKFold(9, dutil.WithNFolds(3), dutil.WithNRepeats(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	splits := kf.Split()
+	if len(splits) != 6 {
+		t.Errorf("Want 6 folds. Got %v\n", len(splits))
+	}
+	checkFolds(t, 9, splits)
+}
+
+func TestTrainTestSplit(t *testing.T) {
+	data := []int{10, 11, 12, 13, 14, 15, 16, 17, 18, 19}
+	ds, err := dutil.NewSliceDataset(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := []int{0, 0, 0, 0, 0, 1, 1, 1, 1, 1}
+	train, test, err := dutil.TrainTestSplit(ds, 0.2, dutil.WithStratify(labels), dutil.WithSplitRand(rand.New(rand.NewSource(42))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if train.Len() != 8 || test.Len() != 2 {
+		t.Fatalf("Want train size 8 and test size 2. Got %v and %v\n", train.Len(), test.Len())
+	}
+	if labels[test.Indices()[0]] == labels[test.Indices()[1]] {
+		t.Errorf("Want one test sample of each class. Got indices %v\n", test.Indices())
+	}
+
+	// Sizes are rounded from total number of samples, not per class.
+	var labels3 []int
+	for c := 0; c < 10; c++ {
+		labels3 = append(labels3, c, c, c)
+	}
+	ds3, err := dutil.NewSliceDataset(make([]int, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	train3, test3, err := dutil.TrainTestSplit(ds3, 0.1, dutil.WithStratify(labels3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if train3.Len() != 27 || test3.Len() != 3 {
+		t.Errorf("Want train size 27 and test size 3. Got %v and %v\n", train3.Len(), test3.Len())
+	}
+
+	item, err := test.Item(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := data[test.Indices()[0]]; item != want {
+		t.Errorf("Want item %v. Got %v\n", want, item)
+	}
+
+	train, val, test, err := dutil.TrainValTestSplit(ds, 0.2, 0.3, dutil.WithSplitShuffle(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]int{{5, 6, 7, 8, 9}, {0, 1}, {2, 3, 4}}
+	got := [][]int{train.Indices(), val.Indices(), test.Indices()}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
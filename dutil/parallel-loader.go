@@ -0,0 +1,430 @@
+package dutil
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// Collate combines samples of a batch into a single value, e.g. a batched
+// tensor.
+type Collate func(items []interface{}) (interface{}, error)
+
+// StackCollate is a Collate function that stacks samples along a new first
+// dimension. Supported sample types:
+//
+//   - *ts.Tensor: samples are stacked to a single tensor.
+//   - int, int64, float32, float64: samples are converted to a 1D tensor.
+//   - []*ts.Tensor: each field is stacked, returns []*ts.Tensor.
+//   - []interface{}: each field is collated recursively, returns []interface{}.
+//     E.g. a sample of (image tensor, int label) returns (batched images, labels).
+func StackCollate(items []interface{}) (interface{}, error) {
+	if len(items) == 0 {
+		err := fmt.Errorf("StackCollate() failed: empty batch")
+		return nil, err
+	}
+
+	switch items[0].(type) {
+	case *ts.Tensor:
+		tensors := make([]*ts.Tensor, len(items))
+		for i, item := range items {
+			x, ok := item.(*ts.Tensor)
+			if !ok {
+				err := fmt.Errorf("StackCollate() failed: expected sample of type *ts.Tensor, got %T", item)
+				return nil, err
+			}
+			tensors[i] = x
+		}
+		return ts.Stack(tensors, 0)
+
+	case int, int64:
+		values := make([]int64, len(items))
+		for i, item := range items {
+			switch v := item.(type) {
+			case int:
+				values[i] = int64(v)
+			case int64:
+				values[i] = v
+			default:
+				err := fmt.Errorf("StackCollate() failed: expected sample of integer type, got %T", item)
+				return nil, err
+			}
+		}
+		return ts.OfSlice(values)
+
+	case float32:
+		values := make([]float32, len(items))
+		for i, item := range items {
+			v, ok := item.(float32)
+			if !ok {
+				err := fmt.Errorf("StackCollate() failed: expected sample of type float32, got %T", item)
+				return nil, err
+			}
+			values[i] = v
+		}
+		return ts.OfSlice(values)
+
+	case float64:
+		values := make([]float64, len(items))
+		for i, item := range items {
+			v, ok := item.(float64)
+			if !ok {
+				err := fmt.Errorf("StackCollate() failed: expected sample of type float64, got %T", item)
+				return nil, err
+			}
+			values[i] = v
+		}
+		return ts.OfSlice(values)
+
+	case []*ts.Tensor:
+		nfields := len(items[0].([]*ts.Tensor))
+		fields := make([][]interface{}, nfields)
+		for _, item := range items {
+			sample, ok := item.([]*ts.Tensor)
+			if !ok || len(sample) != nfields {
+				err := fmt.Errorf("StackCollate() failed: expected sample of type []*ts.Tensor with %d fields, got %T", nfields, item)
+				return nil, err
+			}
+			for j, x := range sample {
+				fields[j] = append(fields[j], x)
+			}
+		}
+		batch := make([]*ts.Tensor, nfields)
+		for j := range fields {
+			x, err := StackCollate(fields[j])
+			if err != nil {
+				return nil, err
+			}
+			batch[j] = x.(*ts.Tensor)
+		}
+		return batch, nil
+
+	case []interface{}:
+		nfields := len(items[0].([]interface{}))
+		fields := make([][]interface{}, nfields)
+		for _, item := range items {
+			sample, ok := item.([]interface{})
+			if !ok || len(sample) != nfields {
+				err := fmt.Errorf("StackCollate() failed: expected sample of type []interface{} with %d fields, got %T", nfields, item)
+				return nil, err
+			}
+			for j, v := range sample {
+				fields[j] = append(fields[j], v)
+			}
+		}
+		batch := make([]interface{}, nfields)
+		for j := range fields {
+			v, err := StackCollate(fields[j])
+			if err != nil {
+				return nil, err
+			}
+			batch[j] = v
+		}
+		return batch, nil
+
+	default:
+		err := fmt.Errorf("StackCollate() failed: unsupported sample type %T", items[0])
+		return nil, err
+	}
+}
+
+// Batch is a batch delivered by ParallelDataLoader.
+type Batch struct {
+	Index   int         // index of batch in the epoch.
+	Indices []int       // indices of samples in dataset.
+	Data    interface{} // collated samples or []interface{} if no Collate function.
+	Err     error
+}
+
+type LoaderOptions struct {
+	NumWorkers int     // number of worker goroutines. Default=runtime.NumCPU()
+	Prefetch   int     // maximum number of batches being loaded or waiting to be consumed. Default=2*NumWorkers
+	Ordered    bool    // whether batches are delivered in sampling order. Default=true
+	Collate    Collate // function to combine samples of a batch. Default=nil (samples are returned as []interface{})
+}
+
+type LoaderOption func(*LoaderOptions)
+
+func NewLoaderOptions(options ...LoaderOption) LoaderOptions {
+	opts := LoaderOptions{
+		NumWorkers: runtime.NumCPU(),
+		Prefetch:   0,
+		Ordered:    true,
+		Collate:    nil,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	if opts.Prefetch <= 0 {
+		opts.Prefetch = 2 * opts.NumWorkers
+	}
+
+	return opts
+}
+
+func WithNumWorkers(n int) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.NumWorkers = n
+	}
+}
+
+func WithPrefetch(n int) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Prefetch = n
+	}
+}
+
+func WithOrdered(ordered bool) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Ordered = ordered
+	}
+}
+
+func WithCollate(collate Collate) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Collate = collate
+	}
+}
+
+// ParallelDataLoader loads batches of samples from a dataset with a pool of
+// worker goroutines so that loading (e.g. image decoding and augmentation)
+// overlaps with training.
+//
+// NOTE. `Dataset.Item()` and the Collate function are called concurrently and
+// must be safe for concurrent use. With ordered delivery and a seeded sampler
+// (see `BatchSampler.SetRand()`), batches are delivered in the same order on
+// every run.
+//
+// Example:
+//
+//	s, _ := dutil.NewBatchSampler(data.Len(), 32, true, true)
+//	dl, _ := dutil.NewParallelDataLoader(data, s, dutil.WithNumWorkers(4), dutil.WithCollate(dutil.StackCollate))
+//	for batch := range dl.Batches(ctx) {
+//		if batch.Err != nil {...}
+//		xs := batch.Data.([]interface{})[0].(*ts.Tensor)
+//		...
+//	}
+type ParallelDataLoader struct {
+	dataset   Dataset
+	sampler   Sampler
+	batchSize int
+	opts      LoaderOptions
+}
+
+// NewParallelDataLoader creates a ParallelDataLoader. If sampler is nil, a
+// default sampler is used as in `NewDataLoader()`.
+func NewParallelDataLoader(data Dataset, s Sampler, opts ...LoaderOption) (*ParallelDataLoader, error) {
+	dkind, err := checkDKind(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if s == nil {
+		switch dkind {
+		case SliceDKind:
+			s = NewSequentialSampler(data.Len())
+		case MapDKind:
+			s, err = NewRandomSampler(data.Len())
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	options := NewLoaderOptions(opts...)
+	if options.NumWorkers < 1 {
+		err := fmt.Errorf("NewParallelDataLoader() failed: number of workers must be greater than 0. Got %v", options.NumWorkers)
+		return nil, err
+	}
+
+	batchSize := s.BatchSize()
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	return &ParallelDataLoader{
+		dataset:   data,
+		sampler:   s,
+		batchSize: batchSize,
+		opts:      options,
+	}, nil
+}
+
+// NumWorkers returns number of worker goroutines.
+func (dl *ParallelDataLoader) NumWorkers() int {
+	return dl.opts.NumWorkers
+}
+
+// Batches starts loading an epoch and returns a channel delivering batches.
+// Samples are drawn from the sampler at each call. The channel is closed when
+// all batches are delivered, after the first batch with an error or when ctx
+// is cancelled.
+//
+// In ordered mode, a batch with an error is delivered after all batches
+// before it. Collated batches which are not delivered (after cancelling or an
+// error) are deleted.
+//
+// NOTE. cancel ctx to stop iterating before the end of epoch, otherwise
+// worker goroutines are blocked.
+func (dl *ParallelDataLoader) Batches(ctx context.Context) <-chan Batch {
+	indices := dl.sampler.Sample()
+	var jobs [][]int
+	for i := 0; i < len(indices); i += dl.batchSize {
+		end := i + dl.batchSize
+		if end > len(indices) {
+			end = len(indices)
+		}
+		jobs = append(jobs, indices[i:end])
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan Batch)
+	jobCh := make(chan Batch)
+	resultCh := make(chan Batch, dl.opts.NumWorkers)
+	// sem limits number of batches in flight.
+	sem := make(chan struct{}, dl.opts.Prefetch)
+
+	// Dispatcher
+	go func() {
+		defer close(jobCh)
+		for i, idxs := range jobs {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobCh <- Batch{Index: i, Indices: idxs}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Workers
+	var wg sync.WaitGroup
+	for w := 0; w < dl.opts.NumWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobCh {
+				job.Data, job.Err = dl.load(job.Indices)
+				select {
+				case resultCh <- job:
+				case <-ctx.Done():
+					dl.drop(job)
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	// Emitter
+	go func() {
+		next := 0
+		pending := make(map[int]Batch)
+		defer close(out)
+		defer func() {
+			// Stop workers and delete undelivered batches.
+			cancel()
+			for _, b := range pending {
+				dl.drop(b)
+			}
+			for b := range resultCh {
+				dl.drop(b)
+			}
+		}()
+
+		send := func(b Batch) bool {
+			if ctx.Err() != nil {
+				dl.drop(b)
+				return false
+			}
+			select {
+			case out <- b:
+				<-sem
+				return true
+			case <-ctx.Done():
+				dl.drop(b)
+				return false
+			}
+		}
+
+		for b := range resultCh {
+			if !dl.opts.Ordered {
+				if !send(b) || b.Err != nil {
+					return
+				}
+				continue
+			}
+
+			pending[b.Index] = b
+			for {
+				pb, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				if !send(pb) || pb.Err != nil {
+					return
+				}
+				next++
+			}
+		}
+	}()
+
+	return out
+}
+
+// drop deletes tensors of a collated batch. Samples of uncollated batches may
+// be owned by the dataset and are kept.
+func (dl *ParallelDataLoader) drop(b Batch) {
+	if dl.opts.Collate == nil || b.Err != nil {
+		return
+	}
+	dropCollated(b.Data)
+}
+
+// dropCollated deletes tensors of a value returned by a Collate function.
+func dropCollated(v interface{}) {
+	switch v := v.(type) {
+	case *ts.Tensor:
+		v.MustDrop()
+	case []*ts.Tensor:
+		for _, x := range v {
+			x.MustDrop()
+		}
+	case []interface{}:
+		for _, x := range v {
+			dropCollated(x)
+		}
+	}
+}
+
+// load loads and collates samples at given indices.
+func (dl *ParallelDataLoader) load(indices []int) (interface{}, error) {
+	items := make([]interface{}, len(indices))
+	for i, idx := range indices {
+		item, err := dl.dataset.Item(idx)
+		if err != nil {
+			err = fmt.Errorf("ParallelDataLoader: loading sample %d failed: %w", idx, err)
+			return nil, err
+		}
+		items[i] = item
+	}
+
+	if dl.opts.Collate == nil {
+		return items, nil
+	}
+
+	return dl.opts.Collate(items)
+}
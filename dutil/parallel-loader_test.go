@@ -0,0 +1,214 @@
+package dutil_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sugarme/gotch/dutil"
+	"github.com/sugarme/gotch/ts"
+)
+
+func newIntDataset(t *testing.T, n int) *dutil.SliceDataset {
+	var data []int
+	for i := 0; i < n; i++ {
+		data = append(data, i)
+	}
+	ds, err := dutil.NewSliceDataset(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+func collectIndices(dl *dutil.ParallelDataLoader) ([][]int, error) {
+	var batches [][]int
+	for b := range dl.Batches(context.Background()) {
+		if b.Err != nil {
+			return nil, b.Err
+		}
+		batches = append(batches, b.Indices)
+	}
+	return batches, nil
+}
+
+func TestParallelDataLoader_Ordered(t *testing.T) {
+	ds := newIntDataset(t, 100)
+	s, err := dutil.NewBatchSampler(100, 8, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetRand(rand.New(rand.NewSource(42)))
+	dl, err := dutil.NewParallelDataLoader(ds, s, dutil.WithNumWorkers(4), dutil.WithPrefetch(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s1, err := dutil.NewBatchSampler(100, 8, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1.SetRand(rand.New(rand.NewSource(42)))
+	want := s1.Sample()
+
+	var got []int
+	i := 0
+	for b := range dl.Batches(context.Background()) {
+		if b.Err != nil {
+			t.Fatal(b.Err)
+		}
+		if b.Index != i {
+			t.Errorf("want batch index %v, got %v", i, b.Index)
+		}
+		for _, item := range b.Data.([]interface{}) {
+			got = append(got, item.(int))
+		}
+		i++
+	}
+	if i != 13 {
+		t.Errorf("want 13 batches, got %v", i)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v\n", want)
+		t.Errorf("got: %v\n", got)
+	}
+}
+
+func TestParallelDataLoader_Unordered(t *testing.T) {
+	ds := newIntDataset(t, 50)
+	s, err := dutil.NewBatchSampler(50, 4, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err := dutil.NewParallelDataLoader(ds, s, dutil.WithNumWorkers(3), dutil.WithOrdered(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batches, err := collectIndices(dl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, b := range batches {
+		got = append(got, b...)
+	}
+	sort.Ints(got)
+	want := s.Sample()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v\n", want)
+		t.Errorf("got: %v\n", got)
+	}
+}
+
+type errDataset struct {
+	*dutil.SliceDataset
+	errIdx int
+}
+
+func (ds *errDataset) Item(idx int) (interface{}, error) {
+	if idx == ds.errIdx {
+		return nil, fmt.Errorf("bad sample")
+	}
+	return ds.SliceDataset.Item(idx)
+}
+
+func TestParallelDataLoader_Error(t *testing.T) {
+	ds := &errDataset{newIntDataset(t, 20), 10}
+	s, err := dutil.NewBatchSampler(20, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err := dutil.NewParallelDataLoader(ds, s, dutil.WithNumWorkers(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Error batch is delivered in order, after batches 0-4.
+	var indices []int
+	var batchErr error
+	for b := range dl.Batches(context.Background()) {
+		if b.Err != nil {
+			batchErr = b.Err
+			if b.Index != 5 {
+				t.Errorf("want error at batch 5, got batch %v", b.Index)
+			}
+			continue
+		}
+		indices = append(indices, b.Index)
+	}
+	if batchErr == nil {
+		t.Errorf("want error, got nil")
+	}
+	if want := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(want, indices) {
+		t.Errorf("want batches %v before error, got %v", want, indices)
+	}
+}
+
+func TestParallelDataLoader_Cancel(t *testing.T) {
+	ds := newIntDataset(t, 100)
+	s, err := dutil.NewBatchSampler(100, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err := dutil.NewParallelDataLoader(ds, s, dutil.WithNumWorkers(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := 0
+	for range dl.Batches(ctx) {
+		n++
+		if n == 5 {
+			cancel()
+		}
+	}
+	if n >= 100 {
+		t.Errorf("want iteration stopped after cancelling, got %v batches", n)
+	}
+}
+
+func TestStackCollate(t *testing.T) {
+	var data []interface{}
+	for i := 0; i < 6; i++ {
+		x := ts.MustOfSlice([]float32{float32(i), float32(i)})
+		data = append(data, []interface{}{x, i})
+	}
+	ds, err := dutil.NewSliceDataset(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := dutil.NewBatchSampler(6, 4, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err := dutil.NewParallelDataLoader(ds, s, dutil.WithNumWorkers(2), dutil.WithCollate(dutil.StackCollate))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var shapes [][]int64
+	var labels []int64
+	for b := range dl.Batches(context.Background()) {
+		if b.Err != nil {
+			t.Fatal(b.Err)
+		}
+		fields := b.Data.([]interface{})
+		shapes = append(shapes, fields[0].(*ts.Tensor).MustSize())
+		labels = append(labels, fields[1].(*ts.Tensor).Int64Values()...)
+	}
+
+	wantShapes := [][]int64{{4, 2}, {2, 2}}
+	if !reflect.DeepEqual(wantShapes, shapes) {
+		t.Errorf("want shapes %v, got %v", wantShapes, shapes)
+	}
+	wantLabels := []int64{0, 1, 2, 3, 4, 5}
+	if !reflect.DeepEqual(wantLabels, labels) {
+		t.Errorf("want labels %v, got %v", wantLabels, labels)
+	}
+}
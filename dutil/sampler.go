@@ -2,8 +2,11 @@ package dutil
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
-	"time"
+	"sort"
+
+	"github.com/sugarme/gotch"
 )
 
 // Sampler represents an interface to draw sample
@@ -49,11 +52,13 @@ type RandomSampler struct {
 	size        int  // size of sampling
 	replacement bool // whether replacement or not
 	batchSize   int  // always = 1
+	rng         *rand.Rand
 }
 
 type RandOptions struct {
 	Size        int
 	Replacement bool
+	Rand        *rand.Rand // random generator. Default=nil means `gotch.DefaultRand()`
 }
 
 type RandOption func(*RandOptions)
@@ -62,6 +67,7 @@ func NewRandOptions(options ...RandOption) RandOptions {
 	opts := RandOptions{
 		Size:        0,
 		Replacement: false,
+		Rand:        nil,
 	}
 
 	for _, o := range options {
@@ -83,11 +89,28 @@ func WithReplacement(replacement bool) RandOption {
 	}
 }
 
+// WithRand sets random generator used for sampling.
+func WithRand(r *rand.Rand) RandOption {
+	return func(o *RandOptions) {
+		o.Rand = r
+	}
+}
+
+// randOrDefault returns r if not nil, otherwise gotch default random generator.
+func randOrDefault(r *rand.Rand) *rand.Rand {
+	if r == nil {
+		return gotch.DefaultRand()
+	}
+
+	return r
+}
+
 // NewRandomSampler creates a new RandomSampler.
 //
 // n : number of samples in dataset
 // size: Optional (default=n). Size of sampling.
 // replacement: Optional (default=false). Whether not repeated or repeated samples.
+// rand: Optional (default=gotch.DefaultRand()). Random generator.
 func NewRandomSampler(n int, opt ...RandOption) (*RandomSampler, error) {
 
 	opts := NewRandOptions(opt...)
@@ -107,12 +130,13 @@ func NewRandomSampler(n int, opt ...RandOption) (*RandomSampler, error) {
 		size:        size,
 		replacement: opts.Replacement,
 		batchSize:   1,
+		rng:         opts.Rand,
 	}, nil
 }
 
 // Sample implements Sampler interface.
 func (s *RandomSampler) Sample() []int {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r := randOrDefault(s.rng)
 	var indices []int
 
 	if !s.replacement {
@@ -168,6 +192,7 @@ type BatchSampler struct {
 	batchSize int
 	shuffle   bool
 	dropLast  bool
+	rng       *rand.Rand
 }
 
 // NewBatchSampler creates a new BatchSampler.
@@ -190,6 +215,12 @@ func NewBatchSampler(n, batchSize int, dropLast bool, shuffleOpt ...bool) (*Batc
 	}, nil
 }
 
+// SetRand sets random generator used for shuffling. Default=gotch.DefaultRand().
+func (s *BatchSampler) SetRand(r *rand.Rand) *BatchSampler {
+	s.rng = r
+	return s
+}
+
 // Sample implements Sampler interface
 func (s *BatchSampler) Sample() []int {
 	var (
@@ -205,8 +236,7 @@ func (s *BatchSampler) Sample() []int {
 		}
 	case true:
 		// random permutation
-		r := rand.New(rand.NewSource(time.Now().UnixNano()))
-		indices = r.Perm(s.n)
+		indices = randOrDefault(s.rng).Perm(s.n)
 	}
 
 	for _, i := range indices {
@@ -227,3 +257,459 @@ func (s *BatchSampler) Sample() []int {
 func (s *BatchSampler) BatchSize() int {
 	return s.batchSize
 }
+
+// WeightedRandomSampler draws samples with given probabilities (weights).
+type WeightedRandomSampler struct {
+	weights     []float64
+	numSamples  int
+	replacement bool
+	batchSize   int // always = 1
+	rng         *rand.Rand
+}
+
+// NewWeightedRandomSampler creates a new WeightedRandomSampler.
+//
+// weights: weight of each sample in dataset. They don't need to sum up to 1.
+// numSamples: number of samples to draw.
+// replacement: whether a sample can be drawn more than once.
+// rand: Optional (default=gotch.DefaultRand()). Random generator set with `WithRand()`.
+func NewWeightedRandomSampler(weights []float64, numSamples int, replacement bool, opt ...RandOption) (*WeightedRandomSampler, error) {
+	opts := NewRandOptions(opt...)
+
+	var (
+		total    float64
+		positive int
+	)
+	for i, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			err := fmt.Errorf("Invalid weight: weights must be non-negative finite values. Got %v at index %v", w, i)
+			return nil, err
+		}
+		if w > 0 {
+			positive++
+		}
+		total += w
+	}
+	if total <= 0 {
+		err := fmt.Errorf("Invalid weights: sum of weights must be greater than 0.")
+		return nil, err
+	}
+	if numSamples < 1 {
+		err := fmt.Errorf("Invalid number of samples: must be greater than 0. Got %v", numSamples)
+		return nil, err
+	}
+	if !replacement && numSamples > positive {
+		err := fmt.Errorf("Number of samples (%v) can not be greater than number of samples with positive weight (%v) without replacement.", numSamples, positive)
+		return nil, err
+	}
+
+	return &WeightedRandomSampler{
+		weights:     weights,
+		numSamples:  numSamples,
+		replacement: replacement,
+		batchSize:   1,
+		rng:         opts.Rand,
+	}, nil
+}
+
+// Sample implements Sampler interface.
+func (s *WeightedRandomSampler) Sample() []int {
+	r := randOrDefault(s.rng)
+	indices := make([]int, s.numSamples)
+
+	if s.replacement {
+		cumsum := make([]float64, len(s.weights))
+		var total float64
+		for i, w := range s.weights {
+			total += w
+			cumsum[i] = total
+		}
+		for i := range indices {
+			idx := sort.SearchFloat64s(cumsum, r.Float64()*total)
+			// Skip zero-weight samples which have same cumulative value.
+			for s.weights[idx] == 0 {
+				idx++
+			}
+			indices[i] = idx
+		}
+		return indices
+	}
+
+	// Weighted sampling without replacement with keys log(u)/w.
+	// Ref. Efraimidis & Spirakis, "Weighted random sampling with a reservoir" (2006)
+	type keyed struct {
+		idx int
+		key float64
+	}
+	var keys []keyed
+	for i, w := range s.weights {
+		if w == 0 {
+			continue
+		}
+		keys = append(keys, keyed{i, math.Log(1-r.Float64()) / w})
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].key > keys[j].key })
+	for i := range indices {
+		indices[i] = keys[i].idx
+	}
+
+	return indices
+}
+
+// BatchSize implements Sampler interface.
+// It's always return 1.
+func (s *WeightedRandomSampler) BatchSize() int {
+	return s.batchSize
+}
+
+// SubsetRandomSampler draws samples randomly from a given subset of indices
+// without replacement.
+type SubsetRandomSampler struct {
+	indices   []int
+	batchSize int // always = 1
+	rng       *rand.Rand
+}
+
+// NewSubsetRandomSampler creates a new SubsetRandomSampler.
+//
+// indices: indices of subset of dataset.
+// rand: Optional (default=gotch.DefaultRand()). Random generator set with `WithRand()`.
+func NewSubsetRandomSampler(indices []int, opt ...RandOption) *SubsetRandomSampler {
+	opts := NewRandOptions(opt...)
+
+	return &SubsetRandomSampler{
+		indices:   indices,
+		batchSize: 1,
+		rng:       opts.Rand,
+	}
+}
+
+// Sample implements Sampler interface.
+func (s *SubsetRandomSampler) Sample() []int {
+	perm := randOrDefault(s.rng).Perm(len(s.indices))
+	indices := make([]int, len(perm))
+	for i, p := range perm {
+		indices[i] = s.indices[p]
+	}
+
+	return indices
+}
+
+// BatchSize implements Sampler interface.
+// It's always return 1.
+func (s *SubsetRandomSampler) BatchSize() int {
+	return s.batchSize
+}
+
+// StratifiedBatchSampler draws batches of samples which keep class proportions
+// of dataset in each batch.
+type StratifiedBatchSampler struct {
+	labels    []int
+	batchSize int
+	shuffle   bool
+	dropLast  bool
+	rng       *rand.Rand
+}
+
+// NewStratifiedBatchSampler creates a new StratifiedBatchSampler.
+//
+// labels: class label of each sample in dataset.
+func NewStratifiedBatchSampler(labels []int, batchSize int, dropLast bool, shuffleOpt ...bool) (*StratifiedBatchSampler, error) {
+	n := len(labels)
+	if batchSize > n || batchSize < 1 {
+		err := fmt.Errorf("Invalid batch size: batch size must be equal or greater than 1 and less or equal to number of samples(%v). Got %v", n, batchSize)
+		return nil, err
+	}
+
+	shuffle := false
+	if len(shuffleOpt) > 0 {
+		shuffle = shuffleOpt[0]
+	}
+
+	return &StratifiedBatchSampler{
+		labels:    labels,
+		batchSize: batchSize,
+		shuffle:   shuffle,
+		dropLast:  dropLast,
+	}, nil
+}
+
+// SetRand sets random generator used for shuffling. Default=gotch.DefaultRand().
+func (s *StratifiedBatchSampler) SetRand(r *rand.Rand) *StratifiedBatchSampler {
+	s.rng = r
+	return s
+}
+
+// Sample implements Sampler interface.
+//
+// Samples of each class are spread evenly over the epoch, i.e. k-th sample of
+// a class with n samples is placed at relative position (k + 0.5)/n, so that
+// every batch has approximately the class proportions of dataset.
+func (s *StratifiedBatchSampler) Sample() []int {
+	classIndices := make(map[int][]int)
+	var classes []int
+	for i, label := range s.labels {
+		if _, ok := classIndices[label]; !ok {
+			classes = append(classes, label)
+		}
+		classIndices[label] = append(classIndices[label], i)
+	}
+	sort.Ints(classes)
+
+	type positioned struct {
+		idx int
+		pos float64
+	}
+	var all []positioned
+	for _, c := range classes {
+		idxs := classIndices[c]
+		if s.shuffle {
+			randOrDefault(s.rng).Shuffle(len(idxs), func(i, j int) { idxs[i], idxs[j] = idxs[j], idxs[i] })
+		}
+		for k, idx := range idxs {
+			all = append(all, positioned{idx, (float64(k) + 0.5) / float64(len(idxs))})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].pos < all[j].pos })
+
+	n := len(all)
+	if s.dropLast {
+		n = (n / s.batchSize) * s.batchSize
+	}
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = all[i].idx
+	}
+
+	return indices
+}
+
+// BatchSize returns batch size.
+func (s *StratifiedBatchSampler) BatchSize() int {
+	return s.batchSize
+}
+
+type DistributedOptions struct {
+	Shuffle   bool
+	Seed      int64
+	DropLast  bool
+	BatchSize int
+}
+
+type DistributedOption func(*DistributedOptions)
+
+func NewDistributedOptions(options ...DistributedOption) DistributedOptions {
+	opts := DistributedOptions{
+		Shuffle:   true,
+		Seed:      0,
+		DropLast:  false,
+		BatchSize: 1,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+func WithDistributedShuffle(shuffle bool) DistributedOption {
+	return func(o *DistributedOptions) {
+		o.Shuffle = shuffle
+	}
+}
+
+// WithDistributedSeed sets seed of shuffling. It must be the same for all processes.
+func WithDistributedSeed(seed int64) DistributedOption {
+	return func(o *DistributedOptions) {
+		o.Seed = seed
+	}
+}
+
+// WithDistributedDropLast sets whether to drop tail samples so that number of
+// samples is divisible by world size. Otherwise, samples are repeated.
+func WithDistributedDropLast(dropLast bool) DistributedOption {
+	return func(o *DistributedOptions) {
+		o.DropLast = dropLast
+	}
+}
+
+func WithDistributedBatchSize(batchSize int) DistributedOption {
+	return func(o *DistributedOptions) {
+		o.BatchSize = batchSize
+	}
+}
+
+// DistributedSampler draws a shard of samples for a process (rank) of a
+// distributed training with worldSize processes. Shards of all processes are
+// disjoint and have the same length.
+//
+// NOTE. call `SetEpoch()` at the beginning of each epoch to reshuffle. All
+// processes must use the same seed.
+type DistributedSampler struct {
+	n         int
+	rank      int
+	worldSize int
+	epoch     int
+	opts      DistributedOptions
+}
+
+// NewDistributedSampler creates a new DistributedSampler.
+//
+// n : number of samples in dataset
+// rank: rank of current process in range [0, worldSize)
+// worldSize: number of processes
+func NewDistributedSampler(n, rank, worldSize int, opt ...DistributedOption) (*DistributedSampler, error) {
+	opts := NewDistributedOptions(opt...)
+
+	if worldSize < 1 {
+		err := fmt.Errorf("Invalid world size: must be greater than 0. Got %v", worldSize)
+		return nil, err
+	}
+	if rank < 0 || rank >= worldSize {
+		err := fmt.Errorf("Invalid rank: must be in range [0, %v). Got %v", worldSize, rank)
+		return nil, err
+	}
+	if opts.BatchSize < 1 {
+		err := fmt.Errorf("Invalid batch size: must be greater than 0. Got %v", opts.BatchSize)
+		return nil, err
+	}
+	if opts.DropLast && n < worldSize {
+		err := fmt.Errorf("Number of samples (%v) can not be less than world size (%v) when dropping last samples.", n, worldSize)
+		return nil, err
+	}
+
+	return &DistributedSampler{
+		n:         n,
+		rank:      rank,
+		worldSize: worldSize,
+		opts:      opts,
+	}, nil
+}
+
+// SetEpoch sets epoch which is combined with seed to shuffle samples.
+func (s *DistributedSampler) SetEpoch(epoch int) {
+	s.epoch = epoch
+}
+
+// NumSamples returns number of samples of each process.
+func (s *DistributedSampler) NumSamples() int {
+	if s.opts.DropLast {
+		return s.n / s.worldSize
+	}
+	return (s.n + s.worldSize - 1) / s.worldSize
+}
+
+// Sample implements Sampler interface.
+func (s *DistributedSampler) Sample() []int {
+	var indices []int
+	if s.opts.Shuffle {
+		r := rand.New(rand.NewSource(s.opts.Seed + int64(s.epoch)))
+		indices = r.Perm(s.n)
+	} else {
+		indices = intRange(s.n)
+	}
+
+	total := s.NumSamples() * s.worldSize
+	if total <= len(indices) {
+		indices = indices[:total]
+	} else {
+		// Repeat samples to make it evenly divisible.
+		for len(indices) < total {
+			pad := total - len(indices)
+			if pad > s.n {
+				pad = s.n
+			}
+			indices = append(indices, indices[:pad]...)
+		}
+	}
+
+	shard := make([]int, 0, s.NumSamples())
+	for i := s.rank; i < total; i += s.worldSize {
+		shard = append(shard, indices[i])
+	}
+
+	return shard
+}
+
+// BatchSize implements Sampler interface.
+func (s *DistributedSampler) BatchSize() int {
+	return s.opts.BatchSize
+}
+
+// BucketBatchSampler draws batches of samples of similar lengths, e.g.
+// variable-length sequences, to minimize padding.
+type BucketBatchSampler struct {
+	lengths   []int
+	batchSize int
+	shuffle   bool
+	dropLast  bool
+	rng       *rand.Rand
+}
+
+// NewBucketBatchSampler creates a new BucketBatchSampler.
+//
+// lengths: length of each sample in dataset.
+// shuffleOpt: Optional (default=false). Whether to shuffle samples of same
+// length and the order of batches.
+func NewBucketBatchSampler(lengths []int, batchSize int, dropLast bool, shuffleOpt ...bool) (*BucketBatchSampler, error) {
+	n := len(lengths)
+	if batchSize > n || batchSize < 1 {
+		err := fmt.Errorf("Invalid batch size: batch size must be equal or greater than 1 and less or equal to number of samples(%v). Got %v", n, batchSize)
+		return nil, err
+	}
+
+	shuffle := false
+	if len(shuffleOpt) > 0 {
+		shuffle = shuffleOpt[0]
+	}
+
+	return &BucketBatchSampler{
+		lengths:   lengths,
+		batchSize: batchSize,
+		shuffle:   shuffle,
+		dropLast:  dropLast,
+	}, nil
+}
+
+// SetRand sets random generator used for shuffling. Default=gotch.DefaultRand().
+func (s *BucketBatchSampler) SetRand(r *rand.Rand) *BucketBatchSampler {
+	s.rng = r
+	return s
+}
+
+// Sample implements Sampler interface.
+//
+// NOTE. the incomplete batch, if not dropped, is always the last one.
+func (s *BucketBatchSampler) Sample() []int {
+	var indices []int
+	if s.shuffle {
+		indices = randOrDefault(s.rng).Perm(len(s.lengths))
+	} else {
+		indices = intRange(len(s.lengths))
+	}
+	sort.SliceStable(indices, func(i, j int) bool { return s.lengths[indices[i]] < s.lengths[indices[j]] })
+
+	var batches [][]int
+	for i := 0; i+s.batchSize <= len(indices); i += s.batchSize {
+		batches = append(batches, indices[i:i+s.batchSize])
+	}
+	if s.shuffle {
+		randOrDefault(s.rng).Shuffle(len(batches), func(i, j int) { batches[i], batches[j] = batches[j], batches[i] })
+	}
+
+	var samples []int
+	for _, batch := range batches {
+		samples = append(samples, batch...)
+	}
+	if rest := len(indices) % s.batchSize; !s.dropLast && rest > 0 {
+		samples = append(samples, indices[len(indices)-rest:]...)
+	}
+
+	return samples
+}
+
+// BatchSize returns batch size.
+func (s *BucketBatchSampler) BatchSize() int {
+	return s.batchSize
+}
@@ -2,9 +2,12 @@ package dutil_test
 
 import (
 	// "fmt"
+	"math/rand"
 	"reflect"
+	"sort"
 	"testing"
 
+	"github.com/sugarme/gotch"
 	"github.com/sugarme/gotch/dutil"
 )
 
@@ -142,3 +145,199 @@ func seq(n int) []int {
 	}
 	return s
 }
+
+func TestSampler_Seed(t *testing.T) {
+	sample := func(seed int64) ([]int, []int, []dutil.Fold) {
+		rs, err := dutil.NewRandomSampler(10, dutil.WithRand(rand.New(rand.NewSource(seed))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, err := dutil.NewBatchSampler(10, 3, false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs.SetRand(rand.New(rand.NewSource(seed)))
+		kf, err := dutil.NewKFold(10, dutil.WithNFolds(2), dutil.WithKFoldShuffle(true), dutil.WithKFoldRand(rand.New(rand.NewSource(seed))))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return rs.Sample(), bs.Sample(), kf.Split()
+	}
+
+	rs1, bs1, folds1 := sample(42)
+	rs2, bs2, folds2 := sample(42)
+	if !reflect.DeepEqual(rs1, rs2) || !reflect.DeepEqual(bs1, bs2) || !reflect.DeepEqual(folds1, folds2) {
+		t.Errorf("Want same samples with same seed")
+	}
+
+	// Default random generator seeded by gotch.ManualSeed
+	defaultSample := func() []int {
+		bs, err := dutil.NewBatchSampler(10, 3, false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bs.Sample()
+	}
+	gotch.ManualSeed(123)
+	want := defaultSample()
+	gotch.ManualSeed(123)
+	got := defaultSample()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %+v\n", want)
+		t.Errorf("Got: %+v\n", got)
+	}
+}
+
+func TestWeightedRandomSampler(t *testing.T) {
+	weights := []float64{0, 1, 0, 3}
+	r := rand.New(rand.NewSource(42))
+
+	s, err := dutil.NewWeightedRandomSampler(weights, 4000, true, dutil.WithRand(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make([]int, len(weights))
+	for _, idx := range s.Sample() {
+		counts[idx]++
+	}
+	if counts[0] != 0 || counts[2] != 0 {
+		t.Errorf("Unexpected samples with zero weight. Got counts: %v\n", counts)
+	}
+	if ratio := float64(counts[3]) / float64(counts[1]); ratio < 2.5 || ratio > 3.5 {
+		t.Errorf("Want sample ratio about 3. Got %v (counts: %v)\n", ratio, counts)
+	}
+
+	// Without replacement
+	s1, err := dutil.NewWeightedRandomSampler(weights, 2, false, dutil.WithRand(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	indices := s1.Sample()
+	if isDup(indices) || len(indices) != 2 {
+		t.Errorf("Want 2 distinct samples. Got: %v\n", indices)
+	}
+
+	_, err = dutil.NewWeightedRandomSampler(weights, 3, false)
+	if err == nil {
+		t.Errorf("Expected error: more samples than positive weights without replacement.")
+	}
+}
+
+func TestSubsetRandomSampler(t *testing.T) {
+	subset := []int{3, 5, 7, 9}
+	s := dutil.NewSubsetRandomSampler(subset, dutil.WithRand(rand.New(rand.NewSource(42))))
+
+	got := s.Sample()
+	sort.Ints(got)
+	if !reflect.DeepEqual(subset, got) {
+		t.Errorf("Want: %v\n", subset)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+func TestStratifiedBatchSampler(t *testing.T) {
+	// 12 samples of class 0 and 4 samples of class 1.
+	var labels []int
+	for i := 0; i < 16; i++ {
+		if i%4 == 3 {
+			labels = append(labels, 1)
+		} else {
+			labels = append(labels, 0)
+		}
+	}
+
+	s, err := dutil.NewStratifiedBatchSampler(labels, 4, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetRand(rand.New(rand.NewSource(42)))
+
+	indices := s.Sample()
+	if len(indices) != 16 || isDup(indices) {
+		t.Fatalf("Want permutation of 16 samples. Got: %v\n", indices)
+	}
+	for b := 0; b < 4; b++ {
+		n := 0
+		for _, idx := range indices[b*4 : (b+1)*4] {
+			n += labels[idx]
+		}
+		if n != 1 {
+			t.Errorf("Want 1 sample of class 1 in batch %v. Got %v\n", b, n)
+		}
+	}
+}
+
+func TestDistributedSampler(t *testing.T) {
+	n, worldSize := 10, 3
+	var all []int
+	for rank := 0; rank < worldSize; rank++ {
+		s, err := dutil.NewDistributedSampler(n, rank, worldSize, dutil.WithDistributedSeed(42))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetEpoch(1)
+		shard := s.Sample()
+		if len(shard) != 4 {
+			t.Errorf("Want shard length 4. Got %v\n", len(shard))
+		}
+		all = append(all, shard...)
+	}
+
+	// 10 samples padded to 12 with 2 repeated samples.
+	seen := make(map[int]bool)
+	for _, idx := range all {
+		seen[idx] = true
+	}
+	if len(seen) != n {
+		t.Errorf("Want all %v samples drawn. Got: %v\n", n, all)
+	}
+
+	// Reshuffle at each epoch.
+	s, err := dutil.NewDistributedSampler(n, 0, worldSize, dutil.WithDistributedSeed(42), dutil.WithDistributedDropLast(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetEpoch(0)
+	epoch0 := s.Sample()
+	s.SetEpoch(1)
+	epoch1 := s.Sample()
+	if len(epoch0) != 3 || reflect.DeepEqual(epoch0, epoch1) {
+		t.Errorf("Want 3 samples reshuffled at each epoch. Got %v and %v\n", epoch0, epoch1)
+	}
+}
+
+func TestBucketBatchSampler(t *testing.T) {
+	lengths := []int{5, 1, 9, 2, 8, 6, 3}
+	s, err := dutil.NewBucketBatchSampler(lengths, 2, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetRand(rand.New(rand.NewSource(42)))
+
+	indices := s.Sample()
+	if len(indices) != 7 || isDup(indices) {
+		t.Fatalf("Want permutation of 7 samples. Got: %v\n", indices)
+	}
+
+	// Each full batch holds neighbours in sorted lengths [1 2] [3 5] [6 8] then [9].
+	var got [][]int
+	for i := 0; i < len(indices); i += 2 {
+		end := i + 2
+		if end > len(indices) {
+			end = len(indices)
+		}
+		var batch []int
+		for _, idx := range indices[i:end] {
+			batch = append(batch, lengths[idx])
+		}
+		sort.Ints(batch)
+		got = append(got, batch)
+	}
+	sort.Slice(got[:3], func(i, j int) bool { return got[i][0] < got[j][0] })
+	want := [][]int{{1, 2}, {3, 5}, {6, 8}, {9}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
@@ -0,0 +1,216 @@
+package dutil
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+type SplitOptions struct {
+	Shuffle  bool       // whether shuffling before splitting. Default=true
+	Rand     *rand.Rand // random generator for shuffling. Default=nil means `gotch.DefaultRand()`
+	Stratify []int      // Optional. Class labels to preserve class proportions in each set.
+}
+
+type SplitOption func(*SplitOptions)
+
+func NewSplitOptions(options ...SplitOption) SplitOptions {
+	opts := SplitOptions{
+		Shuffle:  true,
+		Rand:     nil,
+		Stratify: nil,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+func WithSplitShuffle(shuffle bool) SplitOption {
+	return func(o *SplitOptions) {
+		o.Shuffle = shuffle
+	}
+}
+
+func WithSplitRand(r *rand.Rand) SplitOption {
+	return func(o *SplitOptions) {
+		o.Rand = r
+	}
+}
+
+// WithStratify sets class labels of samples so that each set has the same
+// class proportions. See `TensorLabels()` for labels in a tensor.
+func WithStratify(labels []int) SplitOption {
+	return func(o *SplitOptions) {
+		o.Stratify = labels
+	}
+}
+
+// TrainTestSplit splits a dataset into train and test sets which are views of
+// the dataset.
+//
+// testSize: fraction of samples in test set, in range (0, 1). Test set has
+// round(testSize * n) samples, also with stratification.
+func TrainTestSplit(ds Dataset, testSize float64, opt ...SplitOption) (train, test *SubsetDataset, err error) {
+	subsets, err := splitDataset(ds, []float64{testSize}, opt...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return subsets[0], subsets[1], nil
+}
+
+// TrainValTestSplit splits a dataset into train, validation and test sets which
+// are views of the dataset.
+//
+// valSize, testSize: fractions of samples in validation and test sets.
+func TrainValTestSplit(ds Dataset, valSize, testSize float64, opt ...SplitOption) (train, val, test *SubsetDataset, err error) {
+	subsets, err := splitDataset(ds, []float64{valSize, testSize}, opt...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return subsets[0], subsets[1], subsets[2], nil
+}
+
+// splitDataset splits dataset into a train set and sets of given fractions.
+func splitDataset(ds Dataset, fractions []float64, opt ...SplitOption) ([]*SubsetDataset, error) {
+	opts := NewSplitOptions(opt...)
+	n := ds.Len()
+
+	var total float64
+	for _, f := range fractions {
+		if f <= 0 || f >= 1 {
+			err := fmt.Errorf("Invalid split size: must be in range (0, 1). Got %v", f)
+			return nil, err
+		}
+		total += f
+	}
+	if total >= 1 {
+		err := fmt.Errorf("Invalid split sizes %v: sum must be less than 1.", fractions)
+		return nil, err
+	}
+	if opts.Stratify != nil && len(opts.Stratify) != n {
+		err := fmt.Errorf("Invalid stratify labels: expected %v labels. Got %v", n, len(opts.Stratify))
+		return nil, err
+	}
+
+	// Group samples by class. Without stratification, all samples are in one group.
+	var groups [][]int
+	if opts.Stratify != nil {
+		classIndices := make(map[int][]int)
+		var classes []int
+		for i, label := range opts.Stratify {
+			if _, ok := classIndices[label]; !ok {
+				classes = append(classes, label)
+			}
+			classIndices[label] = append(classIndices[label], i)
+		}
+		sort.Ints(classes)
+		for _, c := range classes {
+			groups = append(groups, classIndices[c])
+		}
+	} else {
+		groups = [][]int{intRange(n)}
+	}
+
+	sizes := groupSizes(groups, fractions, n)
+	sets := make([][]int, len(fractions)+1)
+	for g, idxs := range groups {
+		if opts.Shuffle {
+			randOrDefault(opts.Rand).Shuffle(len(idxs), func(i, j int) { idxs[i], idxs[j] = idxs[j], idxs[i] })
+		}
+		start := 0
+		for i, size := range sizes[g] {
+			sets[i+1] = append(sets[i+1], idxs[start:start+size]...)
+			start += size
+		}
+		sets[0] = append(sets[0], idxs[start:]...)
+	}
+
+	for i, set := range sets {
+		if len(set) == 0 {
+			err := fmt.Errorf("Invalid split sizes %v: set %v is empty for %v samples.", fractions, i, n)
+			return nil, err
+		}
+	}
+
+	var subsets []*SubsetDataset
+	for _, set := range sets {
+		sort.Ints(set)
+		subset, err := NewSubsetDataset(ds, set)
+		if err != nil {
+			return nil, err
+		}
+		subsets = append(subsets, subset)
+	}
+
+	return subsets, nil
+}
+
+// groupSizes returns number of samples of each group in each set of given
+// fractions. Size of a set is rounded from total number of samples n then
+// shared among groups by largest remainder, so that rounding errors of groups
+// do not add up.
+func groupSizes(groups [][]int, fractions []float64, n int) [][]int {
+	sizes := make([][]int, len(groups))
+	used := make([]int, len(groups))
+	for g := range groups {
+		sizes[g] = make([]int, len(fractions))
+	}
+
+	for i, f := range fractions {
+		remaining := int(math.Round(f * float64(n)))
+		remainders := make([]float64, len(groups))
+		for g, idxs := range groups {
+			quota := f * float64(len(idxs))
+			// NOTE. tolerance avoids rounding down float errors, e.g. 0.7 * 10 = 6.999999999999999
+			size := int(math.Floor(quota + 1e-9))
+			if size > len(idxs)-used[g] {
+				size = len(idxs) - used[g]
+			}
+			sizes[g][i] = size
+			used[g] += size
+			remaining -= size
+			remainders[g] = quota - float64(size)
+		}
+
+		order := intRange(len(groups))
+		sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+		for _, g := range order {
+			if remaining <= 0 {
+				break
+			}
+			if used[g] < len(groups[g]) {
+				sizes[g][i]++
+				used[g]++
+				remaining--
+			}
+		}
+	}
+
+	return sizes
+}
+
+// TensorLabels returns class labels from a 1D integer tensor, e.g. to be used
+// in `NewStratifiedKFold()` or `WithStratify()`.
+func TensorLabels(x *ts.Tensor) ([]int, error) {
+	dims := x.MustSize()
+	if len(dims) != 1 {
+		err := fmt.Errorf("TensorLabels() failed: expected 1D tensor. Got shape %v", dims)
+		return nil, err
+	}
+
+	values := x.Int64Values()
+	labels := make([]int, len(values))
+	for i, v := range values {
+		labels[i] = int(v)
+	}
+
+	return labels, nil
+}
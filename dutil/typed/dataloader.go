@@ -0,0 +1,249 @@
+package typed
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch/dutil"
+	"github.com/sugarme/gotch/ts"
+)
+
+// TensorSample is implemented by samples made of several tensors, e.g. an
+// (image, label) pair, so that they can be stacked into batched tensors.
+type TensorSample interface {
+	Tensors() []*ts.Tensor
+}
+
+// Batch is a batch of samples of type T.
+type Batch[T any] struct {
+	Indices []int // indices of samples in dataset.
+	Items   []T   // samples. It is nil if sample tensors are freed after stacking (see `WithFreeSamples()`).
+
+	// Tensors are samples stacked along a new first dimension, one tensor per
+	// sample field. It is nil if T is not *ts.Tensor, []*ts.Tensor or TensorSample,
+	// or if stacking is disabled.
+	Tensors []*ts.Tensor
+}
+
+// Drop frees stacked tensors of batch.
+func (b *Batch[T]) Drop() {
+	for _, x := range b.Tensors {
+		x.MustDrop()
+	}
+	b.Tensors = nil
+}
+
+type LoaderOptions struct {
+	Stack       bool // whether to stack tensor samples into `Batch.Tensors`. Default=true
+	FreeSamples bool // whether to free sample tensors after stacking. Default=false
+}
+
+type LoaderOption func(*LoaderOptions)
+
+func NewLoaderOptions(options ...LoaderOption) LoaderOptions {
+	opts := LoaderOptions{
+		Stack:       true,
+		FreeSamples: false,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+func WithStack(stack bool) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Stack = stack
+	}
+}
+
+// WithFreeSamples sets whether sample tensors are freed after being stacked.
+// `Batch.Items` is then set to nil as its tensors are no longer valid.
+// Samples already loaded are also freed if a batch fails to load or stack.
+//
+// NOTE. only set it if `Dataset.Item()` creates new tensors at each call. Tensors
+// owned by the dataset (e.g. a SliceDataset of tensors) must not be freed.
+func WithFreeSamples(free bool) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.FreeSamples = free
+	}
+}
+
+// DataLoader combines a dataset and a sampler and provides
+// an iterable over batches of the given dataset.
+type DataLoader[T any] struct {
+	dataset   Dataset[T]
+	indexes   []int // order of samples in dataset for interation.
+	batchSize int
+	currIdx   int
+	sampler   dutil.Sampler
+	opts      LoaderOptions
+}
+
+// NewDataLoader creates a DataLoader. If sampler is nil, samples are drawn
+// sequentially one by one.
+func NewDataLoader[T any](data Dataset[T], s dutil.Sampler, opts ...LoaderOption) (*DataLoader[T], error) {
+	if data.Len() == 0 {
+		err := fmt.Errorf("NewDataLoader() failed: empty dataset")
+		return nil, err
+	}
+
+	if s == nil {
+		s = dutil.NewSequentialSampler(data.Len())
+	}
+
+	batchSize := s.BatchSize()
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	return &DataLoader[T]{
+		dataset:   data,
+		indexes:   s.Sample(),
+		batchSize: batchSize,
+		currIdx:   0,
+		sampler:   s,
+		opts:      NewLoaderOptions(opts...),
+	}, nil
+}
+
+// Next acts as iterator to return next batch from dataset.
+func (dl *DataLoader[T]) Next() (*Batch[T], error) {
+	if !dl.HasNext() {
+		err := fmt.Errorf("Next Error: no more item to iterate.\n")
+		return nil, err
+	}
+
+	// NOTE. length of indexes can be shorter than dataset length
+	nextIndex := dl.currIdx + dl.batchSize
+	if nextIndex >= len(dl.indexes) {
+		nextIndex = len(dl.indexes)
+	}
+
+	batch := &Batch[T]{
+		Indices: append([]int(nil), dl.indexes[dl.currIdx:nextIndex]...),
+		Items:   make([]T, 0, nextIndex-dl.currIdx),
+	}
+	for _, idx := range batch.Indices {
+		item, err := dl.dataset.Item(idx)
+		if err != nil {
+			dl.freeItems(batch.Items)
+			return nil, err
+		}
+		batch.Items = append(batch.Items, item)
+	}
+	dl.currIdx = nextIndex
+
+	if !dl.opts.Stack {
+		return batch, nil
+	}
+
+	tensors, ok, err := Stack(batch.Items)
+	if err != nil {
+		dl.freeItems(batch.Items)
+		return nil, err
+	}
+	if ok {
+		batch.Tensors = tensors
+		if dl.opts.FreeSamples {
+			dl.freeItems(batch.Items)
+			batch.Items = nil
+		}
+	}
+
+	return batch, nil
+}
+
+// freeItems frees sample tensors of items if `FreeSamples` is set, i.e. after
+// stacking or when a batch fails to load.
+func (dl *DataLoader[T]) freeItems(items []T) {
+	if !dl.opts.FreeSamples {
+		return
+	}
+
+	for _, item := range items {
+		xs, _ := sampleTensors(item)
+		for _, x := range xs {
+			x.MustDrop()
+		}
+	}
+}
+
+// HasNext returns whether there is a next item in the iteration.
+func (dl *DataLoader[T]) HasNext() bool {
+	return dl.currIdx < len(dl.indexes)
+}
+
+// Reset reset index to start position.
+func (dl *DataLoader[T]) Reset(shuffleOpt ...bool) {
+	shuffle := false
+	if len(shuffleOpt) > 0 {
+		shuffle = shuffleOpt[0]
+	}
+	if shuffle {
+		dl.indexes = dl.sampler.Sample()
+	}
+	dl.currIdx = 0
+}
+
+// Len returns number of samples to be iterated.
+func (dl *DataLoader[T]) Len() int {
+	return len(dl.indexes)
+}
+
+// sampleTensors returns tensors of a sample and whether sample is tensor-valued.
+func sampleTensors(item any) ([]*ts.Tensor, bool) {
+	switch v := item.(type) {
+	case *ts.Tensor:
+		return []*ts.Tensor{v}, true
+	case []*ts.Tensor:
+		return v, true
+	case TensorSample:
+		return v.Tensors(), true
+	default:
+		return nil, false
+	}
+}
+
+// Stack stacks tensor-valued samples along a new first dimension, one batched
+// tensor per sample field. It returns false if T is not *ts.Tensor, []*ts.Tensor
+// or TensorSample. Sample tensors are not freed.
+func Stack[T any](items []T) ([]*ts.Tensor, bool, error) {
+	if len(items) == 0 {
+		return nil, false, nil
+	}
+
+	first, ok := sampleTensors(items[0])
+	if !ok {
+		return nil, false, nil
+	}
+
+	nfields := len(first)
+	fields := make([][]*ts.Tensor, nfields)
+	for i, item := range items {
+		xs, _ := sampleTensors(item)
+		if len(xs) != nfields {
+			err := fmt.Errorf("Stack() failed: sample %d has %d tensors, expected %d", i, len(xs), nfields)
+			return nil, true, err
+		}
+		for j, x := range xs {
+			fields[j] = append(fields[j], x)
+		}
+	}
+
+	batched := make([]*ts.Tensor, nfields)
+	for j, xs := range fields {
+		x, err := ts.Stack(xs, 0)
+		if err != nil {
+			for _, b := range batched[:j] {
+				b.MustDrop()
+			}
+			err = fmt.Errorf("Stack() failed: field %d: %w", j, err)
+			return nil, true, err
+		}
+		batched[j] = x
+	}
+
+	return batched, true, nil
+}
@@ -0,0 +1,96 @@
+package typed_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/dutil"
+	"github.com/sugarme/gotch/dutil/typed"
+	"github.com/sugarme/gotch/ts"
+)
+
+func TestDataLoader_Next(t *testing.T) {
+	ds := typed.NewSliceDataset([]int{100, 1, 2, 3, 4})
+	s, err := dutil.NewBatchSampler(ds.Len(), 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err := typed.NewDataLoader[int](ds, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]int
+	for dl.HasNext() {
+		batch, err := dl.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if batch.Tensors != nil {
+			t.Errorf("Want no stacked tensors for int samples")
+		}
+		got = append(got, batch.Items)
+	}
+
+	want := [][]int{{100, 1}, {2, 3}, {4}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+}
+
+type imageSample struct {
+	image *ts.Tensor
+	label *ts.Tensor
+}
+
+func (s imageSample) Tensors() []*ts.Tensor {
+	return []*ts.Tensor{s.image, s.label}
+}
+
+// imageDataset creates new tensors at each call of Item().
+type imageDataset struct{ n int }
+
+func (ds *imageDataset) Item(idx int) (imageSample, error) {
+	return imageSample{
+		image: ts.MustOnes([]int64{3, 4, 4}, gotch.Float, gotch.CPU),
+		label: ts.MustOfSlice([]int64{int64(idx)}).MustSqueeze(true),
+	}, nil
+}
+
+func (ds *imageDataset) Len() int { return ds.n }
+
+func TestDataLoader_Stack(t *testing.T) {
+	ds := &imageDataset{n: 5}
+	s, err := dutil.NewBatchSampler(ds.Len(), 4, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl, err := typed.NewDataLoader[imageSample](ds, s, typed.WithFreeSamples(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch, err := dl.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer batch.Drop()
+
+	if len(batch.Tensors) != 2 {
+		t.Fatalf("Want 2 stacked tensors, got %v", len(batch.Tensors))
+	}
+	if got := batch.Tensors[0].MustSize(); !reflect.DeepEqual(got, []int64{4, 3, 4, 4}) {
+		t.Errorf("Want image batch shape [4 3 4 4], got %v", got)
+	}
+	if got := batch.Tensors[1].Int64Values(); !reflect.DeepEqual(got, []int64{0, 1, 2, 3}) {
+		t.Errorf("Want labels [0 1 2 3], got %v", got)
+	}
+	if batch.Items != nil {
+		t.Errorf("Want no items of freed samples, got %v", len(batch.Items))
+	}
+	if dl.HasNext() {
+		t.Errorf("Want last incomplete batch dropped")
+	}
+}
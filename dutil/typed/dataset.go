@@ -0,0 +1,90 @@
+// Package typed provides statically typed equivalents of `dutil` Dataset and
+// DataLoader using Go generics.
+//
+// Migration from `dutil` is mechanical: `dutil.NewSliceDataset(data)` becomes
+// `typed.NewSliceDataset(data)`, `dutil.NewDataLoader(ds, s)` becomes
+// `typed.NewDataLoader(ds, s)`, and `Next()` returns a `*Batch[T]` instead of
+// an `interface{}`. Samplers of `dutil` are used as is.
+package typed
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Dataset represents a set of samples of type T and how to access a sample by
+// its index.
+type Dataset[T any] interface {
+	Item(idx int) (T, error)
+	Len() int
+}
+
+// SliceDataset is a slice of samples.
+type SliceDataset[T any] struct {
+	data []T
+}
+
+// NewSliceDataset creates a new SliceDataset.
+func NewSliceDataset[T any](data []T) *SliceDataset[T] {
+	return &SliceDataset[T]{
+		data: data,
+	}
+}
+
+// Item implements Dataset interface to get a sample by its index.
+func (ds *SliceDataset[T]) Item(idx int) (T, error) {
+	if idx < 0 || idx >= len(ds.data) {
+		var zero T
+		err := fmt.Errorf("Idx is out of range.")
+		return zero, err
+	}
+
+	return ds.data[idx], nil
+}
+
+// Len implements Dataset interface.
+func (ds *SliceDataset[T]) Len() int {
+	return len(ds.data)
+}
+
+// MapDataset holds samples in a map with string type keys.
+// Dataset sorted by keys at the time of creating.
+type MapDataset[T any] struct {
+	data map[string]T
+	keys []string // keys to access elements in map
+}
+
+// NewMapDataset creates a new MapDataset.
+func NewMapDataset[T any](data map[string]T) *MapDataset[T] {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return &MapDataset[T]{
+		data: data,
+		keys: keys,
+	}
+}
+
+// Item implements Dataset interface.
+func (ds *MapDataset[T]) Item(idx int) (T, error) {
+	if idx < 0 || idx >= len(ds.keys) {
+		var zero T
+		err := fmt.Errorf("idx is out of range.")
+		return zero, err
+	}
+
+	return ds.data[ds.keys[idx]], nil
+}
+
+// Key returns key of sample at given index.
+func (ds *MapDataset[T]) Key(idx int) string {
+	return ds.keys[idx]
+}
+
+// Len implements Dataset interface.
+func (ds *MapDataset[T]) Len() int {
+	return len(ds.keys)
+}
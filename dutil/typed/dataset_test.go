@@ -0,0 +1,50 @@
+package typed_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch/dutil/typed"
+)
+
+func TestSliceDataset_Item(t *testing.T) {
+	ds := typed.NewSliceDataset([]string{"a", "b", "c"})
+
+	if got := ds.Len(); got != 3 {
+		t.Errorf("Want data length: 3, got %v", got)
+	}
+
+	got, err := ds.Item(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "b" {
+		t.Errorf("Want: b, got: %v", got)
+	}
+
+	if _, err := ds.Item(3); err == nil {
+		t.Errorf("Expected index out of range error.")
+	}
+}
+
+func TestMapDataset_Item(t *testing.T) {
+	ds := typed.NewMapDataset(map[string]int{"c": 3, "a": 1, "b": 2})
+
+	var got []int
+	for i := 0; i < ds.Len(); i++ {
+		item, err := ds.Item(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, item)
+	}
+
+	want := []int{1, 2, 3}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Want: %v\n", want)
+		t.Errorf("Got: %v\n", got)
+	}
+	if key := ds.Key(2); key != "c" {
+		t.Errorf("Want key: c, got: %v", key)
+	}
+}
@@ -0,0 +1,11 @@
+package gotch
+
+import "time"
+
+// SetDownloadRetryWait sets wait before first download retry and returns a
+// function restoring it.
+func SetDownloadRetryWait(wait time.Duration) (restore func()) {
+	old := downloadRetryWait
+	downloadRetryWait = wait
+	return func() { downloadRetryWait = old }
+}
@@ -1,14 +1,21 @@
 package gotch
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"net"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 // This file provides functions to work with local dataset cache, ...
@@ -113,7 +120,40 @@ var ModelUrls map[string]string = map[string]string{
 // 1. Resolves input string to a  fullpath cached filename candidate.
 // 2. Check it at `CachedDir`, if exists, then return the candidate. If not
 // 3. Retrieves and Caches data to `CachedDir` and returns path to cached data
+//
+// Downloaded files are verified against the SHA-256 prefix in filename if
+// any, e.g. "resnet18-f37072fd.pth". See `CachedPathWithChecksum()`.
 func CachedPath(filenameOrUrl string, folderOpt ...string) (resolvedPath string, err error) {
+	return CachedPathWithChecksum(filenameOrUrl, "", folderOpt...)
+}
+
+// NoChecksum is a checksum value which skips verification, including of hash
+// prefix in filename.
+const NoChecksum = "-"
+
+// torchvisionHost is host of torchvision pretrained weights.
+const torchvisionHost = "download.pytorch.org"
+
+// CachedPathWithChecksum is as `CachedPath()` but verifies retrieved data
+// against a checksum, i.e. SHA-256 hex digest or its prefix. If checksum is
+// empty and URL is in `ModelUrls` or at torchvision host, the hash prefix in
+// filename (torchvision style "name-<hash>.ext") is used if any. Checksum
+// `NoChecksum` skips verification. Already cached files are verified too.
+//
+// Downloads are written to a "<cached file>.part" file which is renamed to
+// the cached file after verification, resumed with HTTP range requests and
+// retried on transient errors. If `ModelMirror` (environment variable
+// "GOTCH_MODEL_MIRROR") is set, files are downloaded from the mirror base URL
+// instead. If `Offline` (environment variable "GOTCH_OFFLINE") is true,
+// nothing is downloaded. A "<cached file>.lock" file lock prevents concurrent
+// processes retrieving the same file.
+func CachedPathWithChecksum(filenameOrUrl, checksum string, folderOpt ...string) (resolvedPath string, err error) {
+	return CachedPathWithOffline(filenameOrUrl, checksum, Offline, folderOpt...)
+}
+
+// CachedPathWithOffline is as `CachedPathWithChecksum()` but with given
+// offline mode instead of `Offline`.
+func CachedPathWithOffline(filenameOrUrl, checksum string, offline bool, folderOpt ...string) (resolvedPath string, err error) {
 	filename := path.Base(filenameOrUrl)
 	// Resolves to "candidate" filename at `CachedDir`
 	fullPath := CachedDir
@@ -123,93 +163,304 @@ func CachedPath(filenameOrUrl string, folderOpt ...string) (resolvedPath string,
 
 	cachedFileCandidate := fmt.Sprintf("%s/%s", fullPath, filename)
 
+	switch checksum {
+	case NoChecksum:
+		checksum = ""
+	case "":
+		checksum = checksumFromURL(filenameOrUrl)
+	}
+
 	// 1. Cached candidate file exists
 	if _, err := os.Stat(cachedFileCandidate); err == nil {
-		return cachedFileCandidate, nil
+		return verifyCached(cachedFileCandidate, checksum)
+	}
+
+	_, statErr := os.Stat(filenameOrUrl)
+	isLocalFile := statErr == nil
+	if !isLocalFile && !isValidURL(filenameOrUrl) {
+		err = fmt.Errorf("Unable to parse %q as a URL or as a local path.\n", filenameOrUrl)
+		return "", err
+	}
+	if !isLocalFile && offline {
+		err = fmt.Errorf("CachedPath() failed: %q not found in cache %q and offline mode (%s) is on", filenameOrUrl, fullPath, gotchOfflineKey)
+		return "", err
+	}
+
+	if err := os.MkdirAll(fullPath, 0755); err != nil {
+		return "", err
+	}
+	unlock, err := lockFile(cachedFileCandidate + ".lock")
+	if err != nil {
+		err = fmt.Errorf("CachedPath() failed: %w", err)
+		return "", err
+	}
+	defer unlock()
+
+	// Other process may have cached it while waiting for the lock.
+	if _, err := os.Stat(cachedFileCandidate); err == nil {
+		return verifyCached(cachedFileCandidate, checksum)
 	}
 
 	// 2. If valid fullpath to local file, caches it and return cached filename
-	if _, err := os.Stat(filenameOrUrl); err == nil {
-		err := copyFile(filenameOrUrl, cachedFileCandidate)
-		if err != nil {
+	if isLocalFile {
+		tmpFile := cachedFileCandidate + ".part"
+		if err := copyFile(filenameOrUrl, tmpFile); err != nil {
+			return "", err
+		}
+		if err := commitFile(tmpFile, cachedFileCandidate, checksum); err != nil {
 			return "", err
 		}
 		return cachedFileCandidate, nil
 	}
 
 	// 3. Cached candidate file NOT exist. Try to download it and save to `CacheDir`
-	if isValidURL(filenameOrUrl) {
-		if _, err := http.Get(filenameOrUrl); err == nil {
-			err := downloadFile(filenameOrUrl, cachedFileCandidate)
-			if err != nil {
-				return "", err
-			}
-
-			return cachedFileCandidate, nil
-		} else {
-			fmt.Printf("Error: %v\n", err)
-			err = fmt.Errorf("Unable to parse %q as a URL or as a local path.\n", filenameOrUrl)
-			return "", err
+	url := filenameOrUrl
+	if ModelMirror != "" {
+		url = fmt.Sprintf("%s/%s", strings.TrimSuffix(ModelMirror, "/"), filename)
+	}
+	if err := downloadFile(url, cachedFileCandidate, checksum); err != nil {
+		return "", err
+	}
+
+	return cachedFileCandidate, nil
+}
+
+// verifyCached verifies a cached file against checksum and returns its path.
+func verifyCached(cachedFile, checksum string) (string, error) {
+	if err := VerifyChecksum(cachedFile, checksum); err != nil {
+		err = fmt.Errorf("CachedPath() failed: %w. Remove the cached file to retrieve it again", err)
+		return "", err
+	}
+
+	return cachedFile, nil
+}
+
+func isValidURL(s string) bool {
+	u, err := neturl.ParseRequestURI(s)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// hashPrefixRe matches hash prefix in torchvision style filename, e.g.
+// "resnet18-f37072fd.pth".
+var hashPrefixRe = regexp.MustCompile(`-([0-9a-f]{8,64})\.[^.]+$`)
+
+// checksumFromURL returns SHA-256 prefix in filename of a pretrained model
+// URL, i.e. in `ModelUrls` or at torchvision host, or empty string. Other
+// filenames may end with something looking like a hash, e.g. "data-20240101.csv".
+func checksumFromURL(url string) string {
+	if !isModelURL(url) {
+		return ""
+	}
+
+	m := hashPrefixRe.FindStringSubmatch(path.Base(url))
+	if m == nil {
+		return ""
+	}
+
+	return m[1]
+}
+
+func isModelURL(url string) bool {
+	for _, u := range ModelUrls {
+		if u == url {
+			return true
 		}
 	}
 
-	// Not resolves
-	err = fmt.Errorf("Unable to parse %q as a URL or as a local path.\n", filenameOrUrl)
-	return "", err
+	u, err := neturl.Parse(url)
+
+	return err == nil && u.Hostname() == torchvisionHost
+}
+
+// VerifyChecksum checks that SHA-256 hex digest of a file starts with
+// checksum (case insensitive). Empty checksum and `NoChecksum` are always valid.
+func VerifyChecksum(filename, checksum string) error {
+	if checksum == "" || checksum == NoChecksum {
+		return nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+
+	digest := hex.EncodeToString(h.Sum(nil))
+	if !strings.HasPrefix(digest, strings.ToLower(checksum)) {
+		err := fmt.Errorf("checksum mismatched for %q: want %v, got %v", filename, checksum, digest)
+		return err
+	}
+
+	return nil
+}
+
+// commitFile verifies a temporary file and atomically renames it to its
+// destination. Temporary file is removed if verification failed.
+func commitFile(tmpFile, filepath, checksum string) error {
+	if err := VerifyChecksum(tmpFile, checksum); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+
+	return os.Rename(tmpFile, filepath)
+}
+
+// lockFile acquires an exclusive lock of a file (created if not existing),
+// blocking until it is available. The returned function releases the lock.
+//
+// NOTE. Lock file is kept after unlocking as removing it would race with
+// other processes waiting for the lock.
+func lockFile(filename string) (unlock func(), err error) {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	unlock = func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}
+
+	return unlock, nil
+}
+
+const downloadRetries = 3
+
+var downloadRetryWait = time.Second
+
+// httpStatusError is error of unexpected HTTP response status.
+type httpStatusError struct {
+	url        string
+	statusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	if e.statusCode == http.StatusNotFound {
+		return fmt.Sprintf("download file not found: %q for downloading", e.url)
+	}
+
+	return fmt.Sprintf("download file failed: %q - bad status: %v(%v)", e.url, http.StatusText(e.statusCode), e.statusCode)
 }
 
-func isValidURL(url string) bool {
+// retryable reports whether download should be retried after error, i.e.
+// network errors and server side or throttling HTTP statuses. Local file
+// errors are not retried.
+func retryable(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.statusCode
+		return code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestedRangeNotSatisfiable
+	}
 
-	// TODO: implement
-	return true
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
 }
 
 // downloadFile downloads file from URL and stores it in local filepath.
-// It writes to the destination file as it downloads it, without loading
-// the entire file into memory. An `io.TeeReader` is passed into Copy()
-// to report progress on the download.
-func downloadFile(url string, filepath string) error {
+// It writes to a "<filepath>.part" file as it downloads it, without loading
+// the entire file into memory, and renames it to filepath when completed and
+// verified against checksum. Interrupted downloads are resumed and transient
+// errors retried with increasing wait.
+func downloadFile(url string, filepath string, checksum string) error {
 	// Create path if not existing
 	dir := path.Dir(filepath)
-	filename := path.Base(filepath)
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			log.Fatal(err)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmpFile := filepath + ".part"
+	var err error
+	for i := 0; i <= downloadRetries; i++ {
+		if i > 0 {
+			wait := downloadRetryWait * time.Duration(1<<(i-1))
+			fmt.Printf("Download failed: %v. Retrying in %v...\n", err, wait)
+			time.Sleep(wait)
 		}
+
+		err = fetchFile(url, tmpFile)
+		if err == nil || !retryable(err) {
+			break
+		}
+	}
+	if err != nil {
+		return err
 	}
 
-	// Create the file with .tmp extension, so that we won't overwrite a
-	// file until it's downloaded fully
-	out, err := os.Create(filepath + ".tmp")
+	return commitFile(tmpFile, filepath, checksum)
+}
+
+// fetchFile downloads URL to a file. If the file is not empty, it requests
+// the remaining bytes only.
+func fetchFile(url string, filepath string) error {
+	filename := path.Base(filepath)
+	out, err := os.OpenFile(filepath, os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
 
+	info, err := out.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
 	// Get the data
-	resp, err := http.Get(url)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	// Check server response
-	if resp.StatusCode != http.StatusOK {
-		err := fmt.Errorf("bad status: %s(%v)", resp.Status, resp.StatusCode)
-		if resp.StatusCode == 404 {
-			err = fmt.Errorf("download file not found: %q for downloading", url)
-		} else {
-			err = fmt.Errorf("download file failed: %q", url)
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		fmt.Printf("Resuming download of %s from %s\n", filename, byteCountIEC(uint64(offset)))
+	case http.StatusOK:
+		// Server does not support range requests. Start over.
+		offset = 0
+		if err := out.Truncate(0); err != nil {
+			return err
 		}
+	default:
+		if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			// Partial file is invalid. Start over on retry.
+			out.Truncate(0)
+		}
+		err := &httpStatusError{url: url, statusCode: resp.StatusCode}
+		return err
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
 		return err
 	}
 
 	// the total file size to download
 	size, _ := strconv.Atoi(resp.Header.Get("Content-Length"))
-	downloadSize := uint64(size)
+	downloadSize := uint64(offset) + uint64(size)
 
 	// Create our bytes counter and pass it to be used alongside our writer
-	counter := &writeCounter{FileSize: downloadSize}
+	counter := &writeCounter{Total: uint64(offset), FileSize: downloadSize}
 	_, err = io.Copy(out, io.TeeReader(resp.Body, counter))
 	if err != nil {
 		return err
@@ -219,12 +470,6 @@ func downloadFile(url string, filepath string) error {
 	// The progress use the same line so print a new line once it's finished downloading
 	fmt.Println()
 
-	// Rename the tmp file back to the original file
-	err = os.Rename(filepath+".tmp", filepath)
-	if err != nil {
-		return err
-	}
-
 	return nil
 }
 
@@ -0,0 +1,240 @@
+package gotch_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sugarme/gotch"
+)
+
+func setCacheDir(t *testing.T) string {
+	dir := t.TempDir()
+	cachedDir, mirror, offline := gotch.CachedDir, gotch.ModelMirror, gotch.Offline
+	gotch.CachedDir = dir
+	t.Cleanup(func() {
+		gotch.CachedDir, gotch.ModelMirror, gotch.Offline = cachedDir, mirror, offline
+	})
+
+	return dir
+}
+
+// fileServer serves data at any path and counts requests.
+func fileServer(data []byte, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		http.ServeContent(w, r, "data", time.Time{}, bytes.NewReader(data))
+	}))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCachedPathChecksum(t *testing.T) {
+	dir := setCacheDir(t)
+	data := []byte("pretrained weights")
+	var requests int32
+	server := fileServer(data, &requests)
+	defer server.Close()
+
+	// Hash prefix in filename of pretrained model URLs
+	url := server.URL + "/model-" + sha256Hex(data)[:8] + ".pth"
+	badURL := server.URL + "/model-00000000.pth"
+	gotch.ModelUrls["test_model"] = url
+	gotch.ModelUrls["test_bad_model"] = badURL
+	t.Cleanup(func() {
+		delete(gotch.ModelUrls, "test_model")
+		delete(gotch.ModelUrls, "test_bad_model")
+	})
+	cached, err := gotch.CachedPath(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(cached); !bytes.Equal(got, data) {
+		t.Errorf("Want cached data %q, got %q", data, got)
+	}
+
+	// Corrupted cached file
+	if err := os.WriteFile(cached, []byte("corrupted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gotch.CachedPath(url); err == nil {
+		t.Errorf("Expect error of mismatched checksum of cached file")
+	}
+	if _, err := gotch.CachedPathWithChecksum(url, gotch.NoChecksum); err != nil {
+		t.Error(err)
+	}
+	os.Remove(cached)
+
+	// Mismatched
+	if _, err := gotch.CachedPath(badURL); err == nil {
+		t.Errorf("Expect error of mismatched checksum in filename")
+	}
+	if _, err := gotch.CachedPathWithChecksum(server.URL+"/model.pth", "00000000"); err == nil {
+		t.Errorf("Expect error of mismatched explicit checksum")
+	}
+	for _, name := range []string{"model-00000000.pth", "model.pth", "model.pth.part"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("Want no %q cached with mismatched checksum", name)
+		}
+	}
+
+	if _, err := gotch.CachedPathWithChecksum(server.URL+"/model.pth", sha256Hex(data)); err != nil {
+		t.Error(err)
+	}
+
+	// Other filenames are not hashes. Verification can be skipped explicitly.
+	if _, err := gotch.CachedPath(server.URL + "/data-20240101.csv"); err != nil {
+		t.Error(err)
+	}
+	if _, err := gotch.CachedPathWithChecksum(badURL, gotch.NoChecksum); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCachedPathResume(t *testing.T) {
+	dir := setCacheDir(t)
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	var requests int32
+	server := fileServer(data, &requests)
+	defer server.Close()
+
+	// Interrupted download
+	partFile := filepath.Join(dir, "model.pth.part")
+	if err := os.WriteFile(partFile, data[:400], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err := gotch.CachedPathWithChecksum(server.URL+"/model.pth", sha256Hex(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(cached); !bytes.Equal(got, data) {
+		t.Errorf("Want resumed data of %v bytes, got %v bytes", len(data), len(got))
+	}
+}
+
+func TestCachedPathRetry(t *testing.T) {
+	setCacheDir(t)
+	t.Cleanup(gotch.SetDownloadRetryWait(time.Millisecond))
+	data := []byte("pretrained weights")
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	if _, err := gotch.CachedPath(server.URL + "/model.pth"); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("Want 2 requests, got %v", got)
+	}
+
+	// Truncated body is retried.
+	atomic.StoreInt32(&requests, 0)
+	truncated := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data[:4])
+			return
+		}
+		w.Write(data)
+	}))
+	defer truncated.Close()
+	if _, err := gotch.CachedPathWithChecksum(truncated.URL+"/truncated.pth", sha256Hex(data)); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("Want 2 requests, got %v", got)
+	}
+
+	// Not found is not retried.
+	atomic.StoreInt32(&requests, 0)
+	notFound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer notFound.Close()
+	if _, err := gotch.CachedPath(notFound.URL + "/missing.pth"); err == nil {
+		t.Errorf("Expect error of file not found")
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("Want 1 request, got %v", got)
+	}
+}
+
+func TestCachedPathMirrorOffline(t *testing.T) {
+	dir := setCacheDir(t)
+	data := []byte("pretrained weights")
+	var requests int32
+	mirror := fileServer(data, &requests)
+	defer mirror.Close()
+
+	gotch.Offline = true
+	if _, err := gotch.CachedPath("https://example.com/models/model.pth"); err == nil {
+		t.Errorf("Expect error of uncached file in offline mode")
+	}
+	cachedFile := filepath.Join(dir, "cached.pth")
+	if err := os.WriteFile(cachedFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := gotch.CachedPath("https://example.com/models/cached.pth"); err != nil || got != cachedFile {
+		t.Errorf("Want cached file %q in offline mode, got %q (%v)", cachedFile, got, err)
+	}
+
+	gotch.Offline = false
+	if _, err := gotch.CachedPathWithOffline("https://example.com/models/model.pth", "", true); err == nil {
+		t.Errorf("Expect error of uncached file with offline mode argument")
+	}
+	gotch.ModelMirror = mirror.URL + "/"
+	if _, err := gotch.CachedPath("https://example.com/models/model.pth"); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("Want download from mirror, got %v requests", got)
+	}
+}
+
+func TestCachedPathConcurrent(t *testing.T) {
+	setCacheDir(t)
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	var requests int32
+	server := fileServer(data, &requests)
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = gotch.CachedPath(server.URL + "/model.pth")
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("Want file downloaded once, got %v requests", got)
+	}
+}
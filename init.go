@@ -8,10 +8,14 @@ import (
 )
 
 var (
-	CachedDir     string = "NOT_SETTING"
-	gotchEnvKey   string = "GOTCH_CACHE"
-	gotchDebugKey string = "GOTCH_DEBUG"
-	Debug         bool   = false
+	CachedDir       string = "NOT_SETTING"
+	gotchEnvKey     string = "GOTCH_CACHE"
+	gotchDebugKey   string = "GOTCH_DEBUG"
+	gotchMirrorKey  string = "GOTCH_MODEL_MIRROR"
+	gotchOfflineKey string = "GOTCH_OFFLINE"
+	Debug           bool   = false
+	ModelMirror     string = ""    // base URL files are downloaded from instead of their URLs
+	Offline         bool   = false // only resolve files from `CachedDir`, never download
 )
 
 func init() {
@@ -26,6 +30,11 @@ func initEnv() {
 		Debug = v
 	}
 
+	if v, err := strconv.ParseBool(os.Getenv(gotchOfflineKey)); err == nil {
+		Offline = v
+	}
+	ModelMirror = os.Getenv(gotchMirrorKey)
+
 	val := os.Getenv(gotchEnvKey)
 	if val != "" {
 		CachedDir = val
@@ -214,6 +214,11 @@ func AtBackward(ts Ctensor, keepGraph int, createGraph int) {
 	C.at_backward(ts, ckeepGraph, ccreateGraph)
 }
 
+// void at_retain_grad(tensor);
+func AtRetainGrad(ts Ctensor) {
+	C.at_retain_grad(ts)
+}
+
 /*
  * void at_run_backward(tensor *tensors,
  *                       int ntensors,
@@ -400,6 +405,22 @@ func AtFree(ts Ctensor) {
 	C.at_free(ts)
 }
 
+// void at_manual_seed(int64_t);
+func AtManualSeed(seed int64) {
+	cseed := *(*C.int64_t)(unsafe.Pointer(&seed))
+	C.at_manual_seed(cseed)
+}
+
+// tensor at_get_rng_state();
+func AtGetRngState() Ctensor {
+	return C.at_get_rng_state()
+}
+
+// void at_set_rng_state(tensor);
+func AtSetRngState(state Ctensor) {
+	C.at_set_rng_state(state)
+}
+
 // int at_grad_set_enabled(int b);
 func AtGradSetEnabled(b int) int {
 	cbool := *(*C.int)(unsafe.Pointer(&b))
@@ -407,6 +428,64 @@ func AtGradSetEnabled(b int) int {
 	return *(*int)(unsafe.Pointer(&cretVal))
 }
 
+// void at_autocast_clear_cache();
+func AtAutocastClearCache() {
+	C.at_autocast_clear_cache()
+}
+
+// int at_autocast_decrement_nesting();
+func AtAutocastDecrementNesting() int {
+	cretVal := C.at_autocast_decrement_nesting()
+	return int(cretVal)
+}
+
+// int at_autocast_increment_nesting();
+func AtAutocastIncrementNesting() int {
+	cretVal := C.at_autocast_increment_nesting()
+	return int(cretVal)
+}
+
+// bool at_autocast_is_enabled();
+func AtAutocastIsEnabled() bool {
+	cretVal := C.at_autocast_is_enabled()
+	return *(*bool)(unsafe.Pointer(&cretVal))
+}
+
+// bool at_autocast_set_enabled(bool b);
+func AtAutocastSetEnabled(b bool) bool {
+	cbool := *(*C.bool)(unsafe.Pointer(&b))
+	cretVal := C.at_autocast_set_enabled(cbool)
+	return *(*bool)(unsafe.Pointer(&cretVal))
+}
+
+// int at_autocast_is_cpu_enabled();
+func AtAutocastIsCPUEnabled() int {
+	cretVal := C.at_autocast_is_cpu_enabled()
+	return int(cretVal)
+}
+
+// int at_autocast_set_cpu_enabled(int b);
+func AtAutocastSetCPUEnabled(b int) int {
+	cbool := *(*C.int)(unsafe.Pointer(&b))
+	cretVal := C.at_autocast_set_cpu_enabled(cbool)
+	return int(cretVal)
+}
+
+// int at_autocast_get_dtype(int cuda);
+func AtAutocastGetDType(cuda int) int {
+	ccuda := *(*C.int)(unsafe.Pointer(&cuda))
+	cretVal := C.at_autocast_get_dtype(ccuda)
+	return int(cretVal)
+}
+
+// int at_autocast_set_dtype(int cuda, int dtype);
+func AtAutocastSetDType(cuda int, dtype int) int {
+	ccuda := *(*C.int)(unsafe.Pointer(&cuda))
+	cdtype := *(*C.int)(unsafe.Pointer(&dtype))
+	cretVal := C.at_autocast_set_dtype(ccuda, cdtype)
+	return int(cretVal)
+}
+
 /*
  * optimizer ato_adam(double learning_rate,
  *                    double beta1,
@@ -29,6 +29,19 @@ char *get_and_reset_last_err() {
 
 void at_manual_seed(int64_t seed) { torch::manual_seed(seed); }
 
+tensor at_get_rng_state() {
+  PROTECT(auto gen = at::detail::getDefaultCPUGenerator();
+          std::lock_guard<std::mutex> lock(gen.mutex());
+          return new torch::Tensor(gen.get_state());)
+  return nullptr;
+}
+
+void at_set_rng_state(tensor state) {
+  PROTECT(auto gen = at::detail::getDefaultCPUGenerator();
+          std::lock_guard<std::mutex> lock(gen.mutex());
+          gen.set_state(*state);)
+}
+
 vector<torch::Tensor> of_carray_tensor(torch::Tensor **vs, int len) {
   vector<torch::Tensor> result;
   for (int i = 0; i < len; ++i)
@@ -184,6 +197,31 @@ bool at_autocast_set_enabled(bool b) {
   return -1;
 }
 
+int at_autocast_is_cpu_enabled() {
+  PROTECT(return at::autocast::is_cpu_enabled();)
+  return -1;
+}
+
+int at_autocast_set_cpu_enabled(int b) {
+  PROTECT(bool is_enabled = at::autocast::is_cpu_enabled();
+          at::autocast::set_cpu_enabled(b); return is_enabled;)
+  return -1;
+}
+
+int at_autocast_get_dtype(int cuda) {
+  PROTECT(if (cuda) return int(at::autocast::get_autocast_gpu_dtype());
+          return int(at::autocast::get_autocast_cpu_dtype());)
+  return -1;
+}
+
+int at_autocast_set_dtype(int cuda, int dtype) {
+  PROTECT(int prev = at_autocast_get_dtype(cuda);
+          if (cuda) at::autocast::set_autocast_gpu_dtype(at::ScalarType(dtype));
+          else at::autocast::set_autocast_cpu_dtype(at::ScalarType(dtype));
+          return prev;)
+  return -1;
+}
+
 int at_device(tensor t) {
   PROTECT(auto device = t->device(); if (device.type() == at::kCPU) return -1;
           if (device.type() == at::kCUDA) return device.index();)
@@ -194,6 +232,8 @@ void at_backward(tensor t, int keep_graph, int create_graph) {
   PROTECT(t->backward({}, keep_graph, create_graph);)
 }
 
+void at_retain_grad(tensor t) { PROTECT(t->retain_grad();) }
+
 int at_requires_grad(tensor t) {
   PROTECT(return t->requires_grad();)
   return -1;
@@ -31,6 +31,8 @@ typedef void *ivalue;
 
 char *get_and_reset_last_err(); // thread-local
 void at_manual_seed(int64_t);
+tensor at_get_rng_state();
+void at_set_rng_state(tensor);
 tensor at_new_tensor();
 tensor at_tensor_of_blob(void *data, int64_t *dims, size_t ndims,
                          int64_t *strides, size_t nstrides, int type,
@@ -59,8 +61,13 @@ int at_autocast_decrement_nesting();
 int at_autocast_increment_nesting();
 bool at_autocast_is_enabled();
 bool at_autocast_set_enabled(bool b);
+int at_autocast_is_cpu_enabled();
+int at_autocast_set_cpu_enabled(int b);
+int at_autocast_get_dtype(int cuda);
+int at_autocast_set_dtype(int cuda, int dtype);
 
 void at_backward(tensor, int, int);
+void at_retain_grad(tensor);
 int at_requires_grad(tensor);
 int at_grad_set_enabled(int);
 
@@ -0,0 +1,327 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// Accuracy is the fraction of samples which target is among top-k predictions.
+type Accuracy struct {
+	k           int
+	ignoreIndex int64
+	correct     int64
+	total       int64
+}
+
+var _ Metric = (*Accuracy)(nil)
+
+// NewAccuracy creates Accuracy metric. Options: WithTopK and WithIgnoreIndex.
+func NewAccuracy(opts ...MetricOption) *Accuracy {
+	options := newMetricOptions(opts...)
+
+	return &Accuracy{
+		k:           options.TopK,
+		ignoreIndex: options.IgnoreIndex,
+	}
+}
+
+// Update implements Metric interface.
+//
+// - logits: scores of shape [N, C].
+// - targets: class indices of shape [N].
+func (m *Accuracy) Update(logits, targets *ts.Tensor) error {
+	dims := logits.MustSize()
+	tdims := targets.MustSize()
+	if len(dims) != 2 || len(tdims) != 1 || dims[0] != tdims[0] {
+		err := fmt.Errorf("Accuracy.Update() failed: expected logits of shape [N, C] and targets of shape [N]. Got %v and %v", dims, tdims)
+		return err
+	}
+	if m.k < 1 {
+		err := fmt.Errorf("Accuracy.Update() failed: invalid top-k (%v)", m.k)
+		return err
+	}
+
+	k := int64(m.k)
+	if k > dims[1] {
+		k = dims[1]
+	}
+
+	values, indices := logits.MustTopk(k, -1, true, false, false)
+	values.MustDrop()
+	t := targets.MustTo(logits.MustDevice(), false).MustUnsqueeze(1, true)
+	hits := indices.MustEqTensor(t, true).MustAnyDim(1, false, true)
+	valid := t.MustNe(ts.IntScalar(m.ignoreIndex), true).MustSqueezeDim(1, true)
+
+	m.correct += int64(scalar(hits.MustLogicalAnd(valid, true).MustSum(gotch.Int64, true)))
+	m.total += int64(scalar(valid.MustSum(gotch.Int64, true)))
+
+	return nil
+}
+
+// Compute implements Metric interface.
+func (m *Accuracy) Compute() float64 {
+	if m.total == 0 {
+		return math.NaN()
+	}
+
+	return float64(m.correct) / float64(m.total)
+}
+
+// Reset implements Metric interface.
+func (m *Accuracy) Reset() {
+	m.correct = 0
+	m.total = 0
+}
+
+// ConfusionMatrix accumulates counts of (target, prediction) class pairs.
+type ConfusionMatrix struct {
+	numClasses  int
+	ignoreIndex int64
+	matrix      [][]int64 // [target][prediction]
+}
+
+var _ Metric = (*ConfusionMatrix)(nil)
+
+// NewConfusionMatrix creates ConfusionMatrix metric. Options: WithIgnoreIndex.
+func NewConfusionMatrix(numClasses int, opts ...MetricOption) *ConfusionMatrix {
+	options := newMetricOptions(opts...)
+
+	m := &ConfusionMatrix{
+		numClasses:  numClasses,
+		ignoreIndex: options.IgnoreIndex,
+	}
+	m.Reset()
+
+	return m
+}
+
+// Update implements Metric interface.
+//
+// - preds: class indices of same shape as targets, or scores with an extra class
+// dimension at dim 1, e.g. logits of shape [N, C] or [N, C, H, W].
+// - targets: class indices, e.g. of shape [N] or [N, H, W].
+func (m *ConfusionMatrix) Update(preds, targets *ts.Tensor) error {
+	dims := preds.MustSize()
+	tdims := targets.MustSize()
+
+	var labels []int64
+	switch {
+	case reflect.DeepEqual(dims, tdims):
+		labels = int64s(preds)
+	case len(dims) == len(tdims)+1 && len(dims) > 1:
+		argmax := preds.MustArgmax([]int64{1}, false, false)
+		labels = int64s(argmax)
+		argmax.MustDrop()
+	default:
+		err := fmt.Errorf("ConfusionMatrix.Update() failed: shape mismatched between predictions %v and targets %v", dims, tdims)
+		return err
+	}
+
+	for i, t := range int64s(targets) {
+		if t == m.ignoreIndex {
+			continue
+		}
+		p := labels[i]
+		if t < 0 || t >= int64(m.numClasses) || p < 0 || p >= int64(m.numClasses) {
+			err := fmt.Errorf("ConfusionMatrix.Update() failed: class index out of range [0, %v). Got target %v and prediction %v", m.numClasses, t, p)
+			return err
+		}
+		m.matrix[t][p]++
+	}
+
+	return nil
+}
+
+// Compute implements Metric interface. It returns accuracy.
+func (m *ConfusionMatrix) Compute() float64 {
+	var correct, total int64
+	for i, row := range m.matrix {
+		for j, v := range row {
+			total += v
+			if i == j {
+				correct += v
+			}
+		}
+	}
+	if total == 0 {
+		return math.NaN()
+	}
+
+	return float64(correct) / float64(total)
+}
+
+// Reset implements Metric interface.
+func (m *ConfusionMatrix) Reset() {
+	m.matrix = make([][]int64, m.numClasses)
+	for i := range m.matrix {
+		m.matrix[i] = make([]int64, m.numClasses)
+	}
+}
+
+// Matrix returns a copy of confusion matrix. Rows are targets and columns are
+// predictions.
+func (m *ConfusionMatrix) Matrix() [][]int64 {
+	matrix := make([][]int64, m.numClasses)
+	for i, row := range m.matrix {
+		matrix[i] = append([]int64(nil), row...)
+	}
+
+	return matrix
+}
+
+// counts returns true positives, false positives and false negatives of each class.
+func (m *ConfusionMatrix) counts() (tp, fp, fn []int64) {
+	tp = make([]int64, m.numClasses)
+	fp = make([]int64, m.numClasses)
+	fn = make([]int64, m.numClasses)
+	for t, row := range m.matrix {
+		for p, v := range row {
+			if t == p {
+				tp[t] += v
+			} else {
+				fp[p] += v
+				fn[t] += v
+			}
+		}
+	}
+
+	return tp, fp, fn
+}
+
+func safeDiv(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+func f1(precision, recall float64) float64 {
+	return safeDiv(2*precision*recall, precision+recall)
+}
+
+// PerClass returns precision, recall and F1 score of each class. Undefined
+// values (zero division) are 0.
+func (m *ConfusionMatrix) PerClass() (precision, recall, f1Score []float64) {
+	tp, fp, fn := m.counts()
+	precision = make([]float64, m.numClasses)
+	recall = make([]float64, m.numClasses)
+	f1Score = make([]float64, m.numClasses)
+	for c := 0; c < m.numClasses; c++ {
+		precision[c] = safeDiv(float64(tp[c]), float64(tp[c]+fp[c]))
+		recall[c] = safeDiv(float64(tp[c]), float64(tp[c]+fn[c]))
+		f1Score[c] = f1(precision[c], recall[c])
+	}
+
+	return precision, recall, f1Score
+}
+
+// Averaged returns precision, recall and F1 score averaged with given method.
+// Macro average is over classes present in targets or predictions.
+func (m *ConfusionMatrix) Averaged(average Average) (precision, recall, f1Score float64) {
+	tp, fp, fn := m.counts()
+
+	if average == AverageMicro {
+		var sumTP, sumFP, sumFN int64
+		for c := 0; c < m.numClasses; c++ {
+			sumTP += tp[c]
+			sumFP += fp[c]
+			sumFN += fn[c]
+		}
+		if sumTP+sumFP+sumFN == 0 {
+			return math.NaN(), math.NaN(), math.NaN()
+		}
+		precision = safeDiv(float64(sumTP), float64(sumTP+sumFP))
+		recall = safeDiv(float64(sumTP), float64(sumTP+sumFN))
+		return precision, recall, f1(precision, recall)
+	}
+
+	ps, rs, fs := m.PerClass()
+	var total float64
+	for c := 0; c < m.numClasses; c++ {
+		support := float64(tp[c] + fn[c])
+		var w float64
+		switch average {
+		case AverageWeighted:
+			w = support
+		default:
+			if support > 0 || fp[c] > 0 {
+				w = 1
+			}
+		}
+		precision += w * ps[c]
+		recall += w * rs[c]
+		f1Score += w * fs[c]
+		total += w
+	}
+	if total == 0 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+
+	return precision / total, recall / total, f1Score / total
+}
+
+// Precision is a streaming precision metric of multi-class classification.
+type Precision struct {
+	*ConfusionMatrix
+	average Average
+}
+
+// NewPrecision creates Precision metric. Options: WithAverage and WithIgnoreIndex.
+// See `ConfusionMatrix.Update()` for inputs.
+func NewPrecision(numClasses int, opts ...MetricOption) *Precision {
+	options := newMetricOptions(opts...)
+
+	return &Precision{NewConfusionMatrix(numClasses, opts...), options.Average}
+}
+
+// Compute implements Metric interface.
+func (m *Precision) Compute() float64 {
+	v, _, _ := m.Averaged(m.average)
+	return v
+}
+
+// Recall is a streaming recall metric of multi-class classification.
+type Recall struct {
+	*ConfusionMatrix
+	average Average
+}
+
+// NewRecall creates Recall metric. Options: WithAverage and WithIgnoreIndex.
+// See `ConfusionMatrix.Update()` for inputs.
+func NewRecall(numClasses int, opts ...MetricOption) *Recall {
+	options := newMetricOptions(opts...)
+
+	return &Recall{NewConfusionMatrix(numClasses, opts...), options.Average}
+}
+
+// Compute implements Metric interface.
+func (m *Recall) Compute() float64 {
+	_, v, _ := m.Averaged(m.average)
+	return v
+}
+
+// F1Score is a streaming F1 score metric of multi-class classification.
+//
+// NOTE. macro F1 score is the mean of per-class F1 scores.
+type F1Score struct {
+	*ConfusionMatrix
+	average Average
+}
+
+// NewF1Score creates F1Score metric. Options: WithAverage and WithIgnoreIndex.
+// See `ConfusionMatrix.Update()` for inputs.
+func NewF1Score(numClasses int, opts ...MetricOption) *F1Score {
+	options := newMetricOptions(opts...)
+
+	return &F1Score{NewConfusionMatrix(numClasses, opts...), options.Average}
+}
+
+// Compute implements Metric interface.
+func (m *F1Score) Compute() float64 {
+	_, _, v := m.Averaged(m.average)
+	return v
+}
@@ -0,0 +1,104 @@
+package metrics_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch/metrics"
+	"github.com/sugarme/gotch/ts"
+)
+
+func assertClose(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-6 {
+		t.Errorf("%s: want %v, got %v", name, want, got)
+	}
+}
+
+func TestAccuracy(t *testing.T) {
+	logits := ts.MustOfSlice([]float32{
+		0.1, 0.7, 0.2,
+		0.5, 0.1, 0.4,
+		0.2, 0.3, 0.5,
+		0.6, 0.3, 0.1,
+	}).MustView([]int64{4, 3}, true)
+	targets := ts.MustOfSlice([]int64{1, 2, 2, -100})
+
+	top1 := metrics.NewAccuracy()
+	if err := top1.Update(logits, targets); err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "top-1 accuracy", top1.Compute(), 2.0/3.0)
+
+	top2 := metrics.NewAccuracy(metrics.WithTopK(2))
+	if err := top2.Update(logits, targets); err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "top-2 accuracy", top2.Compute(), 1.0)
+
+	top1.Reset()
+	if !math.IsNaN(top1.Compute()) {
+		t.Errorf("want NaN after reset, got %v", top1.Compute())
+	}
+}
+
+func TestConfusionMatrix(t *testing.T) {
+	preds := ts.MustOfSlice([]int64{0, 1, 1, 2, 2, 2})
+	targets := ts.MustOfSlice([]int64{0, 1, 2, 2, 2, 0})
+
+	cm := metrics.NewConfusionMatrix(3)
+	if err := cm.Update(preds, targets); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]int64{
+		{1, 0, 1},
+		{0, 1, 0},
+		{0, 1, 2},
+	}
+	if got := cm.Matrix(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v\n", want)
+		t.Errorf("got: %v\n", got)
+	}
+	assertClose(t, "accuracy", cm.Compute(), 4.0/6.0)
+
+	// precision: [1, 1/2, 2/3], recall: [1/2, 1, 2/3]
+	precision, recall, f1 := cm.PerClass()
+	assertClose(t, "precision[1]", precision[1], 0.5)
+	assertClose(t, "recall[0]", recall[0], 0.5)
+	assertClose(t, "f1[2]", f1[2], 2.0/3.0)
+
+	macro := metrics.NewPrecision(3)
+	if err := macro.Update(preds, targets); err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "macro precision", macro.Compute(), (1+0.5+2.0/3.0)/3)
+
+	micro := metrics.NewF1Score(3, metrics.WithAverage(metrics.AverageMicro))
+	if err := micro.Update(preds, targets); err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "micro f1", micro.Compute(), 4.0/6.0)
+
+	weighted := metrics.NewRecall(3, metrics.WithAverage(metrics.AverageWeighted))
+	if err := weighted.Update(preds, targets); err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "weighted recall", weighted.Compute(), (2*0.5+1*1+3*2.0/3.0)/6)
+}
+
+func TestConfusionMatrix_Logits(t *testing.T) {
+	// Segmentation logits of shape [N, C, H, W] = [1, 2, 1, 2]
+	logits := ts.MustOfSlice([]float32{0.9, 0.2, 0.1, 0.8}).MustView([]int64{1, 2, 1, 2}, true)
+	targets := ts.MustOfSlice([]int64{0, 0}).MustView([]int64{1, 1, 2}, true)
+
+	cm := metrics.NewConfusionMatrix(2)
+	if err := cm.Update(logits, targets); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]int64{{1, 1}, {0, 0}}
+	if got := cm.Matrix(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// COCOIoUThresholds returns IoU thresholds 0.5, 0.55, ..., 0.95 of COCO mAP@[0.5:0.95].
+func COCOIoUThresholds() []float64 {
+	var thresholds []float64
+	for i := 0; i < 10; i++ {
+		thresholds = append(thresholds, 0.5+0.05*float64(i))
+	}
+
+	return thresholds
+}
+
+type box [4]float64 // x1, y1, x2, y2
+
+func (b box) area() float64 {
+	return math.Max(0, b[2]-b[0]) * math.Max(0, b[3]-b[1])
+}
+
+func iou(a, b box) float64 {
+	w := math.Min(a[2], b[2]) - math.Max(a[0], b[0])
+	h := math.Min(a[3], b[3]) - math.Max(a[1], b[1])
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	inter := w * h
+
+	return inter / (a.area() + b.area() - inter)
+}
+
+type detection struct {
+	image int
+	box   box
+	score float64
+	label int64
+}
+
+type groundTruth struct {
+	box   box
+	label int64
+}
+
+// MeanAveragePrecision is the mean average precision (mAP) of object
+// detection. Average precision of a class is the area under its interpolated
+// precision-recall curve (all-point interpolation). mAP is averaged over
+// classes which have ground truth boxes, then over IoU thresholds.
+//
+// NOTE. all boxes are kept in memory until Reset().
+type MeanAveragePrecision struct {
+	iouThresholds []float64
+	detections    []detection
+	groundTruths  [][]groundTruth // per image
+}
+
+// NewMeanAveragePrecision creates MeanAveragePrecision metric. Options: WithIoUThresholds.
+func NewMeanAveragePrecision(opts ...MetricOption) *MeanAveragePrecision {
+	options := newMetricOptions(opts...)
+
+	return &MeanAveragePrecision{
+		iouThresholds: options.IoUThresholds,
+	}
+}
+
+func boxesOf(name string, x *ts.Tensor) ([]box, error) {
+	dims := x.MustSize()
+	if len(dims) != 2 || dims[1] != 4 {
+		if len(dims) == 1 && dims[0] == 0 {
+			return nil, nil
+		}
+		err := fmt.Errorf("MeanAveragePrecision.Update() failed: expected %s of shape [N, 4]. Got %v", name, dims)
+		return nil, err
+	}
+
+	vals := float64s(x)
+	boxes := make([]box, dims[0])
+	for i := range boxes {
+		copy(boxes[i][:], vals[i*4:i*4+4])
+	}
+
+	return boxes, nil
+}
+
+// Update accumulates detections and ground truth of an image.
+//
+// - predBoxes: predicted boxes of shape [M, 4] in (x1, y1, x2, y2) format.
+// - predScores: confidence scores of shape [M].
+// - predLabels: class indices of shape [M].
+// - gtBoxes: ground truth boxes of shape [G, 4] in (x1, y1, x2, y2) format.
+// - gtLabels: class indices of shape [G].
+func (m *MeanAveragePrecision) Update(predBoxes, predScores, predLabels, gtBoxes, gtLabels *ts.Tensor) error {
+	pboxes, err := boxesOf("predicted boxes", predBoxes)
+	if err != nil {
+		return err
+	}
+	gboxes, err := boxesOf("ground truth boxes", gtBoxes)
+	if err != nil {
+		return err
+	}
+	scores := float64s(predScores)
+	plabels := int64s(predLabels)
+	glabels := int64s(gtLabels)
+	if len(scores) != len(pboxes) || len(plabels) != len(pboxes) || len(glabels) != len(gboxes) {
+		err := fmt.Errorf("MeanAveragePrecision.Update() failed: mismatched number of boxes (%v), scores (%v) and labels (%v), or ground truth boxes (%v) and labels (%v)",
+			len(pboxes), len(scores), len(plabels), len(gboxes), len(glabels))
+		return err
+	}
+
+	image := len(m.groundTruths)
+	for i, b := range pboxes {
+		m.detections = append(m.detections, detection{image, b, scores[i], plabels[i]})
+	}
+	gts := make([]groundTruth, len(gboxes))
+	for i, b := range gboxes {
+		gts[i] = groundTruth{b, glabels[i]}
+	}
+	m.groundTruths = append(m.groundTruths, gts)
+
+	return nil
+}
+
+// classAP calculates average precision of a class at an IoU threshold.
+func (m *MeanAveragePrecision) classAP(label int64, threshold float64, npos int) float64 {
+	var dets []detection
+	for _, d := range m.detections {
+		if d.label == label {
+			dets = append(dets, d)
+		}
+	}
+	sort.SliceStable(dets, func(i, j int) bool { return dets[i].score > dets[j].score })
+
+	matched := make([][]bool, len(m.groundTruths))
+	for i, gts := range m.groundTruths {
+		matched[i] = make([]bool, len(gts))
+	}
+
+	precisions := make([]float64, len(dets))
+	recalls := make([]float64, len(dets))
+	var tp, fp float64
+	for i, d := range dets {
+		best, bestIoU := -1, threshold
+		for j, gt := range m.groundTruths[d.image] {
+			if gt.label != label {
+				continue
+			}
+			if v := iou(d.box, gt.box); v >= bestIoU && (best == -1 || v > bestIoU) {
+				best, bestIoU = j, v
+			}
+		}
+		if best >= 0 && !matched[d.image][best] {
+			matched[d.image][best] = true
+			tp++
+		} else {
+			fp++
+		}
+		precisions[i] = tp / (tp + fp)
+		recalls[i] = tp / float64(npos)
+	}
+
+	// All-point interpolation: precision at recall r is max precision at recall >= r.
+	for i := len(precisions) - 2; i >= 0; i-- {
+		precisions[i] = math.Max(precisions[i], precisions[i+1])
+	}
+	var ap, prevRecall float64
+	for i := range precisions {
+		ap += (recalls[i] - prevRecall) * precisions[i]
+		prevRecall = recalls[i]
+	}
+
+	return ap
+}
+
+// PerClass returns average precision of each class which has ground truth
+// boxes, averaged over IoU thresholds.
+func (m *MeanAveragePrecision) PerClass() map[int64]float64 {
+	npos := make(map[int64]int)
+	for _, gts := range m.groundTruths {
+		for _, gt := range gts {
+			npos[gt.label]++
+		}
+	}
+
+	aps := make(map[int64]float64, len(npos))
+	for label, n := range npos {
+		var sum float64
+		for _, threshold := range m.iouThresholds {
+			sum += m.classAP(label, threshold, n)
+		}
+		aps[label] = sum / float64(len(m.iouThresholds))
+	}
+
+	return aps
+}
+
+// Compute returns mean average precision. It returns NaN if there is no
+// ground truth box.
+func (m *MeanAveragePrecision) Compute() float64 {
+	aps := m.PerClass()
+	if len(aps) == 0 {
+		return math.NaN()
+	}
+
+	var sum float64
+	for _, ap := range aps {
+		sum += ap
+	}
+
+	return sum / float64(len(aps))
+}
+
+// Reset clears accumulated detections and ground truth.
+func (m *MeanAveragePrecision) Reset() {
+	m.detections = nil
+	m.groundTruths = nil
+}
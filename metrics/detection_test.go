@@ -0,0 +1,54 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/sugarme/gotch/metrics"
+	"github.com/sugarme/gotch/ts"
+)
+
+func boxes(vals ...float64) *ts.Tensor {
+	return ts.MustOfSlice(vals).MustView([]int64{-1, 4}, true)
+}
+
+func TestMeanAveragePrecision(t *testing.T) {
+	m := metrics.NewMeanAveragePrecision()
+
+	// Image 1: one true positive and one false positive of class 0.
+	err := m.Update(
+		boxes(0, 0, 10, 10, 20, 20, 30, 30),
+		ts.MustOfSlice([]float64{0.9, 0.8}),
+		ts.MustOfSlice([]int64{0, 0}),
+		boxes(0, 0, 10, 10),
+		ts.MustOfSlice([]int64{0}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Image 2: a missed box of class 0 and a true positive of class 1.
+	err = m.Update(
+		boxes(1, 1, 11, 11),
+		ts.MustOfSlice([]float64{0.7}),
+		ts.MustOfSlice([]int64{1}),
+		boxes(50, 50, 60, 60, 0, 0, 10, 10),
+		ts.MustOfSlice([]int64{0, 1}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aps := m.PerClass()
+	// class 0: precision 1 at recall 0.5 -> AP 0.5. class 1: IoU 81/119 >= 0.5 -> AP 1.
+	assertClose(t, "AP class 0", aps[0], 0.5)
+	assertClose(t, "AP class 1", aps[1], 1.0)
+	assertClose(t, "mAP", m.Compute(), 0.75)
+
+	// IoU of class 1 box (0.68) fails at higher thresholds.
+	coco := metrics.NewMeanAveragePrecision(metrics.WithIoUThresholds([]float64{0.5, 0.75}))
+	err = coco.Update(boxes(1, 1, 11, 11), ts.MustOfSlice([]float64{0.7}), ts.MustOfSlice([]int64{1}), boxes(0, 0, 10, 10), ts.MustOfSlice([]int64{1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "mAP@[0.5, 0.75]", coco.Compute(), 0.5)
+}
@@ -0,0 +1,128 @@
+// Package metrics provides streaming evaluation metrics.
+//
+// Metrics accumulate statistics in Go memory batch by batch with `Update()`,
+// return result with `Compute()` and start over with `Reset()`. Input tensors
+// are not modified nor deleted, and all intermediate tensors are freed so that
+// no C memory is held between updates.
+//
+// Example:
+//
+//	acc := metrics.NewAccuracy(metrics.WithTopK(5))
+//	for ... {
+//		logits := model.ForwardT(x, false)
+//		if err := acc.Update(logits, y); err != nil {...}
+//		logits.MustDrop()
+//	}
+//	fmt.Printf("top-5 accuracy: %.4f\n", acc.Compute())
+package metrics
+
+import (
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// Metric is a streaming metric.
+type Metric interface {
+	// Update accumulates statistics of a batch of predictions and targets.
+	Update(preds, targets *ts.Tensor) error
+	// Compute returns metric value of all accumulated batches. It returns NaN
+	// if metric is undefined, e.g. no batch has been accumulated.
+	Compute() float64
+	// Reset clears accumulated statistics.
+	Reset()
+}
+
+// Average is a method to average per-class metrics.
+type Average int
+
+const (
+	AverageMacro    Average = iota // unweighted mean of per-class metrics
+	AverageMicro                   // metric of total true positives, false positives and false negatives
+	AverageWeighted                // mean of per-class metrics weighted by class support
+)
+
+type metricOptions struct {
+	TopK          int
+	IgnoreIndex   int64
+	Average       Average
+	IoUThresholds []float64
+}
+
+type MetricOption func(*metricOptions)
+
+// WithTopK sets number of top predictions considered correct in Accuracy. Default=1.
+func WithTopK(k int) MetricOption {
+	return func(o *metricOptions) {
+		o.TopK = k
+	}
+}
+
+// WithIgnoreIndex sets target value which is ignored. Default=-100.
+func WithIgnoreIndex(val int64) MetricOption {
+	return func(o *metricOptions) {
+		o.IgnoreIndex = val
+	}
+}
+
+// WithAverage sets averaging method of Precision, Recall and F1Score. Default=AverageMacro.
+func WithAverage(average Average) MetricOption {
+	return func(o *metricOptions) {
+		o.Average = average
+	}
+}
+
+// WithIoUThresholds sets IoU thresholds of MeanAveragePrecision. Default=[0.5].
+// Use `COCOIoUThresholds()` for COCO style mAP@[0.5:0.95].
+func WithIoUThresholds(vals []float64) MetricOption {
+	return func(o *metricOptions) {
+		o.IoUThresholds = vals
+	}
+}
+
+func defaultMetricOptions() *metricOptions {
+	return &metricOptions{
+		TopK:          1,
+		IgnoreIndex:   -100,
+		Average:       AverageMacro,
+		IoUThresholds: []float64{0.5},
+	}
+}
+
+func newMetricOptions(opts ...MetricOption) *metricOptions {
+	options := defaultMetricOptions()
+	for _, o := range opts {
+		o(options)
+	}
+
+	return options
+}
+
+// float64s copies tensor values to Go memory. Input tensor is not deleted.
+func float64s(x *ts.Tensor) []float64 {
+	x1 := x.MustDetach(false).MustTo(gotch.CPU, true).MustTotype(gotch.Double, true).MustContiguous(true)
+	numel := x1.Numel()
+	vals := make([]float64, numel)
+	x1.MustCopyData(vals, numel)
+	x1.MustDrop()
+
+	return vals
+}
+
+// int64s copies tensor values to Go memory. Input tensor is not deleted.
+func int64s(x *ts.Tensor) []int64 {
+	x1 := x.MustDetach(false).MustTo(gotch.CPU, true).MustTotype(gotch.Int64, true).MustContiguous(true)
+	numel := x1.Numel()
+	vals := make([]int64, numel)
+	x1.MustCopyData(vals, numel)
+	x1.MustDrop()
+
+	return vals
+}
+
+// scalar returns value of a single element tensor and deletes it.
+func scalar(x *ts.Tensor) float64 {
+	v := float64s(x)[0]
+	x.MustDrop()
+
+	return v
+}
@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// scoreAccumulator accumulates scores and targets of binary or multi-class
+// classification for ranking metrics.
+type scoreAccumulator struct {
+	numClasses int       // 0 for binary classification
+	scores     []float64 // [N] or [N, C] row-major
+	targets    []int64
+}
+
+func (a *scoreAccumulator) update(name string, scores, targets *ts.Tensor) error {
+	dims := scores.MustSize()
+	tdims := targets.MustSize()
+	if len(tdims) != 1 || len(dims) < 1 || len(dims) > 2 || dims[0] != tdims[0] {
+		err := fmt.Errorf("%s.Update() failed: expected scores of shape [N] or [N, C] and targets of shape [N]. Got %v and %v", name, dims, tdims)
+		return err
+	}
+
+	numClasses := 0
+	if len(dims) == 2 {
+		numClasses = int(dims[1])
+	}
+	if len(a.targets) > 0 && numClasses != a.numClasses {
+		err := fmt.Errorf("%s.Update() failed: number of classes changed from %v to %v", name, a.numClasses, numClasses)
+		return err
+	}
+	a.numClasses = numClasses
+
+	a.scores = append(a.scores, float64s(scores)...)
+	a.targets = append(a.targets, int64s(targets)...)
+
+	return nil
+}
+
+// compute calculates a binary ranking metric, or its macro average over
+// classes (one-vs-rest) for multi-class classification.
+func (a *scoreAccumulator) compute(fn func(scores []float64, positives []bool) float64) float64 {
+	if len(a.targets) == 0 {
+		return math.NaN()
+	}
+
+	if a.numClasses == 0 {
+		positives := make([]bool, len(a.targets))
+		for i, t := range a.targets {
+			positives[i] = t == 1
+		}
+		return fn(a.scores, positives)
+	}
+
+	var (
+		sum    float64
+		nvalid int
+	)
+	n := len(a.targets)
+	scores := make([]float64, n)
+	positives := make([]bool, n)
+	for c := 0; c < a.numClasses; c++ {
+		for i := 0; i < n; i++ {
+			scores[i] = a.scores[i*a.numClasses+c]
+			positives[i] = a.targets[i] == int64(c)
+		}
+		v := fn(scores, positives)
+		if !math.IsNaN(v) {
+			sum += v
+			nvalid++
+		}
+	}
+	if nvalid == 0 {
+		return math.NaN()
+	}
+
+	return sum / float64(nvalid)
+}
+
+func (a *scoreAccumulator) reset() {
+	a.scores = nil
+	a.targets = nil
+}
+
+// rankedCounts sorts samples by descending score and returns cumulative true
+// and false positives at each distinct score threshold.
+func rankedCounts(scores []float64, positives []bool) (tps, fps []float64) {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+
+	var tp, fp float64
+	for i, idx := range order {
+		if positives[idx] {
+			tp++
+		} else {
+			fp++
+		}
+		// Thresholds are only between distinct scores.
+		if i == len(order)-1 || scores[order[i+1]] != scores[idx] {
+			tps = append(tps, tp)
+			fps = append(fps, fp)
+		}
+	}
+
+	return tps, fps
+}
+
+// rocAUC calculates area under ROC curve with trapezoidal rule. It returns
+// NaN if there is only one class.
+func rocAUC(scores []float64, positives []bool) float64 {
+	tps, fps := rankedCounts(scores, positives)
+	npos, nneg := tps[len(tps)-1], fps[len(fps)-1]
+	if npos == 0 || nneg == 0 {
+		return math.NaN()
+	}
+
+	var area, prevTPR, prevFPR float64
+	for i := range tps {
+		tpr, fpr := tps[i]/npos, fps[i]/nneg
+		area += (fpr - prevFPR) * (tpr + prevTPR) / 2
+		prevTPR, prevFPR = tpr, fpr
+	}
+
+	return area
+}
+
+// averagePrecision calculates sum over thresholds of (R_n - R_{n-1}) * P_n.
+// It returns NaN if there is no positive sample.
+func averagePrecision(scores []float64, positives []bool) float64 {
+	tps, fps := rankedCounts(scores, positives)
+	npos := tps[len(tps)-1]
+	if npos == 0 {
+		return math.NaN()
+	}
+
+	var ap, prevRecall float64
+	for i := range tps {
+		recall := tps[i] / npos
+		precision := tps[i] / (tps[i] + fps[i])
+		ap += (recall - prevRecall) * precision
+		prevRecall = recall
+	}
+
+	return ap
+}
+
+// AUROC is the area under receiver operating characteristic curve.
+//
+// For multi-class scores, it is the macro average of one-vs-rest AUROC over
+// classes which have both positive and negative samples.
+//
+// NOTE. all scores are kept in memory until Reset().
+type AUROC struct {
+	acc scoreAccumulator
+}
+
+var _ Metric = (*AUROC)(nil)
+
+// NewAUROC creates AUROC metric.
+func NewAUROC() *AUROC {
+	return &AUROC{}
+}
+
+// Update implements Metric interface.
+//
+// - scores: scores (probabilities or logits) of shape [N] for binary
+// classification, or [N, C] for multi-class classification.
+// - targets: labels 0/1 for binary classification, or class indices, of shape [N].
+func (m *AUROC) Update(scores, targets *ts.Tensor) error {
+	return m.acc.update("AUROC", scores, targets)
+}
+
+// Compute implements Metric interface.
+func (m *AUROC) Compute() float64 {
+	return m.acc.compute(rocAUC)
+}
+
+// Reset implements Metric interface.
+func (m *AUROC) Reset() {
+	m.acc.reset()
+}
+
+// AveragePrecision is the area under precision-recall curve (PR-AUC) computed
+// as average precision, i.e. sum over thresholds of (R_n - R_{n-1}) * P_n.
+//
+// For multi-class scores, it is the macro average of one-vs-rest average
+// precision over classes which have positive samples.
+//
+// NOTE. all scores are kept in memory until Reset().
+type AveragePrecision struct {
+	acc scoreAccumulator
+}
+
+var _ Metric = (*AveragePrecision)(nil)
+
+// NewAveragePrecision creates AveragePrecision metric.
+func NewAveragePrecision() *AveragePrecision {
+	return &AveragePrecision{}
+}
+
+// Update implements Metric interface. See `AUROC.Update()` for inputs.
+func (m *AveragePrecision) Update(scores, targets *ts.Tensor) error {
+	return m.acc.update("AveragePrecision", scores, targets)
+}
+
+// Compute implements Metric interface.
+func (m *AveragePrecision) Compute() float64 {
+	return m.acc.compute(averagePrecision)
+}
+
+// Reset implements Metric interface.
+func (m *AveragePrecision) Reset() {
+	m.acc.reset()
+}
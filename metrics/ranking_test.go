@@ -0,0 +1,43 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/sugarme/gotch/metrics"
+	"github.com/sugarme/gotch/ts"
+)
+
+func TestAUROC(t *testing.T) {
+	auroc := metrics.NewAUROC()
+	// Update in two batches.
+	if err := auroc.Update(ts.MustOfSlice([]float64{0.1, 0.4}), ts.MustOfSlice([]int64{0, 0})); err != nil {
+		t.Fatal(err)
+	}
+	if err := auroc.Update(ts.MustOfSlice([]float64{0.35, 0.8}), ts.MustOfSlice([]int64{1, 1})); err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "AUROC", auroc.Compute(), 0.75)
+
+	ap := metrics.NewAveragePrecision()
+	if err := ap.Update(ts.MustOfSlice([]float64{0.1, 0.4, 0.35, 0.8}), ts.MustOfSlice([]int64{0, 0, 1, 1})); err != nil {
+		t.Fatal(err)
+	}
+	// Ranked: 0.8(+), 0.4(-), 0.35(+), 0.1(-): AP = 0.5*1 + 0.5*2/3
+	assertClose(t, "AveragePrecision", ap.Compute(), 0.5+1.0/3.0)
+}
+
+func TestAUROC_MultiClass(t *testing.T) {
+	scores := ts.MustOfSlice([]float64{
+		0.8, 0.2,
+		0.3, 0.7,
+		0.6, 0.4,
+	}).MustView([]int64{3, 2}, true)
+	targets := ts.MustOfSlice([]int64{0, 1, 1})
+
+	auroc := metrics.NewAUROC()
+	if err := auroc.Update(scores, targets); err != nil {
+		t.Fatal(err)
+	}
+	// class 0: 1.0, class 1: 1.0
+	assertClose(t, "AUROC", auroc.Compute(), 1.0)
+}
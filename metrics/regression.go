@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// regressionStats accumulates sums of errors and targets.
+type regressionStats struct {
+	n           float64
+	sumAbsErr   float64
+	sumSqErr    float64
+	sumTarget   float64
+	sumSqTarget float64
+}
+
+func (s *regressionStats) update(name string, preds, targets *ts.Tensor) error {
+	dims := preds.MustSize()
+	tdims := targets.MustSize()
+	if !reflect.DeepEqual(dims, tdims) {
+		err := fmt.Errorf("%s.Update() failed: shape mismatched between predictions %v and targets %v", name, dims, tdims)
+		return err
+	}
+
+	p := preds.MustDetach(false).MustTotype(gotch.Double, true)
+	t := targets.MustDetach(false).MustTo(p.MustDevice(), true).MustTotype(gotch.Double, true)
+	diff := p.MustSub(t, true)
+
+	s.n += float64(t.Numel())
+	s.sumAbsErr += scalar(diff.MustAbs(false).MustSum(gotch.Double, true))
+	s.sumSqErr += scalar(diff.MustSquare(true).MustSum(gotch.Double, true))
+	s.sumTarget += scalar(t.MustSum(gotch.Double, false))
+	s.sumSqTarget += scalar(t.MustSquare(true).MustSum(gotch.Double, true))
+
+	return nil
+}
+
+func (s *regressionStats) reset() {
+	*s = regressionStats{}
+}
+
+// MeanAbsoluteError is the mean of absolute errors (MAE).
+type MeanAbsoluteError struct {
+	stats regressionStats
+}
+
+var _ Metric = (*MeanAbsoluteError)(nil)
+
+// NewMeanAbsoluteError creates MeanAbsoluteError metric.
+func NewMeanAbsoluteError() *MeanAbsoluteError {
+	return &MeanAbsoluteError{}
+}
+
+// Update implements Metric interface. Predictions and targets have same shape.
+func (m *MeanAbsoluteError) Update(preds, targets *ts.Tensor) error {
+	return m.stats.update("MeanAbsoluteError", preds, targets)
+}
+
+// Compute implements Metric interface.
+func (m *MeanAbsoluteError) Compute() float64 {
+	if m.stats.n == 0 {
+		return math.NaN()
+	}
+
+	return m.stats.sumAbsErr / m.stats.n
+}
+
+// Reset implements Metric interface.
+func (m *MeanAbsoluteError) Reset() {
+	m.stats.reset()
+}
+
+// RootMeanSquaredError is the square root of mean of squared errors (RMSE).
+type RootMeanSquaredError struct {
+	stats regressionStats
+}
+
+var _ Metric = (*RootMeanSquaredError)(nil)
+
+// NewRootMeanSquaredError creates RootMeanSquaredError metric.
+func NewRootMeanSquaredError() *RootMeanSquaredError {
+	return &RootMeanSquaredError{}
+}
+
+// Update implements Metric interface. Predictions and targets have same shape.
+func (m *RootMeanSquaredError) Update(preds, targets *ts.Tensor) error {
+	return m.stats.update("RootMeanSquaredError", preds, targets)
+}
+
+// Compute implements Metric interface.
+func (m *RootMeanSquaredError) Compute() float64 {
+	if m.stats.n == 0 {
+		return math.NaN()
+	}
+
+	return math.Sqrt(m.stats.sumSqErr / m.stats.n)
+}
+
+// Reset implements Metric interface.
+func (m *RootMeanSquaredError) Reset() {
+	m.stats.reset()
+}
+
+// R2Score is the coefficient of determination, i.e. 1 - SSres/SStot.
+type R2Score struct {
+	stats regressionStats
+}
+
+var _ Metric = (*R2Score)(nil)
+
+// NewR2Score creates R2Score metric.
+func NewR2Score() *R2Score {
+	return &R2Score{}
+}
+
+// Update implements Metric interface. Predictions and targets have same shape.
+func (m *R2Score) Update(preds, targets *ts.Tensor) error {
+	return m.stats.update("R2Score", preds, targets)
+}
+
+// Compute implements Metric interface. It returns NaN if targets are constant.
+func (m *R2Score) Compute() float64 {
+	s := m.stats
+	if s.n == 0 {
+		return math.NaN()
+	}
+	ssTot := s.sumSqTarget - s.sumTarget*s.sumTarget/s.n
+	if ssTot <= 0 {
+		return math.NaN()
+	}
+
+	return 1 - s.sumSqErr/ssTot
+}
+
+// Reset implements Metric interface.
+func (m *R2Score) Reset() {
+	m.stats.reset()
+}
+
+// Perplexity is the exponential of mean negative log likelihood of targets,
+// e.g. of a language model.
+type Perplexity struct {
+	ignoreIndex int64
+	sumNLL      float64
+	count       float64
+}
+
+var _ Metric = (*Perplexity)(nil)
+
+// NewPerplexity creates Perplexity metric. Options: WithIgnoreIndex, e.g. for
+// padding tokens.
+func NewPerplexity(opts ...MetricOption) *Perplexity {
+	options := newMetricOptions(opts...)
+
+	return &Perplexity{
+		ignoreIndex: options.IgnoreIndex,
+	}
+}
+
+// Update implements Metric interface.
+//
+// - logits: scores of shape [..., C], e.g. [B, T, C].
+// - targets: class indices of shape [...], e.g. [B, T].
+func (m *Perplexity) Update(logits, targets *ts.Tensor) error {
+	dims := logits.MustSize()
+	tdims := targets.MustSize()
+	if len(dims) != len(tdims)+1 || !reflect.DeepEqual(dims[:len(tdims)], tdims) {
+		err := fmt.Errorf("Perplexity.Update() failed: expected logits of shape [..., C] and targets of shape [...]. Got %v and %v", dims, tdims)
+		return err
+	}
+
+	numClasses := dims[len(dims)-1]
+	x := logits.MustDetach(false).MustReshape([]int64{-1, numClasses}, true).MustTotype(gotch.Double, true)
+	t := targets.MustTo(x.MustDevice(), false).MustReshape([]int64{-1}, true)
+	undefined := ts.NewTensor()
+
+	m.sumNLL += scalar(x.MustCrossEntropyLoss(t, undefined, 2, m.ignoreIndex, 0.0, true))
+	m.count += scalar(t.MustNe(ts.IntScalar(m.ignoreIndex), true).MustSum(gotch.Int64, true))
+	undefined.MustDrop()
+
+	return nil
+}
+
+// Compute implements Metric interface.
+func (m *Perplexity) Compute() float64 {
+	if m.count == 0 {
+		return math.NaN()
+	}
+
+	return math.Exp(m.sumNLL / m.count)
+}
+
+// Reset implements Metric interface.
+func (m *Perplexity) Reset() {
+	m.sumNLL = 0
+	m.count = 0
+}
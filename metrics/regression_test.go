@@ -0,0 +1,41 @@
+package metrics_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/metrics"
+	"github.com/sugarme/gotch/ts"
+)
+
+func TestRegressionMetrics(t *testing.T) {
+	preds := ts.MustOfSlice([]float64{2.5, 0.0, 2.0, 8.0})
+	targets := ts.MustOfSlice([]float64{3.0, -0.5, 2.0, 7.0})
+
+	mae := metrics.NewMeanAbsoluteError()
+	rmse := metrics.NewRootMeanSquaredError()
+	r2 := metrics.NewR2Score()
+	for _, m := range []metrics.Metric{mae, rmse, r2} {
+		if err := m.Update(preds, targets); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertClose(t, "MAE", mae.Compute(), 0.5)
+	assertClose(t, "RMSE", rmse.Compute(), math.Sqrt(0.375))
+	// SSres = 1.5, mean target = 2.875, SStot = 29.1875
+	assertClose(t, "R2", r2.Compute(), 1-1.5/29.1875)
+}
+
+func TestPerplexity(t *testing.T) {
+	// Uniform logits over 4 classes: perplexity = 4.
+	logits := ts.MustZeros([]int64{2, 3, 4}, gotch.Float, gotch.CPU)
+	targets := ts.MustOfSlice([]int64{0, 1, 2, 3, -100, -100}).MustView([]int64{2, 3}, true)
+
+	m := metrics.NewPerplexity()
+	if err := m.Update(logits, targets); err != nil {
+		t.Fatal(err)
+	}
+	assertClose(t, "Perplexity", m.Compute(), 4.0)
+}
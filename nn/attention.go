@@ -0,0 +1,306 @@
+package nn
+
+// Multi-head attention layer.
+
+import (
+	"log"
+	"math"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// MultiheadAttentionConfig is configuration for MultiheadAttention layer.
+type MultiheadAttentionConfig struct {
+	Dropout    float64 // dropout probability on attention weights
+	Bias       bool    // whether to add bias to input/output projections
+	KDim       int64   // number of features of key. Default=0 means embedDim
+	VDim       int64   // number of features of value. Default=0 means embedDim
+	BatchFirst bool    // if true, input/output are [batch, seq, feature]. Otherwise, [seq, batch, feature]
+}
+
+// DefaultMultiheadAttentionConfig creates default MultiheadAttentionConfig.
+func DefaultMultiheadAttentionConfig() *MultiheadAttentionConfig {
+	return &MultiheadAttentionConfig{
+		Dropout:    0.0,
+		Bias:       true,
+		KDim:       0,
+		VDim:       0,
+		BatchFirst: false,
+	}
+}
+
+// MultiheadAttention is a multi-head attention layer.
+//
+// Variables are named as Pytorch `torch.nn.MultiheadAttention` state dict, i.e.
+// "in_proj_weight", "in_proj_bias", "out_proj.weight", "out_proj.bias". If key
+// or value has different number of features, separate "q_proj_weight",
+// "k_proj_weight", "v_proj_weight" are used instead of "in_proj_weight".
+//
+// Ref. https://pytorch.org/docs/stable/generated/torch.nn.MultiheadAttention.html
+type MultiheadAttention struct {
+	EmbedDim int64
+	NumHeads int64
+	HeadDim  int64
+
+	InProjWs *ts.Tensor // [3*embedDim, embedDim]. Nil if kdim/vdim differ from embedDim.
+	QProjWs  *ts.Tensor // [embedDim, embedDim]. Only used if kdim/vdim differ from embedDim.
+	KProjWs  *ts.Tensor // [embedDim, kdim]
+	VProjWs  *ts.Tensor // [embedDim, vdim]
+	InProjBs *ts.Tensor // [3*embedDim]. Optional
+	OutProj  *Linear
+
+	config *MultiheadAttentionConfig
+}
+
+// NewMultiheadAttention creates a MultiheadAttention layer.
+//
+// NOTE. embedDim must be divisible by numHeads.
+func NewMultiheadAttention(vs *Path, embedDim, numHeads int64, cfg *MultiheadAttentionConfig) *MultiheadAttention {
+	if embedDim%numHeads != 0 {
+		log.Fatalf("NewMultiheadAttention() failed: embedDim (%v) must be divisible by numHeads (%v)\n", embedDim, numHeads)
+	}
+
+	kdim, vdim := cfg.KDim, cfg.VDim
+	if kdim == 0 {
+		kdim = embedDim
+	}
+	if vdim == 0 {
+		vdim = embedDim
+	}
+
+	m := &MultiheadAttention{
+		EmbedDim: embedDim,
+		NumHeads: numHeads,
+		HeadDim:  embedDim / numHeads,
+		config:   cfg,
+	}
+
+	// Xavier uniform initialization as Pytorch.
+	xavierInit := func(fanOut, fanIn int64) Init {
+		bound := math.Sqrt(6.0 / float64(fanIn+fanOut))
+		return NewUniformInit(-bound, bound)
+	}
+
+	if kdim == embedDim && vdim == embedDim {
+		m.InProjWs = vs.MustNewVar("in_proj_weight", []int64{3 * embedDim, embedDim}, xavierInit(3*embedDim, embedDim))
+	} else {
+		m.QProjWs = vs.MustNewVar("q_proj_weight", []int64{embedDim, embedDim}, xavierInit(embedDim, embedDim))
+		m.KProjWs = vs.MustNewVar("k_proj_weight", []int64{embedDim, kdim}, xavierInit(embedDim, kdim))
+		m.VProjWs = vs.MustNewVar("v_proj_weight", []int64{embedDim, vdim}, xavierInit(embedDim, vdim))
+	}
+
+	if cfg.Bias {
+		m.InProjBs = vs.MustZeros("in_proj_bias", []int64{3 * embedDim})
+	}
+
+	outProjCfg := DefaultLinearConfig()
+	outProjCfg.Bias = cfg.Bias
+	outProjCfg.BsInit = NewConstInit(0.0)
+	m.OutProj = NewLinear(vs.Sub("out_proj"), embedDim, embedDim, outProjCfg)
+
+	return m
+}
+
+// AttentionOptions are options for attention forward pass.
+type AttentionOptions struct {
+	// KeyPaddingMask is [batch, srcLen] mask of keys to be ignored. If bool, true
+	// values are ignored. If float, values are added to attention scores.
+	KeyPaddingMask *ts.Tensor
+	// AttnMask is [tgtLen, srcLen] or [batch*numHeads, tgtLen, srcLen] mask
+	// preventing attention to certain positions. If bool, true values are not allowed
+	// to attend. If float, values are added to attention scores.
+	AttnMask *ts.Tensor
+	// IsCausal applies a causal mask, i.e. position i can not attend to positions j > i.
+	IsCausal bool
+	// NeedWeights returns attention weights averaged over heads.
+	NeedWeights bool
+}
+
+type AttentionOpt func(*AttentionOptions)
+
+func DefaultAttentionOptions() *AttentionOptions {
+	return &AttentionOptions{
+		KeyPaddingMask: nil,
+		AttnMask:       nil,
+		IsCausal:       false,
+		NeedWeights:    false,
+	}
+}
+
+// WithKeyPaddingMask sets key padding mask option.
+func WithKeyPaddingMask(mask *ts.Tensor) AttentionOpt {
+	return func(o *AttentionOptions) {
+		o.KeyPaddingMask = mask
+	}
+}
+
+// WithAttnMask sets attention mask option.
+func WithAttnMask(mask *ts.Tensor) AttentionOpt {
+	return func(o *AttentionOptions) {
+		o.AttnMask = mask
+	}
+}
+
+// WithCausalMask sets causal mask option.
+func WithCausalMask(v bool) AttentionOpt {
+	return func(o *AttentionOptions) {
+		o.IsCausal = v
+	}
+}
+
+// WithNeedWeights sets whether attention weights are returned.
+func WithNeedWeights(v bool) AttentionOpt {
+	return func(o *AttentionOptions) {
+		o.NeedWeights = v
+	}
+}
+
+// ForwardT computes attention of query on key and value.
+//
+// Shapes (BatchFirst=false, swap first 2 dimensions otherwise):
+// - query: [tgtLen, batch, embedDim]
+// - key: [srcLen, batch, kdim]
+// - value: [srcLen, batch, vdim]
+// - output: [tgtLen, batch, embedDim]
+// - weights: [batch, tgtLen, srcLen] averaged over heads. Nil if `WithNeedWeights(true)` is not set.
+func (m *MultiheadAttention) ForwardT(query, key, value *ts.Tensor, train bool, opts ...AttentionOpt) (output, weights *ts.Tensor) {
+	o := DefaultAttentionOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// Working layout is [batch, seq, feature].
+	if !m.config.BatchFirst {
+		query = query.MustTranspose(0, 1, false)
+		key = key.MustTranspose(0, 1, false)
+		value = value.MustTranspose(0, 1, false)
+	} else {
+		query = query.MustShallowClone()
+		key = key.MustShallowClone()
+		value = value.MustShallowClone()
+	}
+
+	size := query.MustSize()
+	batch, tgtLen := size[0], size[1]
+	srcLen := key.MustSize()[1]
+
+	q := m.project(query, 0, true)
+	k := m.project(key, 1, true)
+	v := m.project(value, 2, true)
+
+	// scores: [batch, numHeads, tgtLen, srcLen]
+	kT := k.MustTranspose(-2, -1, true)
+	scores := q.MustMatmul(kT, true).MustDivScalar(ts.FloatScalar(math.Sqrt(float64(m.HeadDim))), true)
+	kT.MustDrop()
+
+	if o.AttnMask != nil {
+		mask := o.AttnMask
+		if mask.Dim() == 3 {
+			mask = mask.MustView([]int64{batch, m.NumHeads, tgtLen, srcLen}, false)
+		} else {
+			mask = mask.MustShallowClone()
+		}
+		scores = applyMask(scores, mask)
+		mask.MustDrop()
+	}
+
+	if o.IsCausal {
+		mask := causalMask(tgtLen, srcLen, scores.MustDevice())
+		scores = applyMask(scores, mask)
+		mask.MustDrop()
+	}
+
+	if o.KeyPaddingMask != nil {
+		mask := o.KeyPaddingMask.MustView([]int64{batch, 1, 1, srcLen}, false)
+		scores = applyMask(scores, mask)
+		mask.MustDrop()
+	}
+
+	attn := scores.MustSoftmax(-1, scores.DType(), true)
+	if o.NeedWeights {
+		weights = attn.MustMeanDim([]int64{1}, false, attn.DType(), false)
+	}
+	if m.config.Dropout > 0 {
+		attn = dropout(attn, m.config.Dropout, train)
+	}
+
+	// [batch, numHeads, tgtLen, headDim] -> [batch, tgtLen, embedDim]
+	out := attn.MustMatmul(v, true).MustTranspose(1, 2, true).MustReshape([]int64{batch, tgtLen, m.EmbedDim}, true)
+	v.MustDrop()
+
+	output = m.OutProj.Forward(out)
+	out.MustDrop()
+
+	if !m.config.BatchFirst {
+		output = output.MustTranspose(0, 1, true)
+	}
+
+	return output, weights
+}
+
+// project applies input projection i (0: query, 1: key, 2: value) to x [batch, seq, feature]
+// and returns [batch, numHeads, seq, headDim].
+func (m *MultiheadAttention) project(x *ts.Tensor, i int64, del bool) *ts.Tensor {
+	var ws, bs *ts.Tensor
+	if m.InProjWs != nil {
+		ws = m.InProjWs.MustNarrow(0, i*m.EmbedDim, m.EmbedDim, false)
+	} else {
+		ws = []*ts.Tensor{m.QProjWs, m.KProjWs, m.VProjWs}[i].MustShallowClone()
+	}
+	if m.InProjBs != nil {
+		bs = m.InProjBs.MustNarrow(0, i*m.EmbedDim, m.EmbedDim, false)
+	}
+
+	wsT := ws.MustT(true)
+	out := x.MustMatmul(wsT, false)
+	wsT.MustDrop()
+	if bs != nil {
+		out = out.MustAdd(bs, true)
+		bs.MustDrop()
+	}
+	if del {
+		x.MustDrop()
+	}
+
+	size := out.MustSize()
+	return out.MustView([]int64{size[0], size[1], m.NumHeads, m.HeadDim}, true).MustTranspose(1, 2, true)
+}
+
+// applyMask applies bool (masked fill with -inf) or float (added) mask to attention scores.
+// Input scores tensor is deleted.
+func applyMask(scores, mask *ts.Tensor) *ts.Tensor {
+	if mask.DType() == gotch.Bool {
+		return scores.MustMaskedFill(mask, ts.FloatScalar(math.Inf(-1)), true)
+	}
+
+	return scores.MustAdd(mask, true)
+}
+
+// causalMask returns [tgtLen, srcLen] bool mask with true above main diagonal.
+func causalMask(tgtLen, srcLen int64, device gotch.Device) *ts.Tensor {
+	ones := ts.MustOnes([]int64{tgtLen, srcLen}, gotch.Bool, device)
+	return ones.MustTriu(1, true)
+}
+
+// GenerateSquareSubsequentMask creates a [size, size] float causal mask, i.e.
+// -inf above main diagonal and 0 elsewhere, to be used as attention mask.
+func GenerateSquareSubsequentMask(size int64, device gotch.Device) *ts.Tensor {
+	mask := causalMask(size, size, device)
+	zeros := ts.MustZeros([]int64{size, size}, gotch.Float, device)
+	retVal := zeros.MustMaskedFill(mask, ts.FloatScalar(math.Inf(-1)), true)
+	mask.MustDrop()
+
+	return retVal
+}
+
+// dropout applies dropout. Input tensor is deleted.
+func dropout(x *ts.Tensor, p float64, train bool) *ts.Tensor {
+	if p == 0 || !train {
+		return x
+	}
+	retVal := ts.MustDropout(x, p, train)
+	x.MustDrop()
+
+	return retVal
+}
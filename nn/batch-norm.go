@@ -35,17 +35,32 @@ type BatchNorm struct {
 	Ws          *ts.Tensor
 	Bs          *ts.Tensor
 	Nd          uint
+
+	hook *hookPoint
 }
 
 // NewBatchNorm creates a new BatchNorm layer
 func NewBatchNorm(vs *Path, nd uint, outDim int64, config *BatchNormConfig) *BatchNorm {
-	return &BatchNorm{
+	bn := &BatchNorm{
 		config:      config,
 		RunningMean: vs.MustZerosNoTrain("running_mean", []int64{outDim}),
 		RunningVar:  vs.MustOnesNoTrain("running_var", []int64{outDim}),
 		Ws:          vs.MustNewVar("weight", []int64{outDim}, config.WsInit),
 		Bs:          vs.MustNewVar("bias", []int64{outDim}, config.BsInit),
+		hook:        newHookPoint(vs),
 	}
+
+	// Momentum is looked up by `UpdateBatchNorm()`.
+	vs.varstore.Lock()
+	vs.varstore.bnMomentums[vs.getpath("running_mean")] = config.Momentum
+	vs.varstore.Unlock()
+
+	return bn
+}
+
+// Config returns config of BatchNorm layer.
+func (bn *BatchNorm) Config() *BatchNormConfig {
+	return bn.config
 }
 
 // Applies Batch Normalization over a three dimension input.
@@ -76,7 +91,12 @@ func BatchNorm3D(vs *Path, outDim int64, config *BatchNormConfig) *BatchNorm {
 // ==========================================
 
 func (bn *BatchNorm) ForwardT(xs *ts.Tensor, train bool) (retVal *ts.Tensor) {
+	return bn.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return bn.forward(xs, train)
+	})
+}
 
+func (bn *BatchNorm) forward(xs *ts.Tensor, train bool) *ts.Tensor {
 	dim := xs.Dim()
 
 	if bn.Nd == 1 && dim != 2 && dim != 3 {
@@ -88,7 +108,6 @@ func (bn *BatchNorm) ForwardT(xs *ts.Tensor, train bool) (retVal *ts.Tensor) {
 	}
 
 	return ts.MustBatchNorm(xs, bn.Ws, bn.Bs, bn.RunningMean, bn.RunningVar, train, bn.config.Momentum, bn.config.Eps, bn.config.CudnnEnable)
-
 }
 
 // Forward forwards inputs through the module.
@@ -96,15 +115,5 @@ func (bn *BatchNorm) ForwardT(xs *ts.Tensor, train bool) (retVal *ts.Tensor) {
 // This forwarding will update BatchNorm weight by default (training=true).
 // Wrap module with tensor.NoGrad() when running model inference mode.
 func (bn *BatchNorm) Forward(xs *ts.Tensor) (retVal *ts.Tensor) {
-	dim := xs.Dim()
-
-	if bn.Nd == 1 && dim != 2 && dim != 3 {
-		log.Fatalf("Expected an input tensor with 2 or 3 dims, got %v\n", xs.MustSize())
-	}
-
-	if bn.Nd > 1 && int(dim) != int(bn.Nd)+2 {
-		log.Fatalf("Expected an input tensor with %v dims, got %v\n", bn.Nd+2, xs.MustSize())
-	}
-
-	return ts.MustBatchNorm(xs, bn.Ws, bn.Bs, bn.RunningMean, bn.RunningVar, true, bn.config.Momentum, bn.config.Eps, bn.config.CudnnEnable)
+	return bn.ForwardT(xs, true)
 }
@@ -0,0 +1,321 @@
+package nn
+
+// Training checkpoint to save and resume training.
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+const (
+	checkpointFormat = "gotch-checkpoint"
+
+	// Prefixes of tensor names and metadata keys in checkpoint file.
+	ckptModelPrefix     = "model."
+	ckptOptimizerPrefix = "optimizer."
+	ckptSchedulerPrefix = "scheduler."
+	ckptUserPrefix      = "user."
+	ckptTorchRngKey     = "rng.torch"
+)
+
+// Checkpoint is a full training state which is saved to or restored from a
+// single safetensors file, i.e. model weights, optimizer state, schedulers
+// state, random generator states, epoch/step counters and user metadata.
+//
+// NOTE. per-parameter state is only saved for native optimizers (see
+// `NativeConfig()`). For libtorch optimizers, only learning rates are saved.
+//
+// Example:
+//
+//	ckpt := nn.NewCheckpoint(vs, opt, scheduler)
+//	ckpt.Rand = randSrc
+//	// Resume if any
+//	if _, err := os.Stat(path); err == nil {
+//		if err := ckpt.Load(path); err != nil {...}
+//	}
+//	for epoch := ckpt.Epoch; epoch < epochs; epoch++ {
+//		...
+//		ckpt.Epoch = epoch + 1
+//		if err := ckpt.Save(path); err != nil {...}
+//	}
+type Checkpoint struct {
+	VarStore   *VarStore
+	Optimizer  *Optimizer        // Optional
+	Schedulers []*LRScheduler    // Optional
+	Rand       *gotch.RandSource // Optional. Go random source to be saved and restored.
+
+	Epoch    int
+	Step     int
+	Metadata map[string]string
+}
+
+// NewCheckpoint creates a Checkpoint.
+func NewCheckpoint(vs *VarStore, opt *Optimizer, schedulers ...*LRScheduler) *Checkpoint {
+	return &Checkpoint{
+		VarStore:   vs,
+		Optimizer:  opt,
+		Schedulers: schedulers,
+		Metadata:   make(map[string]string),
+	}
+}
+
+// Save writes checkpoint to a file.
+//
+// File is written to a temporary file in the same directory and then renamed
+// so that an existing checkpoint is not corrupted if saving is interrupted.
+func (c *Checkpoint) Save(path string) error {
+	var namedTensors []ts.NamedTensor
+	metadata := map[string]string{
+		"format": checkpointFormat,
+		"epoch":  strconv.Itoa(c.Epoch),
+		"step":   strconv.Itoa(c.Step),
+	}
+
+	if c.VarStore != nil {
+		for _, nt := range c.VarStore.NamedTensors() {
+			namedTensors = append(namedTensors, ts.NamedTensor{Name: ckptModelPrefix + nt.Name, Tensor: nt.Tensor})
+		}
+	}
+
+	// NOTE. tensors other than model variables are created or shallow-cloned here.
+	nvars := len(namedTensors)
+	defer func() {
+		for _, nt := range namedTensors[nvars:] {
+			nt.Tensor.MustDrop()
+		}
+	}()
+
+	if c.Optimizer != nil {
+		var optState []ts.NamedTensor
+		if _, ok := c.Optimizer.opt.(*nativeOptimizer); ok {
+			var err error
+			optState, err = c.Optimizer.StateDict()
+			if err != nil {
+				return err
+			}
+		} else {
+			optState = []ts.NamedTensor{{Name: "param_groups.lr", Tensor: ts.MustOfSlice(c.Optimizer.GetLRs())}}
+		}
+		for _, nt := range optState {
+			namedTensors = append(namedTensors, ts.NamedTensor{Name: ckptOptimizerPrefix + nt.Name, Tensor: nt.Tensor})
+		}
+		metadata["optimizer.step_count"] = strconv.Itoa(c.Optimizer.StepCount())
+	}
+
+	for i, s := range c.Schedulers {
+		state, err := s.StateDict()
+		if err != nil {
+			err = fmt.Errorf("Checkpoint.Save() failed: %w", err)
+			return err
+		}
+		for name, values := range state {
+			namedTensors = append(namedTensors, ts.NamedTensor{
+				Name:   fmt.Sprintf("%s%d.%s", ckptSchedulerPrefix, i, name),
+				Tensor: ts.MustOfSlice(values),
+			})
+		}
+	}
+
+	rngState, err := ts.GetRngState()
+	if err != nil {
+		err = fmt.Errorf("Checkpoint.Save() failed: %w", err)
+		return err
+	}
+	namedTensors = append(namedTensors, ts.NamedTensor{Name: ckptTorchRngKey, Tensor: rngState})
+
+	if c.Rand != nil {
+		seed, count := c.Rand.State()
+		metadata["rng.go.seed"] = strconv.FormatInt(seed, 10)
+		metadata["rng.go.count"] = strconv.FormatUint(count, 10)
+	}
+
+	for k, v := range c.Metadata {
+		metadata[ckptUserPrefix+k] = v
+	}
+
+	// Write to temporary file then rename.
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		err = fmt.Errorf("Checkpoint.Save() failed: %w", err)
+		return err
+	}
+	tmpPath := f.Name()
+	f.Close()
+
+	if err := ts.WriteSafetensors(namedTensors, tmpPath, metadata); err != nil {
+		os.Remove(tmpPath)
+		err = fmt.Errorf("Checkpoint.Save() failed: %w", err)
+		return err
+	}
+	// Temp file is created with mode 0600.
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		os.Remove(tmpPath)
+		err = fmt.Errorf("Checkpoint.Save() failed: %w", err)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		err = fmt.Errorf("Checkpoint.Save() failed: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Load restores checkpoint from a file saved by `Save()`. Model weights,
+// optimizer and schedulers state are loaded to the objects of checkpoint.
+func (c *Checkpoint) Load(path string) error {
+	sf, err := ts.OpenSafetensors(path)
+	if err != nil {
+		err = fmt.Errorf("Checkpoint.Load() failed: %w", err)
+		return err
+	}
+	defer sf.Close()
+
+	metadata := sf.Metadata()
+	if metadata["format"] != checkpointFormat {
+		err := fmt.Errorf("Checkpoint.Load() failed: %q is not a gotch checkpoint file", path)
+		return err
+	}
+
+	if c.VarStore != nil {
+		if err := c.VarStore.LoadFrom(&prefixLoader{sf, ckptModelPrefix}); err != nil {
+			err = fmt.Errorf("Checkpoint.Load() failed: %w", err)
+			return err
+		}
+	}
+
+	// readTensors reads all tensors with given prefix, which is trimmed from tensor names.
+	readTensors := func(prefix string) ([]ts.NamedTensor, error) {
+		var namedTensors []ts.NamedTensor
+		for _, name := range sf.Names() {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			x, err := sf.Tensor(name, gotch.CPU)
+			if err != nil {
+				return nil, err
+			}
+			namedTensors = append(namedTensors, ts.NamedTensor{Name: strings.TrimPrefix(name, prefix), Tensor: x})
+		}
+		return namedTensors, nil
+	}
+	dropTensors := func(namedTensors []ts.NamedTensor) {
+		for _, nt := range namedTensors {
+			nt.Tensor.MustDrop()
+		}
+	}
+
+	if c.Optimizer != nil {
+		optState, err := readTensors(ckptOptimizerPrefix)
+		if err != nil {
+			err = fmt.Errorf("Checkpoint.Load() failed: %w", err)
+			return err
+		}
+		defer dropTensors(optState)
+
+		if _, ok := c.Optimizer.opt.(*nativeOptimizer); ok {
+			err = c.Optimizer.LoadStateDict(optState)
+		} else {
+			for _, nt := range optState {
+				if nt.Name == "param_groups.lr" {
+					err = c.Optimizer.opt.SetLearningRates(nt.Tensor.Float64Values())
+				}
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("Checkpoint.Load() failed: %w", err)
+			return err
+		}
+		if v, ok := metadata["optimizer.step_count"]; ok {
+			stepCount, err := strconv.Atoi(v)
+			if err != nil {
+				err = fmt.Errorf("Checkpoint.Load() failed: invalid optimizer step count: %w", err)
+				return err
+			}
+			c.Optimizer.stepCount = stepCount
+		}
+	}
+
+	for i, s := range c.Schedulers {
+		schedulerState, err := readTensors(fmt.Sprintf("%s%d.", ckptSchedulerPrefix, i))
+		if err != nil {
+			err = fmt.Errorf("Checkpoint.Load() failed: %w", err)
+			return err
+		}
+		state := make(map[string][]float64, len(schedulerState))
+		for _, nt := range schedulerState {
+			state[nt.Name] = nt.Tensor.Float64Values()
+		}
+		dropTensors(schedulerState)
+
+		if err := s.LoadStateDict(state); err != nil {
+			err = fmt.Errorf("Checkpoint.Load() failed: scheduler %d: %w", i, err)
+			return err
+		}
+	}
+
+	if _, ok := sf.Info(ckptTorchRngKey); ok {
+		rngState, err := sf.Tensor(ckptTorchRngKey, gotch.CPU)
+		if err != nil {
+			err = fmt.Errorf("Checkpoint.Load() failed: %w", err)
+			return err
+		}
+		err = ts.SetRngState(rngState)
+		rngState.MustDrop()
+		if err != nil {
+			err = fmt.Errorf("Checkpoint.Load() failed: %w", err)
+			return err
+		}
+	}
+
+	if c.Rand != nil {
+		if v, ok := metadata["rng.go.seed"]; ok {
+			seed, err1 := strconv.ParseInt(v, 10, 64)
+			count, err2 := strconv.ParseUint(metadata["rng.go.count"], 10, 64)
+			if err1 != nil || err2 != nil {
+				err := fmt.Errorf("Checkpoint.Load() failed: invalid Go random source state (seed: %q, count: %q)", v, metadata["rng.go.count"])
+				return err
+			}
+			c.Rand.SetState(seed, count)
+		}
+	}
+
+	if c.Epoch, err = strconv.Atoi(metadata["epoch"]); err != nil {
+		err = fmt.Errorf("Checkpoint.Load() failed: invalid epoch: %w", err)
+		return err
+	}
+	if c.Step, err = strconv.Atoi(metadata["step"]); err != nil {
+		err = fmt.Errorf("Checkpoint.Load() failed: invalid step: %w", err)
+		return err
+	}
+
+	c.Metadata = make(map[string]string)
+	for k, v := range metadata {
+		if strings.HasPrefix(k, ckptUserPrefix) {
+			c.Metadata[strings.TrimPrefix(k, ckptUserPrefix)] = v
+		}
+	}
+
+	return nil
+}
+
+// prefixLoader is a TensorLoader reading tensors which names have a prefix.
+type prefixLoader struct {
+	loader TensorLoader
+	prefix string
+}
+
+func (l *prefixLoader) Shape(name string) ([]int64, bool) {
+	return l.loader.Shape(l.prefix + name)
+}
+
+func (l *prefixLoader) Tensor(name string, device gotch.Device) (*ts.Tensor, error) {
+	return l.loader.Tensor(l.prefix+name, device)
+}
@@ -0,0 +1,177 @@
+package nn_test
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+type ckptTrainer struct {
+	vs        *nn.VarStore
+	model     *nn.SequentialT
+	opt       *nn.Optimizer
+	scheduler *nn.LRScheduler
+	randSrc   *gotch.RandSource
+	rng       *rand.Rand
+	ckpt      *nn.Checkpoint
+}
+
+func newCkptTrainer(t *testing.T) *ckptTrainer {
+	vs := nn.NewVarStore(gotch.CPU)
+	model := nn.SeqT()
+	model.Add(nn.NewLinear(vs.Root().Sub("fc1"), 4, 8, nn.DefaultLinearConfig()))
+	model.Add(nn.NewDropout(0.5))
+	model.Add(nn.NewLinear(vs.Root().Sub("fc2"), 8, 1, nn.DefaultLinearConfig()))
+
+	opt, err := nn.NativeConfig(nn.DefaultAdamConfig()).Build(vs, 1e-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler := nn.NewStepLR(opt, 1, 0.5).Build()
+	randSrc := gotch.NewRandSource(42)
+
+	ckpt := nn.NewCheckpoint(vs, opt, scheduler)
+	ckpt.Rand = randSrc
+
+	return &ckptTrainer{
+		vs:        vs,
+		model:     model,
+		opt:       opt,
+		scheduler: scheduler,
+		randSrc:   randSrc,
+		rng:       rand.New(randSrc),
+		ckpt:      ckpt,
+	}
+}
+
+func (tr *ckptTrainer) trainEpoch(x, y *ts.Tensor) {
+	// Shuffle with Go random source.
+	n := x.MustSize()[0]
+	perm := tr.rng.Perm(int(n))
+	idx := make([]int64, n)
+	for i, p := range perm {
+		idx[i] = int64(p)
+	}
+	idxTs := ts.MustOfSlice(idx)
+	xs := x.MustIndexSelect(0, idxTs, false)
+	ys := y.MustIndexSelect(0, idxTs, false)
+
+	for i := int64(0); i < n; i += 4 {
+		bx := xs.MustNarrow(0, i, 4, false)
+		by := ys.MustNarrow(0, i, 4, false)
+		loss := tr.model.ForwardT(bx, true).MustMseLoss(by, 1, true)
+		tr.opt.MustBackwardStep(loss)
+		loss.MustDrop()
+		bx.MustDrop()
+		by.MustDrop()
+		tr.ckpt.Step += 1
+	}
+	tr.scheduler.Step()
+	tr.ckpt.Epoch += 1
+
+	xs.MustDrop()
+	ys.MustDrop()
+	idxTs.MustDrop()
+}
+
+func (tr *ckptTrainer) weights() []float64 {
+	var values []float64
+	vars := tr.vs.Variables()
+	for _, name := range varNames(tr.vs) {
+		x := vars[name]
+		values = append(values, x.Float64Values()...)
+	}
+
+	return values
+}
+
+func TestCheckpoint_Resume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ckpt.safetensors")
+
+	x := ts.MustRandn([]int64{16, 4}, gotch.Float, gotch.CPU)
+	y := ts.MustRandn([]int64{16, 1}, gotch.Float, gotch.CPU)
+
+	// Uninterrupted run.
+	tr := newCkptTrainer(t)
+	for epoch := 0; epoch < 2; epoch++ {
+		tr.trainEpoch(x, y)
+	}
+	tr.ckpt.Metadata["note"] = "resume test"
+	if err := tr.ckpt.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != 0644 {
+		t.Errorf("want checkpoint file mode 0644, got %v", got)
+	}
+	for epoch := 0; epoch < 2; epoch++ {
+		tr.trainEpoch(x, y)
+	}
+	want := tr.weights()
+	wantLRs := tr.opt.GetLRs()
+
+	// Resumed run.
+	tr1 := newCkptTrainer(t)
+	if err := tr1.ckpt.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if tr1.ckpt.Epoch != 2 || tr1.ckpt.Step != 8 {
+		t.Errorf("want epoch 2 and step 8, got epoch %v and step %v", tr1.ckpt.Epoch, tr1.ckpt.Step)
+	}
+	if got := tr1.ckpt.Metadata["note"]; got != "resume test" {
+		t.Errorf("want metadata %q, got %q", "resume test", got)
+	}
+	for epoch := 0; epoch < 2; epoch++ {
+		tr1.trainEpoch(x, y)
+	}
+	got := tr1.weights()
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want weights: %v\n", want)
+		t.Errorf("got weights: %v\n", got)
+	}
+	if gotLRs := tr1.opt.GetLRs(); !reflect.DeepEqual(wantLRs, gotLRs) {
+		t.Errorf("want learning rates %v, got %v", wantLRs, gotLRs)
+	}
+}
+
+func TestLRScheduler_StateDict(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	opt, err := nn.DefaultSGDConfig().Build(vs, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := nn.NewReduceLROnPlateau(opt, nn.WithReduceOnPlateauPatience(1), nn.WithReduceOnPlateauCooldown(2)).Build()
+	for _, loss := range []float64{1.0, 0.5, 0.6, 0.7} {
+		s.Step(nn.WithLoss(loss))
+	}
+	state, err := s.StateDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s1 := nn.NewReduceLROnPlateau(opt, nn.WithReduceOnPlateauPatience(1), nn.WithReduceOnPlateauCooldown(2)).Build()
+	if err := s1.LoadStateDict(state); err != nil {
+		t.Fatal(err)
+	}
+	state1, err := s1.StateDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state, state1) {
+		t.Errorf("want state %v, got %v", state, state1)
+	}
+	if got := state["best"][0]; got != 0.5 {
+		t.Errorf("want best loss 0.5, got %v", got)
+	}
+}
@@ -59,6 +59,8 @@ type ConvTranspose1D struct {
 	Ws     *ts.Tensor
 	Bs     *ts.Tensor // optional
 	Config *ConvTranspose1DConfig
+
+	hook *hookPoint
 }
 
 func NewConvTranspose1D(vs *Path, inDim, outDim int64, ksizes []int64, cfg *ConvTranspose1DConfig) *ConvTranspose1D {
@@ -83,6 +85,7 @@ func NewConvTranspose1D(vs *Path, inDim, outDim int64, ksizes []int64, cfg *Conv
 		Ws:     ws,
 		Bs:     bs,
 		Config: cfg,
+		hook:   newHookPoint(vs),
 	}
 }
 
@@ -90,6 +93,8 @@ type ConvTranspose2D struct {
 	Ws     *ts.Tensor
 	Bs     *ts.Tensor // optional
 	Config *ConvTranspose2DConfig
+
+	hook *hookPoint
 }
 
 func NewConvTranspose2D(vs *Path, inDim, outDim int64, ksizes []int64, cfg *ConvTranspose2DConfig) *ConvTranspose2D {
@@ -114,6 +119,7 @@ func NewConvTranspose2D(vs *Path, inDim, outDim int64, ksizes []int64, cfg *Conv
 		Ws:     ws,
 		Bs:     bs,
 		Config: cfg,
+		hook:   newHookPoint(vs),
 	}
 }
 
@@ -121,6 +127,8 @@ type ConvTranspose3D struct {
 	Ws     *ts.Tensor
 	Bs     *ts.Tensor // optional
 	Config *ConvTranspose3DConfig
+
+	hook *hookPoint
 }
 
 func NewConvTranspose3D(vs *Path, inDim, outDim int64, ksizes []int64, cfg *ConvTranspose3DConfig) *ConvTranspose3D {
@@ -144,6 +152,7 @@ func NewConvTranspose3D(vs *Path, inDim, outDim int64, ksizes []int64, cfg *Conv
 		Ws:     ws,
 		Bs:     bs,
 		Config: cfg,
+		hook:   newHookPoint(vs),
 	}
 }
 
@@ -151,12 +160,18 @@ func NewConvTranspose3D(vs *Path, inDim, outDim int64, ksizes []int64, cfg *Conv
 // ============================================
 
 func (c *ConvTranspose1D) Forward(xs *ts.Tensor) *ts.Tensor {
-	return ts.MustConvTranspose1d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.OutputPadding, c.Config.Groups, c.Config.Dilation)
+	return c.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return ts.MustConvTranspose1d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.OutputPadding, c.Config.Groups, c.Config.Dilation)
+	})
 }
 
 func (c *ConvTranspose2D) Forward(xs *ts.Tensor) *ts.Tensor {
-	return ts.MustConvTranspose2d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.OutputPadding, c.Config.Groups, c.Config.Dilation)
+	return c.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return ts.MustConvTranspose2d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.OutputPadding, c.Config.Groups, c.Config.Dilation)
+	})
 }
 func (c *ConvTranspose3D) Forward(xs *ts.Tensor) *ts.Tensor {
-	return ts.MustConvTranspose3d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.OutputPadding, c.Config.Groups, c.Config.Dilation)
+	return c.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return ts.MustConvTranspose3d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.OutputPadding, c.Config.Groups, c.Config.Dilation)
+	})
 }
@@ -284,6 +284,8 @@ type Conv1D struct {
 	Ws     *ts.Tensor
 	Bs     *ts.Tensor // optional
 	Config *Conv1DConfig
+
+	hook *hookPoint
 }
 
 // NewConv1D creates Conv1D struct.
@@ -318,6 +320,7 @@ func NewConv1D(vs *Path, inDim, outDim, k int64, cfg *Conv1DConfig) *Conv1D {
 		Ws:     ws,
 		Bs:     bs,
 		Config: cfg,
+		hook:   newHookPoint(vs),
 	}
 }
 
@@ -326,6 +329,8 @@ type Conv2D struct {
 	Ws     *ts.Tensor
 	Bs     *ts.Tensor // optional
 	Config *Conv2DConfig
+
+	hook *hookPoint
 }
 
 // NewConv2D creates new Conv2D.
@@ -361,6 +366,7 @@ func NewConv2D(vs *Path, inDim, outDim int64, k int64, cfg *Conv2DConfig) *Conv2
 		Ws:     ws,
 		Bs:     bs,
 		Config: cfg,
+		hook:   newHookPoint(vs),
 	}
 }
 
@@ -369,6 +375,8 @@ type Conv3D struct {
 	Ws     *ts.Tensor
 	Bs     *ts.Tensor // optional
 	Config *Conv3DConfig
+
+	hook *hookPoint
 }
 
 // NewConv3D creates new Conv3D struct.
@@ -404,6 +412,7 @@ func NewConv3D(vs *Path, inDim, outDim, k int64, cfg *Conv3DConfig) *Conv3D {
 		Ws:     ws,
 		Bs:     bs,
 		Config: cfg,
+		hook:   newHookPoint(vs),
 	}
 }
 
@@ -478,6 +487,7 @@ func NewConv(vs *Path, inDim, outDim int64, ksizes []int64, config interface{})
 			Ws:     ws,
 			Bs:     bs,
 			Config: cfg,
+			hook:   newHookPoint(vs),
 		}
 	case len(ksizes) == 2 && configT.String() == "*nn.Conv2DConfig":
 		cfg := config.(*Conv2DConfig)
@@ -491,6 +501,7 @@ func NewConv(vs *Path, inDim, outDim int64, ksizes []int64, config interface{})
 			Ws:     ws,
 			Bs:     bs,
 			Config: cfg,
+			hook:   newHookPoint(vs),
 		}
 	case len(ksizes) == 3 && configT.String() == "*nn.Conv3DConfig":
 		cfg := config.(*Conv3DConfig)
@@ -504,6 +515,7 @@ func NewConv(vs *Path, inDim, outDim int64, ksizes []int64, config interface{})
 			Ws:     ws,
 			Bs:     bs,
 			Config: cfg,
+			hook:   newHookPoint(vs),
 		}
 	default:
 		err := fmt.Errorf("Expected nd length from 1 to 3. Got %v - configT name: '%v'\n", len(ksizes), configT.String())
@@ -515,14 +527,20 @@ func NewConv(vs *Path, inDim, outDim int64, ksizes []int64, config interface{})
 // ============================================
 
 func (c *Conv1D) Forward(xs *ts.Tensor) *ts.Tensor {
-	return ts.MustConv1d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.Dilation, c.Config.Groups)
+	return c.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return ts.MustConv1d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.Dilation, c.Config.Groups)
+	})
 }
 
 func (c *Conv2D) Forward(xs *ts.Tensor) *ts.Tensor {
-	return ts.MustConv2d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.Dilation, c.Config.Groups)
+	return c.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return ts.MustConv2d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.Dilation, c.Config.Groups)
+	})
 }
 func (c *Conv3D) Forward(xs *ts.Tensor) *ts.Tensor {
-	return ts.MustConv3d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.Dilation, c.Config.Groups)
+	return c.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return ts.MustConv3d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.Dilation, c.Config.Groups)
+	})
 }
 
 // Implement ModuleT for Conv1D, Conv2D, Conv3D:
@@ -531,12 +549,18 @@ func (c *Conv3D) Forward(xs *ts.Tensor) *ts.Tensor {
 // NOTE: `train` param won't be used, will be?
 
 func (c *Conv1D) ForwardT(xs *ts.Tensor, train bool) *ts.Tensor {
-	return ts.MustConv1d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.Dilation, c.Config.Groups)
+	return c.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return ts.MustConv1d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.Dilation, c.Config.Groups)
+	})
 }
 
 func (c *Conv2D) ForwardT(xs *ts.Tensor, train bool) *ts.Tensor {
-	return ts.MustConv2d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.Dilation, c.Config.Groups)
+	return c.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return ts.MustConv2d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.Dilation, c.Config.Groups)
+	})
 }
 func (c *Conv3D) ForwardT(xs *ts.Tensor, train bool) *ts.Tensor {
-	return ts.MustConv3d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.Dilation, c.Config.Groups)
+	return c.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return ts.MustConv3d(xs, c.Ws, c.Bs, c.Config.Stride, c.Config.Padding, c.Config.Dilation, c.Config.Groups)
+	})
 }
@@ -0,0 +1,298 @@
+package nn
+
+// Exponential moving average of model weights.
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+type EMAOptions struct {
+	Decay          float64
+	Warmup         bool
+	IncludeBuffers bool
+}
+
+type EMAOption func(*EMAOptions)
+
+func defaultEMAOptions() *EMAOptions {
+	return &EMAOptions{
+		Decay:          0.9999,
+		Warmup:         false,
+		IncludeBuffers: false,
+	}
+}
+
+func NewEMAOptions(opts ...EMAOption) *EMAOptions {
+	options := defaultEMAOptions()
+	for _, o := range opts {
+		o(options)
+	}
+
+	return options
+}
+
+// WithEMADecay sets decay of moving average. Default=0.9999.
+func WithEMADecay(val float64) EMAOption {
+	return func(o *EMAOptions) {
+		o.Decay = val
+	}
+}
+
+// WithEMAWarmup uses decay min(decay, (1 + n)/(10 + n)) at n-th update so
+// that early weights are quickly forgotten. Default=false.
+func WithEMAWarmup(val bool) EMAOption {
+	return func(o *EMAOptions) {
+		o.Warmup = val
+	}
+}
+
+// WithEMABuffers also averages "buffer" variables, e.g. BatchNorm running
+// statistics. Non-float buffers are copied. Default=false.
+func WithEMABuffers(val bool) EMAOption {
+	return func(o *EMAOptions) {
+		o.IncludeBuffers = val
+	}
+}
+
+// detachedCopy returns a copy of tensor which does not require grad.
+func detachedCopy(x *ts.Tensor) *ts.Tensor {
+	y := x.MustZerosLike(false)
+	ts.NoGrad(func() {
+		y.Copy_(x)
+	})
+
+	return y
+}
+
+// EMA maintains exponential moving average (shadow copies) of VarStore
+// variables: shadow = decay * shadow + (1 - decay) * variable.
+//
+// It also implements Callback interface to update after every optimizer step
+// of a Trainer.
+//
+// Example:
+//
+//	ema := nn.NewEMA(vs, nn.WithEMADecay(0.999))
+//	for ... {
+//		opt.MustBackwardStep(loss)
+//		ema.MustUpdate()
+//	}
+//	// Evaluate with EMA weights
+//	ema.MustApply()
+//	...
+//	ema.MustRestore()
+type EMA struct {
+	BaseCallback
+	*EMAOptions
+
+	varstore   *VarStore
+	shadow     map[string]*ts.Tensor
+	backup     map[string]*ts.Tensor // variables replaced by Apply()
+	numUpdates int
+	lastStep   int
+}
+
+// NewEMA creates EMA of trainable (and optionally buffer) variables of
+// VarStore. Shadow copies are initialized with current values.
+func NewEMA(vs *VarStore, opts ...EMAOption) *EMA {
+	e := &EMA{
+		EMAOptions: NewEMAOptions(opts...),
+		varstore:   vs,
+		shadow:     make(map[string]*ts.Tensor),
+	}
+
+	for name, x := range e.trackedVars() {
+		e.shadow[name] = detachedCopy(x)
+	}
+
+	return e
+}
+
+func (e *EMA) trackedVars() map[string]*ts.Tensor {
+	e.varstore.Lock()
+	defer e.varstore.Unlock()
+
+	vars := make(map[string]*ts.Tensor)
+	for name, v := range e.varstore.vars {
+		if v.Trainable || (e.IncludeBuffers && v.Type == "buffer") {
+			vars[name] = v.Tensor
+		}
+	}
+
+	return vars
+}
+
+// NumUpdates returns number of updates.
+func (e *EMA) NumUpdates() int {
+	return e.numUpdates
+}
+
+// CurrentDecay returns decay which is used at next update.
+func (e *EMA) CurrentDecay() float64 {
+	if !e.Warmup {
+		return e.Decay
+	}
+
+	n := float64(e.numUpdates)
+	warmup := (1 + n) / (10 + n)
+	if warmup < e.Decay {
+		return warmup
+	}
+	return e.Decay
+}
+
+// Update updates shadow copies with current variable values.
+func (e *EMA) Update() error {
+	if e.backup != nil {
+		err := fmt.Errorf("EMA.Update() failed: shadow weights are applied. Call Restore() first")
+		return err
+	}
+
+	vars := e.trackedVars()
+	weight := ts.FloatScalar(1 - e.CurrentDecay())
+	for name := range e.shadow {
+		if _, ok := vars[name]; !ok {
+			err := fmt.Errorf("EMA.Update() failed: variable %q not found in VarStore", name)
+			return err
+		}
+	}
+
+	ts.NoGrad(func() {
+		for name, s := range e.shadow {
+			x := vars[name]
+			if gotch.IsFloatDType(s.DType()) {
+				// s + (1 - decay) * (x - s)
+				s.MustLerp_(x, weight)
+			} else {
+				s.Copy_(x)
+			}
+		}
+	})
+	e.numUpdates++
+
+	return nil
+}
+
+// MustUpdate updates shadow copies. It panics if error occurred.
+func (e *EMA) MustUpdate() {
+	if err := e.Update(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Apply swaps shadow weights into VarStore, e.g. for evaluation. Current
+// weights are kept to be restored with Restore().
+func (e *EMA) Apply() error {
+	if e.backup != nil {
+		err := fmt.Errorf("EMA.Apply() failed: shadow weights are already applied")
+		return err
+	}
+
+	vars := e.trackedVars()
+	e.backup = make(map[string]*ts.Tensor, len(e.shadow))
+	for name := range e.shadow {
+		e.backup[name] = detachedCopy(vars[name])
+	}
+	ts.NoGrad(func() {
+		for name, s := range e.shadow {
+			vars[name].Copy_(s)
+		}
+	})
+
+	return nil
+}
+
+// MustApply swaps shadow weights into VarStore. It panics if error occurred.
+func (e *EMA) MustApply() {
+	if err := e.Apply(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Restore swaps back weights which were replaced by Apply().
+func (e *EMA) Restore() error {
+	if e.backup == nil {
+		err := fmt.Errorf("EMA.Restore() failed: shadow weights are not applied")
+		return err
+	}
+
+	vars := e.trackedVars()
+	ts.NoGrad(func() {
+		for name, b := range e.backup {
+			vars[name].Copy_(b)
+			b.MustDrop()
+		}
+	})
+	e.backup = nil
+
+	return nil
+}
+
+// MustRestore swaps back weights which were replaced by Apply(). It panics if
+// error occurred.
+func (e *EMA) MustRestore() {
+	if err := e.Restore(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Save saves VarStore weights with shadow weights in place of tracked
+// variables. Saved file can be loaded with `VarStore.Load()`.
+func (e *EMA) Save(filepath string) error {
+	namedTensors := e.varstore.NamedTensors()
+	for i, nt := range namedTensors {
+		if s, ok := e.shadow[nt.Name]; ok {
+			namedTensors[i].Tensor = s
+		}
+	}
+
+	if err := ts.SaveMultiNew(namedTensors, filepath); err != nil {
+		err = fmt.Errorf("EMA.Save() failed: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// Drop frees shadow copies.
+func (e *EMA) Drop() {
+	for _, s := range e.shadow {
+		s.MustDrop()
+	}
+	for _, b := range e.backup {
+		b.MustDrop()
+	}
+	e.shadow = nil
+	e.backup = nil
+}
+
+// OnTrainBegin implements Callback interface.
+func (e *EMA) OnTrainBegin(t *Trainer) error {
+	e.lastStep = t.Step
+	return nil
+}
+
+func (e *EMA) updateIfStepped(t *Trainer) error {
+	if t.Step == e.lastStep {
+		return nil
+	}
+	e.lastStep = t.Step
+
+	return e.Update()
+}
+
+// OnBatchEnd implements Callback interface. It updates shadow copies after
+// every optimizer step.
+func (e *EMA) OnBatchEnd(t *Trainer, batch int, loss float64) error {
+	return e.updateIfStepped(t)
+}
+
+// OnEpochEnd implements Callback interface. It updates shadow copies after
+// the last optimizer step of an epoch with remaining accumulated gradients.
+func (e *EMA) OnEpochEnd(t *Trainer, epoch int, logs Logs) error {
+	return e.updateIfStepped(t)
+}
@@ -0,0 +1,186 @@
+package nn_test
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+func fillVar(x *ts.Tensor, val float64) {
+	ts.NoGrad(func() {
+		x.MustFill_(ts.FloatScalar(val))
+	})
+}
+
+func TestEMA(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	model, _, _ := linearRegression(vs)
+
+	ema := nn.NewEMA(vs, nn.WithEMADecay(0.5))
+	fillVar(model.Ws, 1.0)
+	ema.MustUpdate()
+	fillVar(model.Ws, 2.0)
+	ema.MustUpdate()
+	// 0.5 * (0.5 * 0 + 0.5 * 1) + 0.5 * 2
+	want := 1.25
+
+	ema.MustApply()
+	if got := model.Ws.Float64Values()[0]; got != want {
+		t.Errorf("Want EMA weight %v, got %v", want, got)
+	}
+	if err := ema.Update(); err == nil {
+		t.Errorf("Expect error updating while shadow weights are applied")
+	}
+	ema.MustRestore()
+	if got := model.Ws.Float64Values()[0]; got != 2.0 {
+		t.Errorf("Want restored weight 2, got %v", got)
+	}
+
+	// Saved shadow weights can be loaded to a VarStore.
+	path := filepath.Join(t.TempDir(), "ema.gt")
+	if err := ema.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	vs1 := nn.NewVarStore(gotch.CPU)
+	model1, _, _ := linearRegression(vs1)
+	if err := vs1.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := model1.Ws.Float64Values()[0]; got != want {
+		t.Errorf("Want loaded EMA weight %v, got %v", want, got)
+	}
+
+	// Warmup
+	ema = nn.NewEMA(vs, nn.WithEMADecay(0.999), nn.WithEMAWarmup(true))
+	if got := ema.CurrentDecay(); got != 0.1 {
+		t.Errorf("Want warmup decay 0.1 at first update, got %v", got)
+	}
+}
+
+func TestEMA_Trainer(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	model, x, y := linearRegression(vs)
+	opt, err := nn.DefaultSGDConfig().Build(vs, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ema := nn.NewEMA(vs, nn.WithEMADecay(0.9))
+	// 14 samples, batch size 4, accumulate 3: 2 steps per epoch.
+	trainer := nn.NewTrainer(model, opt, mseLossFn, nn.WithEpochs(3), nn.WithAccumulateSteps(3), nn.WithCallbacks(ema))
+	if err := trainer.Fit(regressionIterFn(x, y, 4), nil); err != nil {
+		t.Fatal(err)
+	}
+	if ema.NumUpdates() != trainer.Step || trainer.Step != 6 {
+		t.Errorf("Want 6 EMA updates, got %v (optimizer steps %v)", ema.NumUpdates(), trainer.Step)
+	}
+}
+
+func TestSWA(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	model, _, _ := linearRegression(vs)
+	swa := nn.NewSWA(vs)
+
+	for _, v := range []float64{1, 2, 3} {
+		fillVar(model.Ws, v)
+		swa.MustUpdate()
+	}
+
+	// Checkpoint
+	path := filepath.Join(t.TempDir(), "ckpt.gt")
+	fillVar(model.Ws, 6)
+	if err := vs.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := swa.AddCheckpoint(path); err != nil {
+		t.Fatal(err)
+	}
+
+	swa.MustApply()
+	if swa.NumAveraged() != 4 {
+		t.Errorf("Want 4 averaged weight sets, got %v", swa.NumAveraged())
+	}
+	if got := model.Ws.Float64Values()[0]; got != 3.0 {
+		t.Errorf("Want averaged weight 3, got %v", got)
+	}
+}
+
+func TestUpdateBatchNorm(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	cfg := nn.DefaultBatchNormConfig()
+	cfg.Momentum = 0.3
+	bn := nn.BatchNorm1D(vs.Root().Sub("bn"), 2, cfg)
+
+	data := []float32{
+		1, 10,
+		3, 20,
+		5, 30,
+		7, 40,
+		2, 0,
+		4, 0,
+	}
+	x := ts.MustOfSlice(data).MustView([]int64{6, 2}, true)
+	y := ts.MustZeros([]int64{6}, gotch.Int64, gotch.CPU)
+	iter := ts.MustNewIter2(x, y, 2)
+
+	if err := nn.UpdateBatchNorm(bn, vs, iter); err != nil {
+		t.Fatal(err)
+	}
+
+	// Average of batch means and unbiased batch variances.
+	wantMean := []float64{(2.0 + 6.0 + 3.0) / 3, (15.0 + 35.0 + 0.0) / 3}
+	wantVar := []float64{(2.0 + 2.0 + 2.0) / 3, (50.0 + 50.0 + 0.0) / 3}
+	gotMean := bn.RunningMean.Float64Values()
+	gotVar := bn.RunningVar.Float64Values()
+	for i := range wantMean {
+		if math.Abs(gotMean[i]-wantMean[i]) > 1e-4 || math.Abs(gotVar[i]-wantVar[i]) > 1e-4 {
+			t.Errorf("Want running mean %v and var %v, got %v and %v", wantMean, wantVar, gotMean, gotVar)
+			break
+		}
+	}
+}
+
+func TestUpdateBatchNorm_StochasticLayer(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	cfg := nn.DefaultBatchNormConfig()
+	cfg.Momentum = 0.3
+
+	// Layer giving a different output at each call, as Dropout does.
+	calls := 0
+	net := nn.SeqT()
+	net.AddFnT(nn.NewFuncT(func(xs *ts.Tensor, train bool) *ts.Tensor {
+		calls++
+		return xs.MustAddScalar(ts.FloatScalar(float64(calls-1)), false)
+	}))
+	net.Add(nn.BatchNorm1D(vs.Root().Sub("bn"), 2, cfg))
+
+	data := []float32{
+		1, 10,
+		3, 20,
+		5, 30,
+		7, 40,
+		2, 0,
+		4, 0,
+	}
+	x := ts.MustOfSlice(data).MustView([]int64{6, 2}, true)
+	y := ts.MustZeros([]int64{6}, gotch.Int64, gotch.CPU)
+	iter := ts.MustNewIter2(x, y, 2)
+
+	if err := nn.UpdateBatchNorm(net, vs, iter); err != nil {
+		t.Fatal(err)
+	}
+
+	// Batch i is shifted by i.
+	wantMean := []float64{(2.0 + 7.0 + 5.0) / 3, (15.0 + 36.0 + 2.0) / 3}
+	gotMean := vs.Variables()["bn.running_mean"].Float64Values()
+	for i := range wantMean {
+		if math.Abs(gotMean[i]-wantMean[i]) > 1e-4 {
+			t.Errorf("Want running mean %v, got %v", wantMean, gotMean)
+			break
+		}
+	}
+}
@@ -7,13 +7,25 @@ import (
 )
 
 type Func struct {
-	f func(*ts.Tensor) *ts.Tensor
+	f    func(*ts.Tensor) *ts.Tensor
+	name string
 }
 
 func NewFunc(fn func(*ts.Tensor) *ts.Tensor) (retVal Func) {
 	return Func{f: fn}
 }
 
+// NewNamedFunc creates a Func with a name so that it can be identified
+// (e.g. when exporting a model to other formats).
+func NewNamedFunc(name string, fn func(*ts.Tensor) *ts.Tensor) Func {
+	return Func{f: fn, name: name}
+}
+
+// Name returns name of the Func. It is empty if Func was created with `NewFunc`.
+func (fn Func) Name() string {
+	return fn.name
+}
+
 // Implement Module interface for Func:
 // ====================================
 func (fn Func) Forward(xs *ts.Tensor) (retVal *ts.Tensor) {
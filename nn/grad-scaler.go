@@ -0,0 +1,214 @@
+package nn
+
+// Gradient scaling for mixed precision training.
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+type GradScalerOptions struct {
+	InitScale      float64
+	GrowthFactor   float64
+	BackoffFactor  float64
+	GrowthInterval int
+	Enabled        bool
+}
+
+type GradScalerOption func(*GradScalerOptions)
+
+func defaultGradScalerOptions() *GradScalerOptions {
+	return &GradScalerOptions{
+		InitScale:      65536.0, // 2^16
+		GrowthFactor:   2.0,
+		BackoffFactor:  0.5,
+		GrowthInterval: 2000,
+		Enabled:        true,
+	}
+}
+
+func NewGradScalerOptions(opts ...GradScalerOption) *GradScalerOptions {
+	options := defaultGradScalerOptions()
+	for _, o := range opts {
+		o(options)
+	}
+
+	return options
+}
+
+// WithInitScale sets initial scale factor. Default=2^16.
+func WithInitScale(val float64) GradScalerOption {
+	return func(o *GradScalerOptions) {
+		o.InitScale = val
+	}
+}
+
+// WithGrowthFactor sets factor which scale is multiplied by after
+// `GrowthInterval` consecutive steps without inf/NaN gradients. Default=2.0.
+func WithGrowthFactor(val float64) GradScalerOption {
+	return func(o *GradScalerOptions) {
+		o.GrowthFactor = val
+	}
+}
+
+// WithBackoffFactor sets factor which scale is multiplied by after a step
+// with inf/NaN gradients. Default=0.5.
+func WithBackoffFactor(val float64) GradScalerOption {
+	return func(o *GradScalerOptions) {
+		o.BackoffFactor = val
+	}
+}
+
+// WithGrowthInterval sets number of consecutive steps without inf/NaN
+// gradients before scale grows. Default=2000.
+func WithGrowthInterval(val int) GradScalerOption {
+	return func(o *GradScalerOptions) {
+		o.GrowthInterval = val
+	}
+}
+
+// WithGradScalerEnabled enables or disables scaling. If disabled, GradScaler
+// methods fall back to plain optimizer behaviour. Default=true.
+func WithGradScalerEnabled(val bool) GradScalerOption {
+	return func(o *GradScalerOptions) {
+		o.Enabled = val
+	}
+}
+
+// GradScaler scales loss to prevent small gradients underflowing in low
+// precision (e.g. Half) and unscales gradients before optimizer step. Steps
+// which have inf/NaN gradients are skipped and scale is reduced; scale grows
+// again after a number of steps without inf/NaN gradients.
+//
+// Example:
+//
+//	scaler := nn.NewGradScaler()
+//	for ... {
+//		var loss *ts.Tensor
+//		ts.MustAutocast(device, gotch.Half, func() {
+//			loss = nn.CrossEntropyLoss(model.ForwardT(x, true), y)
+//		})
+//		opt.MustZeroGrad()
+//		scaler.Scale(loss).MustBackward()
+//		scaler.Unscale(opt)   // Optional, e.g. before opt.ClipGradNorm()
+//		scaler.Step(opt)
+//		scaler.Update()
+//	}
+type GradScaler struct {
+	*GradScalerOptions
+
+	scale         float64
+	growthTracker int
+	unscaled      bool
+	foundInf      bool
+}
+
+// NewGradScaler creates a GradScaler.
+func NewGradScaler(opts ...GradScalerOption) *GradScaler {
+	options := NewGradScalerOptions(opts...)
+
+	return &GradScaler{
+		GradScalerOptions: options,
+		scale:             options.InitScale,
+	}
+}
+
+// GetScale returns current scale factor. It returns 1 if disabled.
+func (s *GradScaler) GetScale() float64 {
+	if !s.Enabled {
+		return 1.0
+	}
+	return s.scale
+}
+
+// Scale returns a new tensor of loss multiplied by scale factor. Input tensor is
+// not deleted.
+func (s *GradScaler) Scale(loss *ts.Tensor) *ts.Tensor {
+	if !s.Enabled {
+		return loss.MustShallowClone()
+	}
+	return loss.MustMulScalar(ts.FloatScalar(s.scale), false)
+}
+
+// Unscale divides gradients of optimizer trainable variables by scale factor
+// in place and records whether any of them is inf/NaN. It is called at most
+// once between steps; `Step()` calls it if not called yet.
+func (s *GradScaler) Unscale(opt *Optimizer) error {
+	if !s.Enabled || s.unscaled {
+		return nil
+	}
+
+	invScale := ts.FloatScalar(1.0 / s.scale)
+	found := ts.MustZeros([]int64{}, gotch.Bool, opt.varstore.device)
+	for _, x := range opt.varstore.TrainableVariables() {
+		grad := x.MustGrad(false)
+		if !grad.MustDefined() {
+			grad.MustDrop()
+			continue
+		}
+		grad.MustMulScalar_(invScale)
+		nonFinite := grad.MustIsfinite(false).MustLogicalNot(true).MustAny(true).MustTo(found.MustDevice(), true)
+		grad.MustDrop()
+		found = found.MustLogicalOr(nonFinite, true)
+		nonFinite.MustDrop()
+	}
+
+	foundInf, err := found.Int64Value([]int64{})
+	found.MustDrop()
+	if err != nil {
+		err = fmt.Errorf("GradScaler.Unscale() failed: %w", err)
+		return err
+	}
+	s.foundInf = s.foundInf || foundInf != 0
+	s.unscaled = true
+
+	return nil
+}
+
+// Step unscales gradients if not yet and runs optimizer step unless there are
+// inf/NaN gradients. It returns whether optimizer step was taken.
+func (s *GradScaler) Step(opt *Optimizer) (bool, error) {
+	if !s.Enabled {
+		if err := opt.Step(); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if err := s.Unscale(opt); err != nil {
+		return false, err
+	}
+	if s.foundInf {
+		return false, nil
+	}
+	if err := opt.Step(); err != nil {
+		err = fmt.Errorf("GradScaler.Step() failed: %w", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Update updates scale factor for next iteration: backoff if inf/NaN
+// gradients were found at last step, growth after `GrowthInterval`
+// consecutive steps without them.
+func (s *GradScaler) Update() {
+	if !s.Enabled {
+		return
+	}
+
+	if s.foundInf {
+		s.scale *= s.BackoffFactor
+		s.growthTracker = 0
+	} else {
+		s.growthTracker++
+		if s.growthTracker >= s.GrowthInterval {
+			s.scale *= s.GrowthFactor
+			s.growthTracker = 0
+		}
+	}
+	s.foundInf = false
+	s.unscaled = false
+}
@@ -0,0 +1,99 @@
+package nn_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+)
+
+func TestGradScaler(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	model, x, y := linearRegression(vs)
+	opt, err := nn.DefaultSGDConfig().Build(vs, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scaler := nn.NewGradScaler(nn.WithInitScale(1024), nn.WithGrowthInterval(2))
+	step := func() bool {
+		opt.MustZeroGrad()
+		loss := x.Apply(model).MustMseLoss(y, 1, true)
+		scaled := scaler.Scale(loss)
+		scaled.MustBackward()
+		stepped, err := scaler.Step(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scaler.Update()
+		scaled.MustDrop()
+		loss.MustDrop()
+		return stepped
+	}
+
+	// Gradients are unscaled: same update as without scaler.
+	if !step() {
+		t.Fatalf("Expect optimizer step to be taken")
+	}
+	bias := model.Bs.Float64Values()[0]
+	// Initial bias grad: 2 * mean(0 - y) = -2 * mean(y).
+	meanY := y.MustMean(gotch.Float, false).Float64Values(true)[0]
+	if want := 0.1 * 2 * meanY; math.Abs(bias-want) > 1e-4 {
+		t.Errorf("Want bias %v after unscaled step, got %v", want, bias)
+	}
+
+	// Growth after 2 steps without inf/NaN.
+	step()
+	if scaler.GetScale() != 2048 {
+		t.Errorf("Want scale 2048 after growth, got %v", scaler.GetScale())
+	}
+
+	// Overflow: step is skipped and scale backs off.
+	scaler = nn.NewGradScaler(nn.WithInitScale(math.MaxFloat64))
+	ws := model.Ws.Float64Values()
+	if step() {
+		t.Errorf("Expect optimizer step to be skipped with inf gradients")
+	}
+	if ws1 := model.Ws.Float64Values(); ws1[0] != ws[0] {
+		t.Errorf("Want weights unchanged after skipped step, got %v -> %v", ws, ws1)
+	}
+	if scaler.GetScale() != math.MaxFloat64*0.5 {
+		t.Errorf("Want scale backed off to %v, got %v", math.MaxFloat64*0.5, scaler.GetScale())
+	}
+
+	// Disabled scaler is a no-op.
+	scaler = nn.NewGradScaler(nn.WithGradScalerEnabled(false))
+	if !step() || scaler.GetScale() != 1 {
+		t.Errorf("Expect disabled scaler to always step with scale 1")
+	}
+}
+
+func TestTrainer_MixedPrecision(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	model, x, y := linearRegression(vs)
+	opt, err := nn.DefaultSGDConfig().Build(vs, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trainer := nn.NewTrainer(model, opt, mseLossFn,
+		nn.WithEpochs(30),
+		nn.WithAutocast(gotch.CPU, gotch.BFloat16),
+		nn.WithGradScaler(nn.NewGradScaler()),
+	)
+	if err := trainer.Fit(regressionIterFn(x, y, 4), regressionIterFn(x, y, 14)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Master weights stay in Float.
+	if model.Ws.DType() != gotch.Float {
+		t.Errorf("Want weights in Float, got %v", model.Ws.DType())
+	}
+	if loss := trainer.History[len(trainer.History)-1]["val_loss"]; loss > 0.05 {
+		t.Errorf("Expect validation loss < 0.05, got %v", loss)
+	}
+	if trainer.Step == 0 {
+		t.Errorf("Expect optimizer steps to be taken")
+	}
+}
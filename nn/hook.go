@@ -0,0 +1,231 @@
+package nn
+
+// Forward and backward hooks of modules.
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// ForwardPreHook is called with input before forward pass of a module.
+// Returning a non-nil tensor replaces input.
+type ForwardPreHook func(name string, input *ts.Tensor) *ts.Tensor
+
+// ForwardHook is called with input and output after forward pass of a
+// module. Returning a non-nil tensor replaces output (which is then deleted).
+type ForwardHook func(name string, input, output *ts.Tensor) *ts.Tensor
+
+// BackwardHook is called with gradient of a module output after backward
+// pass (see `ts.Tensor.RegisterGradHook()`). Gradient tensor is deleted after
+// hook returns.
+type BackwardHook func(name string, gradOutput *ts.Tensor)
+
+type hookKind int
+
+const (
+	forwardPreHook hookKind = iota
+	forwardHook
+	backwardHook
+)
+
+type hookEntry struct {
+	id     int
+	kind   hookKind
+	prefix string
+	fn     interface{}
+}
+
+// hookRegistry holds hooks of modules created from a VarStore.
+type hookRegistry struct {
+	sync.Mutex
+	nextID  int
+	entries []hookEntry
+	count   int32 // number of entries, read without lock
+}
+
+func newHookRegistry() *hookRegistry {
+	return &hookRegistry{}
+}
+
+// HookHandle is returned by hook registration to remove the hook.
+type HookHandle struct {
+	registry *hookRegistry
+	id       int
+}
+
+// Remove removes hook. It is no-op if hook has been removed.
+func (h *HookHandle) Remove() {
+	r := h.registry
+	r.Lock()
+	defer r.Unlock()
+
+	for i, e := range r.entries {
+		if e.id == h.id {
+			r.entries = append(r.entries[:i], r.entries[i+1:]...)
+			atomic.AddInt32(&r.count, -1)
+			return
+		}
+	}
+}
+
+func (r *hookRegistry) add(kind hookKind, prefix string, fn interface{}) *HookHandle {
+	r.Lock()
+	defer r.Unlock()
+
+	r.nextID++
+	r.entries = append(r.entries, hookEntry{
+		id:     r.nextID,
+		kind:   kind,
+		prefix: prefix,
+		fn:     fn,
+	})
+	atomic.AddInt32(&r.count, 1)
+
+	return &HookHandle{registry: r, id: r.nextID}
+}
+
+func (r *hookRegistry) clear() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.entries = nil
+	atomic.StoreInt32(&r.count, 0)
+}
+
+// matchPrefix returns whether module name is prefix or under prefix. Empty
+// prefix matches all modules.
+func matchPrefix(name, prefix string) bool {
+	return prefix == "" || name == prefix || strings.HasPrefix(name, prefix+SEP)
+}
+
+// match returns hooks of kind which apply to module name in registration
+// order.
+func (r *hookRegistry) match(name string, kind hookKind) []interface{} {
+	r.Lock()
+	defer r.Unlock()
+
+	var fns []interface{}
+	for _, e := range r.entries {
+		if e.kind == kind && matchPrefix(name, e.prefix) {
+			fns = append(fns, e.fn)
+		}
+	}
+
+	return fns
+}
+
+// RegisterForwardPreHook registers hook to be called before forward pass of
+// modules which path is prefix or under prefix, e.g. prefix "features"
+// matches "features", "features.0", "features.0.conv" but not "features2".
+// Empty prefix matches all modules.
+//
+// Hooks apply to built-in layers which hold variables: Linear, Conv*,
+// ConvTranspose*, BatchNorm, LayerNorm and Embedding. A module is named by
+// path it was created with, e.g. "layer1.0.conv1".
+func (vs *VarStore) RegisterForwardPreHook(prefix string, hook ForwardPreHook) *HookHandle {
+	return vs.hooks.add(forwardPreHook, prefix, hook)
+}
+
+// RegisterForwardHook registers hook to be called after forward pass of
+// modules which path is prefix or under prefix. See
+// `RegisterForwardPreHook()`.
+//
+// Example:
+//
+//	features := make(map[string]*ts.Tensor)
+//	h := vs.RegisterForwardHook("layer4", func(name string, input, output *ts.Tensor) *ts.Tensor {
+//		features[name] = output.MustShallowClone()
+//		return nil
+//	})
+//	defer h.Remove()
+func (vs *VarStore) RegisterForwardHook(prefix string, hook ForwardHook) *HookHandle {
+	return vs.hooks.add(forwardHook, prefix, hook)
+}
+
+// RegisterBackwardHook registers hook to be called with gradient of output of
+// modules which path is prefix or under prefix, after `Backward()` of a
+// tensor computed from the output. See `RegisterForwardPreHook()`.
+//
+// NOTE. outputs which do not require grad (e.g. inside `ts.NoGrad()`) are not
+// hooked.
+func (vs *VarStore) RegisterBackwardHook(prefix string, hook BackwardHook) *HookHandle {
+	return vs.hooks.add(backwardHook, prefix, hook)
+}
+
+// ClearHooks removes all hooks of VarStore.
+func (vs *VarStore) ClearHooks() {
+	vs.hooks.clear()
+}
+
+// RegisterForwardPreHook registers forward pre-hook of modules under path.
+func (p *Path) RegisterForwardPreHook(hook ForwardPreHook) *HookHandle {
+	return p.varstore.RegisterForwardPreHook(strings.Join(p.path, SEP), hook)
+}
+
+// RegisterForwardHook registers forward hook of modules under path.
+func (p *Path) RegisterForwardHook(hook ForwardHook) *HookHandle {
+	return p.varstore.RegisterForwardHook(strings.Join(p.path, SEP), hook)
+}
+
+// RegisterBackwardHook registers backward hook of modules under path.
+func (p *Path) RegisterBackwardHook(hook BackwardHook) *HookHandle {
+	return p.varstore.RegisterBackwardHook(strings.Join(p.path, SEP), hook)
+}
+
+// hookPoint runs hooks of a module.
+type hookPoint struct {
+	registry *hookRegistry
+	name     string
+}
+
+func newHookPoint(p *Path) *hookPoint {
+	return &hookPoint{
+		registry: p.varstore.hooks,
+		name:     strings.Join(p.path, SEP),
+	}
+}
+
+// forward runs forward function of a module with hooks. Input tensor is not
+// deleted.
+func (h *hookPoint) forward(x *ts.Tensor, fn func(x *ts.Tensor) *ts.Tensor) *ts.Tensor {
+	if h == nil || h.registry == nil || atomic.LoadInt32(&h.registry.count) == 0 {
+		return fn(x)
+	}
+
+	input := x
+	for _, hook := range h.registry.match(h.name, forwardPreHook) {
+		if y := hook.(ForwardPreHook)(h.name, input); y != nil && y != input {
+			if input != x {
+				input.MustDrop()
+			}
+			input = y
+		}
+	}
+
+	out := fn(input)
+	for _, hook := range h.registry.match(h.name, forwardHook) {
+		if y := hook.(ForwardHook)(h.name, input, out); y != nil && y != out {
+			out.MustDrop()
+			out = y
+		}
+	}
+	if input != x {
+		input.MustDrop()
+	}
+
+	backwardHooks := h.registry.match(h.name, backwardHook)
+	if len(backwardHooks) > 0 && out.MustRequiresGrad() {
+		name := h.name
+		for _, hook := range backwardHooks {
+			bh := hook.(BackwardHook)
+			out.MustRegisterGradHook(func(grad *ts.Tensor) {
+				bh(name, grad)
+			})
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,101 @@
+package nn_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+func hookedNet(vs *nn.VarStore) *nn.SequentialT {
+	root := vs.Root()
+	cfg := &nn.LinearConfig{
+		WsInit: nn.NewConstInit(1.0),
+		BsInit: nn.NewConstInit(0.0),
+		Bias:   true,
+	}
+	net := nn.SeqT()
+	net.Add(nn.NewLinear(root.Sub("features").Sub("fc1"), 2, 3, cfg))
+	net.Add(nn.NewLinear(root.Sub("features").Sub("fc2"), 3, 3, cfg))
+	net.Add(nn.NewLinear(root.Sub("head"), 3, 1, cfg))
+
+	return net
+}
+
+func TestForwardHooks(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	net := hookedNet(vs)
+	x := ts.MustOnes([]int64{1, 2}, gotch.Float, gotch.CPU)
+
+	var (
+		names  []string
+		shapes [][]int64
+	)
+	h := vs.RegisterForwardHook("features", func(name string, input, output *ts.Tensor) *ts.Tensor {
+		names = append(names, name)
+		shapes = append(shapes, output.MustSize())
+		return nil
+	})
+	// Pre-hook doubles input of head.
+	pre := vs.Root().Sub("head").RegisterForwardPreHook(func(name string, input *ts.Tensor) *ts.Tensor {
+		return input.MustMulScalar(ts.FloatScalar(2), false)
+	})
+
+	// fc1: 2, fc2: 6, head: 2 * 18
+	y := net.ForwardT(x, false)
+	if got := y.Float64Values()[0]; got != 36 {
+		t.Errorf("Want output 36, got %v", got)
+	}
+	wantNames := []string{"features.fc1", "features.fc2"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("Want hooked modules %v, got %v", wantNames, names)
+	}
+	if !reflect.DeepEqual(shapes, [][]int64{{1, 3}, {1, 3}}) {
+		t.Errorf("Unexpected hooked output shapes: %v", shapes)
+	}
+
+	h.Remove()
+	pre.Remove()
+	names = nil
+	y = net.ForwardT(x, false)
+	if got := y.Float64Values()[0]; got != 18 || len(names) != 0 {
+		t.Errorf("Want output 18 and no hook calls after removal, got %v and %v", got, names)
+	}
+
+	// Forward hook replaces output.
+	vs.RegisterForwardHook("head", func(name string, input, output *ts.Tensor) *ts.Tensor {
+		return output.MustNeg(false)
+	})
+	y = net.ForwardT(x, false)
+	if got := y.Float64Values()[0]; got != -18 {
+		t.Errorf("Want replaced output -18, got %v", got)
+	}
+	vs.ClearHooks()
+}
+
+func TestBackwardHook(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	net := hookedNet(vs)
+	x := ts.MustOnes([]int64{1, 2}, gotch.Float, gotch.CPU)
+
+	grads := make(map[string][]float64)
+	h := vs.RegisterBackwardHook("", func(name string, grad *ts.Tensor) {
+		grads[name] = grad.Float64Values()
+	})
+	defer h.Remove()
+
+	loss := net.ForwardT(x, true).MustSum(gotch.Float, true)
+	loss.MustBackward()
+
+	// d(loss)/d(output) of each layer with all weights 1.
+	want := map[string][]float64{
+		"features.fc1": {3, 3, 3},
+		"features.fc2": {1, 1, 1},
+		"head":         {1},
+	}
+	if !reflect.DeepEqual(grads, want) {
+		t.Errorf("Want output gradients %v, got %v", want, grads)
+	}
+}
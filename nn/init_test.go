@@ -2,6 +2,8 @@ package nn
 
 import (
 	"fmt"
+	"math/rand"
+	"reflect"
 	"testing"
 	"time"
 
@@ -42,3 +44,37 @@ func TestInitTensor_Memcheck(t *testing.T) {
 	time.Sleep(time.Second * 10)
 	gotch.PrintMemStats("Final")
 }
+
+func TestManualSeed(t *testing.T) {
+	newWeights := func() []float64 {
+		vs := NewVarStore(gotch.CPU)
+		l := NewLinear(vs.Root(), 4, 4, DefaultLinearConfig())
+		return l.Ws.Float64Values()
+	}
+
+	gotch.ManualSeed(42)
+	want := newWeights()
+	gotch.ManualSeed(42)
+	got := newWeights()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want same initial weights with same seed")
+	}
+}
+
+func TestDropout_SetRand(t *testing.T) {
+	x := ts.MustOnes([]int64{4, 8}, gotch.Float, gotch.CPU)
+	d1 := NewDropout(0.5).SetRand(rand.New(rand.NewSource(42)))
+	d2 := NewDropout(0.5).SetRand(rand.New(rand.NewSource(42)))
+
+	want := d1.ForwardT(x, true).Float64Values()
+	got := d2.ForwardT(x, true).Float64Values()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v\n", want)
+		t.Errorf("got: %v\n", got)
+	}
+	for _, v := range got {
+		if v != 0 && v != 2 {
+			t.Errorf("want dropout output 0 or 2, got %v", v)
+		}
+	}
+}
@@ -34,6 +34,8 @@ type LayerNorm struct {
 	Ws              *ts.Tensor // optional
 	Bs              *ts.Tensor // optional
 	NormalizedShape []int64
+
+	hook *hookPoint
 }
 
 func NewLayerNorm(vs *Path, normalizedShape []int64, config *LayerNormConfig) *LayerNorm {
@@ -47,13 +49,14 @@ func NewLayerNorm(vs *Path, normalizedShape []int64, config *LayerNormConfig) *L
 		bs = vs.MustNewVar(config.BsName, normalizedShape, config.BsInit)
 	}
 
-	return &LayerNorm{config, ws, bs, normalizedShape}
+	return &LayerNorm{config, ws, bs, normalizedShape, newHookPoint(vs)}
 }
 
 // Implement Module interface for LayerNorm:
 // =========================================
 
 func (ln *LayerNorm) Forward(xs *ts.Tensor) (retVal *ts.Tensor) {
-
-	return ts.MustLayerNorm(xs, ln.NormalizedShape, ln.Ws, ln.Bs, ln.Config.Eps, ln.Config.CudnnEnable)
+	return ln.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return ts.MustLayerNorm(xs, ln.NormalizedShape, ln.Ws, ln.Bs, ln.Config.Eps, ln.Config.CudnnEnable)
+	})
 }
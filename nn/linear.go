@@ -33,6 +33,8 @@ func DefaultLinearConfig() *LinearConfig {
 type Linear struct {
 	Ws *ts.Tensor
 	Bs *ts.Tensor
+
+	hook *hookPoint
 }
 
 // NewLinear creates a new linear layer
@@ -65,8 +67,9 @@ func NewLinear(vs *Path, inDim, outDim int64, c *LinearConfig) *Linear {
 	ws := vs.MustNewVar("weight", []int64{outDim, inDim}, c.WsInit).MustT(false)
 
 	return &Linear{
-		Ws: ws,
-		Bs: bs,
+		Ws:   ws,
+		Bs:   bs,
+		hook: newHookPoint(vs),
 	}
 }
 
@@ -99,18 +102,22 @@ func NewLinear(vs *Path, inDim, outDim int64, c *LinearConfig) *Linear {
 //	  1 1 1
 //		1 1 1 ]
 func (l *Linear) Forward(xs *ts.Tensor) (retVal *ts.Tensor) {
-	mul := xs.MustMatmul(l.Ws, false)
-	if l.Bs != nil {
-		return mul.MustAdd(l.Bs, true)
-	} else {
-		return mul
-	}
+	return l.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		mul := xs.MustMatmul(l.Ws, false)
+		if l.Bs != nil {
+			return mul.MustAdd(l.Bs, true)
+		} else {
+			return mul
+		}
+	})
 }
 
 // ForwardT implements ModuleT interface for Linear layer.
 //
 // NOTE: train param will not be used.
 func (l *Linear) ForwardT(xs *ts.Tensor, train bool) (retVal *ts.Tensor) {
-	mul := xs.MustMatmul(l.Ws, false)
-	return mul.MustAdd(l.Bs, true)
+	return l.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		mul := xs.MustMatmul(l.Ws, false)
+		return mul.MustAdd(l.Bs, true)
+	})
 }
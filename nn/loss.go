@@ -1,14 +1,42 @@
 package nn
 
 import (
+	"math"
+
+	"github.com/sugarme/gotch"
 	"github.com/sugarme/gotch/ts"
 )
 
+// Reduction modes of loss functions.
+const (
+	ReductionNone int64 = 0
+	ReductionMean int64 = 1
+	ReductionSum  int64 = 2
+)
+
 type lossFnOptions struct {
-	ClassWeights []float64
-	Reduction    int64 // 0: "None", 1: "mean", 2: "sum"
-	IgnoreIndex  int64
-	PosWeight    int64 // index of the weight attributed to positive class. Used in BCELoss
+	ClassWeights   []float64
+	Reduction      int64 // 0: "None", 1: "mean", 2: "sum"
+	IgnoreIndex    int64
+	ignoreIndexSet bool      // elementwise losses only ignore targets if set explicitly
+	PosWeight      []float64 // weights of positive examples per class. Used in BCEWithLogitsLoss
+	LabelSmoothing float64   // Used in CrossEntropyLoss
+	Delta          float64   // threshold of SmoothL1Loss (beta) and HuberLoss (delta)
+	LogTarget      bool      // whether target is in log space. Used in KLDivLoss
+	Alpha          float64   // weight of positive class in FocalLoss. Negative value means no weighting
+	Gamma          float64   // focusing parameter of FocalLoss
+	Smooth         float64   // smoothing constant of DiceLoss and IoULoss
+	Blank          int64     // blank label of CTCLoss
+	ZeroInfinity   bool      // whether to zero infinite losses. Used in CTCLoss
+	Temperature    float64   // Used in InfoNCELoss
+	LogInput       bool      // whether input is in log space. Used in PoissonNLLLoss
+	Full           bool      // whether to add constant term. Used in PoissonNLLLoss and GaussianNLLLoss
+
+	// Loss specific defaults are used if not set.
+	Margin    float64
+	marginSet bool
+	Eps       float64
+	epsSet    bool
 }
 
 type LossFnOption func(*lossFnOptions)
@@ -26,84 +54,276 @@ func WithLossFnReduction(val int64) LossFnOption {
 func WithLossFnIgnoreIndex(val int64) LossFnOption {
 	return func(o *lossFnOptions) {
 		o.IgnoreIndex = val
+		o.ignoreIndexSet = true
+	}
+}
+
+// WithLossFnPosWeight sets weights of positive examples, one per class.
+func WithLossFnPosWeight(vals []float64) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.PosWeight = vals
+	}
+}
+
+func WithLossFnLabelSmoothing(val float64) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.LabelSmoothing = val
+	}
+}
+
+func WithLossFnDelta(val float64) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.Delta = val
+	}
+}
+
+func WithLossFnLogTarget(val bool) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.LogTarget = val
+	}
+}
+
+func WithLossFnAlpha(val float64) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.Alpha = val
+	}
+}
+
+func WithLossFnGamma(val float64) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.Gamma = val
 	}
 }
 
-func WithLossFnPosWeight(val int64) LossFnOption {
+func WithLossFnSmooth(val float64) LossFnOption {
 	return func(o *lossFnOptions) {
-		o.PosWeight = val
+		o.Smooth = val
+	}
+}
+
+func WithLossFnBlank(val int64) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.Blank = val
+	}
+}
+
+func WithLossFnZeroInfinity(val bool) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.ZeroInfinity = val
+	}
+}
+
+func WithLossFnTemperature(val float64) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.Temperature = val
+	}
+}
+
+func WithLossFnLogInput(val bool) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.LogInput = val
+	}
+}
+
+func WithLossFnFull(val bool) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.Full = val
+	}
+}
+
+// WithLossFnMargin sets margin of CosineEmbeddingLoss (default=0), MarginRankingLoss (default=0)
+// and TripletMarginLoss (default=1).
+func WithLossFnMargin(val float64) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.Margin = val
+		o.marginSet = true
+	}
+}
+
+// WithLossFnEps sets epsilon for numerical stability of PoissonNLLLoss (default=1e-8),
+// GaussianNLLLoss (default=1e-6), TripletMarginLoss (default=1e-6) and InfoNCELoss (default=1e-8).
+func WithLossFnEps(val float64) LossFnOption {
+	return func(o *lossFnOptions) {
+		o.Eps = val
+		o.epsSet = true
 	}
 }
 
 func defaultLossFnOptions() *lossFnOptions {
 	return &lossFnOptions{
-		ClassWeights: nil,
-		Reduction:    1, // "mean"
-		IgnoreIndex:  -100,
-		PosWeight:    -1,
+		ClassWeights:   nil,
+		Reduction:      1, // "mean"
+		IgnoreIndex:    -100,
+		PosWeight:      nil,
+		LabelSmoothing: 0.0,
+		Delta:          1.0,
+		LogTarget:      false,
+		Alpha:          0.25,
+		Gamma:          2.0,
+		Smooth:         1.0,
+		Blank:          0,
+		ZeroInfinity:   false,
+		Temperature:    0.07,
+		LogInput:       true,
+		Full:           false,
 	}
 }
 
-// CrossEntropyLoss calculates cross entropy loss.
-// Ref. https://github.com/pytorch/pytorch/blob/15be189f0de4addf4f68d18022500f67617ab05d/torch/nn/functional.py#L2012
-// - logits: tensor of shape [B, C, H, W] corresponding the raw output of the model.
-// - target: ground truth tensor of shape [B, 1, H, W]
-// - posWeight: scalar representing the weight attributed to positive class.
-// This is especially useful for an imbalanced dataset
-func CrossEntropyLoss(logits, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+func newLossFnOptions(opts ...LossFnOption) *lossFnOptions {
 	options := defaultLossFnOptions()
 	for _, o := range opts {
 		o(options)
 	}
 
-	var ws *ts.Tensor
-	device := logits.MustDevice()
-	dtype := logits.DType()
+	return options
+}
+
+func (o *lossFnOptions) margin(defaultVal float64) float64 {
+	if o.marginSet {
+		return o.Margin
+	}
+	return defaultVal
+}
+
+func (o *lossFnOptions) eps(defaultVal float64) float64 {
+	if o.epsSet {
+		return o.Eps
+	}
+	return defaultVal
+}
+
+// weightTensor returns a tensor of given values or an undefined tensor if values are empty.
+func weightTensor(vals []float64, dtype gotch.DType, device gotch.Device) *ts.Tensor {
+	if len(vals) == 0 {
+		return ts.NewTensor()
+	}
+
+	return ts.MustOfSlice(vals).MustTotype(dtype, true).MustTo(device, true)
+}
+
+// reduceLoss reduces an unreduced loss. It deletes input loss tensor.
+func reduceLoss(loss *ts.Tensor, reduction int64) *ts.Tensor {
+	switch reduction {
+	case ReductionMean:
+		return loss.MustMean(loss.DType(), true)
+	case ReductionSum:
+		return loss.MustSum(loss.DType(), true)
+	default:
+		return loss
+	}
+}
+
+// elementwiseLoss calculates an unreduced loss with lossFn, applies class
+// weights and ignore index then reduces it. Loss has same shape as target.
+// Class weights are broadcast along last dimension. Elements whose target
+// equals ignore index are zeroed out and their target is replaced by zero
+// before calling lossFn. "mean" reduction averages over non-ignored elements.
+// Ignore index is only applied if set with WithLossFnIgnoreIndex.
+func elementwiseLoss(target *ts.Tensor, options *lossFnOptions, lossFn func(target *ts.Tensor) *ts.Tensor) *ts.Tensor {
+	var mask *ts.Tensor
+	var loss *ts.Tensor
+	if options.ignoreIndexSet {
+		mask = target.MustNe(ts.IntScalar(options.IgnoreIndex), false).MustTotype(target.DType(), true)
+		masked := target.MustMul(mask, false)
+		loss = lossFn(masked)
+		masked.MustDrop()
+	} else {
+		loss = lossFn(target)
+	}
+
 	if len(options.ClassWeights) > 0 {
-		ws = ts.MustOfSlice(options.ClassWeights).MustTotype(dtype, true).MustTo(device, true)
+		ws := weightTensor(options.ClassWeights, loss.DType(), loss.MustDevice())
+		loss = loss.MustMul(ws, true)
+		ws.MustDrop()
+	}
+
+	if mask == nil {
+		return reduceLoss(loss, options.Reduction)
+	}
+
+	loss = loss.MustMul(mask, true)
+	if options.Reduction == ReductionMean {
+		count := mask.MustSum(loss.DType(), false).MustClampMin(ts.FloatScalar(1.0), true)
+		loss = loss.MustSum(loss.DType(), true).MustDiv(count, true)
+		count.MustDrop()
 	} else {
-		ws = ts.NewTensor()
+		loss = reduceLoss(loss, options.Reduction)
 	}
-	reduction := options.Reduction
-	ignoreIndex := options.IgnoreIndex
+	mask.MustDrop()
+
+	return loss
+}
+
+// CrossEntropyLoss calculates cross entropy loss.
+// Ref. https://github.com/pytorch/pytorch/blob/15be189f0de4addf4f68d18022500f67617ab05d/torch/nn/functional.py#L2012
+// - logits: tensor of shape [B, C] or [B, C, H, W] corresponding the raw output of the model.
+// - target: ground truth class indices of shape [B] or [B, H, W], or class probabilities of same shape as logits.
+//
+// Options: WithLossFnWeights, WithLossFnReduction, WithLossFnIgnoreIndex and WithLossFnLabelSmoothing.
+func CrossEntropyLoss(logits, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
 
-	logSm := logits.MustLogSoftmax(-1, dtype, false)
-	loss := logSm.MustNllLoss(target, ws, reduction, ignoreIndex, true)
+	ws := weightTensor(options.ClassWeights, logits.DType(), logits.MustDevice())
+	loss := logits.MustCrossEntropyLoss(target, ws, options.Reduction, options.IgnoreIndex, options.LabelSmoothing, false)
 	ws.MustDrop()
 
 	return loss
 }
 
-// BCELoss calculates a binary cross entropy loss.
+// NLLLoss calculates negative log likelihood loss.
 //
-// - logits: tensor of shape [B, C, H, W] corresponding the raw output of the model.
-// - target: ground truth tensor of shape [B, 1, H, W]
-// - posWeight: scalar representing the weight attributed to positive class.
-// This is especially useful for an imbalanced dataset
-func BCELoss(logits, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
-	options := defaultLossFnOptions()
-	for _, o := range opts {
-		o(options)
-	}
+// - logProbs: log-probabilities of shape [B, C] or [B, C, H, W], e.g. output of log-softmax.
+// - target: ground truth class indices of shape [B] or [B, H, W].
+//
+// Options: WithLossFnWeights, WithLossFnReduction and WithLossFnIgnoreIndex.
+func NLLLoss(logProbs, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	ws := weightTensor(options.ClassWeights, logProbs.DType(), logProbs.MustDevice())
+	loss := logProbs.MustNllLossNd(target, ws, options.Reduction, options.IgnoreIndex, false)
+	ws.MustDrop()
+
+	return loss
+}
+
+// BCELoss calculates a binary cross entropy loss between probabilities and target.
+//
+// - probs: probabilities in range [0, 1], e.g. output of sigmoid.
+// - target: ground truth tensor of same shape as probs.
+//
+// Options: WithLossFnWeights (broadcast along last dimension) and WithLossFnReduction.
+//
+// NOTE. use BCEWithLogitsLoss for raw model outputs as it is numerically more stable.
+func BCELoss(probs, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	ws := weightTensor(options.ClassWeights, probs.DType(), probs.MustDevice())
+	loss := probs.MustBinaryCrossEntropy(target, ws, options.Reduction, false)
+	ws.MustDrop()
+
+	return loss
+}
+
+// BCEWithLogitsLoss calculates a binary cross entropy loss between logits and target.
+//
+// - logits: raw output of the model.
+// - target: ground truth tensor of same shape as logits.
+//
+// Options: WithLossFnWeights (broadcast along last dimension), WithLossFnPosWeight
+// (one per class, i.e. along last dimension) and WithLossFnReduction. Positive weights
+// are especially useful for an imbalanced dataset.
+func BCEWithLogitsLoss(logits, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
 
-	var ws *ts.Tensor
 	device := logits.MustDevice()
 	dtype := logits.DType()
-	if len(options.ClassWeights) > 0 {
-		ws = ts.MustOfSlice(options.ClassWeights).MustTotype(dtype, true).MustTo(device, true)
-	} else {
-		ws = ts.NewTensor()
-	}
-	reduction := options.Reduction
+	ws := weightTensor(options.ClassWeights, dtype, device)
+	posWeight := weightTensor(options.PosWeight, dtype, device)
 
-	var posWeight *ts.Tensor
-	if options.PosWeight >= 0 {
-		posWeight = ts.MustOfSlice([]int64{options.PosWeight})
-	} else {
-		posWeight = ts.NewTensor()
-	}
+	loss := logits.MustBinaryCrossEntropyWithLogits(target, ws, posWeight, options.Reduction, false)
+	ws.MustDrop()
+	posWeight.MustDrop()
 
-	loss := logits.MustSqueeze(false).MustBinaryCrossEntropyWithLogits(target, ws, posWeight, reduction, true)
 	return loss
 }
 
@@ -119,3 +339,304 @@ func MSELoss(logits, labels *ts.Tensor, reductionOpt ...int64) *ts.Tensor {
 
 	return out
 }
+
+// L1Loss calculates mean absolute error.
+//
+// Options: WithLossFnWeights (broadcast along last dimension), WithLossFnIgnoreIndex
+// (target value of elements to ignore) and WithLossFnReduction.
+func L1Loss(input, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return elementwiseLoss(target, options, func(target *ts.Tensor) *ts.Tensor {
+		return input.MustL1Loss(target, ReductionNone, false)
+	})
+}
+
+// SmoothL1Loss calculates a loss which is quadratic if absolute error is less
+// than beta and L1 otherwise.
+//
+// Options: WithLossFnDelta (beta, default=1.0), WithLossFnWeights (broadcast along last dimension),
+// WithLossFnIgnoreIndex (target value of elements to ignore) and WithLossFnReduction.
+func SmoothL1Loss(input, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return elementwiseLoss(target, options, func(target *ts.Tensor) *ts.Tensor {
+		return input.MustSmoothL1Loss(target, ReductionNone, options.Delta, false)
+	})
+}
+
+// HuberLoss calculates a loss which is quadratic if absolute error is less than
+// delta and delta-scaled L1 otherwise.
+//
+// Options: WithLossFnDelta (default=1.0), WithLossFnWeights (broadcast along last dimension),
+// WithLossFnIgnoreIndex (target value of elements to ignore) and WithLossFnReduction.
+func HuberLoss(input, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return elementwiseLoss(target, options, func(target *ts.Tensor) *ts.Tensor {
+		return input.MustHuberLoss(target, ReductionNone, options.Delta, false)
+	})
+}
+
+// KLDivLoss calculates Kullback-Leibler divergence loss.
+//
+// - input: log-probabilities.
+// - target: probabilities, or log-probabilities if WithLossFnLogTarget(true).
+//
+// Options: WithLossFnLogTarget, WithLossFnWeights (one per class, along last dimension),
+// WithLossFnIgnoreIndex (target value of elements to ignore) and WithLossFnReduction.
+//
+// NOTE. "mean" reduction averages over all elements. Divide "sum" reduction by
+// batch size to get mathematical KL divergence.
+func KLDivLoss(input, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return elementwiseLoss(target, options, func(target *ts.Tensor) *ts.Tensor {
+		return input.MustKlDiv(target, ReductionNone, options.LogTarget, false)
+	})
+}
+
+// FocalLoss calculates binary focal loss from logits as in "Focal Loss for
+// Dense Object Detection" (https://arxiv.org/abs/1708.02002).
+//
+//	loss = -alpha_t * (1 - p_t)^gamma * log(p_t)
+//
+// Options: WithLossFnAlpha (default=0.25, negative value means no weighting),
+// WithLossFnGamma (default=2.0), WithLossFnWeights (one per class, along last dimension),
+// WithLossFnIgnoreIndex (target value of elements to ignore) and WithLossFnReduction.
+func FocalLoss(logits, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return elementwiseLoss(target, options, func(target *ts.Tensor) *ts.Tensor {
+		return focalLoss(logits, target, options)
+	})
+}
+
+// focalLoss calculates unreduced focal loss.
+func focalLoss(logits, target *ts.Tensor, options *lossFnOptions) *ts.Tensor {
+	p := logits.MustSigmoid(false)
+	ce := logits.MustBinaryCrossEntropyWithLogits(target, ts.NewTensor(), ts.NewTensor(), ReductionNone, false)
+
+	// p_t = p * t + (1 - p) * (1 - t)
+	pt := p.MustMul(target, false)
+	oneMinusP := p.MustNeg(false).MustAddScalar(ts.FloatScalar(1.0), true)
+	oneMinusT := target.MustNeg(false).MustAddScalar(ts.FloatScalar(1.0), true)
+	pt = pt.MustAdd(oneMinusP.MustMul(oneMinusT, false), true)
+
+	modulating := pt.MustNeg(true).MustAddScalar(ts.FloatScalar(1.0), true).MustPowTensorScalar(ts.FloatScalar(options.Gamma), true)
+	loss := ce.MustMul(modulating, true)
+
+	if options.Alpha >= 0 {
+		// alpha_t = alpha * t + (1 - alpha) * (1 - t)
+		alphaT := target.MustMulScalar(ts.FloatScalar(options.Alpha), false).MustAdd(oneMinusT.MustMulScalar(ts.FloatScalar(1-options.Alpha), false), true)
+		loss = loss.MustMul(alphaT, true)
+		alphaT.MustDrop()
+	}
+
+	p.MustDrop()
+	oneMinusP.MustDrop()
+	oneMinusT.MustDrop()
+	modulating.MustDrop()
+
+	return loss
+}
+
+// overlapLoss calculates per sample and class intersection and sum of cardinalities
+// of probs and target of shape [B, C, ...].
+func overlapLoss(probs, target *ts.Tensor, options *lossFnOptions, iou bool) *ts.Tensor {
+	dims := probs.MustSize()
+	var spatialDims []int64
+	for i := 2; i < len(dims); i++ {
+		spatialDims = append(spatialDims, int64(i))
+	}
+	sum := func(x *ts.Tensor) *ts.Tensor {
+		if len(spatialDims) == 0 {
+			return x
+		}
+		return x.MustSumDimIntlist(spatialDims, false, x.DType(), true)
+	}
+
+	dtype := probs.DType()
+	intersection := sum(probs.MustMul(target, false))
+	cardinality := sum(probs.MustAdd(target, false))
+
+	var num, den *ts.Tensor
+	if iou {
+		// (I + smooth) / (U + smooth) where U = |P| + |T| - I
+		num = intersection.MustAddScalar(ts.FloatScalar(options.Smooth), false)
+		den = cardinality.MustSub(intersection, false).MustAddScalar(ts.FloatScalar(options.Smooth), true)
+	} else {
+		// (2I + smooth) / (|P| + |T| + smooth)
+		num = intersection.MustMulScalar(ts.FloatScalar(2.0), false).MustAddScalar(ts.FloatScalar(options.Smooth), true)
+		den = cardinality.MustAddScalar(ts.FloatScalar(options.Smooth), false)
+	}
+	intersection.MustDrop()
+	cardinality.MustDrop()
+
+	loss := num.MustDiv(den, true).MustNeg(true).MustAddScalar(ts.FloatScalar(1.0), true)
+	den.MustDrop()
+
+	if len(options.ClassWeights) > 0 {
+		ws := weightTensor(options.ClassWeights, dtype, probs.MustDevice())
+		loss = loss.MustMul(ws, true)
+		ws.MustDrop()
+	}
+
+	return reduceLoss(loss, options.Reduction)
+}
+
+// DiceLoss calculates Dice loss for segmentation.
+//
+// - probs: predicted probabilities of shape [B, C, ...], e.g. output of softmax or sigmoid.
+// - target: ground truth of same shape as probs, e.g. one-hot encoded masks.
+//
+// Loss of each sample and class is 1 - (2 * |P*T| + smooth) / (|P| + |T| + smooth).
+// With "none" reduction, a tensor of shape [B, C] is returned.
+//
+// Options: WithLossFnWeights (one per class), WithLossFnSmooth (default=1.0) and WithLossFnReduction.
+func DiceLoss(probs, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return overlapLoss(probs, target, options, false)
+}
+
+// IoULoss calculates Jaccard (intersection over union) loss for segmentation.
+//
+// - probs: predicted probabilities of shape [B, C, ...], e.g. output of softmax or sigmoid.
+// - target: ground truth of same shape as probs, e.g. one-hot encoded masks.
+//
+// Loss of each sample and class is 1 - (|P*T| + smooth) / (|P| + |T| - |P*T| + smooth).
+// With "none" reduction, a tensor of shape [B, C] is returned.
+//
+// Options: WithLossFnWeights (one per class), WithLossFnSmooth (default=1.0) and WithLossFnReduction.
+func IoULoss(probs, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return overlapLoss(probs, target, options, true)
+}
+
+// CTCLoss calculates Connectionist Temporal Classification loss.
+//
+// - logProbs: log-probabilities of shape [T, B, C].
+// - targets: target labels of shape [B, S] or concatenated targets of shape [sum(targetLengths)].
+// - inputLengths, targetLengths: int64 tensors of shape [B].
+//
+// Options: WithLossFnBlank (default=0), WithLossFnZeroInfinity and WithLossFnReduction.
+// "mean" reduction divides losses by target lengths then averages over batch.
+// WithLossFnWeights and WithLossFnIgnoreIndex do not apply and are ignored.
+func CTCLoss(logProbs, targets, inputLengths, targetLengths *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return ts.MustCtcLossTensor(logProbs, targets, inputLengths, targetLengths, options.Blank, options.Reduction, options.ZeroInfinity)
+}
+
+// CosineEmbeddingLoss calculates a loss which measures whether two inputs are
+// similar (target=1) or dissimilar (target=-1) using cosine similarity.
+//
+// Options: WithLossFnMargin (default=0) and WithLossFnReduction. WithLossFnWeights
+// and WithLossFnIgnoreIndex do not apply to pairs and are ignored.
+func CosineEmbeddingLoss(input1, input2, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return ts.MustCosineEmbeddingLoss(input1, input2, target, options.margin(0.0), options.Reduction)
+}
+
+// MarginRankingLoss calculates max(0, -target * (input1 - input2) + margin) where
+// target is 1 or -1.
+//
+// Options: WithLossFnMargin (default=0) and WithLossFnReduction. WithLossFnWeights
+// and WithLossFnIgnoreIndex do not apply to pairs and are ignored.
+func MarginRankingLoss(input1, input2, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return ts.MustMarginRankingLoss(input1, input2, target, options.margin(0.0), options.Reduction)
+}
+
+// TripletMarginLoss calculates max(d(a, p) - d(a, n) + margin, 0) where d is
+// L2 distance.
+//
+// Options: WithLossFnMargin (default=1), WithLossFnEps (default=1e-6) and WithLossFnReduction.
+// WithLossFnWeights and WithLossFnIgnoreIndex do not apply to triplets and are ignored.
+func TripletMarginLoss(anchor, positive, negative *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return ts.MustTripletMarginLoss(anchor, positive, negative, options.margin(1.0), 2.0, options.eps(1e-6), false, options.Reduction)
+}
+
+// InfoNCELoss calculates contrastive InfoNCE (NT-Xent) loss of a batch of
+// positive pairs. For each query, the key at same index is positive and all
+// other keys in batch are negatives.
+//
+// - query, key: embeddings of shape [B, D]. They are L2 normalized.
+//
+// Options: WithLossFnTemperature (default=0.07), WithLossFnEps (default=1e-8) and WithLossFnReduction.
+// WithLossFnWeights and WithLossFnIgnoreIndex do not apply as labels are implied
+// by batch positions, and are ignored.
+func InfoNCELoss(query, key *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	eps := options.eps(1e-8)
+	normalize := func(x *ts.Tensor) *ts.Tensor {
+		norm := x.MustSquare(false).MustSumDimIntlist([]int64{1}, true, x.DType(), true).MustSqrt(true).MustClampMin(ts.FloatScalar(eps), true)
+		out := x.MustDiv(norm, false)
+		norm.MustDrop()
+		return out
+	}
+
+	q := normalize(query)
+	k := normalize(key)
+	kt := k.MustT(true)
+	logits := q.MustMm(kt, true).MustDivScalar(ts.FloatScalar(options.Temperature), true)
+	kt.MustDrop()
+
+	n := query.MustSize()[0]
+	labels := ts.MustArange(ts.IntScalar(n), gotch.Int64, query.MustDevice())
+	loss := logits.MustCrossEntropyLoss(labels, ts.NewTensor(), options.Reduction, -100, 0.0, true)
+	labels.MustDrop()
+
+	return loss
+}
+
+// PoissonNLLLoss calculates negative log likelihood loss with Poisson
+// distribution of target.
+//
+// Options: WithLossFnLogInput (default=true), WithLossFnFull (Stirling approximation term),
+// WithLossFnEps (default=1e-8), WithLossFnWeights (broadcast along last dimension),
+// WithLossFnIgnoreIndex (target value of elements to ignore) and WithLossFnReduction.
+func PoissonNLLLoss(input, target *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return elementwiseLoss(target, options, func(target *ts.Tensor) *ts.Tensor {
+		return ts.MustPoissonNllLoss(input, target, options.LogInput, options.Full, options.eps(1e-8), ReductionNone)
+	})
+}
+
+// GaussianNLLLoss calculates negative log likelihood loss with Gaussian
+// distribution of target, given predicted mean (input) and variance.
+//
+//	loss = 0.5 * (log(max(var, eps)) + (input - target)^2 / max(var, eps))
+//
+// Options: WithLossFnFull (adds 0.5 * log(2*pi)), WithLossFnEps (default=1e-6),
+// WithLossFnWeights (broadcast along last dimension), WithLossFnIgnoreIndex (target
+// value of elements to ignore) and WithLossFnReduction.
+func GaussianNLLLoss(input, target, variance *ts.Tensor, opts ...LossFnOption) *ts.Tensor {
+	options := newLossFnOptions(opts...)
+
+	return elementwiseLoss(target, options, func(target *ts.Tensor) *ts.Tensor {
+		return gaussianNLLLoss(input, target, variance, options)
+	})
+}
+
+// gaussianNLLLoss calculates unreduced Gaussian negative log likelihood loss.
+func gaussianNLLLoss(input, target, variance *ts.Tensor, options *lossFnOptions) *ts.Tensor {
+	v := variance.MustClampMin(ts.FloatScalar(options.eps(1e-6)), false)
+	sqErr := input.MustSub(target, false).MustSquare(true).MustDiv(v, true)
+	loss := v.MustLog(false).MustAdd(sqErr, true).MustMulScalar(ts.FloatScalar(0.5), true)
+	if options.Full {
+		loss = loss.MustAddScalar(ts.FloatScalar(0.5*math.Log(2*math.Pi)), true)
+	}
+	v.MustDrop()
+	sqErr.MustDrop()
+
+	return loss
+}
@@ -0,0 +1,127 @@
+package nn_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+func tensorOf(vals []float64, shape ...int64) *ts.Tensor {
+	x := ts.MustOfSlice(vals)
+	if len(shape) > 0 {
+		x = x.MustView(shape, true)
+	}
+	return x
+}
+
+func assertLoss(t *testing.T, name string, loss *ts.Tensor, want float64) {
+	t.Helper()
+	got := loss.Float64Values()[0]
+	if math.Abs(got-want) > 1e-4 {
+		t.Errorf("%s: want %v, got %v", name, want, got)
+	}
+}
+
+func TestRegressionLosses(t *testing.T) {
+	input := tensorOf([]float64{1, 2, 3})
+	target := tensorOf([]float64{2, 2, 5})
+
+	assertLoss(t, "L1Loss", nn.L1Loss(input, target), 1.0)
+	assertLoss(t, "SmoothL1Loss", nn.SmoothL1Loss(input, target), 2.0/3.0)
+	assertLoss(t, "HuberLoss", nn.HuberLoss(input, target), 2.0/3.0)
+	assertLoss(t, "HuberLoss(delta=2, sum)", nn.HuberLoss(input, target, nn.WithLossFnDelta(2), nn.WithLossFnReduction(nn.ReductionSum)), 2.5)
+
+	assertLoss(t, "L1Loss(weights, sum)", nn.L1Loss(input, target, nn.WithLossFnWeights([]float64{1, 2, 3}), nn.WithLossFnReduction(nn.ReductionSum)), 7.0)
+	assertLoss(t, "L1Loss(ignore)", nn.L1Loss(input, tensorOf([]float64{2, -1, 5}), nn.WithLossFnIgnoreIndex(-1)), 1.5)
+
+	none := nn.L1Loss(input, target, nn.WithLossFnReduction(nn.ReductionNone))
+	if got := none.MustSize(); len(got) != 1 || got[0] != 3 {
+		t.Errorf("L1Loss(none): want shape [3], got %v", got)
+	}
+}
+
+func TestClassificationLosses(t *testing.T) {
+	logits := tensorOf([]float64{0, math.Log(3), 0, math.Log(3)}, 2, 2) // probs: [0.25, 0.75]
+	target := ts.MustOfSlice([]int64{0, 1})
+	target0 := ts.MustOfSlice([]int64{0})
+	logits0 := tensorOf([]float64{0, math.Log(3)}, 1, 2)
+
+	assertLoss(t, "CrossEntropyLoss", nn.CrossEntropyLoss(logits0, target0), math.Log(4))
+	assertLoss(t, "CrossEntropyLoss(weights)", nn.CrossEntropyLoss(logits, target, nn.WithLossFnWeights([]float64{1, 3})), (math.Log(4)+3*math.Log(4.0/3.0))/4)
+	assertLoss(t, "CrossEntropyLoss(ignore)", nn.CrossEntropyLoss(logits, ts.MustOfSlice([]int64{0, -100})), math.Log(4))
+	assertLoss(t, "CrossEntropyLoss(smoothing)", nn.CrossEntropyLoss(logits0, target0, nn.WithLossFnLabelSmoothing(0.2)), 0.8*math.Log(4)+0.2*(math.Log(4)+math.Log(4.0/3.0))/2)
+
+	logProbs := tensorOf([]float64{math.Log(0.25), math.Log(0.75)}, 1, 2)
+	assertLoss(t, "NLLLoss", nn.NLLLoss(logProbs, ts.MustOfSlice([]int64{1})), math.Log(4.0/3.0))
+
+	probs := tensorOf([]float64{0.5, 0.8})
+	assertLoss(t, "BCELoss", nn.BCELoss(probs, tensorOf([]float64{1, 0})), (math.Log(2)+math.Log(5))/2)
+
+	zeros := tensorOf([]float64{0, 0}, 1, 2)
+	ones := tensorOf([]float64{1, 1}, 1, 2)
+	assertLoss(t, "BCEWithLogitsLoss", nn.BCEWithLogitsLoss(zeros, ones), math.Log(2))
+	assertLoss(t, "BCEWithLogitsLoss(posWeight)", nn.BCEWithLogitsLoss(zeros, ones, nn.WithLossFnPosWeight([]float64{2, 3}), nn.WithLossFnReduction(nn.ReductionSum)), 5*math.Log(2))
+
+	input := tensorOf([]float64{math.Log(0.5), math.Log(0.5)})
+	assertLoss(t, "KLDivLoss", nn.KLDivLoss(input, tensorOf([]float64{0.25, 0.75}), nn.WithLossFnReduction(nn.ReductionSum)), 0.25*math.Log(0.5)+0.75*math.Log(1.5))
+
+	assertLoss(t, "FocalLoss", nn.FocalLoss(tensorOf([]float64{0}), tensorOf([]float64{1})), 0.25*0.25*math.Log(2))
+	assertLoss(t, "FocalLoss(alpha=-1)", nn.FocalLoss(tensorOf([]float64{0}), tensorOf([]float64{1}), nn.WithLossFnAlpha(-1)), 0.25*math.Log(2))
+	focal := nn.FocalLoss(tensorOf([]float64{0, 0}, 1, 2), tensorOf([]float64{1, -100}, 1, 2), nn.WithLossFnAlpha(-1), nn.WithLossFnWeights([]float64{2, 3}), nn.WithLossFnIgnoreIndex(-100))
+	assertLoss(t, "FocalLoss(weights, ignore)", focal, 2*0.25*math.Log(2))
+}
+
+func TestSegmentationLosses(t *testing.T) {
+	probs := tensorOf([]float64{1, 0, 0.5, 0}, 1, 1, 2, 2)
+	target := tensorOf([]float64{1, 0, 1, 0}, 1, 1, 2, 2)
+
+	// intersection=1.5, |P|=1.5, |T|=2
+	assertLoss(t, "DiceLoss", nn.DiceLoss(probs, target), 1-4.0/4.5)
+	assertLoss(t, "IoULoss", nn.IoULoss(probs, target), 1-2.5/3.0)
+	assertLoss(t, "DiceLoss(weights)", nn.DiceLoss(probs, target, nn.WithLossFnWeights([]float64{2})), 2*(1-4.0/4.5))
+
+	none := nn.DiceLoss(probs, target, nn.WithLossFnReduction(nn.ReductionNone))
+	if got := none.MustSize(); len(got) != 2 || got[0] != 1 || got[1] != 1 {
+		t.Errorf("DiceLoss(none): want shape [1 1], got %v", got)
+	}
+}
+
+func TestCTCLoss(t *testing.T) {
+	logProbs := tensorOf([]float64{math.Log(0.2), math.Log(0.8)}, 1, 1, 2) // [T, B, C]
+	targets := ts.MustOfSlice([]int64{1}).MustView([]int64{1, 1}, true)
+	inputLengths := ts.MustOfSlice([]int64{1})
+	targetLengths := ts.MustOfSlice([]int64{1})
+
+	assertLoss(t, "CTCLoss", nn.CTCLoss(logProbs, targets, inputLengths, targetLengths), -math.Log(0.8))
+}
+
+func TestEmbeddingLosses(t *testing.T) {
+	x1 := tensorOf([]float64{1, 0}, 1, 2)
+	x2 := tensorOf([]float64{1, 1}, 1, 2)
+	assertLoss(t, "CosineEmbeddingLoss", nn.CosineEmbeddingLoss(x1, x2, tensorOf([]float64{1})), 1-1/math.Sqrt(2))
+	assertLoss(t, "CosineEmbeddingLoss(-1)", nn.CosineEmbeddingLoss(x1, x2, tensorOf([]float64{-1}), nn.WithLossFnMargin(0.5)), 1/math.Sqrt(2)-0.5)
+
+	assertLoss(t, "MarginRankingLoss", nn.MarginRankingLoss(tensorOf([]float64{1, 2}), tensorOf([]float64{2, 1}), tensorOf([]float64{1, 1}), nn.WithLossFnMargin(0.5)), 0.75)
+
+	anchor := tensorOf([]float64{0, 0}, 1, 2)
+	positive := tensorOf([]float64{3, 4}, 1, 2)
+	negative := tensorOf([]float64{0, 1}, 1, 2)
+	assertLoss(t, "TripletMarginLoss", nn.TripletMarginLoss(anchor, positive, negative), 5.0)
+
+	q := tensorOf([]float64{1, 0, 0, 1}, 2, 2)
+	assertLoss(t, "InfoNCELoss", nn.InfoNCELoss(q, q, nn.WithLossFnTemperature(1)), math.Log(1+math.Exp(-1)))
+}
+
+func TestNLLLosses(t *testing.T) {
+	input := tensorOf([]float64{0, math.Log(2)})
+	target := tensorOf([]float64{2, 1})
+	assertLoss(t, "PoissonNLLLoss", nn.PoissonNLLLoss(input, target), (1+2-math.Log(2))/2)
+
+	mean := tensorOf([]float64{1})
+	variance := tensorOf([]float64{2})
+	want := 0.5 * (math.Log(2) + 2)
+	assertLoss(t, "GaussianNLLLoss", nn.GaussianNLLLoss(mean, tensorOf([]float64{3}), variance), want)
+	assertLoss(t, "GaussianNLLLoss(full)", nn.GaussianNLLLoss(mean, tensorOf([]float64{3}), variance, nn.WithLossFnFull(true)), want+0.5*math.Log(2*math.Pi))
+}
@@ -0,0 +1,616 @@
+package nn
+
+// Optimizers implemented in Go.
+//
+// Unlike libtorch optimizers, their per-parameter state (momentum buffers,
+// moving averages, ...) are plain tensors which can be inspected, saved and
+// loaded via `Optimizer.StateDict()` and `Optimizer.LoadStateDict()`.
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// nativeParam is a variable tracked by native optimizer and its state.
+type nativeParam struct {
+	name  string
+	x     *ts.Tensor
+	group uint
+	step  int64
+	state map[string]*ts.Tensor
+}
+
+// stateOf returns state tensor of given key. It is created and filled with
+// value v if not existing.
+func (p *nativeParam) stateOf(key string, v float64) *ts.Tensor {
+	if x, ok := p.state[key]; ok {
+		return x
+	}
+	x := p.x.MustFullLike(ts.FloatScalar(v), false)
+	p.state[key] = x
+
+	return x
+}
+
+// updateRule updates a variable given its gradient and learning rate.
+type updateRule interface {
+	update(p *nativeParam, grad *ts.Tensor, lr float64)
+	setMomentum(m float64) error
+}
+
+// nativeOptimizer is a Go implementation of optimizer backend.
+type nativeOptimizer struct {
+	lr     float64 // initial learning rate of new parameter groups
+	lrs    []float64
+	params []*nativeParam
+	rule   updateRule
+}
+
+func newNativeOptimizer(lr float64, rule updateRule) *nativeOptimizer {
+	return &nativeOptimizer{
+		lr:   lr,
+		lrs:  []float64{lr},
+		rule: rule,
+	}
+}
+
+func (o *nativeOptimizer) addParameter(name string, x *ts.Tensor, group uint) {
+	for uint(len(o.lrs)) <= group {
+		o.lrs = append(o.lrs, o.lr)
+	}
+	o.params = append(o.params, &nativeParam{
+		name:  name,
+		x:     x,
+		group: group,
+		state: make(map[string]*ts.Tensor),
+	})
+}
+
+func (o *nativeOptimizer) AddParameter(param *ts.Tensor, group uint) error {
+	o.addParameter(fmt.Sprintf("param_%d", len(o.params)), param, group)
+	return nil
+}
+
+func (o *nativeOptimizer) SetLearningRate(lr float64) error {
+	for i := range o.lrs {
+		o.lrs[i] = lr
+	}
+
+	return nil
+}
+
+func (o *nativeOptimizer) GetLearningRates() ([]float64, error) {
+	lrs := make([]float64, len(o.lrs))
+	copy(lrs, o.lrs)
+
+	return lrs, nil
+}
+
+func (o *nativeOptimizer) SetLearningRates(lrs []float64) error {
+	if len(lrs) != len(o.lrs) {
+		err := fmt.Errorf("SetLearningRates() failed: expected %v learning rates, got %v", len(o.lrs), len(lrs))
+		return err
+	}
+	copy(o.lrs, lrs)
+
+	return nil
+}
+
+func (o *nativeOptimizer) ParamGroupNum() (int64, error) {
+	return int64(len(o.lrs)), nil
+}
+
+func (o *nativeOptimizer) AddParamGroup(tensors []*ts.Tensor) error {
+	group := uint(len(o.lrs))
+	o.lrs = append(o.lrs, o.lr)
+	for i, x := range tensors {
+		o.addParameter(fmt.Sprintf("group_%d.param_%d", group, i), x, group)
+	}
+
+	return nil
+}
+
+func (o *nativeOptimizer) SetMomentum(m float64) error {
+	return o.rule.setMomentum(m)
+}
+
+func (o *nativeOptimizer) ZeroGrad() error {
+	for _, p := range o.params {
+		p.x.ZeroGrad()
+	}
+
+	return nil
+}
+
+func (o *nativeOptimizer) Step() error {
+	ts.NoGrad(func() {
+		for _, p := range o.params {
+			grad := p.x.MustGrad(false)
+			if !grad.MustDefined() {
+				grad.MustDrop()
+				continue
+			}
+			p.step += 1
+			o.rule.update(p, grad, o.lrs[p.group])
+			grad.MustDrop()
+		}
+	})
+
+	return nil
+}
+
+// stateDict returns optimizer state as named tensors:
+// - "param_groups.lr": learning rates of parameter groups.
+// - "state.<variable name>.step": number of steps of variable.
+// - "state.<variable name>.<key>": state tensors of variable.
+func (o *nativeOptimizer) stateDict() []ts.NamedTensor {
+	namedTensors := []ts.NamedTensor{
+		{Name: "param_groups.lr", Tensor: ts.MustOfSlice(o.lrs)},
+	}
+	for _, p := range o.params {
+		prefix := fmt.Sprintf("state.%s.", p.name)
+		namedTensors = append(namedTensors, ts.NamedTensor{
+			Name:   prefix + "step",
+			Tensor: ts.MustOfSlice([]int64{p.step}),
+		})
+		for key, x := range p.state {
+			namedTensors = append(namedTensors, ts.NamedTensor{
+				Name:   prefix + key,
+				Tensor: x.MustShallowClone(),
+			})
+		}
+	}
+
+	return namedTensors
+}
+
+// loadStateDict copies optimizer state from named tensors. State of variables
+// not found in namedTensors are left unchanged.
+func (o *nativeOptimizer) loadStateDict(namedTensors []ts.NamedTensor) error {
+	tensors := make(map[string]*ts.Tensor, len(namedTensors))
+	for _, nt := range namedTensors {
+		tensors[nt.Name] = nt.Tensor
+	}
+
+	if x, ok := tensors["param_groups.lr"]; ok {
+		if err := o.SetLearningRates(x.Float64Values()); err != nil {
+			err = fmt.Errorf("LoadStateDict() failed: %w", err)
+			return err
+		}
+	}
+
+	for _, p := range o.params {
+		prefix := fmt.Sprintf("state.%s.", p.name)
+		for name, src := range tensors {
+			key := strings.TrimPrefix(name, prefix)
+			// NOTE. state keys do not contain '.' so that prefix of other variable names are not matched.
+			if key == name || strings.Contains(key, ".") {
+				continue
+			}
+
+			if key == "step" {
+				p.step = src.Int64Values()[0]
+				continue
+			}
+
+			if got, want := src.MustSize(), p.x.MustSize(); !equalShape(got, want) {
+				err := fmt.Errorf("LoadStateDict() failed: mismatched shape for %q, expected %v, got %v", name, want, got)
+				return err
+			}
+			ts.NoGrad(func() {
+				p.stateOf(key, 0).Copy_(src)
+			})
+		}
+	}
+
+	return nil
+}
+
+func equalShape(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// weightDecay returns grad + wd * x.
+func weightDecay(grad, x *ts.Tensor, wd float64) *ts.Tensor {
+	if wd == 0 {
+		return grad.MustShallowClone()
+	}
+	decay := x.MustMulScalar(ts.FloatScalar(wd), false)
+	retVal := grad.MustAdd(decay, false)
+	decay.MustDrop()
+
+	return retVal
+}
+
+// addScaled_ adds in-place alpha * y to x.
+func addScaled_(x, y *ts.Tensor, alpha float64) {
+	tmp := y.MustMulScalar(ts.FloatScalar(alpha), false)
+	x.MustAdd_(tmp)
+	tmp.MustDrop()
+}
+
+// adamMoments updates first and second moments of Adam-like optimizers and
+// returns bias-corrected update m_hat / (sqrt(v_hat) + eps).
+func adamMoments(p *nativeParam, grad *ts.Tensor, beta1, beta2, eps float64) *ts.Tensor {
+	m := p.stateOf("exp_avg", 0)
+	v := p.stateOf("exp_avg_sq", 0)
+
+	m.MustLerp_(grad, ts.FloatScalar(1-beta1))
+	sq := grad.MustSquare(false)
+	v.MustMulScalar_(ts.FloatScalar(beta2))
+	addScaled_(v, sq, 1-beta2)
+	sq.MustDrop()
+
+	bc1 := 1 - math.Pow(beta1, float64(p.step))
+	bc2 := 1 - math.Pow(beta2, float64(p.step))
+	denom := v.MustSqrt(false).MustDivScalar(ts.FloatScalar(math.Sqrt(bc2)), true).MustAddScalar(ts.FloatScalar(eps), true)
+	retVal := m.MustDiv(denom, false).MustDivScalar(ts.FloatScalar(bc1), true)
+	denom.MustDrop()
+
+	return retVal
+}
+
+// NativeConfig wraps SGDConfig, AdamConfig, AdamWConfig or RMSPropConfig so
+// that built optimizer is implemented in Go instead of libtorch.
+//
+// Example:
+//
+//	opt, err := nn.NativeConfig(nn.DefaultAdamConfig()).Build(vs, 1e-3)
+func NativeConfig(config OptimizerConfig) OptimizerConfig {
+	return &nativeConfig{config}
+}
+
+type nativeConfig struct {
+	config OptimizerConfig
+}
+
+// NOTE. epsilon of libtorch Adam and AdamW optimizers.
+const adamEps = 1e-8
+
+func (c *nativeConfig) buildOpt(lr float64) (optimizer, error) {
+	var rule updateRule
+	switch cfg := c.config.(type) {
+	case *SGDConfig:
+		rule = &sgdRule{*cfg}
+	case *AdamConfig:
+		rule = &adamRule{beta1: cfg.Beta1, beta2: cfg.Beta2, eps: adamEps, wd: cfg.Wd}
+	case *AdamWConfig:
+		rule = &adamRule{beta1: cfg.Beta1, beta2: cfg.Beta2, eps: adamEps, wd: cfg.Wd, decoupled: true}
+	case *RMSPropConfig:
+		rule = &rmspropRule{*cfg}
+	default:
+		// Native-only configs (Adagrad, LAMB, Lion).
+		return c.config.buildOpt(lr)
+	}
+
+	return newNativeOptimizer(lr, rule), nil
+}
+
+func (c *nativeConfig) Build(vs *VarStore, lr float64) (*Optimizer, error) {
+	return defaultBuild(c, vs, lr)
+}
+
+// SGD:
+// ====
+
+type sgdRule struct {
+	SGDConfig
+}
+
+func (r *sgdRule) update(p *nativeParam, grad *ts.Tensor, lr float64) {
+	d := weightDecay(grad, p.x, r.Wd)
+	if r.Momentum != 0 {
+		buf, ok := p.state["momentum_buffer"]
+		if !ok {
+			buf = p.stateOf("momentum_buffer", 0)
+			buf.Copy_(d)
+		} else {
+			buf.MustMulScalar_(ts.FloatScalar(r.Momentum))
+			addScaled_(buf, d, 1-r.Dampening)
+		}
+
+		if r.Nesterov {
+			nesterov := buf.MustMulScalar(ts.FloatScalar(r.Momentum), false)
+			d = d.MustAdd(nesterov, true)
+			nesterov.MustDrop()
+		} else {
+			d.MustDrop()
+			d = buf.MustShallowClone()
+		}
+	}
+
+	addScaled_(p.x, d, -lr)
+	d.MustDrop()
+}
+
+func (r *sgdRule) setMomentum(m float64) error {
+	r.Momentum = m
+	return nil
+}
+
+// Adam and AdamW:
+// ===============
+
+type adamRule struct {
+	beta1, beta2, eps, wd float64
+	decoupled             bool // AdamW
+}
+
+func (r *adamRule) update(p *nativeParam, grad *ts.Tensor, lr float64) {
+	var d *ts.Tensor
+	if r.decoupled {
+		p.x.MustMulScalar_(ts.FloatScalar(1 - lr*r.wd))
+		d = grad.MustShallowClone()
+	} else {
+		d = weightDecay(grad, p.x, r.wd)
+	}
+
+	upd := adamMoments(p, d, r.beta1, r.beta2, r.eps)
+	addScaled_(p.x, upd, -lr)
+	upd.MustDrop()
+	d.MustDrop()
+}
+
+func (r *adamRule) setMomentum(m float64) error {
+	r.beta1 = m
+	return nil
+}
+
+// RMSProp:
+// ========
+
+type rmspropRule struct {
+	RMSPropConfig
+}
+
+func (r *rmspropRule) update(p *nativeParam, grad *ts.Tensor, lr float64) {
+	d := weightDecay(grad, p.x, r.Wd)
+
+	sqAvg := p.stateOf("square_avg", 0)
+	sq := d.MustSquare(false)
+	sqAvg.MustMulScalar_(ts.FloatScalar(r.Alpha))
+	addScaled_(sqAvg, sq, 1-r.Alpha)
+	sq.MustDrop()
+
+	var avg *ts.Tensor
+	if r.Centered {
+		gradAvg := p.stateOf("grad_avg", 0)
+		gradAvg.MustLerp_(d, ts.FloatScalar(1-r.Alpha))
+		gradAvgSq := gradAvg.MustSquare(false)
+		avg = sqAvg.MustSub(gradAvgSq, false).MustSqrt(true).MustAddScalar(ts.FloatScalar(r.Eps), true)
+		gradAvgSq.MustDrop()
+	} else {
+		avg = sqAvg.MustSqrt(false).MustAddScalar(ts.FloatScalar(r.Eps), true)
+	}
+
+	if r.Momentum > 0 {
+		buf := p.stateOf("momentum_buffer", 0)
+		buf.MustMulScalar_(ts.FloatScalar(r.Momentum))
+		buf.MustAddcdiv_(d, avg)
+		addScaled_(p.x, buf, -lr)
+	} else {
+		upd := d.MustDiv(avg, false)
+		addScaled_(p.x, upd, -lr)
+		upd.MustDrop()
+	}
+	avg.MustDrop()
+	d.MustDrop()
+}
+
+func (r *rmspropRule) setMomentum(m float64) error {
+	r.Momentum = m
+	return nil
+}
+
+// Adagrad optimizer:
+// ==================
+
+// AdagradConfig holds parameters for building Adagrad optimizer.
+//
+// NOTE. Adagrad is only implemented natively in Go.
+type AdagradConfig struct {
+	LrDecay       float64
+	Wd            float64
+	InitialAccVal float64 // initial value of sum of squared gradients
+	Eps           float64
+}
+
+// DefaultAdagradConfig creates AdagradConfig with default values.
+func DefaultAdagradConfig() *AdagradConfig {
+	return &AdagradConfig{
+		LrDecay:       0.0,
+		Wd:            0.0,
+		InitialAccVal: 0.0,
+		Eps:           1e-10,
+	}
+}
+
+// NewAdagradConfig creates AdagradConfig with specified values.
+func NewAdagradConfig(lrDecay, wd, initialAccVal, eps float64) *AdagradConfig {
+	return &AdagradConfig{
+		LrDecay:       lrDecay,
+		Wd:            wd,
+		InitialAccVal: initialAccVal,
+		Eps:           eps,
+	}
+}
+
+// Implement OptimizerConfig interface for AdagradConfig
+func (c *AdagradConfig) buildOpt(lr float64) (optimizer, error) {
+	return newNativeOptimizer(lr, &adagradRule{*c}), nil
+}
+
+func (c *AdagradConfig) Build(vs *VarStore, lr float64) (*Optimizer, error) {
+	return defaultBuild(c, vs, lr)
+}
+
+type adagradRule struct {
+	AdagradConfig
+}
+
+func (r *adagradRule) update(p *nativeParam, grad *ts.Tensor, lr float64) {
+	d := weightDecay(grad, p.x, r.Wd)
+	clr := lr / (1 + float64(p.step-1)*r.LrDecay)
+
+	sum := p.stateOf("sum", r.InitialAccVal)
+	sum.MustAddcmul_(d, d)
+	std := sum.MustSqrt(false).MustAddScalar(ts.FloatScalar(r.Eps), true)
+	upd := d.MustDiv(std, false)
+	addScaled_(p.x, upd, -clr)
+
+	upd.MustDrop()
+	std.MustDrop()
+	d.MustDrop()
+}
+
+func (r *adagradRule) setMomentum(m float64) error {
+	err := fmt.Errorf("Adagrad optimizer does not have momentum")
+	return err
+}
+
+// LAMB optimizer:
+// ===============
+
+// LAMBConfig holds parameters for building LAMB (Layer-wise Adaptive Moments
+// for Batch training) optimizer.
+//
+// NOTE. LAMB is only implemented natively in Go.
+// Ref. https://arxiv.org/abs/1904.00962
+type LAMBConfig struct {
+	Beta1 float64
+	Beta2 float64
+	Eps   float64
+	Wd    float64
+}
+
+// DefaultLAMBConfig creates LAMBConfig with default values.
+func DefaultLAMBConfig() *LAMBConfig {
+	return &LAMBConfig{
+		Beta1: 0.9,
+		Beta2: 0.999,
+		Eps:   1e-6,
+		Wd:    0.0,
+	}
+}
+
+// NewLAMBConfig creates LAMBConfig with specified values.
+func NewLAMBConfig(beta1, beta2, eps, wd float64) *LAMBConfig {
+	return &LAMBConfig{
+		Beta1: beta1,
+		Beta2: beta2,
+		Eps:   eps,
+		Wd:    wd,
+	}
+}
+
+// Implement OptimizerConfig interface for LAMBConfig
+func (c *LAMBConfig) buildOpt(lr float64) (optimizer, error) {
+	return newNativeOptimizer(lr, &lambRule{*c}), nil
+}
+
+func (c *LAMBConfig) Build(vs *VarStore, lr float64) (*Optimizer, error) {
+	return defaultBuild(c, vs, lr)
+}
+
+type lambRule struct {
+	LAMBConfig
+}
+
+func (r *lambRule) update(p *nativeParam, grad *ts.Tensor, lr float64) {
+	upd := adamMoments(p, grad, r.Beta1, r.Beta2, r.Eps)
+	if r.Wd != 0 {
+		addScaled_(upd, p.x, r.Wd)
+	}
+
+	// Trust ratio ||x|| / ||update||
+	wNorm := p.x.MustNorm(false).Float64Values(true)[0]
+	uNorm := upd.MustNorm(false).Float64Values(true)[0]
+	trust := 1.0
+	if wNorm > 0 && uNorm > 0 {
+		trust = wNorm / uNorm
+	}
+
+	addScaled_(p.x, upd, -lr*trust)
+	upd.MustDrop()
+}
+
+func (r *lambRule) setMomentum(m float64) error {
+	r.Beta1 = m
+	return nil
+}
+
+// Lion optimizer:
+// ===============
+
+// LionConfig holds parameters for building Lion (EvoLved Sign Momentum) optimizer.
+//
+// NOTE. Lion is only implemented natively in Go.
+// Ref. https://arxiv.org/abs/2302.06675
+type LionConfig struct {
+	Beta1 float64
+	Beta2 float64
+	Wd    float64
+}
+
+// DefaultLionConfig creates LionConfig with default values.
+func DefaultLionConfig() *LionConfig {
+	return &LionConfig{
+		Beta1: 0.9,
+		Beta2: 0.99,
+		Wd:    0.0,
+	}
+}
+
+// NewLionConfig creates LionConfig with specified values.
+func NewLionConfig(beta1, beta2, wd float64) *LionConfig {
+	return &LionConfig{
+		Beta1: beta1,
+		Beta2: beta2,
+		Wd:    wd,
+	}
+}
+
+// Implement OptimizerConfig interface for LionConfig
+func (c *LionConfig) buildOpt(lr float64) (optimizer, error) {
+	return newNativeOptimizer(lr, &lionRule{*c}), nil
+}
+
+func (c *LionConfig) Build(vs *VarStore, lr float64) (*Optimizer, error) {
+	return defaultBuild(c, vs, lr)
+}
+
+type lionRule struct {
+	LionConfig
+}
+
+func (r *lionRule) update(p *nativeParam, grad *ts.Tensor, lr float64) {
+	if r.Wd != 0 {
+		p.x.MustMulScalar_(ts.FloatScalar(1 - lr*r.Wd))
+	}
+
+	m := p.stateOf("exp_avg", 0)
+	c := m.MustMulScalar(ts.FloatScalar(r.Beta1), false)
+	addScaled_(c, grad, 1-r.Beta1)
+	c = c.MustSign(true)
+	addScaled_(p.x, c, -lr)
+	c.MustDrop()
+
+	m.MustLerp_(grad, ts.FloatScalar(1-r.Beta2))
+}
+
+func (r *lionRule) setMomentum(m float64) error {
+	r.Beta1 = m
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"sort"
 
 	"github.com/sugarme/gotch/ts"
 )
@@ -13,16 +14,49 @@ import (
 // Optimizer is a struct object to run gradient descent.
 type Optimizer struct {
 	varstore *VarStore
-	opt      *ts.COptimizer
+	opt      optimizer
 	// variablesInOptimizer uint8
 	variablesInOptimizer map[string]struct{}
 	config               interface{}
 	stepCount            int
 }
 
+// optimizer is backend of Optimizer. It is implemented by libtorch `ts.COptimizer`
+// and Go-native optimizer.
+type optimizer interface {
+	AddParameter(param *ts.Tensor, group uint) error
+	SetLearningRate(lr float64) error
+	GetLearningRates() ([]float64, error)
+	SetLearningRates(lrs []float64) error
+	ParamGroupNum() (int64, error)
+	AddParamGroup(tensors []*ts.Tensor) error
+	SetMomentum(m float64) error
+	ZeroGrad() error
+	Step() error
+}
+
+// cOptimizer wraps result of libtorch optimizer constructor as optimizer backend.
+func cOptimizer(opt *ts.COptimizer, err error) (optimizer, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return opt, nil
+}
+
+// addParameter adds a named variable to optimizer backend.
+func addParameter(opt optimizer, name string, x *ts.Tensor, group uint) error {
+	if o, ok := opt.(*nativeOptimizer); ok {
+		o.addParameter(name, x, group)
+		return nil
+	}
+
+	return opt.AddParameter(x, group)
+}
+
 // OptimizerConfig defines Optimizer configurations. These configs can be used to build optimizer.
 type OptimizerConfig interface {
-	buildCOpt(lr float64) (*ts.COptimizer, error)
+	buildOpt(lr float64) (optimizer, error)
 
 	// Build builds an optimizer with the specified learning rate handling variables stored in `vs`.
 	//
@@ -38,15 +72,23 @@ type OptimizerConfig interface {
 
 // defaultBuild is `default` Build method for OptimizerConfig interface
 func defaultBuild(config OptimizerConfig, vs *VarStore, lr float64) (*Optimizer, error) {
-	opt, err := config.buildCOpt(lr)
+	opt, err := config.buildOpt(lr)
 	if err != nil {
 		return nil, err
 	}
 
+	// NOTE. variables are added in name order so that native optimizer state is deterministic.
+	var varNames []string
+	for name := range vs.vars {
+		varNames = append(varNames, name)
+	}
+	sort.Strings(varNames)
+
 	names := make(map[string]struct{})
-	for name, v := range vs.vars {
+	for _, name := range varNames {
+		v := vs.vars[name]
 		if v.Trainable {
-			if err = opt.AddParameter(v.Tensor, v.Group); err != nil {
+			if err = addParameter(opt, name, v.Tensor, v.Group); err != nil {
 				err = fmt.Errorf("Optimizer defaultBuild - AddParameter failed: %w\n", err)
 				return nil, err
 			}
@@ -95,8 +137,8 @@ func NewSGDConfig(momentum, dampening, wd float64, nesterov bool) *SGDConfig {
 }
 
 // Implement OptimizerConfig interface for SGDConfig
-func (c *SGDConfig) buildCOpt(lr float64) (*ts.COptimizer, error) {
-	return ts.Sgd(lr, c.Momentum, c.Dampening, c.Wd, c.Nesterov)
+func (c *SGDConfig) buildOpt(lr float64) (optimizer, error) {
+	return cOptimizer(ts.Sgd(lr, c.Momentum, c.Dampening, c.Wd, c.Nesterov))
 }
 
 func (c *SGDConfig) Build(vs *VarStore, lr float64) (*Optimizer, error) {
@@ -131,8 +173,8 @@ func NewAdamConfig(beta1, beta2, wd float64) *AdamConfig {
 }
 
 // Implement OptimizerConfig interface for AdamConfig
-func (c *AdamConfig) buildCOpt(lr float64) (*ts.COptimizer, error) {
-	return ts.Adam(lr, c.Beta1, c.Beta2, c.Wd)
+func (c *AdamConfig) buildOpt(lr float64) (optimizer, error) {
+	return cOptimizer(ts.Adam(lr, c.Beta1, c.Beta2, c.Wd))
 }
 
 func (c *AdamConfig) Build(vs *VarStore, lr float64) (*Optimizer, error) {
@@ -167,8 +209,8 @@ func NewAdamWConfig(beta1, beta2, wd float64) *AdamWConfig {
 }
 
 // Implement OptimizerConfig interface for AdamWConfig
-func (c *AdamWConfig) buildCOpt(lr float64) (*ts.COptimizer, error) {
-	return ts.AdamW(lr, c.Beta1, c.Beta2, c.Wd)
+func (c *AdamWConfig) buildOpt(lr float64) (optimizer, error) {
+	return cOptimizer(ts.AdamW(lr, c.Beta1, c.Beta2, c.Wd))
 }
 
 // Build builds AdamW optimizer
@@ -210,8 +252,8 @@ func NewRMSPropConfig(alpha, eps, wd, momentum float64, centered bool) *RMSPropC
 }
 
 // Implement OptimizerConfig interface for RMSPropConfig
-func (c *RMSPropConfig) buildCOpt(lr float64) (*ts.COptimizer, error) {
-	return ts.RmsProp(lr, c.Alpha, c.Eps, c.Wd, c.Momentum, c.Centered)
+func (c *RMSPropConfig) buildOpt(lr float64) (optimizer, error) {
+	return cOptimizer(ts.RmsProp(lr, c.Alpha, c.Eps, c.Wd, c.Momentum, c.Centered))
 }
 
 func (c *RMSPropConfig) Build(vs *VarStore, lr float64) (*Optimizer, error) {
@@ -237,7 +279,7 @@ func (opt *Optimizer) addMissingVariables() {
 		log.Println("INFO: Optimizer.addMissingVariables()...")
 		for name, x := range trainables {
 			if _, ok := opt.variablesInOptimizer[name]; !ok {
-				opt.opt.AddParameter(x.tensor, x.group)
+				addParameter(opt.opt, name, x.tensor, x.group)
 				opt.variablesInOptimizer[name] = struct{}{}
 			}
 		}
@@ -403,8 +445,6 @@ func (opt *Optimizer) ClipGradNorm(max float64, opts ...ClipOpt) error {
 		option(o)
 	}
 
-	opt.varstore.Lock()
-	defer opt.varstore.Unlock()
 	parameters := opt.varstore.TrainableVariables()
 	if len(parameters) == 0 {
 		// return ts.MustOfSlice([]float64{0.0}), nil
@@ -422,19 +462,19 @@ func (opt *Optimizer) ClipGradNorm(max float64, opts ...ClipOpt) error {
 	dtype := parameters[0].DType()
 
 	if o.NormType == math.Inf(1) {
-		for _, v := range opt.varstore.vars {
-			n := v.Tensor.MustGrad(false).MustDetach(true).MustAbs(true).MustMax(true).MustTo(device, true)
+		for _, x := range parameters {
+			n := x.MustGrad(false).MustDetach(true).MustAbs(true).MustMax(true).MustTo(device, true)
 			norms = append(norms, n)
 		}
 		// total_norm = norms[0] if len(norms) == 1 else torch.max(torch.stack(norms))
 		totalNorm = ts.MustStack(norms, 0).MustMax(true)
 	} else {
-		for _, v := range opt.varstore.vars {
-			// x := v.Tensor.MustGrad(false).MustNorm(true)
+		for _, p := range parameters {
+			// x := p.MustGrad(false).MustNorm(true)
 
 			// NOTE. tensor.Norm() is going to be deprecated. So use linalg_norm
 			// Ref. https://pytorch.org/docs/stable/generated/torch.linalg.norm.html#torch.linalg.norm
-			x := v.Tensor.MustGrad(false).MustDetach(true).MustLinalgNorm(ts.FloatScalar(o.NormType), nil, false, dtype, true)
+			x := p.MustGrad(false).MustDetach(true).MustLinalgNorm(ts.FloatScalar(o.NormType), nil, false, dtype, true)
 			norms = append(norms, x)
 		}
 	}
@@ -463,12 +503,10 @@ func (opt *Optimizer) ClipGradNorm(max float64, opts ...ClipOpt) error {
 	if clipCoef > 1.0 {
 		clipCoef = 1.0
 	}
-	for _, v := range opt.varstore.vars {
-		if v.Trainable {
-			// p.grad.detach().mul_(clip_coef_clamped.to(p.grad.device))
-			// v.Tensor.MustGrad(false).MustDetach(true).MustMulScalar_(ts.FloatScalar(clipCoef))
-			v.Tensor.MustGrad(false).MustMulScalar_(ts.FloatScalar(clipCoef))
-		}
+	for _, p := range parameters {
+		// p.grad.detach().mul_(clip_coef_clamped.to(p.grad.device))
+		// p.MustGrad(false).MustDetach(true).MustMulScalar_(ts.FloatScalar(clipCoef))
+		p.MustGrad(false).MustMulScalar_(ts.FloatScalar(clipCoef))
 	}
 
 	return nil
@@ -565,3 +603,60 @@ func (opt *Optimizer) AddParamGroup(tensors []*ts.Tensor) {
 		log.Fatalf("Optimizer - ParamGroupNum  method call error: %v\n", err)
 	}
 }
+
+// StateDict returns optimizer state, i.e. learning rates of parameter groups
+// and per-variable state tensors, as named tensors.
+//
+// NOTE. It is only supported by optimizers implemented in Go (see `NativeConfig()`).
+// Returned tensors are new tensors (sharing data with optimizer state) owned
+// by the caller, who must drop them.
+func (opt *Optimizer) StateDict() ([]ts.NamedTensor, error) {
+	o, ok := opt.opt.(*nativeOptimizer)
+	if !ok {
+		err := fmt.Errorf("Optimizer.StateDict() failed: state of libtorch optimizer is not accessible. Use a native optimizer instead")
+		return nil, err
+	}
+
+	return o.stateDict(), nil
+}
+
+// LoadStateDict loads optimizer state from named tensors returned by `StateDict()`.
+func (opt *Optimizer) LoadStateDict(namedTensors []ts.NamedTensor) error {
+	o, ok := opt.opt.(*nativeOptimizer)
+	if !ok {
+		err := fmt.Errorf("Optimizer.LoadStateDict() failed: state of libtorch optimizer is not accessible. Use a native optimizer instead")
+		return err
+	}
+
+	return o.loadStateDict(namedTensors)
+}
+
+// SaveState saves optimizer state to a safetensors file.
+func (opt *Optimizer) SaveState(filepath string) error {
+	namedTensors, err := opt.StateDict()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, nt := range namedTensors {
+			nt.Tensor.MustDrop()
+		}
+	}()
+
+	return ts.WriteSafetensors(namedTensors, filepath)
+}
+
+// LoadState loads optimizer state from a safetensors file saved by `SaveState()`.
+func (opt *Optimizer) LoadState(filepath string) error {
+	namedTensors, err := ts.ReadSafetensors(filepath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, nt := range namedTensors {
+			nt.Tensor.MustDrop()
+		}
+	}()
+
+	return opt.LoadStateDict(namedTensors)
+}
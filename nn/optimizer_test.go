@@ -2,6 +2,8 @@ package nn_test
 
 import (
 	"fmt"
+	"math"
+	"path/filepath"
 	"testing"
 
 	"github.com/sugarme/gotch"
@@ -74,3 +76,171 @@ func TestClipGradNorm(t *testing.T) {
 func TestClipGradValue(t *testing.T) {
 	// TODO
 }
+
+// linearRegression creates a 1D linear model and data y = x * 0.42 + 1.337.
+func linearRegression(vs *nn.VarStore) (model *nn.Linear, x, y *ts.Tensor) {
+	x = ts.MustArangeStart(ts.IntScalar(1), ts.IntScalar(15), gotch.Float, gotch.CPU).MustView([]int64{-1, 1}, true).MustDivScalar(ts.FloatScalar(15), true)
+	y = x.MustMulScalar(ts.FloatScalar(0.42), false).MustAddScalar(ts.FloatScalar(1.337), false)
+	cfg := &nn.LinearConfig{
+		WsInit: nn.NewConstInit(0.0),
+		BsInit: nn.NewConstInit(0.0),
+		Bias:   true,
+	}
+	model = nn.NewLinear(vs.Root(), 1, 1, cfg)
+
+	return model, x, y
+}
+
+func TestNativeOptimizers(t *testing.T) {
+	configs := map[string]nn.OptimizerConfig{
+		"sgd":     nn.NativeConfig(nn.NewSGDConfig(0.9, 0, 0, true)),
+		"adam":    nn.NativeConfig(nn.DefaultAdamConfig()),
+		"adamw":   nn.NativeConfig(nn.DefaultAdamWConfig()),
+		"rmsprop": nn.NativeConfig(nn.NewRMSPropConfig(0.99, 1e-8, 0, 0.5, true)),
+		"adagrad": nn.DefaultAdagradConfig(),
+		"lamb":    nn.DefaultLAMBConfig(),
+		"lion":    nn.DefaultLionConfig(),
+	}
+
+	for name, cfg := range configs {
+		vs := nn.NewVarStore(gotch.CPU)
+		model, x, y := linearRegression(vs)
+		lr := 5e-2
+		if name == "lion" {
+			lr = 1e-2
+		}
+		opt, err := cfg.Build(vs, lr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		initialLoss := x.Apply(model).MustMseLoss(y, 1, true).Float64Values(true)[0]
+		for i := 0; i < 200; i++ {
+			loss := x.Apply(model).MustMseLoss(y, 1, true)
+			opt.MustBackwardStep(loss)
+			loss.MustDrop()
+		}
+		finalLoss := x.Apply(model).MustMseLoss(y, 1, true).Float64Values(true)[0]
+		if finalLoss > initialLoss*0.1 {
+			t.Errorf("%s: expect final loss < %v, got %v", name, initialLoss*0.1, finalLoss)
+		}
+	}
+}
+
+func TestNativeOptimizer_SGD(t *testing.T) {
+	// Native SGD without momentum is equivalent to libtorch SGD.
+	train := func(cfg nn.OptimizerConfig) []float64 {
+		vs := nn.NewVarStore(gotch.CPU)
+		model, x, y := linearRegression(vs)
+		opt, err := cfg.Build(vs, 1e-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			loss := x.Apply(model).MustMseLoss(y, 1, true)
+			opt.MustBackwardStep(loss)
+			loss.MustDrop()
+		}
+		return append(model.Ws.Float64Values(), model.Bs.Float64Values()...)
+	}
+
+	want := train(nn.DefaultSGDConfig())
+	got := train(nn.NativeConfig(nn.DefaultSGDConfig()))
+	for i := range want {
+		if math.Abs(want[i]-got[i]) > 1e-6 {
+			t.Errorf("want %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+func TestOptimizer_StateDict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "optim.safetensors")
+
+	vs := nn.NewVarStore(gotch.CPU)
+	model, x, y := linearRegression(vs)
+	opt, err := nn.NativeConfig(nn.DefaultAdamConfig()).Build(vs, 1e-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := func(opt *nn.Optimizer) {
+		loss := x.Apply(model).MustMseLoss(y, 1, true)
+		opt.MustBackwardStep(loss)
+		loss.MustDrop()
+	}
+	for i := 0; i < 5; i++ {
+		step(opt)
+	}
+
+	stateDict, err := opt.StateDict()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, nt := range stateDict {
+		names[nt.Name] = true
+		nt.Tensor.MustDrop()
+	}
+	for _, name := range []string{"param_groups.lr", "state.weight.step", "state.weight.exp_avg", "state.bias.exp_avg_sq"} {
+		if !names[name] {
+			t.Errorf("expect %q in state dict", name)
+		}
+	}
+
+	if err := opt.SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+	weights := model.Ws.MustDetach(false).MustZerosLike(true)
+	weights.Copy_(model.Ws)
+	bias := model.Bs.MustDetach(false).MustZerosLike(true)
+	bias.Copy_(model.Bs)
+
+	// Continue training with original optimizer.
+	step(opt)
+	want := model.Ws.Float64Values()
+
+	// Restore variables and continue training with a new optimizer loaded from saved state.
+	ts.NoGrad(func() {
+		model.Ws.Copy_(weights)
+		model.Bs.Copy_(bias)
+	})
+	opt1, err := nn.NativeConfig(nn.DefaultAdamConfig()).Build(vs, 1e-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := opt1.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	step(opt1)
+	got := model.Ws.Float64Values()
+
+	if math.Abs(want[0]-got[0]) > 1e-6 {
+		t.Errorf("want weight %v after resuming, got %v", want, got)
+	}
+
+	// libtorch optimizers do not expose state.
+	optC, err := nn.DefaultAdamConfig().Build(vs, 1e-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := optC.StateDict(); err == nil {
+		t.Errorf("expect error from StateDict() of libtorch optimizer")
+	}
+}
+
+func TestNativeOptimizer_Scheduler(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	linearRegression(vs)
+	opt, err := nn.NativeConfig(nn.DefaultSGDConfig()).Build(vs, 1.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := nn.NewStepLR(opt, 2, 0.5).Build()
+	for i := 0; i < 4; i++ {
+		s.Step()
+	}
+	if got := opt.GetLRs(); len(got) != 1 || got[0] != 0.25 {
+		t.Errorf("want learning rates [0.25], got %v", got)
+	}
+}
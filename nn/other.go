@@ -1,6 +1,8 @@
 package nn
 
 import (
+	"math/rand"
+
 	"github.com/sugarme/gotch/ts"
 )
 
@@ -10,6 +12,7 @@ import (
 // Dropout represents a neural network dropout layer.
 type Dropout struct {
 	dropoutProb float64
+	rng         *rand.Rand
 }
 
 // NewDropout creates a new Dropout layer
@@ -19,9 +22,39 @@ func NewDropout(p float64) *Dropout {
 	}
 }
 
+// Prob returns dropout probability.
+func (d *Dropout) Prob() float64 {
+	return d.dropoutProb
+}
+
+// SetRand sets Go random generator to draw dropout masks.
+//
+// NOTE. By default, masks are drawn by libtorch random generator which can be
+// seeded with `gotch.ManualSeed()`. Drawing masks in Go is slower but
+// independent of other libtorch random operations.
+func (d *Dropout) SetRand(r *rand.Rand) *Dropout {
+	d.rng = r
+	return d
+}
+
 // ForwardT implements ModuleT for Dropout layer.
 func (d *Dropout) ForwardT(input *ts.Tensor, train bool) (retVal *ts.Tensor) {
-	return ts.MustDropout(input, d.dropoutProb, train)
+	if d.rng == nil || !train || d.dropoutProb == 0 {
+		return ts.MustDropout(input, d.dropoutProb, train)
+	}
+
+	scale := 1.0 / (1.0 - d.dropoutProb)
+	values := make([]float32, input.Numel())
+	for i := range values {
+		if d.rng.Float64() >= d.dropoutProb {
+			values[i] = float32(scale)
+		}
+	}
+	mask := ts.MustOfSlice(values).MustView(input.MustSize(), true).MustTotype(input.DType(), true).MustTo(input.MustDevice(), true)
+	retVal = input.MustMul(mask, false)
+	mask.MustDrop()
+
+	return retVal
 }
 
 // Parameter:
@@ -149,3 +182,10 @@ func NewMaxPool2D(kernelSize []int64, opts ...MaxPool2DOpt) *MaxPool2D {
 func (m *MaxPool2D) Forward(x *ts.Tensor) *ts.Tensor {
 	return x.MustMaxPool2d(m.Kernel, m.Stride, m.Padding, m.Dilation, m.CeilMode, false)
 }
+
+// ForwardT implements ModuleT for MaxPool2D layer.
+//
+// NOTE: train param will not be used.
+func (m *MaxPool2D) ForwardT(x *ts.Tensor, train bool) *ts.Tensor {
+	return m.Forward(x)
+}
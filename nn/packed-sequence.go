@@ -0,0 +1,192 @@
+package nn
+
+// Packed sequences of variable-length sequences for recurrent layers.
+//
+// Ref. https://pytorch.org/docs/stable/generated/torch.nn.utils.rnn.PackedSequence.html
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// PackedSequence holds data and list of batch sizes of a packed sequence.
+//
+// NOTE. It should not be created manually but by `PackPaddedSequence()` or `PackSequence()`.
+type PackedSequence struct {
+	Data       *ts.Tensor // [sum(lengths), *]
+	BatchSizes *ts.Tensor // [maxLength] int64 on CPU. Batch size at each time step.
+
+	// SortedIndices is permutation of batch sorting sequences by length in
+	// descending order. Nil if input sequences were already sorted.
+	SortedIndices *ts.Tensor
+	// UnsortedIndices is inverse permutation of SortedIndices.
+	UnsortedIndices *ts.Tensor
+}
+
+// MustDrop deletes tensors of packed sequence.
+func (ps *PackedSequence) MustDrop() {
+	for _, x := range []*ts.Tensor{ps.Data, ps.BatchSizes, ps.SortedIndices, ps.UnsortedIndices} {
+		if x != nil {
+			x.MustDrop()
+		}
+	}
+}
+
+// BatchDim returns number of sequences in packed sequence.
+func (ps *PackedSequence) BatchDim() int64 {
+	return ps.BatchSizes.Int64Values()[0]
+}
+
+// withData returns a new packed sequence of given data sharing batch sizes and indices.
+func (ps *PackedSequence) withData(data *ts.Tensor) *PackedSequence {
+	retVal := &PackedSequence{
+		Data:       data,
+		BatchSizes: ps.BatchSizes.MustShallowClone(),
+	}
+	if ps.SortedIndices != nil {
+		retVal.SortedIndices = ps.SortedIndices.MustShallowClone()
+		retVal.UnsortedIndices = ps.UnsortedIndices.MustShallowClone()
+	}
+
+	return retVal
+}
+
+// PackPaddedSequence packs a tensor containing padded sequences of variable length.
+//
+// input is [seqLen, batch, *] or [batch, seqLen, *] if batchFirst is true.
+// lengths is a 1D tensor of sequence lengths. If enforceSorted is true,
+// sequences should be sorted by length in descending order. Otherwise, they
+// are sorted and the permutation is kept in packed sequence.
+func PackPaddedSequence(input, lengths *ts.Tensor, batchFirst bool, enforceSorted bool) (*PackedSequence, error) {
+	lengths, err := lengths.To(gotch.CPU, false)
+	if err != nil {
+		return nil, err
+	}
+	lengths = lengths.MustTotype(gotch.Int64, true)
+	defer lengths.MustDrop()
+
+	seqLen := input.MustSize()[0]
+	if batchFirst {
+		seqLen = input.MustSize()[1]
+	}
+	lens := lengths.Int64Values()
+	for i, l := range lens {
+		switch {
+		case l <= 0 || l > seqLen:
+			err := fmt.Errorf("PackPaddedSequence() failed: invalid length %v at %v (sequence length: %v)", l, i, seqLen)
+			return nil, err
+		case enforceSorted && i > 0 && l > lens[i-1]:
+			err := fmt.Errorf("PackPaddedSequence() failed: lengths must be sorted in descending order when enforceSorted is true")
+			return nil, err
+		}
+	}
+
+	var sortedIndices, unsortedIndices *ts.Tensor
+	if enforceSorted {
+		input = input.MustShallowClone()
+		lengths = lengths.MustShallowClone()
+	} else {
+		var sortedLengths *ts.Tensor
+		sortedLengths, sortedIndices = lengths.MustSort(0, true, false)
+		unsortedIndices = sortedIndices.MustArgsort(0, false, false)
+		lengths = sortedLengths
+
+		batchDim := int64(1)
+		if batchFirst {
+			batchDim = 0
+		}
+		idx := sortedIndices.MustTo(input.MustDevice(), false)
+		input = input.MustIndexSelect(batchDim, idx, false)
+		idx.MustDrop()
+	}
+	defer input.MustDrop()
+	defer lengths.MustDrop()
+
+	data, batchSizes := ts.Must_PackPaddedSequence(input, lengths, batchFirst)
+
+	return &PackedSequence{
+		Data:            data,
+		BatchSizes:      batchSizes,
+		SortedIndices:   sortedIndices,
+		UnsortedIndices: unsortedIndices,
+	}, nil
+}
+
+// MustPackPaddedSequence packs a tensor containing padded sequences of variable length.
+// It panics if error occurred.
+func MustPackPaddedSequence(input, lengths *ts.Tensor, batchFirst bool, enforceSorted bool) *PackedSequence {
+	ps, err := PackPaddedSequence(input, lengths, batchFirst, enforceSorted)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return ps
+}
+
+// PackSequence packs a list of variable length tensors of shape [length, *].
+func PackSequence(sequences []*ts.Tensor, enforceSorted bool) (*PackedSequence, error) {
+	lens := make([]int64, len(sequences))
+	for i, x := range sequences {
+		lens[i] = x.MustSize()[0]
+	}
+	lengths := ts.MustOfSlice(lens)
+	defer lengths.MustDrop()
+
+	padded := ts.MustPadSequence(sequences, false, 0.0)
+	defer padded.MustDrop()
+
+	return PackPaddedSequence(padded, lengths, false, enforceSorted)
+}
+
+// PadPackedSequence pads a packed sequence. It is an inverse of `PackPaddedSequence()`.
+//
+// It returns padded tensor of shape [seqLen, batch, *] (or [batch, seqLen, *] if
+// batchFirst is true) in original batch order, and a CPU int64 tensor of sequence
+// lengths. If totalLength > 0, output is padded to totalLength steps.
+func PadPackedSequence(ps *PackedSequence, batchFirst bool, paddingValue float64, totalLength int64) (*ts.Tensor, *ts.Tensor, error) {
+	maxLength := ps.BatchSizes.MustSize()[0]
+	if totalLength > 0 {
+		if totalLength < maxLength {
+			err := fmt.Errorf("PadPackedSequence() failed: totalLength (%v) is less than max sequence length (%v)", totalLength, maxLength)
+			return nil, nil, err
+		}
+		maxLength = totalLength
+	}
+
+	padded, lengths := ts.Must_PadPackedSequence(ps.Data, ps.BatchSizes, batchFirst, ts.FloatScalar(paddingValue), maxLength)
+
+	if ps.UnsortedIndices != nil {
+		batchDim := int64(1)
+		if batchFirst {
+			batchDim = 0
+		}
+		idx := ps.UnsortedIndices.MustTo(padded.MustDevice(), false)
+		padded = padded.MustIndexSelect(batchDim, idx, true)
+		idx.MustDrop()
+		lengths = lengths.MustIndexSelect(0, ps.UnsortedIndices, true)
+	}
+
+	return padded, lengths, nil
+}
+
+// MustPadPackedSequence pads a packed sequence. It panics if error occurred.
+func MustPadPackedSequence(ps *PackedSequence, batchFirst bool, paddingValue float64, totalLength int64) (*ts.Tensor, *ts.Tensor) {
+	padded, lengths, err := PadPackedSequence(ps, batchFirst, paddingValue, totalLength)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return padded, lengths
+}
+
+// permuteState selects batch entries (dim 1) of state tensor x with CPU indices.
+func permuteState(x, indices *ts.Tensor) *ts.Tensor {
+	idx := indices.MustTo(x.MustDevice(), false)
+	retVal := x.MustIndexSelect(1, idx, false)
+	idx.MustDrop()
+
+	return retVal
+}
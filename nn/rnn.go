@@ -70,6 +70,21 @@ func DefaultRNNConfig() *RNNConfig {
 	}
 }
 
+// NumDirections returns 2 if RNN is bidirectional, otherwise 1.
+func (c *RNNConfig) NumDirections() int64 {
+	if c.Bidirectional {
+		return 2
+	}
+	return 1
+}
+
+// LayerState selects state of a given layer and direction (0: forward, 1: reverse)
+// from a state tensor of shape [numLayers*numDirections, batch, hidden] as
+// returned by LSTM and GRU. The returned tensor has shape [batch, hidden].
+func LayerState(state *ts.Tensor, numDirections, layer, direction int64) *ts.Tensor {
+	return state.MustSelect(0, layer*numDirections+direction, false)
+}
+
 // A Long Short-Term Memory (LSTM) layer.
 //
 // https://en.wikipedia.org/wiki/Long_short-term_memory
@@ -198,6 +213,50 @@ func (l *LSTM) SeqInit(input *ts.Tensor, inState State) (*ts.Tensor, State) {
 	}
 }
 
+// SeqPacked applies LSTM on a packed sequence of variable-length sequences
+// with zero initial state.
+//
+// Padded time steps are skipped, i.e. final states are taken at last valid
+// step of each sequence. Final states are in original batch order.
+func (l *LSTM) SeqPacked(input *PackedSequence) (*PackedSequence, State) {
+	inState := l.ZeroState(input.BatchDim())
+
+	output, state := l.SeqInitPacked(input, inState)
+
+	inState.(*LSTMState).Tensor1.MustDrop()
+	inState.(*LSTMState).Tensor2.MustDrop()
+
+	return output, state
+}
+
+// SeqInitPacked applies LSTM on a packed sequence with given initial state.
+// Batch dimension of state is in original batch order.
+func (l *LSTM) SeqInitPacked(input *PackedSequence, inState State) (*PackedSequence, State) {
+	h0 := inState.(*LSTMState).Tensor1
+	c0 := inState.(*LSTMState).Tensor2
+	if input.SortedIndices != nil {
+		h0 = permuteState(h0, input.SortedIndices)
+		c0 = permuteState(c0, input.SortedIndices)
+		defer h0.MustDrop()
+		defer c0.MustDrop()
+	}
+
+	output, h, c := ts.MustLstmData(input.Data, input.BatchSizes, []*ts.Tensor{h0, c0}, l.flatWeights, l.config.HasBiases, l.config.NumLayers, l.config.Dropout, l.config.Train, l.config.Bidirectional)
+
+	if input.UnsortedIndices != nil {
+		hx, cx := h, c
+		h = permuteState(hx, input.UnsortedIndices)
+		c = permuteState(cx, input.UnsortedIndices)
+		hx.MustDrop()
+		cx.MustDrop()
+	}
+
+	return input.withData(output), &LSTMState{
+		Tensor1: h,
+		Tensor2: c,
+	}
+}
+
 // GRUState is a GRU state. It contains a single tensor.
 type GRUState struct {
 	Tensor *ts.Tensor
@@ -307,3 +366,38 @@ func (g *GRU) SeqInit(input *ts.Tensor, inState State) (*ts.Tensor, State) {
 
 	return output, &GRUState{Tensor: h}
 }
+
+// SeqPacked applies GRU on a packed sequence of variable-length sequences
+// with zero initial state.
+//
+// Padded time steps are skipped, i.e. final states are taken at last valid
+// step of each sequence. Final states are in original batch order.
+func (g *GRU) SeqPacked(input *PackedSequence) (*PackedSequence, State) {
+	inState := g.ZeroState(input.BatchDim())
+
+	output, state := g.SeqInitPacked(input, inState)
+
+	inState.(*GRUState).Tensor.MustDrop()
+
+	return output, state
+}
+
+// SeqInitPacked applies GRU on a packed sequence with given initial state.
+// Batch dimension of state is in original batch order.
+func (g *GRU) SeqInitPacked(input *PackedSequence, inState State) (*PackedSequence, State) {
+	h0 := inState.(*GRUState).Tensor
+	if input.SortedIndices != nil {
+		h0 = permuteState(h0, input.SortedIndices)
+		defer h0.MustDrop()
+	}
+
+	output, h := ts.MustGruData(input.Data, input.BatchSizes, h0, g.flatWeights, g.config.HasBiases, g.config.NumLayers, g.config.Dropout, g.config.Train, g.config.Bidirectional)
+
+	if input.UnsortedIndices != nil {
+		hx := h
+		h = permuteState(hx, input.UnsortedIndices)
+		hx.MustDrop()
+	}
+
+	return input.withData(output), &GRUState{Tensor: h}
+}
@@ -147,3 +147,93 @@ func TestLSTM(t *testing.T) {
 	cfg.Bidirectional = true
 	lstmTest(cfg, t)
 }
+
+func TestLSTM_SeqPacked(t *testing.T) {
+	var (
+		inputDim  int64 = 2
+		hiddenDim int64 = 3
+		maxLen    int64 = 4
+	)
+	lengths := []int64{2, 4, 3} // not sorted
+
+	for _, bidirectional := range []bool{false, true} {
+		cfg := nn.DefaultRNNConfig()
+		cfg.NumLayers = 2
+		cfg.Bidirectional = bidirectional
+		cfg.Train = false
+
+		vs := nn.NewVarStore(gotch.CPU)
+		lstm := nn.NewLSTM(vs.Root(), inputDim, hiddenDim, cfg)
+
+		batchDim := int64(len(lengths))
+		input := ts.MustRandn([]int64{batchDim, maxLen, inputDim}, gotch.Float, gotch.CPU)
+		packed := nn.MustPackPaddedSequence(input, ts.MustOfSlice(lengths), true, false)
+
+		output, state := lstm.SeqPacked(packed)
+		padded, gotLengths := nn.MustPadPackedSequence(output, true, 0.0, 0)
+
+		wantShape := []int64{batchDim, maxLen, hiddenDim * cfg.NumDirections()}
+		if got := padded.MustSize(); !reflect.DeepEqual(wantShape, got) {
+			t.Errorf("want padded output shape %v, got %v\n", wantShape, got)
+		}
+		if got := gotLengths.Int64Values(); !reflect.DeepEqual(lengths, got) {
+			t.Errorf("want lengths %v, got %v\n", lengths, got)
+		}
+
+		// Final states of each sequence should be the same as running it alone without padding.
+		h := state.(*nn.LSTMState).Tensor1
+		for i, l := range lengths {
+			x := input.MustSelect(0, int64(i), false).MustNarrow(0, 0, l, true).MustUnsqueeze(0, true)
+			_, s := lstm.Seq(x)
+			want := s.(*nn.LSTMState).Tensor1
+			got := h.MustNarrow(1, int64(i), 1, false)
+			if !want.MustAllclose(got, 1e-5, 1e-6, false, false) {
+				t.Errorf("bidirectional=%v: final state of sequence %d mismatched.\nwant: %v\ngot: %v\n", bidirectional, i, want, got)
+			}
+
+			// Last layer, forward direction.
+			hLast := nn.LayerState(h, cfg.NumDirections(), cfg.NumLayers-1, 0).MustSelect(0, int64(i), true)
+			wantLast := nn.LayerState(want, cfg.NumDirections(), cfg.NumLayers-1, 0).MustSelect(0, 0, true)
+			if !wantLast.MustAllclose(hLast, 1e-5, 1e-6, false, false) {
+				t.Errorf("bidirectional=%v: last layer state of sequence %d mismatched\n", bidirectional, i)
+			}
+		}
+	}
+}
+
+func TestGRU_SeqPacked(t *testing.T) {
+	var (
+		inputDim  int64 = 2
+		hiddenDim int64 = 3
+	)
+
+	cfg := nn.DefaultRNNConfig()
+	cfg.Train = false
+	vs := nn.NewVarStore(gotch.CPU)
+	gru := nn.NewGRU(vs.Root(), inputDim, hiddenDim, cfg)
+
+	sequences := []*ts.Tensor{
+		ts.MustRandn([]int64{3, inputDim}, gotch.Float, gotch.CPU),
+		ts.MustRandn([]int64{1, inputDim}, gotch.Float, gotch.CPU),
+		ts.MustRandn([]int64{2, inputDim}, gotch.Float, gotch.CPU),
+	}
+	packed, err := nn.PackSequence(sequences, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, state := gru.SeqPacked(packed)
+	h := state.(*nn.GRUState).Tensor
+	for i, x := range sequences {
+		_, s := gru.Seq(x.MustUnsqueeze(0, false))
+		want := s.(*nn.GRUState).Tensor
+		got := h.MustNarrow(1, int64(i), 1, false)
+		if !want.MustAllclose(got, 1e-5, 1e-6, false, false) {
+			t.Errorf("final state of sequence %d mismatched.\nwant: %v\ngot: %v\n", i, want, got)
+		}
+	}
+
+	if _, err := nn.PackSequence(sequences, true); err == nil {
+		t.Errorf("want error packing unsorted sequences with enforceSorted=true, got nil")
+	}
+}
@@ -57,6 +57,34 @@ func (s *LRScheduler) Step(opts ...SchedulerOption) {
 	s.scheduler.SetLRs(opts...)
 }
 
+// StateDict returns internal state of scheduler (last epoch, counters, ...)
+// which is needed to resume training.
+func (s *LRScheduler) StateDict() (map[string][]float64, error) {
+	sc, ok := s.scheduler.(statefulScheduler)
+	if !ok {
+		err := fmt.Errorf("LRScheduler.StateDict() failed: scheduler %T does not support state saving", s.scheduler)
+		return nil, err
+	}
+
+	return sc.stateFields().dict(), nil
+}
+
+// LoadStateDict restores internal state of scheduler from `StateDict()` output.
+func (s *LRScheduler) LoadStateDict(state map[string][]float64) error {
+	sc, ok := s.scheduler.(statefulScheduler)
+	if !ok {
+		err := fmt.Errorf("LRScheduler.LoadStateDict() failed: scheduler %T does not support state loading", s.scheduler)
+		return err
+	}
+
+	if err := sc.stateFields().load(state); err != nil {
+		err = fmt.Errorf("LRScheduler.LoadStateDict() failed: %w", err)
+		return err
+	}
+
+	return nil
+}
+
 type LambdaFn func(in interface{}) float64
 
 // LamdaLR calculates new learning rate for each parameter group by applying
@@ -1715,3 +1743,109 @@ func (oc *OneCycleLR) Build() *LRScheduler {
 	s.Step()
 	return s
 }
+
+// Scheduler state:
+// ================
+
+// statefulScheduler is a scheduler which state can be saved and restored.
+type statefulScheduler interface {
+	stateFields() schedulerState
+}
+
+// schedulerState maps state names to pointers of scheduler fields of type
+// *int, *float64 or *[]float64.
+type schedulerState map[string]interface{}
+
+func (s schedulerState) dict() map[string][]float64 {
+	dict := make(map[string][]float64, len(s))
+	for name, field := range s {
+		switch v := field.(type) {
+		case *int:
+			dict[name] = []float64{float64(*v)}
+		case *float64:
+			dict[name] = []float64{*v}
+		case *[]float64:
+			dict[name] = append([]float64{}, (*v)...)
+		}
+	}
+
+	return dict
+}
+
+func (s schedulerState) load(dict map[string][]float64) error {
+	for name, field := range s {
+		values, ok := dict[name]
+		if !ok {
+			err := fmt.Errorf("missing state %q", name)
+			return err
+		}
+
+		switch v := field.(type) {
+		case *int, *float64:
+			if len(values) != 1 {
+				err := fmt.Errorf("expected 1 value for state %q, got %v", name, len(values))
+				return err
+			}
+			if x, ok := v.(*int); ok {
+				*x = int(values[0])
+			} else {
+				*(v.(*float64)) = values[0]
+			}
+		case *[]float64:
+			*v = append([]float64{}, values...)
+		}
+	}
+
+	return nil
+}
+
+func (l *LambdaLR) stateFields() schedulerState {
+	return schedulerState{"last_epoch": &l.lastEpoch, "step_count": &l.stepCount, "initial_lrs": &l.initialLRs}
+}
+
+func (m *MultiplicativeLR) stateFields() schedulerState {
+	return schedulerState{"last_epoch": &m.lastEpoch, "step_count": &m.stepCount, "initial_lrs": &m.initialLRs}
+}
+
+func (s *StepLR) stateFields() schedulerState {
+	return schedulerState{"last_epoch": &s.lastEpoch, "step_count": &s.stepCount, "initial_lrs": &s.initialLRs}
+}
+
+func (ms *MultiStepLR) stateFields() schedulerState {
+	return schedulerState{"last_epoch": &ms.lastEpoch, "step_count": &ms.stepCount, "initial_lrs": &ms.initialLRs}
+}
+
+func (e *ExponentialLR) stateFields() schedulerState {
+	return schedulerState{"last_epoch": &e.lastEpoch, "step_count": &e.stepCount, "initial_lrs": &e.initialLRs}
+}
+
+func (ca *CosineAnnealingLR) stateFields() schedulerState {
+	return schedulerState{"last_epoch": &ca.lastEpoch, "step_count": &ca.stepCount, "initial_lrs": &ca.initialLRs}
+}
+
+func (s *ReduceLROnPlateau) stateFields() schedulerState {
+	return schedulerState{
+		"last_epoch":       &s.lastEpoch,
+		"best":             &s.best,
+		"num_bad_epochs":   &s.numBadEpochs,
+		"cooldown_counter": &s.cooldownCounter,
+	}
+}
+
+func (cyc *CyclicLR) stateFields() schedulerState {
+	return schedulerState{"last_epoch": &cyc.lastEpoch, "initial_lrs": &cyc.initialLRs, "max_lrs": &cyc.maxLRs}
+}
+
+func (s *CosineAnnealingWarmRestarts) stateFields() schedulerState {
+	return schedulerState{
+		"last_epoch":  &s.lastEpoch,
+		"step_count":  &s.stepCount,
+		"t_i":         &s.ti,
+		"t_cur":       &s.tcur,
+		"initial_lrs": &s.initialLRs,
+	}
+}
+
+func (oc *OneCycleLR) stateFields() schedulerState {
+	return schedulerState{"last_epoch": &oc.lastEpoch, "initial_lrs": &oc.initialLRs, "max_lrs": &oc.maxLRs, "min_lrs": &oc.minLRs}
+}
@@ -36,6 +36,11 @@ func (s *Sequential) Add(l ts.Module) {
 	s.layers = append(s.layers, l)
 }
 
+// Layers returns sub-layers in order they were added.
+func (s *Sequential) Layers() []ts.Module {
+	return s.layers
+}
+
 // AddFn appends a closure after all the current layers.
 //
 // NOTE: fn should have signature `func(t ts.Tensor) ts.Tensor`
@@ -160,6 +165,11 @@ func (s *SequentialT) Add(l ts.ModuleT) {
 	s.layers = append(s.layers, l)
 }
 
+// Layers returns sub-layers in order they were added.
+func (s *SequentialT) Layers() []ts.ModuleT {
+	return s.layers
+}
+
 // AddFn appends a closure after all the current layers.
 //
 // NOTE: fn should have signature `func(t ts.Tensor) ts.Tensor`
@@ -30,6 +30,8 @@ func DefaultEmbeddingConfig() *EmbeddingConfig {
 type Embedding struct {
 	Ws     *ts.Tensor
 	config *EmbeddingConfig
+
+	hook *hookPoint
 }
 
 // NewEmbedding creates a new Embedding
@@ -37,6 +39,7 @@ func NewEmbedding(vs *Path, numEmbeddings int64, embeddingDim int64, config *Emb
 	return &Embedding{
 		Ws:     vs.MustNewVar("weight", []int64{numEmbeddings, embeddingDim}, config.WsInit),
 		config: config,
+		hook:   newHookPoint(vs),
 	}
 }
 
@@ -45,10 +48,14 @@ func NewEmbedding(vs *Path, numEmbeddings int64, embeddingDim int64, config *Emb
 
 // Forward implements Module interface for Embedding
 func (e *Embedding) Forward(xs *ts.Tensor) *ts.Tensor {
-	return ts.MustEmbedding(e.Ws, xs, e.config.PaddingIdx, e.config.ScaleGradByFreq, e.config.Sparse)
+	return e.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return ts.MustEmbedding(e.Ws, xs, e.config.PaddingIdx, e.config.ScaleGradByFreq, e.config.Sparse)
+	})
 }
 
 // ForwardT implements ModuleT interface for Embedding
 func (e *Embedding) ForwardT(xs *ts.Tensor, train bool) *ts.Tensor {
-	return ts.MustEmbedding(e.Ws, xs, e.config.PaddingIdx, e.config.ScaleGradByFreq, e.config.Sparse)
+	return e.hook.forward(xs, func(xs *ts.Tensor) *ts.Tensor {
+		return ts.MustEmbedding(e.Ws, xs, e.config.PaddingIdx, e.config.ScaleGradByFreq, e.config.Sparse)
+	})
 }
@@ -0,0 +1,375 @@
+package nn
+
+// Model summary: per-layer output shapes, parameter counts and MACs.
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+// LayerSequence is implemented by container modules which forward pass is a
+// chain of sub-layers, e.g. `SequentialT`. Summary() reports each sub-layer of
+// a LayerSequence instead of the container as a whole.
+type LayerSequence interface {
+	ts.ModuleT
+	Layers() []ts.ModuleT
+}
+
+// LayerSummary is summary of a layer.
+type LayerSummary struct {
+	Name               string // index path in containers, e.g. "2.0"
+	Type               string // e.g. "Linear"
+	Depth              int    // nesting level in containers
+	OutputShape        []int64
+	TrainableParams    int64
+	NonTrainableParams int64
+	ActivationBytes    int64 // memory of output
+	MACs               int64 // estimated multiply-accumulates
+}
+
+// ModelSummary is summary of a model for an input shape.
+type ModelSummary struct {
+	InputShape         []int64
+	Layers             []LayerSummary
+	TrainableParams    int64
+	NonTrainableParams int64
+	ParamBytes         int64
+	ActivationBytes    int64
+	MACs               int64
+}
+
+// TotalParams returns number of trainable and non-trainable parameters.
+func (s *ModelSummary) TotalParams() int64 {
+	return s.TrainableParams + s.NonTrainableParams
+}
+
+type SummaryOptions struct {
+	Device gotch.Device
+	DType  gotch.DType
+}
+
+type SummaryOption func(*SummaryOptions)
+
+func defaultSummaryOptions() *SummaryOptions {
+	return &SummaryOptions{
+		Device: gotch.CPU,
+		DType:  gotch.Float,
+	}
+}
+
+// WithSummaryDevice sets device of dummy input. Default=CPU.
+func WithSummaryDevice(device gotch.Device) SummaryOption {
+	return func(o *SummaryOptions) {
+		o.Device = device
+	}
+}
+
+// WithSummaryDType sets dtype of dummy input, e.g. `gotch.Int64` for
+// embedding input. Default=Float.
+func WithSummaryDType(dtype gotch.DType) SummaryOption {
+	return func(o *SummaryOptions) {
+		o.DType = dtype
+	}
+}
+
+// Summary runs a dummy forward pass (zeros of inputShape, including batch
+// dimension) through module in evaluation mode and without gradient, and
+// reports each layer's type, output shape, parameter counts, activation
+// memory and estimated multiply-accumulates (MACs).
+//
+// Module should implement `ts.Module` or `ts.ModuleT`. Sub-layers of
+// `*Sequential` and `LayerSequence` (e.g. `*SequentialT`) are reported
+// recursively. Parameters are counted from `*ts.Tensor` fields of layers;
+// MACs are estimated for linear, convolution and normalization layers.
+//
+// Example:
+//
+//	s, err := nn.Summary(net, []int64{1, 3, 224, 224})
+//	if err != nil {...}
+//	fmt.Println(s)
+func Summary(module interface{}, inputShape []int64, opts ...SummaryOption) (*ModelSummary, error) {
+	o := defaultSummaryOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	s := &ModelSummary{
+		InputShape: inputShape,
+	}
+
+	x := ts.MustZeros(inputShape, o.DType, o.Device)
+	var (
+		out *ts.Tensor
+		err error
+	)
+	ts.NoGrad(func() {
+		out, err = s.summarize(module, x, "", 0)
+	})
+	x.MustDrop()
+	if err != nil {
+		err = fmt.Errorf("Summary() failed: %w", err)
+		return nil, err
+	}
+	out.MustDrop()
+
+	for _, l := range s.Layers {
+		s.TrainableParams += l.TrainableParams
+		s.NonTrainableParams += l.NonTrainableParams
+		s.ActivationBytes += l.ActivationBytes
+		s.MACs += l.MACs
+	}
+
+	return s, nil
+}
+
+func layerName(prefix string, i int) string {
+	if prefix == "" {
+		return fmt.Sprintf("%d", i)
+	}
+	return fmt.Sprintf("%s.%d", prefix, i)
+}
+
+// summarize forwards x through module, records layer summaries and returns
+// output. Input tensor is not deleted.
+func (s *ModelSummary) summarize(module interface{}, x *ts.Tensor, prefix string, depth int) (*ts.Tensor, error) {
+	var layers []interface{}
+	switch m := module.(type) {
+	case *Sequential:
+		for _, l := range m.Layers() {
+			layers = append(layers, l)
+		}
+	case LayerSequence:
+		for _, l := range m.Layers() {
+			layers = append(layers, l)
+		}
+	default:
+		name := prefix
+		if name == "" {
+			name = "0"
+		}
+		return s.summarizeLayer(module, x, name, depth)
+	}
+
+	out := x.MustShallowClone()
+	for i, l := range layers {
+		y, err := s.summarize(l, out, layerName(prefix, i), depth+1)
+		out.MustDrop()
+		if err != nil {
+			return nil, err
+		}
+		out = y
+	}
+
+	return out, nil
+}
+
+func (s *ModelSummary) summarizeLayer(module interface{}, x *ts.Tensor, name string, depth int) (*ts.Tensor, error) {
+	var y *ts.Tensor
+	switch m := module.(type) {
+	case ts.ModuleT:
+		y = m.ForwardT(x, false)
+	case ts.Module:
+		y = m.Forward(x)
+	default:
+		err := fmt.Errorf("layer %q of type %T implements neither ts.Module nor ts.ModuleT", name, module)
+		return nil, err
+	}
+
+	trainable, nonTrainable, nbytes := countParams(module)
+	s.ParamBytes += nbytes
+	s.Layers = append(s.Layers, LayerSummary{
+		Name:               name,
+		Type:               typeName(module),
+		Depth:              depth,
+		OutputShape:        y.MustSize(),
+		TrainableParams:    trainable,
+		NonTrainableParams: nonTrainable,
+		ActivationBytes:    int64(y.Numel() * y.DType().Size()),
+		MACs:               estimateMACs(module, x, y),
+	})
+
+	return y, nil
+}
+
+func typeName(module interface{}) string {
+	t := reflect.TypeOf(module)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Name() == "" {
+		return t.String()
+	}
+	return t.Name()
+}
+
+// moduleTensors returns tensors held by exported fields of module, including
+// nested structs, pointers, slices and maps.
+func moduleTensors(module interface{}) []*ts.Tensor {
+	var (
+		tensors []*ts.Tensor
+		visited = make(map[uintptr]bool)
+		walk    func(v reflect.Value)
+	)
+	tensorType := reflect.TypeOf((*ts.Tensor)(nil))
+
+	walk = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Ptr:
+			if v.IsNil() || visited[v.Pointer()] {
+				return
+			}
+			visited[v.Pointer()] = true
+			if v.Type() == tensorType {
+				tensors = append(tensors, v.Interface().(*ts.Tensor))
+				return
+			}
+			walk(v.Elem())
+		case reflect.Interface:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				if v.Type().Field(i).IsExported() {
+					walk(v.Field(i))
+				}
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		case reflect.Map:
+			iter := v.MapRange()
+			for iter.Next() {
+				walk(iter.Value())
+			}
+		}
+	}
+	walk(reflect.ValueOf(module))
+
+	return tensors
+}
+
+// countParams returns number of trainable and non-trainable parameters of
+// a layer and their memory size in bytes.
+func countParams(module interface{}) (trainable, nonTrainable, nbytes int64) {
+	for _, x := range moduleTensors(module) {
+		if !x.MustDefined() {
+			continue
+		}
+		numel := int64(x.Numel())
+		if x.MustRequiresGrad() {
+			trainable += numel
+		} else {
+			nonTrainable += numel
+		}
+		nbytes += numel * int64(x.DType().Size())
+	}
+
+	return trainable, nonTrainable, nbytes
+}
+
+func shapeProd(dims []int64) int64 {
+	p := int64(1)
+	for _, d := range dims {
+		p *= d
+	}
+	return p
+}
+
+// estimateMACs estimates multiply-accumulates of known layers.
+func estimateMACs(module interface{}, x, y *ts.Tensor) int64 {
+	outNumel := int64(y.Numel())
+	weightMACs := func(ws *ts.Tensor, numel int64) int64 {
+		if ws == nil || !ws.MustDefined() {
+			return 0
+		}
+		// Weights of shape [out, in/groups, k...] or [in, out/groups, k...]
+		// (transposed): MACs per element of output (or input).
+		return numel * shapeProd(ws.MustSize()[1:])
+	}
+
+	switch m := module.(type) {
+	case *Linear:
+		// NOTE. Linear is the exception: weights are stored transposed as [in, out].
+		if m.Ws == nil || !m.Ws.MustDefined() {
+			return 0
+		}
+		return outNumel * m.Ws.MustSize()[0]
+	case *Conv1D:
+		return weightMACs(m.Ws, outNumel)
+	case *Conv2D:
+		return weightMACs(m.Ws, outNumel)
+	case *Conv3D:
+		return weightMACs(m.Ws, outNumel)
+	case *ConvTranspose1D:
+		return weightMACs(m.Ws, int64(x.Numel()))
+	case *ConvTranspose2D:
+		return weightMACs(m.Ws, int64(x.Numel()))
+	case *ConvTranspose3D:
+		return weightMACs(m.Ws, int64(x.Numel()))
+	case *BatchNorm, *LayerNorm:
+		return outNumel
+	default:
+		return 0
+	}
+}
+
+func formatShape(shape []int64) string {
+	return strings.ReplaceAll(fmt.Sprint(shape), " ", ", ")
+}
+
+// String returns summary as a table.
+func (s *ModelSummary) String() string {
+	rows := [][]string{{"Layer (type)", "Output Shape", "Param #", "MACs"}}
+	for _, l := range s.Layers {
+		rows = append(rows, []string{
+			fmt.Sprintf("%s%s (%s)", strings.Repeat("  ", l.Depth), l.Name, l.Type),
+			formatShape(l.OutputShape),
+			fmt.Sprint(l.TrainableParams + l.NonTrainableParams),
+			fmt.Sprint(l.MACs),
+		})
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	lineWidth := 0
+	for _, w := range widths {
+		lineWidth += w + 2
+	}
+
+	var b strings.Builder
+	writeRow := func(row []string) {
+		for i, cell := range row {
+			fmt.Fprintf(&b, "%-*s  ", widths[i], cell)
+		}
+		b.WriteString("\n")
+	}
+	writeRow(rows[0])
+	b.WriteString(strings.Repeat("=", lineWidth) + "\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	b.WriteString(strings.Repeat("=", lineWidth) + "\n")
+
+	const mb = 1024 * 1024
+	fmt.Fprintf(&b, "Input shape: %s\n", formatShape(s.InputShape))
+	fmt.Fprintf(&b, "Total params: %d\n", s.TotalParams())
+	fmt.Fprintf(&b, "Trainable params: %d\n", s.TrainableParams)
+	fmt.Fprintf(&b, "Non-trainable params: %d\n", s.NonTrainableParams)
+	fmt.Fprintf(&b, "Total MACs: %d\n", s.MACs)
+	fmt.Fprintf(&b, "Activations size (MB): %.2f\n", float64(s.ActivationBytes)/mb)
+	fmt.Fprintf(&b, "Params size (MB): %.2f\n", float64(s.ParamBytes)/mb)
+
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package nn_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+func TestSummary_Sequential(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	root := vs.Root()
+	net := nn.Seq()
+	net.Add(nn.NewLinear(root.Sub("fc1"), 4, 8, nn.DefaultLinearConfig()))
+	net.AddFn(nn.ForwardWith(func(x *ts.Tensor) *ts.Tensor {
+		return x.MustRelu(false)
+	}))
+	net.Add(nn.NewLinear(root.Sub("fc2"), 8, 2, nn.DefaultLinearConfig()))
+
+	s, err := nn.Summary(net, []int64{3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []nn.LayerSummary{
+		{Name: "0", Type: "Linear", Depth: 1, OutputShape: []int64{3, 8}, TrainableParams: 40, ActivationBytes: 96, MACs: 96},
+		{Name: "1", Type: "ForwardWith", Depth: 1, OutputShape: []int64{3, 8}, ActivationBytes: 96},
+		{Name: "2", Type: "Linear", Depth: 1, OutputShape: []int64{3, 2}, TrainableParams: 18, ActivationBytes: 24, MACs: 48},
+	}
+	if !reflect.DeepEqual(s.Layers, want) {
+		t.Errorf("Want layers:\n%+v\nGot:\n%+v", want, s.Layers)
+	}
+	if s.TotalParams() != 58 || s.MACs != 144 || s.ParamBytes != 58*4 {
+		t.Errorf("Want 58 params (232 bytes) and 144 MACs, got %v params (%v bytes) and %v MACs", s.TotalParams(), s.ParamBytes, s.MACs)
+	}
+
+	table := s.String()
+	for _, str := range []string{"0 (Linear)", "[3, 8]", "Total params: 58", "Total MACs: 144"} {
+		if !strings.Contains(table, str) {
+			t.Errorf("Expect %q in summary table:\n%s", str, table)
+		}
+	}
+}
+
+func TestSummary_SequentialT(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	root := vs.Root()
+
+	cfg := nn.DefaultConv2DConfig()
+	cfg.Bias = false
+	block := nn.SeqT()
+	block.Add(nn.NewConv2D(root.Sub("conv"), 3, 4, 3, cfg))
+	block.Add(nn.BatchNorm2D(root.Sub("bn"), 4, nn.DefaultBatchNormConfig()))
+	net := nn.SeqT()
+	net.Add(block)
+	net.AddFnT(nn.ForwardTWith(func(x *ts.Tensor, train bool) *ts.Tensor {
+		return x.MustFlatten(1, -1, false)
+	}))
+
+	s, err := nn.Summary(net, []int64{1, 3, 8, 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Layers) != 3 {
+		t.Fatalf("Want 3 layers, got %+v", s.Layers)
+	}
+
+	conv, bn, flatten := s.Layers[0], s.Layers[1], s.Layers[2]
+	if conv.Name != "0.0" || conv.Depth != 2 || !reflect.DeepEqual(conv.OutputShape, []int64{1, 4, 6, 6}) {
+		t.Errorf("Unexpected conv summary: %+v", conv)
+	}
+	// 4 * 6 * 6 outputs, 3 * 3 * 3 MACs each.
+	if conv.TrainableParams != 108 || conv.MACs != 144*27 {
+		t.Errorf("Want conv 108 params and %v MACs, got %v and %v", 144*27, conv.TrainableParams, conv.MACs)
+	}
+	if bn.Name != "0.1" || bn.TrainableParams != 8 || bn.NonTrainableParams != 8 {
+		t.Errorf("Want batch norm 8 trainable and 8 non-trainable params, got %+v", bn)
+	}
+	if flatten.Name != "1" || !reflect.DeepEqual(flatten.OutputShape, []int64{1, 144}) {
+		t.Errorf("Unexpected flatten summary: %+v", flatten)
+	}
+	if s.TrainableParams != 116 || s.NonTrainableParams != 8 {
+		t.Errorf("Want 116 trainable and 8 non-trainable params, got %v and %v", s.TrainableParams, s.NonTrainableParams)
+	}
+}
@@ -0,0 +1,285 @@
+package nn
+
+// Stochastic weight averaging (SWA).
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// SWA keeps equal-weight running average of trainable variables of a
+// VarStore over training steps or saved checkpoints.
+//
+// Ref. Averaging Weights Leads to Wider Optima and Better Generalization
+// https://arxiv.org/abs/1803.05407
+//
+// Example:
+//
+//	swa := nn.NewSWA(vs)
+//	for epoch := 0; epoch < epochs; epoch++ {
+//		... // train an epoch
+//		if epoch >= swaStart {
+//			swa.MustUpdate()
+//		}
+//	}
+//	swa.MustApply()
+//	err := nn.UpdateBatchNorm(model, vs, iter)
+type SWA struct {
+	varstore *VarStore
+	average  map[string]*ts.Tensor
+	n        int
+}
+
+// NewSWA creates SWA of trainable variables of VarStore.
+func NewSWA(vs *VarStore) *SWA {
+	return &SWA{
+		varstore: vs,
+		average:  make(map[string]*ts.Tensor),
+	}
+}
+
+// NumAveraged returns number of averaged weight sets.
+func (s *SWA) NumAveraged() int {
+	return s.n
+}
+
+func (s *SWA) trainableVars() map[string]*ts.Tensor {
+	s.varstore.Lock()
+	defer s.varstore.Unlock()
+
+	vars := make(map[string]*ts.Tensor)
+	for name, v := range s.varstore.vars {
+		if v.Trainable {
+			vars[name] = v.Tensor
+		}
+	}
+
+	return vars
+}
+
+// add adds a weight set to running average.
+func (s *SWA) add(vars map[string]*ts.Tensor) error {
+	if s.n == 0 {
+		for name, x := range vars {
+			s.average[name] = detachedCopy(x)
+		}
+		s.n = 1
+		return nil
+	}
+
+	for name, avg := range s.average {
+		x, ok := vars[name]
+		if !ok {
+			err := fmt.Errorf("variable %q not found", name)
+			return err
+		}
+		if !reflect.DeepEqual(x.MustSize(), avg.MustSize()) {
+			err := fmt.Errorf("mismatched shape of variable %q: %v - average %v", name, x.MustSize(), avg.MustSize())
+			return err
+		}
+	}
+
+	// avg + (x - avg) / (n + 1)
+	weight := ts.FloatScalar(1.0 / float64(s.n+1))
+	ts.NoGrad(func() {
+		for name, avg := range s.average {
+			avg.MustLerp_(vars[name], weight)
+		}
+	})
+	s.n++
+
+	return nil
+}
+
+// Update adds current trainable variable values to average.
+func (s *SWA) Update() error {
+	if err := s.add(s.trainableVars()); err != nil {
+		err = fmt.Errorf("SWA.Update() failed: %w", err)
+		return err
+	}
+
+	return nil
+}
+
+// MustUpdate adds current trainable variable values to average. It panics if error occurred.
+func (s *SWA) MustUpdate() {
+	if err := s.Update(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// AddCheckpoint adds trainable variable values from a file saved with
+// `VarStore.Save()` to average.
+func (s *SWA) AddCheckpoint(filepath string) error {
+	namedTensors, err := ts.LoadMultiWithDevice(filepath, s.varstore.device)
+	if err != nil {
+		err = fmt.Errorf("SWA.AddCheckpoint() failed: %w", err)
+		return err
+	}
+
+	loaded := make(map[string]*ts.Tensor, len(namedTensors))
+	for _, nt := range namedTensors {
+		loaded[nt.Name] = nt.Tensor
+	}
+	vars := make(map[string]*ts.Tensor)
+	for name := range s.trainableVars() {
+		x, ok := loaded[name]
+		if !ok {
+			err = fmt.Errorf("SWA.AddCheckpoint() failed: variable %q not found in %q", name, filepath)
+			break
+		}
+		vars[name] = x
+	}
+	if err == nil {
+		if err = s.add(vars); err != nil {
+			err = fmt.Errorf("SWA.AddCheckpoint() failed: %w", err)
+		}
+	}
+
+	for _, nt := range namedTensors {
+		nt.Tensor.MustDrop()
+	}
+
+	return err
+}
+
+// Apply copies averaged weights to VarStore.
+func (s *SWA) Apply() error {
+	if s.n == 0 {
+		err := fmt.Errorf("SWA.Apply() failed: no weights have been averaged")
+		return err
+	}
+
+	vars := s.trainableVars()
+	ts.NoGrad(func() {
+		for name, avg := range s.average {
+			if x, ok := vars[name]; ok {
+				x.Copy_(avg)
+			}
+		}
+	})
+
+	return nil
+}
+
+// MustApply copies averaged weights to VarStore. It panics if error occurred.
+func (s *SWA) MustApply() {
+	if err := s.Apply(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Drop frees averaged weights.
+func (s *SWA) Drop() {
+	for _, avg := range s.average {
+		avg.MustDrop()
+	}
+	s.average = make(map[string]*ts.Tensor)
+	s.n = 0
+}
+
+type bnStats struct {
+	mean, variance       *ts.Tensor // running statistics in VarStore
+	momentum             float64
+	sumMean, sumVariance *ts.Tensor
+}
+
+// UpdateBatchNorm recomputes running statistics of all BatchNorm layers of
+// model, i.e. "running_mean" and "running_var" buffers of VarStore, as
+// equal-weight averages of statistics over all batches of iter. It is needed
+// after weights are replaced by averaged weights (e.g. `SWA.Apply()`).
+//
+// NOTE. model is run in training mode without gradient. Batch tensors returned
+// by iter are deleted after use. BatchNorm layers must be created with
+// `NewBatchNorm()` (or `BatchNorm1D/2D/3D()`) in vs for their momentum to be known.
+func UpdateBatchNorm(model ts.ModuleT, vs *VarStore, iter BatchIterator) error {
+	vs.Lock()
+	var stats []*bnStats
+	var err error
+	for name, v := range vs.vars {
+		if !strings.HasSuffix(name, "running_mean") {
+			continue
+		}
+		varName := strings.TrimSuffix(name, "running_mean") + "running_var"
+		variance, ok := vs.vars[varName]
+		if !ok {
+			continue
+		}
+		momentum, ok := vs.bnMomentums[name]
+		switch {
+		case !ok:
+			err = fmt.Errorf("UpdateBatchNorm() failed: no BatchNorm layer found for %q", name)
+		case math.Abs(momentum) < 1e-6:
+			err = fmt.Errorf("UpdateBatchNorm() failed: BatchNorm momentum of %q is zero", name)
+		}
+		if err != nil {
+			break
+		}
+		stats = append(stats, &bnStats{mean: v.Tensor, variance: variance.Tensor, momentum: momentum})
+	}
+	vs.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+
+	for _, s := range stats {
+		s.sumMean = s.mean.MustZerosLike(false)
+		s.sumVariance = s.variance.MustZerosLike(false)
+	}
+	defer func() {
+		for _, s := range stats {
+			s.sumMean.MustDrop()
+			s.sumVariance.MustDrop()
+		}
+	}()
+
+	// With running statistics reset to 0 before every batch, BatchNorm sets
+	// them to momentum * batch statistics.
+	n := 0
+	ts.NoGrad(func() {
+		for {
+			item, ok := iter.Next()
+			if !ok {
+				break
+			}
+
+			for _, s := range stats {
+				s.mean.MustZero_()
+				s.variance.MustZero_()
+			}
+			model.ForwardT(item.Data, true).MustDrop()
+			for _, s := range stats {
+				s.sumMean.MustAdd_(s.mean)
+				s.sumVariance.MustAdd_(s.variance)
+			}
+			item.Data.MustDrop()
+			item.Label.MustDrop()
+			n++
+		}
+	})
+	if n == 0 {
+		err := fmt.Errorf("UpdateBatchNorm() failed: empty iterator")
+		return err
+	}
+
+	ts.NoGrad(func() {
+		for _, s := range stats {
+			scale := ts.FloatScalar(1.0 / (s.momentum * float64(n)))
+			s.mean.Copy_(s.sumMean)
+			s.mean.MustMulScalar_(scale)
+			s.variance.Copy_(s.sumVariance)
+			s.variance.MustMulScalar_(scale)
+		}
+	})
+
+	return nil
+}
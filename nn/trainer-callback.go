@@ -0,0 +1,357 @@
+package nn
+
+// Training callbacks of Trainer.
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// Callback is a set of hooks which are called by Trainer during training.
+// Returning an error aborts training.
+//
+// Embed BaseCallback to implement only some of the hooks.
+type Callback interface {
+	OnTrainBegin(t *Trainer) error
+	OnTrainEnd(t *Trainer) error
+	OnEpochBegin(t *Trainer, epoch int) error
+	OnEpochEnd(t *Trainer, epoch int, logs Logs) error
+	OnBatchBegin(t *Trainer, batch int) error
+	OnBatchEnd(t *Trainer, batch int, loss float64) error
+}
+
+// BaseCallback implements Callback interface with no-op hooks.
+type BaseCallback struct{}
+
+var _ Callback = (*BaseCallback)(nil)
+
+func (BaseCallback) OnTrainBegin(t *Trainer) error                        { return nil }
+func (BaseCallback) OnTrainEnd(t *Trainer) error                          { return nil }
+func (BaseCallback) OnEpochBegin(t *Trainer, epoch int) error             { return nil }
+func (BaseCallback) OnEpochEnd(t *Trainer, epoch int, logs Logs) error    { return nil }
+func (BaseCallback) OnBatchBegin(t *Trainer, batch int) error             { return nil }
+func (BaseCallback) OnBatchEnd(t *Trainer, batch int, loss float64) error { return nil }
+
+// MonitorMode specifies whether a monitored value improves when decreasing or
+// increasing.
+type MonitorMode int
+
+const (
+	MonitorMin MonitorMode = iota // e.g. loss
+	MonitorMax                    // e.g. accuracy
+)
+
+// monitor tracks best value of a logged quantity.
+type monitor struct {
+	key      string
+	mode     MonitorMode
+	minDelta float64
+	best     float64
+}
+
+func newMonitor(key string, mode MonitorMode) monitor {
+	best := math.Inf(1)
+	if mode == MonitorMax {
+		best = math.Inf(-1)
+	}
+
+	return monitor{key: key, mode: mode, best: best}
+}
+
+// update returns whether logged value improves best value by more than minDelta.
+func (m *monitor) update(logs Logs) (bool, error) {
+	v, ok := logs[m.key]
+	if !ok {
+		err := fmt.Errorf("monitored value %q not found in logs. Available: %v", m.key, logs.Keys())
+		return false, err
+	}
+
+	var improved bool
+	switch m.mode {
+	case MonitorMax:
+		improved = v > m.best+m.minDelta
+	default:
+		improved = v < m.best-m.minDelta
+	}
+	if improved {
+		m.best = v
+	}
+
+	return improved, nil
+}
+
+// EarlyStopping stops training when a monitored value has not improved for
+// `Patience` epochs.
+type EarlyStopping struct {
+	BaseCallback
+	monitor
+
+	Patience  int
+	Wait      int // number of epochs without improvement
+	BestEpoch int
+}
+
+// NewEarlyStopping creates EarlyStopping callback.
+func NewEarlyStopping(key string, mode MonitorMode, patience int) *EarlyStopping {
+	return &EarlyStopping{
+		monitor:   newMonitor(key, mode),
+		Patience:  patience,
+		BestEpoch: -1,
+	}
+}
+
+// SetMinDelta sets minimum change of monitored value to qualify as an improvement.
+func (c *EarlyStopping) SetMinDelta(val float64) *EarlyStopping {
+	c.minDelta = val
+	return c
+}
+
+// Best returns best monitored value.
+func (c *EarlyStopping) Best() float64 {
+	return c.best
+}
+
+// OnTrainBegin implements Callback interface.
+func (c *EarlyStopping) OnTrainBegin(t *Trainer) error {
+	c.Wait = 0
+	return nil
+}
+
+// OnEpochEnd implements Callback interface.
+func (c *EarlyStopping) OnEpochEnd(t *Trainer, epoch int, logs Logs) error {
+	improved, err := c.update(logs)
+	if err != nil {
+		err = fmt.Errorf("EarlyStopping.OnEpochEnd() failed: %w", err)
+		return err
+	}
+
+	if improved {
+		c.Wait = 0
+		c.BestEpoch = epoch
+		return nil
+	}
+
+	c.Wait++
+	if c.Wait >= c.Patience {
+		t.StopTraining()
+	}
+
+	return nil
+}
+
+// ModelCheckpoint saves model weights with `VarStore.Save()` whenever a
+// monitored value improves, or at end of every epoch if key is empty.
+type ModelCheckpoint struct {
+	BaseCallback
+	monitor
+
+	VarStore  *VarStore
+	Path      string
+	BestEpoch int
+}
+
+// NewModelCheckpoint creates ModelCheckpoint callback.
+func NewModelCheckpoint(vs *VarStore, path string, key string, mode MonitorMode) *ModelCheckpoint {
+	return &ModelCheckpoint{
+		monitor:   newMonitor(key, mode),
+		VarStore:  vs,
+		Path:      path,
+		BestEpoch: -1,
+	}
+}
+
+// Best returns best monitored value.
+func (c *ModelCheckpoint) Best() float64 {
+	return c.best
+}
+
+// OnEpochEnd implements Callback interface.
+func (c *ModelCheckpoint) OnEpochEnd(t *Trainer, epoch int, logs Logs) error {
+	if c.key != "" {
+		improved, err := c.update(logs)
+		if err != nil {
+			err = fmt.Errorf("ModelCheckpoint.OnEpochEnd() failed: %w", err)
+			return err
+		}
+		if !improved {
+			return nil
+		}
+	}
+
+	if err := c.VarStore.Save(c.Path); err != nil {
+		err = fmt.Errorf("ModelCheckpoint.OnEpochEnd() failed: %w", err)
+		return err
+	}
+	c.BestEpoch = epoch
+
+	return nil
+}
+
+// CSVLogger writes logs of every epoch as a row of a CSV file. Columns are
+// "epoch" followed by sorted log names of the first epoch.
+type CSVLogger struct {
+	BaseCallback
+
+	Path string
+
+	file    *os.File
+	writer  *csv.Writer
+	columns []string
+}
+
+// NewCSVLogger creates CSVLogger callback. File is truncated at train begin.
+func NewCSVLogger(path string) *CSVLogger {
+	return &CSVLogger{Path: path}
+}
+
+// OnTrainBegin implements Callback interface.
+func (c *CSVLogger) OnTrainBegin(t *Trainer) error {
+	f, err := os.Create(c.Path)
+	if err != nil {
+		err = fmt.Errorf("CSVLogger.OnTrainBegin() failed: %w", err)
+		return err
+	}
+	c.file = f
+	c.writer = csv.NewWriter(f)
+	c.columns = nil
+
+	return nil
+}
+
+// OnEpochEnd implements Callback interface.
+func (c *CSVLogger) OnEpochEnd(t *Trainer, epoch int, logs Logs) error {
+	if c.columns == nil {
+		c.columns = logs.Keys()
+		if err := c.writer.Write(append([]string{"epoch"}, c.columns...)); err != nil {
+			return err
+		}
+	}
+
+	row := []string{strconv.Itoa(epoch)}
+	for _, k := range c.columns {
+		v, ok := logs[k]
+		if !ok {
+			row = append(row, "")
+			continue
+		}
+		row = append(row, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	if err := c.writer.Write(row); err != nil {
+		return err
+	}
+	c.writer.Flush()
+
+	return c.writer.Error()
+}
+
+// OnTrainEnd implements Callback interface.
+func (c *CSVLogger) OnTrainEnd(t *Trainer) error {
+	if c.file == nil {
+		return nil
+	}
+	c.writer.Flush()
+	err := c.file.Close()
+	c.file = nil
+
+	return err
+}
+
+// JSONLogger writes logs of every epoch as a JSON object per line, e.g.
+// {"epoch":0,"loss":0.51,"lr":0.001,"val_loss":0.43}. If BatchEvery > 0, it
+// also writes {"epoch":0,"batch":99,"loss":0.6} every BatchEvery batches.
+type JSONLogger struct {
+	BaseCallback
+
+	BatchEvery int
+
+	w     io.Writer
+	path  string
+	file  *os.File
+	epoch int
+}
+
+// NewJSONLogger creates JSONLogger callback writing to a file which is
+// truncated at train begin.
+func NewJSONLogger(path string) *JSONLogger {
+	return &JSONLogger{path: path}
+}
+
+// NewJSONLoggerWriter creates JSONLogger callback writing to w, e.g. `os.Stdout`.
+func NewJSONLoggerWriter(w io.Writer) *JSONLogger {
+	return &JSONLogger{w: w}
+}
+
+// OnTrainBegin implements Callback interface.
+func (c *JSONLogger) OnTrainBegin(t *Trainer) error {
+	if c.path == "" {
+		return nil
+	}
+
+	f, err := os.Create(c.path)
+	if err != nil {
+		err = fmt.Errorf("JSONLogger.OnTrainBegin() failed: %w", err)
+		return err
+	}
+	c.file = f
+	c.w = f
+
+	return nil
+}
+
+func (c *JSONLogger) write(record map[string]interface{}) error {
+	// NOTE. NaN and Inf are not valid JSON numbers.
+	for k, v := range record {
+		if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			record[k] = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = c.w.Write(append(data, '\n'))
+
+	return err
+}
+
+// OnEpochBegin implements Callback interface.
+func (c *JSONLogger) OnEpochBegin(t *Trainer, epoch int) error {
+	c.epoch = epoch
+	return nil
+}
+
+// OnBatchEnd implements Callback interface.
+func (c *JSONLogger) OnBatchEnd(t *Trainer, batch int, loss float64) error {
+	if c.BatchEvery <= 0 || (batch+1)%c.BatchEvery != 0 {
+		return nil
+	}
+
+	return c.write(map[string]interface{}{"epoch": c.epoch, "batch": batch, "loss": loss})
+}
+
+// OnEpochEnd implements Callback interface.
+func (c *JSONLogger) OnEpochEnd(t *Trainer, epoch int, logs Logs) error {
+	record := map[string]interface{}{"epoch": epoch}
+	for k, v := range logs {
+		record[k] = v
+	}
+
+	return c.write(record)
+}
+
+// OnTrainEnd implements Callback interface.
+func (c *JSONLogger) OnTrainEnd(t *Trainer) error {
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+
+	return err
+}
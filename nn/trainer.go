@@ -0,0 +1,489 @@
+package nn
+
+// High-level training loop.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/metrics"
+	"github.com/sugarme/gotch/ts"
+)
+
+// BatchIterator is a source of (data, label) batches, e.g. `*ts.Iter2`.
+type BatchIterator interface {
+	Next() (ts.Iter2Item, bool)
+}
+
+// BatchIteratorFn returns a fresh BatchIterator, e.g. a new shuffled `*ts.Iter2`.
+// It is called at start of every epoch.
+type BatchIteratorFn func() (BatchIterator, error)
+
+// TrainLossFn calculates a scalar loss from model outputs and targets, e.g.
+//
+//	func(logits, target *ts.Tensor) *ts.Tensor {
+//		return nn.CrossEntropyLoss(logits, target, nn.WithLossFnLabelSmoothing(0.1))
+//	}
+type TrainLossFn func(logits, target *ts.Tensor) *ts.Tensor
+
+// Logs is a set of named values of an epoch, e.g. "loss", "val_loss", "lr"
+// and "val_<metric name>".
+type Logs map[string]float64
+
+// Keys returns sorted names of logged values.
+func (l Logs) Keys() []string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+type TrainerOptions struct {
+	Epochs            int
+	Scheduler         *LRScheduler // Optional
+	SchedulerPerBatch bool
+	ClipGradNorm      float64 // 0 means no clipping
+	AccumulateSteps   int
+	Callbacks         []Callback
+	Metrics           map[string]metrics.Metric
+	GradScaler        *GradScaler // Optional
+	Autocast          bool
+	AutocastDevice    gotch.Device
+	AutocastDType     gotch.DType
+}
+
+type TrainerOption func(*TrainerOptions)
+
+func defaultTrainerOptions() *TrainerOptions {
+	return &TrainerOptions{
+		Epochs:            1,
+		Scheduler:         nil,
+		SchedulerPerBatch: false,
+		ClipGradNorm:      0,
+		AccumulateSteps:   1,
+		Callbacks:         nil,
+		Metrics:           make(map[string]metrics.Metric),
+		GradScaler:        nil,
+		Autocast:          false,
+	}
+}
+
+func NewTrainerOptions(opts ...TrainerOption) *TrainerOptions {
+	options := defaultTrainerOptions()
+	for _, o := range opts {
+		o(options)
+	}
+
+	return options
+}
+
+// WithEpochs sets number of training epochs. Default=1.
+func WithEpochs(val int) TrainerOption {
+	return func(o *TrainerOptions) {
+		o.Epochs = val
+	}
+}
+
+// WithScheduler sets learning rate scheduler. It is stepped at end of every
+// epoch with validation loss (or training loss if no validation data), or
+// after every optimizer step if perBatch is true.
+func WithScheduler(s *LRScheduler, perBatch bool) TrainerOption {
+	return func(o *TrainerOptions) {
+		o.Scheduler = s
+		o.SchedulerPerBatch = perBatch
+	}
+}
+
+// WithClipGradNorm clips gradient norm to max before every optimizer step.
+func WithClipGradNorm(max float64) TrainerOption {
+	return func(o *TrainerOptions) {
+		o.ClipGradNorm = max
+	}
+}
+
+// WithAccumulateSteps sets number of batches which gradients are accumulated
+// before an optimizer step. Default=1.
+func WithAccumulateSteps(val int) TrainerOption {
+	return func(o *TrainerOptions) {
+		o.AccumulateSteps = val
+	}
+}
+
+// WithCallbacks appends training callbacks.
+func WithCallbacks(callbacks ...Callback) TrainerOption {
+	return func(o *TrainerOptions) {
+		o.Callbacks = append(o.Callbacks, callbacks...)
+	}
+}
+
+// WithMetric adds a metric which is evaluated on validation data and logged
+// as "val_<name>".
+func WithMetric(name string, m metrics.Metric) TrainerOption {
+	return func(o *TrainerOptions) {
+		o.Metrics[name] = m
+	}
+}
+
+// WithGradScaler sets gradient scaler of mixed precision training.
+func WithGradScaler(s *GradScaler) TrainerOption {
+	return func(o *TrainerOptions) {
+		o.GradScaler = s
+	}
+}
+
+// WithAutocast runs forward pass and loss in autocast scope of device type and
+// dtype (`gotch.BFloat16` or `gotch.Half`). See `ts.Autocast()`.
+func WithAutocast(device gotch.Device, dtype gotch.DType) TrainerOption {
+	return func(o *TrainerOptions) {
+		o.Autocast = true
+		o.AutocastDevice = device
+		o.AutocastDType = dtype
+	}
+}
+
+// Trainer runs training loop of a model: forward, loss, backward, gradient
+// accumulation and clipping, optimizer and scheduler steps, validation and
+// callbacks.
+//
+// NOTE. batch tensors returned by iterators are deleted after use.
+//
+// Example:
+//
+//	trainer := nn.NewTrainer(net, opt, lossFn,
+//		nn.WithEpochs(20),
+//		nn.WithClipGradNorm(1.0),
+//		nn.WithMetric("acc", metrics.NewAccuracy()),
+//		nn.WithCallbacks(
+//			nn.NewEarlyStopping("val_loss", nn.MonitorMin, 3),
+//			nn.NewModelCheckpoint(vs, "best.gt", "val_acc", nn.MonitorMax),
+//			nn.NewCSVLogger("train.csv"),
+//		),
+//	)
+//	err := trainer.Fit(trainFn, valFn)
+type Trainer struct {
+	Model     ts.ModuleT
+	Optimizer *Optimizer
+	LossFn    TrainLossFn
+	Options   *TrainerOptions
+
+	Epoch   int    // current epoch
+	Step    int    // number of optimizer steps taken
+	History []Logs // logs of finished epochs
+
+	stop bool
+}
+
+// NewTrainer creates a Trainer.
+func NewTrainer(model ts.ModuleT, opt *Optimizer, lossFn TrainLossFn, opts ...TrainerOption) *Trainer {
+	return &Trainer{
+		Model:     model,
+		Optimizer: opt,
+		LossFn:    lossFn,
+		Options:   NewTrainerOptions(opts...),
+	}
+}
+
+// StopTraining requests training to stop at end of current epoch, e.g. from
+// a callback.
+func (t *Trainer) StopTraining() {
+	t.stop = true
+}
+
+// Stopped returns whether training stop has been requested.
+func (t *Trainer) Stopped() bool {
+	return t.stop
+}
+
+// Fit trains model for `Options.Epochs` epochs, or until a callback stops
+// training. valFn is optional.
+//
+// OnTrainEnd of every callback is called also when training fails so that
+// callbacks can release resources, e.g. close log files. Its errors are
+// joined to the returned error.
+func (t *Trainer) Fit(trainFn, valFn BatchIteratorFn) (err error) {
+	if t.Options.AccumulateSteps < 1 {
+		err := fmt.Errorf("Trainer.Fit() failed: invalid accumulate steps (%v)", t.Options.AccumulateSteps)
+		return err
+	}
+
+	t.stop = false
+	defer func() {
+		for _, cb := range t.Options.Callbacks {
+			if endErr := cb.OnTrainEnd(t); endErr != nil {
+				err = errors.Join(err, endErr)
+			}
+		}
+	}()
+
+	for _, cb := range t.Options.Callbacks {
+		if err := cb.OnTrainBegin(t); err != nil {
+			return err
+		}
+	}
+
+	for ; t.Epoch < t.Options.Epochs && !t.stop; t.Epoch++ {
+		logs, err := t.runEpoch(trainFn, valFn)
+		if err != nil {
+			return err
+		}
+		t.History = append(t.History, logs)
+	}
+
+	return nil
+}
+
+func (t *Trainer) runEpoch(trainFn, valFn BatchIteratorFn) (Logs, error) {
+	for _, cb := range t.Options.Callbacks {
+		if err := cb.OnEpochBegin(t, t.Epoch); err != nil {
+			return nil, err
+		}
+	}
+
+	trainLoss, err := t.trainEpoch(trainFn)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := Logs{"loss": trainLoss}
+	lrs := t.Optimizer.GetLRs()
+	if len(lrs) > 0 {
+		logs["lr"] = lrs[0]
+	}
+	monitorLoss := trainLoss
+
+	if valFn != nil {
+		valLogs, err := t.Evaluate(valFn)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range valLogs {
+			logs["val_"+k] = v
+		}
+		monitorLoss = valLogs["loss"]
+	}
+
+	if t.Options.Scheduler != nil && !t.Options.SchedulerPerBatch {
+		t.Options.Scheduler.Step(WithLoss(monitorLoss))
+	}
+
+	for _, cb := range t.Options.Callbacks {
+		if err := cb.OnEpochEnd(t, t.Epoch, logs); err != nil {
+			return nil, err
+		}
+	}
+
+	return logs, nil
+}
+
+// trainEpoch runs a training epoch and returns mean training loss.
+func (t *Trainer) trainEpoch(trainFn BatchIteratorFn) (float64, error) {
+	iter, err := trainFn()
+	if err != nil {
+		err = fmt.Errorf("Trainer.Fit() failed: creating train iterator at epoch %v: %w", t.Epoch, err)
+		return 0, err
+	}
+
+	if err := t.Optimizer.ZeroGrad(); err != nil {
+		return 0, err
+	}
+
+	accum := t.Options.AccumulateSteps
+	var (
+		sumLoss float64
+		batch   int
+	)
+	for ; ; batch++ {
+		item, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		for _, cb := range t.Options.Callbacks {
+			if err := cb.OnBatchBegin(t, batch); err != nil {
+				item.Data.MustDrop()
+				item.Label.MustDrop()
+				return 0, err
+			}
+		}
+
+		logits, loss, err := t.forward(item, true)
+		if err != nil {
+			item.Data.MustDrop()
+			item.Label.MustDrop()
+			return 0, err
+		}
+		lossVal := loss.MustFloat64Value([]int64{})
+		if accum > 1 {
+			loss = loss.MustDivScalar(ts.FloatScalar(float64(accum)), true)
+		}
+		if t.Options.GradScaler != nil {
+			scaled := t.Options.GradScaler.Scale(loss)
+			loss.MustDrop()
+			loss = scaled
+		}
+		loss.MustBackward()
+		loss.MustDrop()
+		logits.MustDrop()
+		item.Data.MustDrop()
+		item.Label.MustDrop()
+
+		if (batch+1)%accum == 0 {
+			if err := t.optimizerStep(); err != nil {
+				return 0, err
+			}
+		}
+		sumLoss += lossVal
+
+		for _, cb := range t.Options.Callbacks {
+			if err := cb.OnBatchEnd(t, batch, lossVal); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	// Remaining accumulated gradients.
+	if batch%accum != 0 {
+		if err := t.optimizerStep(); err != nil {
+			return 0, err
+		}
+	}
+
+	if batch == 0 {
+		err := fmt.Errorf("Trainer.Fit() failed: empty train iterator at epoch %v", t.Epoch)
+		return 0, err
+	}
+
+	return sumLoss / float64(batch), nil
+}
+
+// forward runs model and loss function, in autocast scope if enabled.
+func (t *Trainer) forward(item ts.Iter2Item, train bool) (logits, loss *ts.Tensor, err error) {
+	fn := func() {
+		logits = t.Model.ForwardT(item.Data, train)
+		loss = t.LossFn(logits, item.Label)
+	}
+
+	if !t.Options.Autocast {
+		fn()
+		return logits, loss, nil
+	}
+
+	if err := ts.Autocast(t.Options.AutocastDevice, t.Options.AutocastDType, fn); err != nil {
+		err = fmt.Errorf("Trainer.forward() failed: %w", err)
+		return nil, nil, err
+	}
+
+	return logits, loss, nil
+}
+
+func (t *Trainer) optimizerStep() error {
+	scaler := t.Options.GradScaler
+	if scaler != nil {
+		// Gradients must be unscaled before clipping.
+		if err := scaler.Unscale(t.Optimizer); err != nil {
+			return err
+		}
+	}
+	if t.Options.ClipGradNorm > 0 {
+		if err := t.Optimizer.ClipGradNorm(t.Options.ClipGradNorm); err != nil {
+			return err
+		}
+	}
+
+	stepped := true
+	if scaler != nil {
+		var err error
+		stepped, err = scaler.Step(t.Optimizer)
+		if err != nil {
+			return err
+		}
+		scaler.Update()
+	} else {
+		if err := t.Optimizer.Step(); err != nil {
+			return err
+		}
+	}
+	if err := t.Optimizer.ZeroGrad(); err != nil {
+		return err
+	}
+	if !stepped {
+		return nil
+	}
+	t.Step++
+
+	if t.Options.Scheduler != nil && t.Options.SchedulerPerBatch {
+		t.Options.Scheduler.Step()
+	}
+
+	return nil
+}
+
+// Evaluate runs model in evaluation mode without gradient over batches of
+// valFn and returns mean loss ("loss") and value of every metric.
+func (t *Trainer) Evaluate(valFn BatchIteratorFn) (Logs, error) {
+	iter, err := valFn()
+	if err != nil {
+		err = fmt.Errorf("Trainer.Evaluate() failed: creating iterator: %w", err)
+		return nil, err
+	}
+
+	for _, m := range t.Options.Metrics {
+		m.Reset()
+	}
+
+	var (
+		sumLoss float64
+		n       int
+		evalErr error
+	)
+	ts.NoGrad(func() {
+		for {
+			item, ok := iter.Next()
+			if !ok {
+				break
+			}
+
+			logits, loss, err := t.forward(item, false)
+			if err != nil {
+				item.Data.MustDrop()
+				item.Label.MustDrop()
+				evalErr = err
+				break
+			}
+			sumLoss += loss.MustFloat64Value([]int64{})
+			n++
+			for name, m := range t.Options.Metrics {
+				if err := m.Update(logits, item.Label); err != nil && evalErr == nil {
+					evalErr = fmt.Errorf("Trainer.Evaluate() failed: updating metric %q: %w", name, err)
+				}
+			}
+			loss.MustDrop()
+			logits.MustDrop()
+			item.Data.MustDrop()
+			item.Label.MustDrop()
+
+			if evalErr != nil {
+				break
+			}
+		}
+	})
+	if evalErr != nil {
+		return nil, evalErr
+	}
+	if n == 0 {
+		err := fmt.Errorf("Trainer.Evaluate() failed: empty iterator")
+		return nil, err
+	}
+
+	logs := Logs{"loss": sumLoss / float64(n)}
+	for name, m := range t.Options.Metrics {
+		logs[name] = m.Compute()
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,253 @@
+package nn_test
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+func regressionIterFn(x, y *ts.Tensor, batchSize int64) nn.BatchIteratorFn {
+	return func() (nn.BatchIterator, error) {
+		iter, err := ts.NewIter2(x, y, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		return iter.ReturnSmallLastBatch(), nil
+	}
+}
+
+func mseLossFn(logits, target *ts.Tensor) *ts.Tensor {
+	return nn.MSELoss(logits, target)
+}
+
+// countingCallback counts hook calls.
+type countingCallback struct {
+	nn.BaseCallback
+	trainBegin, trainEnd, epochBegin, epochEnd, batchBegin, batchEnd int
+}
+
+func (c *countingCallback) OnTrainBegin(t *nn.Trainer) error { c.trainBegin++; return nil }
+func (c *countingCallback) OnTrainEnd(t *nn.Trainer) error   { c.trainEnd++; return nil }
+func (c *countingCallback) OnEpochBegin(t *nn.Trainer, epoch int) error {
+	c.epochBegin++
+	return nil
+}
+func (c *countingCallback) OnEpochEnd(t *nn.Trainer, epoch int, logs nn.Logs) error {
+	c.epochEnd++
+	return nil
+}
+func (c *countingCallback) OnBatchBegin(t *nn.Trainer, batch int) error {
+	c.batchBegin++
+	return nil
+}
+func (c *countingCallback) OnBatchEnd(t *nn.Trainer, batch int, loss float64) error {
+	c.batchEnd++
+	return nil
+}
+
+func TestTrainer_Fit(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	model, x, y := linearRegression(vs)
+	opt, err := nn.DefaultSGDConfig().Build(vs, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb := &countingCallback{}
+	trainer := nn.NewTrainer(model, opt, mseLossFn,
+		nn.WithEpochs(30),
+		nn.WithClipGradNorm(10),
+		nn.WithCallbacks(cb),
+	)
+	// 14 samples, batch size 4: 4 batches per epoch.
+	if err := trainer.Fit(regressionIterFn(x, y, 4), regressionIterFn(x, y, 14)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trainer.History) != 30 || trainer.Epoch != 30 || trainer.Step != 30*4 {
+		t.Errorf("Want 30 epochs and 120 steps, got %v epochs (history %v) and %v steps", trainer.Epoch, len(trainer.History), trainer.Step)
+	}
+	if cb.trainBegin != 1 || cb.trainEnd != 1 || cb.epochBegin != 30 || cb.epochEnd != 30 || cb.batchBegin != 120 || cb.batchEnd != 120 {
+		t.Errorf("Unexpected callback counts: %+v", *cb)
+	}
+
+	first, last := trainer.History[0], trainer.History[len(trainer.History)-1]
+	for _, k := range []string{"loss", "lr", "val_loss"} {
+		if _, ok := last[k]; !ok {
+			t.Errorf("Missing %q in logs %v", k, last)
+		}
+	}
+	if last["val_loss"] > 0.01 || last["val_loss"] >= first["val_loss"] {
+		t.Errorf("Expect validation loss to decrease below 0.01, got %v -> %v", first["val_loss"], last["val_loss"])
+	}
+}
+
+// failingCallback fails at a batch.
+type failingCallback struct {
+	nn.BaseCallback
+	batch int
+}
+
+func (c *failingCallback) OnBatchBegin(t *nn.Trainer, batch int) error {
+	if batch == c.batch {
+		return fmt.Errorf("failing at batch %v", batch)
+	}
+	return nil
+}
+
+func TestTrainer_FitError(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	model, x, y := linearRegression(vs)
+	opt, err := nn.DefaultSGDConfig().Build(vs, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb := &countingCallback{}
+	trainer := nn.NewTrainer(model, opt, mseLossFn,
+		nn.WithEpochs(3),
+		nn.WithCallbacks(cb, &failingCallback{batch: 2}),
+	)
+	if err := trainer.Fit(regressionIterFn(x, y, 4), nil); err == nil {
+		t.Fatal("Expect error from failing callback")
+	}
+
+	// Training ends also on failure.
+	if cb.trainBegin != 1 || cb.trainEnd != 1 || cb.batchEnd != 2 {
+		t.Errorf("Unexpected callback counts: %+v", *cb)
+	}
+}
+
+func TestTrainer_AccumulateSteps(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	model, x, y := linearRegression(vs)
+	opt, err := nn.DefaultSGDConfig().Build(vs, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 14 samples, batch size 4: 4 batches, 2 steps per epoch.
+	trainer := nn.NewTrainer(model, opt, mseLossFn, nn.WithEpochs(3), nn.WithAccumulateSteps(2))
+	if err := trainer.Fit(regressionIterFn(x, y, 4), nil); err != nil {
+		t.Fatal(err)
+	}
+	if trainer.Step != 6 {
+		t.Errorf("Want 6 optimizer steps, got %v", trainer.Step)
+	}
+
+	// 3 batches, 2 steps per epoch with the last partial accumulation.
+	trainer = nn.NewTrainer(model, opt, mseLossFn, nn.WithEpochs(1), nn.WithAccumulateSteps(2))
+	if err := trainer.Fit(regressionIterFn(x, y, 5), nil); err != nil {
+		t.Fatal(err)
+	}
+	if trainer.Step != 2 {
+		t.Errorf("Want 2 optimizer steps, got %v", trainer.Step)
+	}
+}
+
+func TestEarlyStopping(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	model, x, y := linearRegression(vs)
+	// Zero learning rate: validation loss never improves.
+	opt, err := nn.DefaultSGDConfig().Build(vs, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	es := nn.NewEarlyStopping("val_loss", nn.MonitorMin, 3)
+	trainer := nn.NewTrainer(model, opt, mseLossFn, nn.WithEpochs(100), nn.WithCallbacks(es))
+	if err := trainer.Fit(regressionIterFn(x, y, 7), regressionIterFn(x, y, 7)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Best at epoch 0, then 3 epochs without improvement.
+	if trainer.Epoch != 4 || es.BestEpoch != 0 || !trainer.Stopped() {
+		t.Errorf("Want stop after 4 epochs with best epoch 0, got %v epochs with best epoch %v", trainer.Epoch, es.BestEpoch)
+	}
+
+	// Missing monitored value is an error.
+	es = nn.NewEarlyStopping("val_acc", nn.MonitorMax, 3)
+	trainer = nn.NewTrainer(model, opt, mseLossFn, nn.WithEpochs(1), nn.WithCallbacks(es))
+	if err := trainer.Fit(regressionIterFn(x, y, 7), nil); err == nil {
+		t.Errorf("Expect error for missing monitored value")
+	}
+}
+
+func TestModelCheckpoint_Loggers(t *testing.T) {
+	dir := t.TempDir()
+	vs := nn.NewVarStore(gotch.CPU)
+	model, x, y := linearRegression(vs)
+	opt, err := nn.DefaultSGDConfig().Build(vs, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ckptPath := filepath.Join(dir, "best.gt")
+	csvPath := filepath.Join(dir, "log.csv")
+	jsonPath := filepath.Join(dir, "log.jsonl")
+	ckpt := nn.NewModelCheckpoint(vs, ckptPath, "val_loss", nn.MonitorMin)
+	trainer := nn.NewTrainer(model, opt, mseLossFn,
+		nn.WithEpochs(5),
+		nn.WithCallbacks(ckpt, nn.NewCSVLogger(csvPath), nn.NewJSONLogger(jsonPath)),
+	)
+	if err := trainer.Fit(regressionIterFn(x, y, 7), regressionIterFn(x, y, 14)); err != nil {
+		t.Fatal(err)
+	}
+
+	if ckpt.BestEpoch < 0 || ckpt.Best() != trainer.History[ckpt.BestEpoch]["val_loss"] {
+		t.Errorf("Unexpected best checkpoint: epoch %v, value %v", ckpt.BestEpoch, ckpt.Best())
+	}
+	vs1 := nn.NewVarStore(gotch.CPU)
+	linearRegression(vs1)
+	if err := vs1.Load(ckptPath); err != nil {
+		t.Errorf("Failed to load saved checkpoint: %v", err)
+	}
+
+	f, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(f).ReadAll()
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHeader := []string{"epoch", "loss", "lr", "val_loss"}
+	if len(records) != 6 || len(records[0]) != len(wantHeader) {
+		t.Fatalf("Want header and 5 rows of %v, got %v", wantHeader, records)
+	}
+	for i, h := range wantHeader {
+		if records[0][i] != h {
+			t.Errorf("Want header %v, got %v", wantHeader, records[0])
+		}
+	}
+
+	f, err = os.Open(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record map[string]float64
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		if int(record["epoch"]) != lines || record["val_loss"] != trainer.History[lines]["val_loss"] {
+			t.Errorf("Unexpected record %v at line %v", record, lines)
+		}
+		lines++
+	}
+	if lines != 5 {
+		t.Errorf("Want 5 JSON lines, got %v", lines)
+	}
+}
@@ -0,0 +1,404 @@
+package nn
+
+// Transformer encoder/decoder layers.
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/sugarme/gotch/ts"
+)
+
+// TransformerLayerConfig is configuration for Transformer encoder/decoder layers.
+type TransformerLayerConfig struct {
+	DimFeedforward int64   // dimension of feedforward network
+	Dropout        float64 // dropout probability
+	Activation     string  // activation of feedforward network. Either "relu" or "gelu"
+	LayerNormEps   float64 // eps of layer normalization
+	BatchFirst     bool    // if true, input/output are [batch, seq, feature]. Otherwise, [seq, batch, feature]
+	NormFirst      bool    // if true, layer norm is done prior to attention and feedforward (pre-norm). Otherwise, after (post-norm)
+	Bias           bool    // whether linear and layer norm layers have bias
+}
+
+// DefaultTransformerLayerConfig creates default TransformerLayerConfig as Pytorch.
+func DefaultTransformerLayerConfig() *TransformerLayerConfig {
+	return &TransformerLayerConfig{
+		DimFeedforward: 2048,
+		Dropout:        0.1,
+		Activation:     "relu",
+		LayerNormEps:   1e-5,
+		BatchFirst:     false,
+		NormFirst:      false,
+		Bias:           true,
+	}
+}
+
+func (c *TransformerLayerConfig) attentionConfig() *MultiheadAttentionConfig {
+	cfg := DefaultMultiheadAttentionConfig()
+	cfg.Dropout = c.Dropout
+	cfg.Bias = c.Bias
+	cfg.BatchFirst = c.BatchFirst
+
+	return cfg
+}
+
+func (c *TransformerLayerConfig) layerNorm(vs *Path, dModel int64) *LayerNorm {
+	cfg := DefaultLayerNormConfig()
+	cfg.Eps = c.LayerNormEps
+	if c.Bias {
+		return NewLayerNorm(vs, []int64{dModel}, cfg)
+	}
+
+	// NOTE. Pytorch LayerNorm with bias=False has no bias variable. A zero
+	// bias, not stored in var store, is used instead.
+	shape := []int64{dModel}
+	ws := vs.MustNewVar(cfg.WsName, shape, cfg.WsInit)
+	bs := ts.MustZeros(shape, ws.DType(), vs.Device())
+
+	return &LayerNorm{cfg, ws, bs, shape, newHookPoint(vs)}
+}
+
+// feedforward is the feedforward block shared by encoder and decoder layers.
+type feedforward struct {
+	linear1    *Linear
+	linear2    *Linear
+	activation string
+	dropout    float64
+}
+
+func newFeedforward(vs *Path, dModel int64, cfg *TransformerLayerConfig) *feedforward {
+	switch cfg.Activation {
+	case "relu", "gelu":
+	default:
+		log.Fatalf("unsupported Transformer activation %q. Expected 'relu' or 'gelu'\n", cfg.Activation)
+	}
+
+	linearCfg := DefaultLinearConfig()
+	linearCfg.Bias = cfg.Bias
+
+	return &feedforward{
+		linear1:    NewLinear(vs.Sub("linear1"), dModel, cfg.DimFeedforward, linearCfg),
+		linear2:    NewLinear(vs.Sub("linear2"), cfg.DimFeedforward, dModel, linearCfg),
+		activation: cfg.Activation,
+		dropout:    cfg.Dropout,
+	}
+}
+
+func (ff *feedforward) forwardT(x *ts.Tensor, train bool) *ts.Tensor {
+	h := ff.linear1.Forward(x)
+	switch ff.activation {
+	case "gelu":
+		h = h.MustGelu("none", true)
+	default:
+		h = h.MustRelu(true)
+	}
+	h = dropout(h, ff.dropout, train)
+	out := ff.linear2.Forward(h)
+	h.MustDrop()
+
+	return dropout(out, ff.dropout, train)
+}
+
+// TransformerEncoderLayer is made up of self-attention and feedforward network.
+//
+// Variables are named as Pytorch `torch.nn.TransformerEncoderLayer` state dict, i.e.
+// "self_attn.*", "linear1.*", "linear2.*", "norm1.*", "norm2.*".
+type TransformerEncoderLayer struct {
+	SelfAttn *MultiheadAttention
+	Linear1  *Linear
+	Linear2  *Linear
+	Norm1    *LayerNorm
+	Norm2    *LayerNorm
+
+	ff     *feedforward
+	config *TransformerLayerConfig
+}
+
+// NewTransformerEncoderLayer creates a TransformerEncoderLayer.
+func NewTransformerEncoderLayer(vs *Path, dModel, nhead int64, cfg *TransformerLayerConfig) *TransformerEncoderLayer {
+	ff := newFeedforward(vs, dModel, cfg)
+	return &TransformerEncoderLayer{
+		SelfAttn: NewMultiheadAttention(vs.Sub("self_attn"), dModel, nhead, cfg.attentionConfig()),
+		Linear1:  ff.linear1,
+		Linear2:  ff.linear2,
+		Norm1:    cfg.layerNorm(vs.Sub("norm1"), dModel),
+		Norm2:    cfg.layerNorm(vs.Sub("norm2"), dModel),
+		ff:       ff,
+		config:   cfg,
+	}
+}
+
+// ForwardT implements ModuleT interface for TransformerEncoderLayer.
+func (l *TransformerEncoderLayer) ForwardT(src *ts.Tensor, train bool) *ts.Tensor {
+	return l.ForwardMask(src, train)
+}
+
+// ForwardMask passes input through encoder layer with optional masks. Supported
+// options are `WithAttnMask()`, `WithKeyPaddingMask()` and `WithCausalMask()`.
+func (l *TransformerEncoderLayer) ForwardMask(src *ts.Tensor, train bool, opts ...AttentionOpt) *ts.Tensor {
+	opts = append(opts, WithNeedWeights(false))
+
+	selfAttn := func(x *ts.Tensor) *ts.Tensor {
+		out, _ := l.SelfAttn.ForwardT(x, x, x, train, opts...)
+		return dropout(out, l.config.Dropout, train)
+	}
+
+	x := src.MustShallowClone()
+	if l.config.NormFirst {
+		x = residual(x, l.Norm1.Forward, selfAttn)
+		x = residual(x, l.Norm2.Forward, func(x *ts.Tensor) *ts.Tensor { return l.ff.forwardT(x, train) })
+		return x
+	}
+
+	x = normResidual(x, l.Norm1, selfAttn)
+	x = normResidual(x, l.Norm2, func(x *ts.Tensor) *ts.Tensor { return l.ff.forwardT(x, train) })
+
+	return x
+}
+
+// DecoderOptions are options for Transformer decoder forward pass.
+type DecoderOptions struct {
+	TgtMask              *ts.Tensor // attention mask of target self-attention
+	MemoryMask           *ts.Tensor // attention mask of attention on encoder output (memory)
+	TgtKeyPaddingMask    *ts.Tensor // key padding mask of target
+	MemoryKeyPaddingMask *ts.Tensor // key padding mask of memory
+	TgtIsCausal          bool       // apply causal mask to target self-attention
+}
+
+type DecoderOpt func(*DecoderOptions)
+
+func DefaultDecoderOptions() *DecoderOptions {
+	return &DecoderOptions{}
+}
+
+// WithTgtMask sets attention mask of target self-attention.
+func WithTgtMask(mask *ts.Tensor) DecoderOpt {
+	return func(o *DecoderOptions) {
+		o.TgtMask = mask
+	}
+}
+
+// WithMemoryMask sets attention mask of attention on memory.
+func WithMemoryMask(mask *ts.Tensor) DecoderOpt {
+	return func(o *DecoderOptions) {
+		o.MemoryMask = mask
+	}
+}
+
+// WithTgtKeyPaddingMask sets key padding mask of target.
+func WithTgtKeyPaddingMask(mask *ts.Tensor) DecoderOpt {
+	return func(o *DecoderOptions) {
+		o.TgtKeyPaddingMask = mask
+	}
+}
+
+// WithMemoryKeyPaddingMask sets key padding mask of memory.
+func WithMemoryKeyPaddingMask(mask *ts.Tensor) DecoderOpt {
+	return func(o *DecoderOptions) {
+		o.MemoryKeyPaddingMask = mask
+	}
+}
+
+// WithTgtCausalMask sets whether a causal mask is applied to target self-attention.
+func WithTgtCausalMask(v bool) DecoderOpt {
+	return func(o *DecoderOptions) {
+		o.TgtIsCausal = v
+	}
+}
+
+// TransformerDecoderLayer is made up of self-attention, attention on encoder
+// output (memory) and feedforward network.
+//
+// Variables are named as Pytorch `torch.nn.TransformerDecoderLayer` state dict, i.e.
+// "self_attn.*", "multihead_attn.*", "linear1.*", "linear2.*", "norm1.*", "norm2.*", "norm3.*".
+type TransformerDecoderLayer struct {
+	SelfAttn      *MultiheadAttention
+	MultiheadAttn *MultiheadAttention
+	Linear1       *Linear
+	Linear2       *Linear
+	Norm1         *LayerNorm
+	Norm2         *LayerNorm
+	Norm3         *LayerNorm
+
+	ff     *feedforward
+	config *TransformerLayerConfig
+}
+
+// NewTransformerDecoderLayer creates a TransformerDecoderLayer.
+func NewTransformerDecoderLayer(vs *Path, dModel, nhead int64, cfg *TransformerLayerConfig) *TransformerDecoderLayer {
+	ff := newFeedforward(vs, dModel, cfg)
+	return &TransformerDecoderLayer{
+		SelfAttn:      NewMultiheadAttention(vs.Sub("self_attn"), dModel, nhead, cfg.attentionConfig()),
+		MultiheadAttn: NewMultiheadAttention(vs.Sub("multihead_attn"), dModel, nhead, cfg.attentionConfig()),
+		Linear1:       ff.linear1,
+		Linear2:       ff.linear2,
+		Norm1:         cfg.layerNorm(vs.Sub("norm1"), dModel),
+		Norm2:         cfg.layerNorm(vs.Sub("norm2"), dModel),
+		Norm3:         cfg.layerNorm(vs.Sub("norm3"), dModel),
+		ff:            ff,
+		config:        cfg,
+	}
+}
+
+// ForwardT passes target and encoder output (memory) through decoder layer.
+func (l *TransformerDecoderLayer) ForwardT(tgt, memory *ts.Tensor, train bool, opts ...DecoderOpt) *ts.Tensor {
+	o := DefaultDecoderOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	selfAttn := func(x *ts.Tensor) *ts.Tensor {
+		out, _ := l.SelfAttn.ForwardT(x, x, x, train,
+			WithAttnMask(o.TgtMask),
+			WithKeyPaddingMask(o.TgtKeyPaddingMask),
+			WithCausalMask(o.TgtIsCausal),
+		)
+		return dropout(out, l.config.Dropout, train)
+	}
+	crossAttn := func(x *ts.Tensor) *ts.Tensor {
+		out, _ := l.MultiheadAttn.ForwardT(x, memory, memory, train,
+			WithAttnMask(o.MemoryMask),
+			WithKeyPaddingMask(o.MemoryKeyPaddingMask),
+		)
+		return dropout(out, l.config.Dropout, train)
+	}
+	ff := func(x *ts.Tensor) *ts.Tensor {
+		return l.ff.forwardT(x, train)
+	}
+
+	x := tgt.MustShallowClone()
+	if l.config.NormFirst {
+		x = residual(x, l.Norm1.Forward, selfAttn)
+		x = residual(x, l.Norm2.Forward, crossAttn)
+		x = residual(x, l.Norm3.Forward, ff)
+		return x
+	}
+
+	x = normResidual(x, l.Norm1, selfAttn)
+	x = normResidual(x, l.Norm2, crossAttn)
+	x = normResidual(x, l.Norm3, ff)
+
+	return x
+}
+
+// residual computes pre-norm block `x + f(norm(x))`. Input x is deleted.
+func residual(x *ts.Tensor, norm func(*ts.Tensor) *ts.Tensor, f func(*ts.Tensor) *ts.Tensor) *ts.Tensor {
+	h := norm(x)
+	y := f(h)
+	h.MustDrop()
+
+	retVal := x.MustAdd(y, true)
+	y.MustDrop()
+
+	return retVal
+}
+
+// normResidual computes post-norm block `norm(x + f(x))`. Input x is deleted.
+func normResidual(x *ts.Tensor, norm *LayerNorm, f func(*ts.Tensor) *ts.Tensor) *ts.Tensor {
+	y := f(x)
+	sum := x.MustAdd(y, true)
+	y.MustDrop()
+
+	retVal := norm.Forward(sum)
+	sum.MustDrop()
+
+	return retVal
+}
+
+// TransformerEncoder is a stack of TransformerEncoderLayer.
+//
+// Variables are named as Pytorch `torch.nn.TransformerEncoder` state dict, i.e.
+// "layers.<i>.*" and "norm.*" (optional).
+type TransformerEncoder struct {
+	Layers []*TransformerEncoderLayer
+	Norm   *LayerNorm // optional
+}
+
+// NewTransformerEncoder creates a TransformerEncoder of numLayers layers. An
+// optional final layer norm is added if withNormOpt is true (default=false).
+func NewTransformerEncoder(vs *Path, numLayers, dModel, nhead int64, cfg *TransformerLayerConfig, withNormOpt ...bool) *TransformerEncoder {
+	layers := make([]*TransformerEncoderLayer, numLayers)
+	for i := range layers {
+		layers[i] = NewTransformerEncoderLayer(vs.Sub(fmt.Sprintf("layers.%d", i)), dModel, nhead, cfg)
+	}
+
+	var norm *LayerNorm
+	if len(withNormOpt) > 0 && withNormOpt[0] {
+		norm = cfg.layerNorm(vs.Sub("norm"), dModel)
+	}
+
+	return &TransformerEncoder{
+		Layers: layers,
+		Norm:   norm,
+	}
+}
+
+// ForwardT implements ModuleT interface for TransformerEncoder.
+func (e *TransformerEncoder) ForwardT(src *ts.Tensor, train bool) *ts.Tensor {
+	return e.ForwardMask(src, train)
+}
+
+// ForwardMask passes input through encoder layers with optional masks.
+// See `TransformerEncoderLayer.ForwardMask()`.
+func (e *TransformerEncoder) ForwardMask(src *ts.Tensor, train bool, opts ...AttentionOpt) *ts.Tensor {
+	x := src.MustShallowClone()
+	for _, l := range e.Layers {
+		out := l.ForwardMask(x, train, opts...)
+		x.MustDrop()
+		x = out
+	}
+
+	if e.Norm != nil {
+		out := e.Norm.Forward(x)
+		x.MustDrop()
+		x = out
+	}
+
+	return x
+}
+
+// TransformerDecoder is a stack of TransformerDecoderLayer.
+//
+// Variables are named as Pytorch `torch.nn.TransformerDecoder` state dict, i.e.
+// "layers.<i>.*" and "norm.*" (optional).
+type TransformerDecoder struct {
+	Layers []*TransformerDecoderLayer
+	Norm   *LayerNorm // optional
+}
+
+// NewTransformerDecoder creates a TransformerDecoder of numLayers layers. An
+// optional final layer norm is added if withNormOpt is true (default=false).
+func NewTransformerDecoder(vs *Path, numLayers, dModel, nhead int64, cfg *TransformerLayerConfig, withNormOpt ...bool) *TransformerDecoder {
+	layers := make([]*TransformerDecoderLayer, numLayers)
+	for i := range layers {
+		layers[i] = NewTransformerDecoderLayer(vs.Sub(fmt.Sprintf("layers.%d", i)), dModel, nhead, cfg)
+	}
+
+	var norm *LayerNorm
+	if len(withNormOpt) > 0 && withNormOpt[0] {
+		norm = cfg.layerNorm(vs.Sub("norm"), dModel)
+	}
+
+	return &TransformerDecoder{
+		Layers: layers,
+		Norm:   norm,
+	}
+}
+
+// ForwardT passes target and encoder output (memory) through decoder layers.
+func (d *TransformerDecoder) ForwardT(tgt, memory *ts.Tensor, train bool, opts ...DecoderOpt) *ts.Tensor {
+	x := tgt.MustShallowClone()
+	for _, l := range d.Layers {
+		out := l.ForwardT(x, memory, train, opts...)
+		x.MustDrop()
+		x = out
+	}
+
+	if d.Norm != nil {
+		out := d.Norm.Forward(x)
+		x.MustDrop()
+		x = out
+	}
+
+	return x
+}
@@ -0,0 +1,130 @@
+package nn_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+func varNames(vs *nn.VarStore) []string {
+	var names []string
+	for name := range vs.Variables() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestTransformerEncoderLayer_VarNames(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	nn.NewTransformerEncoder(vs.Root(), 1, 8, 2, nn.DefaultTransformerLayerConfig(), true)
+
+	want := []string{
+		"layers.0.linear1.bias",
+		"layers.0.linear1.weight",
+		"layers.0.linear2.bias",
+		"layers.0.linear2.weight",
+		"layers.0.norm1.bias",
+		"layers.0.norm1.weight",
+		"layers.0.norm2.bias",
+		"layers.0.norm2.weight",
+		"layers.0.self_attn.in_proj_bias",
+		"layers.0.self_attn.in_proj_weight",
+		"layers.0.self_attn.out_proj.bias",
+		"layers.0.self_attn.out_proj.weight",
+		"norm.bias",
+		"norm.weight",
+	}
+	got := varNames(vs)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v\n", want)
+		t.Errorf("got: %v\n", got)
+	}
+}
+
+func TestMultiheadAttention(t *testing.T) {
+	var (
+		batch  int64 = 2
+		seqLen int64 = 4
+		dim    int64 = 8
+	)
+
+	vs := nn.NewVarStore(gotch.CPU)
+	cfg := nn.DefaultMultiheadAttentionConfig()
+	cfg.BatchFirst = true
+	mha := nn.NewMultiheadAttention(vs.Root(), dim, 2, cfg)
+
+	x := ts.MustRandn([]int64{batch, seqLen, dim}, gotch.Float, gotch.CPU)
+	out, weights := mha.ForwardT(x, x, x, false, nn.WithCausalMask(true), nn.WithNeedWeights(true))
+
+	if got := out.MustSize(); !reflect.DeepEqual(got, []int64{batch, seqLen, dim}) {
+		t.Errorf("want output shape %v, got %v\n", []int64{batch, seqLen, dim}, got)
+	}
+	if got := weights.MustSize(); !reflect.DeepEqual(got, []int64{batch, seqLen, seqLen}) {
+		t.Errorf("want weights shape %v, got %v\n", []int64{batch, seqLen, seqLen}, got)
+	}
+
+	// With causal mask, first position only attends to itself.
+	w := weights.MustSelect(1, 0, false).MustSelect(1, 0, true).Float64Values()
+	for _, v := range w {
+		if v < 0.9999 {
+			t.Errorf("want weight of first position = 1, got %v\n", v)
+		}
+	}
+
+	// Key padding mask: last position is ignored.
+	mask := ts.MustOfSlice([]bool{false, false, false, true, false, false, false, true}).MustView([]int64{batch, seqLen}, true)
+	_, weights = mha.ForwardT(x, x, x, false, nn.WithKeyPaddingMask(mask), nn.WithNeedWeights(true))
+	last := weights.MustSelect(2, seqLen-1, false).Float64Values()
+	for _, v := range last {
+		if v != 0 {
+			t.Errorf("want zero weight on padded key, got %v\n", v)
+		}
+	}
+}
+
+func TestTransformer_EncoderDecoder(t *testing.T) {
+	var (
+		batch  int64 = 2
+		srcLen int64 = 5
+		tgtLen int64 = 3
+		dim    int64 = 8
+	)
+
+	for _, normFirst := range []bool{false, true} {
+		vs := nn.NewVarStore(gotch.CPU)
+		cfg := nn.DefaultTransformerLayerConfig()
+		cfg.DimFeedforward = 16
+		cfg.NormFirst = normFirst
+		encoder := nn.NewTransformerEncoder(vs.Root().Sub("encoder"), 2, dim, 2, cfg, true)
+		decoder := nn.NewTransformerDecoder(vs.Root().Sub("decoder"), 2, dim, 2, cfg, true)
+
+		src := ts.MustRandn([]int64{srcLen, batch, dim}, gotch.Float, gotch.CPU)
+		tgt := ts.MustRandn([]int64{tgtLen, batch, dim}, gotch.Float, gotch.CPU)
+
+		memory := encoder.ForwardT(src, false)
+		tgtMask := nn.GenerateSquareSubsequentMask(tgtLen, gotch.CPU)
+		out := decoder.ForwardT(tgt, memory, false, nn.WithTgtMask(tgtMask))
+
+		want := []int64{tgtLen, batch, dim}
+		if got := out.MustSize(); !reflect.DeepEqual(want, got) {
+			t.Errorf("normFirst=%v: want output shape %v, got %v\n", normFirst, want, got)
+		}
+
+		// Causal: output of first target position does not depend on later positions.
+		head := tgt.MustNarrow(0, 0, 1, false)
+		tail := ts.MustZeros([]int64{tgtLen - 1, batch, dim}, gotch.Float, gotch.CPU)
+		tgt1 := ts.MustCat([]*ts.Tensor{head, tail}, 0)
+		out1 := decoder.ForwardT(tgt1, memory, false, nn.WithTgtCausalMask(true))
+		first := out.MustSelect(0, 0, false)
+		first1 := out1.MustSelect(0, 0, false)
+		if !first.MustAllclose(first1, 1e-5, 1e-6, false, false) {
+			t.Errorf("normFirst=%v: want first position unchanged by later positions\n", normFirst)
+		}
+	}
+}
@@ -29,6 +29,9 @@ type VarStore struct {
 	sync.Mutex
 	device gotch.Device
 	vars   map[string]Var
+	hooks  *hookRegistry
+
+	bnMomentums map[string]float64 // momentum of BatchNorm layers by "running_mean" variable name
 }
 
 // Path is variable store with an associated path for variables naming.
@@ -47,8 +50,10 @@ type Entry struct {
 // NewVarStore creates a new variable store located on the specified device
 func NewVarStore(device gotch.Device) *VarStore {
 	return &VarStore{
-		device: device,
-		vars:   make(map[string]Var, 0),
+		device:      device,
+		vars:        make(map[string]Var, 0),
+		hooks:       newHookRegistry(),
+		bnMomentums: make(map[string]float64),
 	}
 }
 
@@ -129,6 +134,24 @@ func (vs *VarStore) Save(filepath string) error {
 	vs.Lock()
 	defer vs.Unlock()
 
+	namedTensors := vs.persistentTensors()
+
+	// return ts.SaveMulti(namedTensors, filepath)
+	return ts.SaveMultiNew(namedTensors, filepath)
+}
+
+// NamedTensors returns all variables to be saved, i.e. "parameter" and persistent
+// "buffer" variables, sorted by name.
+//
+// NOTE. returned tensors are references to variables in VarStore.
+func (vs *VarStore) NamedTensors() []ts.NamedTensor {
+	vs.Lock()
+	defer vs.Unlock()
+
+	return vs.persistentTensors()
+}
+
+func (vs *VarStore) persistentTensors() []ts.NamedTensor {
 	var namedTensors []ts.NamedTensor
 	for k, v := range vs.vars {
 		if v.Type == "parameter" || (v.Type == "buffer" && v.Persitent) {
@@ -138,9 +161,11 @@ func (vs *VarStore) Save(filepath string) error {
 			})
 		}
 	}
+	sort.Slice(namedTensors, func(i, j int) bool {
+		return namedTensors[i].Name < namedTensors[j].Name
+	})
 
-	// return ts.SaveMulti(namedTensors, filepath)
-	return ts.SaveMultiNew(namedTensors, filepath)
+	return namedTensors
 }
 
 // Load loads VarStore variable values from a file.
@@ -199,49 +224,7 @@ func (vs *VarStore) Load(filepath string) error {
 
 // LoadWeights loads pretrained weights to VarStore.
 func (vs *VarStore) LoadWeights(namedTensors []ts.NamedTensor) error {
-	var namedTensorsMap map[string]*ts.Tensor = make(map[string]*ts.Tensor, 0)
-	for _, namedTensor := range namedTensors {
-		namedTensorsMap[namedTensor.Name] = namedTensor.Tensor
-	}
-
-	// Match and in-place copy value (update) from newly loaded tensors
-	// to existing named tensors if name is matched. Throw error otherwise.
-	vs.Lock()
-	defer vs.Unlock()
-
-	for tmpName, v := range vs.vars {
-		// missing variable
-		currTs, ok := namedTensorsMap[tmpName]
-		if !ok {
-			//尝试使用
-			tmpName = strings.Replace(tmpName, "gamma", "weight", -1)
-			tmpName = strings.Replace(tmpName, "beta", "bias", -1)
-		}
-		name := tmpName
-		currTs, ok = namedTensorsMap[tmpName]
-		if !ok {
-			err := fmt.Errorf("VarStore.LoadWeights() failed: there's a tensor with name %q in VarStore, but not found in the loaded weights.\n", name)
-			fmt.Println(err)
-			continue
-			//return err
-		}
-
-		// mismatched shape
-		sourceShape := currTs.MustSize()
-		destShape := v.Tensor.MustSize()
-		if !reflect.DeepEqual(destShape, sourceShape) {
-			err := fmt.Errorf("VarStore.LoadWeights() failed. Mismatched shape error for variable name: %v - At store: %v - At source %v\n", name, destShape, sourceShape)
-			return err
-		}
-
-		ts.NoGrad(func() {
-			v.Tensor.Copy_(currTs)
-		})
-	}
-
-	ts.CleanUp()
-
-	return nil
+	return vs.LoadWeightsFrom(newNamedTensorsLoader(namedTensors))
 }
 
 // LoadPartial loads the VarStore variable values from a file if it exists.
@@ -316,40 +299,181 @@ func (vs *VarStore) LoadPartial(filepath string) ([]string, error) {
 //
 // Returns a String Vector containing the names of missing variables.
 func (vs *VarStore) LoadWeightsPartial(namedTensors []ts.NamedTensor) ([]string, error) {
-	var namedTensorsMap map[string]*ts.Tensor = make(map[string]*ts.Tensor, 0)
+	return vs.LoadPartialFrom(newNamedTensorsLoader(namedTensors))
+}
+
+// TensorLoader provides named tensors on demand so that VarStore variables
+// can be loaded without reading all tensors of a source into memory first.
+type TensorLoader interface {
+	// Shape returns shape of the tensor with given name and whether it exists.
+	Shape(name string) ([]int64, bool)
+	// Tensor reads the tensor with given name to a device. The returned tensor
+	// is owned by caller.
+	Tensor(name string, device gotch.Device) (*ts.Tensor, error)
+}
+
+// namedTensorsLoader is a TensorLoader of in-memory named tensors.
+type namedTensorsLoader map[string]*ts.Tensor
+
+func newNamedTensorsLoader(namedTensors []ts.NamedTensor) namedTensorsLoader {
+	l := make(namedTensorsLoader, len(namedTensors))
 	for _, namedTensor := range namedTensors {
-		namedTensorsMap[namedTensor.Name] = namedTensor.Tensor
+		l[namedTensor.Name] = namedTensor.Tensor
 	}
 
-	var missingVariables []string
+	return l
+}
 
-	// Match and in-place copy value (update) from newly loaded tensors
-	// to existing named tensors if name is matched. Throw error otherwise.
+func (l namedTensorsLoader) Shape(name string) ([]int64, bool) {
+	x, ok := l[name]
+	if !ok {
+		return nil, false
+	}
+
+	return x.MustSize(), true
+}
+
+func (l namedTensorsLoader) Tensor(name string, device gotch.Device) (*ts.Tensor, error) {
+	x, ok := l[name]
+	if !ok {
+		err := fmt.Errorf("tensor %q not found", name)
+		return nil, err
+	}
+
+	return x.ShallowClone()
+}
+
+func isSameShape(destShape, sourceShape []int64) bool {
+	if len(destShape) == 0 && len(sourceShape) == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(destShape, sourceShape)
+}
+
+// copyFrom reads tensor from loader and in-place copies its values to a variable.
+func (vs *VarStore) copyFrom(loader TensorLoader, name string, v Var) error {
+	currTs, err := loader.Tensor(name, vs.device)
+	if err != nil {
+		return err
+	}
+
+	ts.NoGrad(func() {
+		v.Tensor.Copy_(currTs)
+	})
+	currTs.MustDrop()
+
+	return nil
+}
+
+// LoadFrom loads VarStore variable values from a TensorLoader.
+//
+// It has the same semantic as `Load()`: it will throw error if a variable in
+// VarStore is not found in the loader or has mismatched shape. All variables
+// are checked before any of them is modified.
+func (vs *VarStore) LoadFrom(loader TensorLoader) error {
 	vs.Lock()
 	defer vs.Unlock()
 
 	for name, v := range vs.vars {
-		var currTs *ts.Tensor
-		var ok bool
+		// missing variable
+		sourceShape, ok := loader.Shape(name)
+		if !ok {
+			err := fmt.Errorf("VarStore.LoadFrom() failed: there's a tensor with name %q in VarStore, but not found in the loaded weights.\n", name)
+			return err
+		}
+
+		// mismatched shape
+		destShape := v.Tensor.MustSize()
+		if !isSameShape(destShape, sourceShape) {
+			err := fmt.Errorf("Mismatched shape error for variable name: %v - At store: %v - At source %v\n", name, destShape, sourceShape)
+			return err
+		}
+	}
 
+	for name, v := range vs.vars {
+		if err := vs.copyFrom(loader, name, v); err != nil {
+			return err
+		}
+	}
+
+	ts.CleanUp()
+
+	return nil
+}
+
+// LoadWeightsFrom loads pretrained weights from a TensorLoader.
+//
+// It has the same semantic as `LoadWeights()`: variables not found in the
+// loader (also after renaming "gamma"/"beta" to "weight"/"bias") are reported
+// and skipped. It will throw error if there's a mismatched shape.
+func (vs *VarStore) LoadWeightsFrom(loader TensorLoader) error {
+	vs.Lock()
+	defer vs.Unlock()
+
+	for tmpName, v := range vs.vars {
 		// missing variable
-		if currTs, ok = namedTensorsMap[name]; !ok {
+		_, ok := loader.Shape(tmpName)
+		if !ok {
+			//尝试使用
+			tmpName = strings.Replace(tmpName, "gamma", "weight", -1)
+			tmpName = strings.Replace(tmpName, "beta", "bias", -1)
+		}
+		name := tmpName
+		sourceShape, ok := loader.Shape(tmpName)
+		if !ok {
+			err := fmt.Errorf("VarStore.LoadWeights() failed: there's a tensor with name %q in VarStore, but not found in the loaded weights.\n", name)
+			fmt.Println(err)
+			continue
+			//return err
+		}
+
+		// mismatched shape
+		destShape := v.Tensor.MustSize()
+		if !isSameShape(destShape, sourceShape) {
+			err := fmt.Errorf("VarStore.LoadWeights() failed. Mismatched shape error for variable name: %v - At store: %v - At source %v\n", name, destShape, sourceShape)
+			return err
+		}
+
+		if err := vs.copyFrom(loader, name, v); err != nil {
+			return err
+		}
+	}
+
+	ts.CleanUp()
+
+	return nil
+}
+
+// LoadPartialFrom loads VarStore variable values from a TensorLoader.
+//
+// It has the same semantic as `LoadPartial()`: variables not present in the
+// loader or having mismatched shape are skipped. Returns names of missing variables.
+func (vs *VarStore) LoadPartialFrom(loader TensorLoader) ([]string, error) {
+	var missingVariables []string
+
+	vs.Lock()
+	defer vs.Unlock()
+
+	for name, v := range vs.vars {
+		// missing variable
+		sourceShape, ok := loader.Shape(name)
+		if !ok {
 			missingVariables = append(missingVariables, name)
 			continue
 		}
 
 		// mismatched shape
-		destShape := currTs.MustSize()
-		sourceShape := v.Tensor.MustSize()
-		if !reflect.DeepEqual(destShape, sourceShape) {
+		destShape := v.Tensor.MustSize()
+		if !isSameShape(destShape, sourceShape) {
 			fmt.Printf("WARNING: Mismatched shape error for variable name: %v - At store: %v - At source %v. Skip loading this weight...\n", name, destShape, sourceShape)
 			missingVariables = append(missingVariables, name)
 			continue
 		}
 
-		ts.NoGrad(func() {
-			v.Tensor.Copy_(currTs)
-		})
+		if err := vs.copyFrom(loader, name, v); err != nil {
+			return nil, err
+		}
 	}
 
 	ts.CleanUp()
@@ -357,6 +481,45 @@ func (vs *VarStore) LoadWeightsPartial(namedTensors []ts.NamedTensor) ([]string,
 	return missingVariables, nil
 }
 
+// SaveSafetensors saves the VarStore variable values to a safetensors file.
+//
+// NOTE: as `Save()`, all parameters and persistent buffers are saved.
+func (vs *VarStore) SaveSafetensors(filepath string) error {
+	vs.Lock()
+	defer vs.Unlock()
+
+	return ts.WriteSafetensors(vs.persistentTensors(), filepath)
+}
+
+// LoadSafetensors loads VarStore variable values from a safetensors file.
+//
+// It has the same semantic as `Load()`, i.e. it will throw error if a variable
+// in VarStore is not found in the file or has mismatched shape. The file is
+// memory-mapped and each tensor is only read when copying to its variable.
+func (vs *VarStore) LoadSafetensors(filepath string) error {
+	sf, err := ts.OpenSafetensors(filepath)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+
+	return vs.LoadFrom(sf)
+}
+
+// LoadSafetensorsPartial loads the VarStore variable values from a safetensors file.
+//
+// It has the same semantic as `LoadPartial()`: variables not present in the file
+// or having mismatched shape are skipped and their names are returned.
+func (vs *VarStore) LoadSafetensorsPartial(filepath string) ([]string, error) {
+	sf, err := ts.OpenSafetensors(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer sf.Close()
+
+	return vs.LoadPartialFrom(sf)
+}
+
 // Freeze freezes this VarStore.
 //
 // Gradients for the variables in this store are not tracked anymore.
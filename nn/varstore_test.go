@@ -175,3 +175,61 @@ func TestVarstore_Memcheck(t *testing.T) {
 	time.Sleep(time.Second * 10)
 	gotch.PrintMemStats("Final")
 }
+
+func TestSaveLoadSafetensors(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "vsload.safetensors")
+
+	vs1 := nn.NewVarStore(gotch.CPU)
+	vs2 := nn.NewVarStore(gotch.CPU)
+	vs3 := nn.NewVarStore(gotch.CPU)
+
+	u1 := vs1.Root().MustZeros("t1", []int64{4})
+	v1 := vs1.Root().Sub("a").MustOnes("t2", []int64{3})
+	u2 := vs2.Root().MustZeros("t1", []int64{4})
+	v2 := vs2.Root().Sub("a").MustOnes("t2", []int64{3})
+	u3 := vs3.Root().MustZeros("t1", []int64{4})
+	_ = vs3.Root().MustZeros("extra", []int64{2})
+
+	ts.NoGrad(func() {
+		u1.AddScalar_(ts.FloatScalar(42.0))
+		v1.MulScalar_(ts.FloatScalar(2.0))
+	})
+
+	err := vs1.SaveSafetensors(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = vs2.LoadSafetensors(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotU2 := u2.MustMean(gotch.Float, false).Float64Values()[0]
+	gotV2 := v2.MustMean(gotch.Float, false).Float64Values()[0]
+	if gotU2 != 42.0 || gotV2 != 2.0 {
+		t.Errorf("Expected u2: 42, v2: 2\n")
+		t.Errorf("Got u2: %v, v2: %v\n", gotU2, gotV2)
+	}
+
+	// vs3 has a variable not in the file.
+	err = vs3.LoadSafetensors(filename)
+	if err == nil {
+		t.Errorf("Expected error for missing variable, got nil")
+	}
+
+	missing, err := vs3.LoadSafetensorsPartial(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string{"extra"}, missing) {
+		t.Errorf("Expected missing: %v\n", []string{"extra"})
+		t.Errorf("Got missing: %v\n", missing)
+	}
+
+	gotU3 := u3.MustMean(gotch.Float, false).Float64Values()[0]
+	if gotU3 != 42.0 {
+		t.Errorf("Expected u3: 42\n")
+		t.Errorf("Got u3: %v\n", gotU3)
+	}
+}
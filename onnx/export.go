@@ -0,0 +1,508 @@
+package onnx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+const (
+	// IRVersion is ONNX IR version of exported models.
+	IRVersion int64 = 8
+	// OpsetVersion is the default ONNX operator set version of exported models.
+	OpsetVersion int64 = 17
+
+	ProducerName string = "gotch"
+)
+
+// Activation describes how a named `nn.Func` is exported to an ONNX node.
+type Activation struct {
+	OpType     string
+	Attributes []*AttributeProto
+	// Fn is the Go implementation. It is used by `Func()` to create a named `nn.Func`.
+	Fn func(*ts.Tensor) *ts.Tensor
+}
+
+var (
+	activationsMu sync.RWMutex
+	activations   = map[string]Activation{
+		"relu": {
+			OpType: "Relu",
+			Fn:     func(xs *ts.Tensor) *ts.Tensor { return xs.MustRelu(false) },
+		},
+		"sigmoid": {
+			OpType: "Sigmoid",
+			Fn:     func(xs *ts.Tensor) *ts.Tensor { return xs.MustSigmoid(false) },
+		},
+		"tanh": {
+			OpType: "Tanh",
+			Fn:     func(xs *ts.Tensor) *ts.Tensor { return xs.MustTanh(false) },
+		},
+		"leaky_relu": {
+			OpType:     "LeakyRelu",
+			Attributes: []*AttributeProto{floatAttr("alpha", 0.01)},
+			Fn:         func(xs *ts.Tensor) *ts.Tensor { return xs.MustLeakyRelu(false) },
+		},
+		"softmax": {
+			OpType:     "Softmax",
+			Attributes: []*AttributeProto{intAttr("axis", -1)},
+			Fn:         func(xs *ts.Tensor) *ts.Tensor { return xs.MustSoftmax(-1, xs.DType(), false) },
+		},
+		"flatten": {
+			OpType:     "Flatten",
+			Attributes: []*AttributeProto{intAttr("axis", 1)},
+			Fn:         func(xs *ts.Tensor) *ts.Tensor { return xs.MustFlatten(1, -1, false) },
+		},
+	}
+)
+
+// RegisterActivation registers an activation so that `nn.Func` layers named
+// `name` (see `nn.NewNamedFunc()`) can be exported. Existing registration is replaced.
+func RegisterActivation(name string, a Activation) {
+	activationsMu.Lock()
+	defer activationsMu.Unlock()
+
+	activations[name] = a
+}
+
+func lookupActivation(name string) (Activation, bool) {
+	activationsMu.RLock()
+	defer activationsMu.RUnlock()
+
+	a, ok := activations[name]
+	return a, ok
+}
+
+// Func returns a named `nn.Func` of a registered activation, e.g. `onnx.Func("relu")`.
+func Func(name string) (nn.Func, error) {
+	a, ok := lookupActivation(name)
+	if !ok || a.Fn == nil {
+		err := fmt.Errorf("onnx.Func() failed: activation %q not registered", name)
+		return nn.Func{}, err
+	}
+
+	return nn.NewNamedFunc(name, a.Fn), nil
+}
+
+// MustFunc returns a named `nn.Func` of a registered activation. It panics if error occurred.
+func MustFunc(name string) nn.Func {
+	fn, err := Func(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return fn
+}
+
+// Export exports a sequential model to an ONNX file.
+//
+// Weights are taken from var store and named after their var store names.
+// input is an example input which is passed through the model to infer
+// shapes of the graph input and output.
+//
+// Supported layers: `nn.Linear`, `nn.Conv2D`, `nn.BatchNorm`, `nn.LayerNorm`,
+// `nn.Embedding`, `nn.MaxPool2D`, `nn.Dropout`, `nn.Identity`, nested sequential
+// layers and named `nn.Func` of registered activations (see `RegisterActivation()`).
+func Export(vs *nn.VarStore, seq *nn.Sequential, input *ts.Tensor, path string) error {
+	var layers []interface{}
+	for _, l := range seq.Layers() {
+		layers = append(layers, l)
+	}
+
+	return export(vs, layers, input, path)
+}
+
+// ExportT exports a sequential model with training mode support to an ONNX file.
+// The model is exported in inference mode. See `Export()` for details.
+func ExportT(vs *nn.VarStore, seq *nn.SequentialT, input *ts.Tensor, path string) error {
+	var layers []interface{}
+	for _, l := range seq.Layers() {
+		layers = append(layers, l)
+	}
+
+	return export(vs, layers, input, path)
+}
+
+func export(vs *nn.VarStore, layers []interface{}, input *ts.Tensor, path string) error {
+	var (
+		model *ModelProto
+		err   error
+	)
+	ts.NoGrad(func() {
+		model, err = NewModel(vs, layers, input)
+	})
+	if err != nil {
+		err = fmt.Errorf("onnx.Export() failed: %w", err)
+		return err
+	}
+
+	return WriteModel(model, path)
+}
+
+// NewModel builds an ONNX model from layers of a sequential model.
+// See `Export()` for supported layers.
+func NewModel(vs *nn.VarStore, layers []interface{}, input *ts.Tensor) (*ModelProto, error) {
+	inputType, err := dataTypeOf(input.DType())
+	if err != nil {
+		return nil, err
+	}
+
+	b := &graphBuilder{
+		graph:   &GraphProto{Name: "main_graph"},
+		varName: make(map[uintptr]string),
+		opCount: make(map[string]int),
+	}
+	for _, nt := range vs.NamedTensors() {
+		b.varName[uintptr(nt.Tensor.MustDataPtr())] = nt.Name
+	}
+
+	b.graph.Input = []*ValueInfoProto{{
+		Name:     "input",
+		ElemType: inputType,
+		Shape:    input.MustSize(),
+	}}
+
+	x := input.MustShallowClone()
+	name := "input"
+	for _, l := range layers {
+		name, x, err = b.addLayer(l, name, x)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer x.MustDrop()
+
+	outputType, err := dataTypeOf(x.DType())
+	if err != nil {
+		return nil, err
+	}
+	// rename last value to "output"
+	b.rename(name, "output")
+	b.graph.Output = []*ValueInfoProto{{
+		Name:     "output",
+		ElemType: outputType,
+		Shape:    x.MustSize(),
+	}}
+
+	model := &ModelProto{
+		IRVersion:    IRVersion,
+		OpsetImport:  []*OperatorSetIdProto{{Version: OpsetVersion}},
+		ProducerName: ProducerName,
+		Graph:        b.graph,
+	}
+
+	return model, nil
+}
+
+// WriteModel writes an ONNX model to file.
+func WriteModel(model *ModelProto, path string) error {
+	return os.WriteFile(path, model.Marshal(), 0644)
+}
+
+type graphBuilder struct {
+	graph   *GraphProto
+	varName map[uintptr]string // data pointer -> var store name
+	opCount map[string]int     // number of nodes per op type
+}
+
+// addLayer adds node(s) of a layer taking input value `input` (tensor x).
+// It returns output value name and output tensor. Input tensor x is dropped.
+func (b *graphBuilder) addLayer(layer interface{}, input string, x *ts.Tensor) (string, *ts.Tensor, error) {
+	switch l := layer.(type) {
+	case *nn.Sequential:
+		var err error
+		for _, sub := range l.Layers() {
+			input, x, err = b.addLayer(sub, input, x)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+		return input, x, nil
+
+	case *nn.SequentialT:
+		var err error
+		for _, sub := range l.Layers() {
+			input, x, err = b.addLayer(sub, input, x)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+		return input, x, nil
+
+	case *nn.Linear:
+		ws := l.Ws.MustT(false) // [outDim, inDim] as stored in var store
+		defer ws.MustDrop()
+		var output string
+		if x.Dim() == 2 {
+			inputs := []string{input, b.initializer(ws, "Gemm", "weight")}
+			if l.Bs != nil {
+				inputs = append(inputs, b.initializer(l.Bs, "Gemm", "bias"))
+			}
+			output = b.node("Gemm", inputs, intAttr("transB", 1))
+		} else {
+			wT := b.node("Transpose", []string{b.initializer(ws, "MatMul", "weight")}, intsAttr("perm", []int64{1, 0}))
+			output = b.node("MatMul", []string{input, wT})
+			if l.Bs != nil {
+				output = b.node("Add", []string{output, b.initializer(l.Bs, "Add", "bias")})
+			}
+		}
+		return b.forward(output, l, x)
+
+	case *nn.Conv2D:
+		kernel := l.Ws.MustSize()[2:]
+		padding := pair(l.Config.Padding, 0)
+		inputs := []string{input, b.initializer(l.Ws, "Conv", "weight")}
+		if l.Bs != nil {
+			inputs = append(inputs, b.initializer(l.Bs, "Conv", "bias"))
+		}
+		groups := l.Config.Groups
+		if groups == 0 {
+			groups = 1
+		}
+		output := b.node("Conv", inputs,
+			intsAttr("kernel_shape", kernel),
+			intsAttr("strides", pair(l.Config.Stride, 1)),
+			intsAttr("pads", []int64{padding[0], padding[1], padding[0], padding[1]}),
+			intsAttr("dilations", pair(l.Config.Dilation, 1)),
+			intAttr("group", groups),
+		)
+		return b.forward(output, l, x)
+
+	case *nn.BatchNorm:
+		config := l.Config()
+		inputs := []string{
+			input,
+			b.initializer(l.Ws, "BatchNormalization", "weight"),
+			b.initializer(l.Bs, "BatchNormalization", "bias"),
+			b.initializer(l.RunningMean, "BatchNormalization", "running_mean"),
+			b.initializer(l.RunningVar, "BatchNormalization", "running_var"),
+		}
+		// NOTE. ONNX momentum is weight of running statistics, i.e. (1 - Pytorch momentum).
+		output := b.node("BatchNormalization", inputs,
+			floatAttr("epsilon", float32(config.Eps)),
+			floatAttr("momentum", float32(1-config.Momentum)),
+		)
+		return b.forward(output, l, x)
+
+	case *nn.LayerNorm:
+		var scale string
+		if l.Ws != nil {
+			scale = b.initializer(l.Ws, "LayerNormalization", "weight")
+		} else {
+			// Scale input is required by ONNX.
+			ones := ts.MustOnes(l.NormalizedShape, x.DType(), gotch.CPU)
+			scale = b.initializer(ones, "LayerNormalization", "scale")
+			ones.MustDrop()
+		}
+		inputs := []string{input, scale}
+		if l.Bs != nil {
+			inputs = append(inputs, b.initializer(l.Bs, "LayerNormalization", "bias"))
+		}
+		output := b.node("LayerNormalization", inputs,
+			intAttr("axis", -int64(len(l.NormalizedShape))),
+			floatAttr("epsilon", float32(l.Config.Eps)),
+		)
+		return b.forward(output, l, x)
+
+	case *nn.Embedding:
+		output := b.node("Gather", []string{b.initializer(l.Ws, "Gather", "weight"), input}, intAttr("axis", 0))
+		return b.forward(output, l, x)
+
+	case *nn.MaxPool2D:
+		stride := l.Stride
+		if len(stride) == 0 {
+			stride = l.Kernel
+		}
+		padding := pair(l.Padding, 0)
+		ceilMode := int64(0)
+		if l.CeilMode {
+			ceilMode = 1
+		}
+		output := b.node("MaxPool", []string{input},
+			intsAttr("kernel_shape", pair(l.Kernel, 1)),
+			intsAttr("strides", pair(stride, 1)),
+			intsAttr("pads", []int64{padding[0], padding[1], padding[0], padding[1]}),
+			intsAttr("dilations", pair(l.Dilation, 1)),
+			intAttr("ceil_mode", ceilMode),
+		)
+		return b.forward(output, l, x)
+
+	case *nn.Dropout:
+		name := b.nodeName("Dropout")
+		ratio := &TensorProto{
+			Name:     name + ".ratio",
+			DataType: DataTypeFloat,
+			RawData:  binary.LittleEndian.AppendUint32(nil, math.Float32bits(float32(l.Prob()))),
+		}
+		b.graph.Initializer = append(b.graph.Initializer, ratio)
+		output := name + "_output_0"
+		b.graph.Node = append(b.graph.Node, &NodeProto{
+			Name:   name,
+			OpType: "Dropout",
+			Input:  []string{input, ratio.Name},
+			Output: []string{output},
+		})
+		return b.forward(output, l, x)
+
+	case *nn.Identity:
+		output := b.node("Identity", []string{input})
+		return b.forward(output, l, x)
+
+	case nn.Func:
+		a, ok := lookupActivation(l.Name())
+		if !ok {
+			err := fmt.Errorf("unsupported nn.Func %q. Use `nn.NewNamedFunc()` with a registered activation name", l.Name())
+			return "", nil, err
+		}
+		output := b.node(a.OpType, []string{input}, a.Attributes...)
+		return b.forward(output, l, x)
+
+	default:
+		err := fmt.Errorf("unsupported layer type %T", layer)
+		return "", nil, err
+	}
+}
+
+// forward runs layer in inference mode to track output shape.
+func (b *graphBuilder) forward(output string, layer interface{}, x *ts.Tensor) (string, *ts.Tensor, error) {
+	var y *ts.Tensor
+	switch l := layer.(type) {
+	case ts.ModuleT:
+		y = l.ForwardT(x, false)
+	case ts.Module:
+		y = l.Forward(x)
+	}
+	x.MustDrop()
+
+	return output, y, nil
+}
+
+// nodeName returns a unique node name for op type.
+func (b *graphBuilder) nodeName(opType string) string {
+	name := fmt.Sprintf("%s_%d", opType, b.opCount[opType])
+	b.opCount[opType]++
+
+	return name
+}
+
+// node adds a node with single output and returns its output name.
+func (b *graphBuilder) node(opType string, inputs []string, attrs ...*AttributeProto) string {
+	name := b.nodeName(opType)
+	output := name + "_output_0"
+	b.graph.Node = append(b.graph.Node, &NodeProto{
+		Name:      name,
+		OpType:    opType,
+		Input:     inputs,
+		Output:    []string{output},
+		Attribute: attrs,
+	})
+
+	return output
+}
+
+// initializer adds tensor x as graph initializer and returns its name. Name
+// is var store name of x if found, otherwise it is generated from node name.
+func (b *graphBuilder) initializer(x *ts.Tensor, opType, suffix string) string {
+	name, ok := b.varName[uintptr(x.MustDataPtr())]
+	if !ok {
+		name = fmt.Sprintf("%s_%d.%s", opType, b.opCount[opType], suffix)
+	}
+	for _, t := range b.graph.Initializer {
+		if t.Name == name { // shared weights
+			return name
+		}
+	}
+
+	dataType, err := dataTypeOf(x.DType())
+	if err != nil {
+		panic(err)
+	}
+	b.graph.Initializer = append(b.graph.Initializer, &TensorProto{
+		Name:     name,
+		Dims:     x.MustSize(),
+		DataType: dataType,
+		RawData:  x.MustBytes(),
+	})
+
+	return name
+}
+
+// rename renames a node output value.
+func (b *graphBuilder) rename(from, to string) {
+	for _, n := range b.graph.Node {
+		for i := range n.Input {
+			if n.Input[i] == from {
+				n.Input[i] = to
+			}
+		}
+		for i := range n.Output {
+			if n.Output[i] == from {
+				n.Output[i] = to
+			}
+		}
+	}
+}
+
+// pair expands an 1 or 2 element option to 2 elements.
+func pair(v []int64, defaultVal int64) []int64 {
+	switch len(v) {
+	case 0:
+		return []int64{defaultVal, defaultVal}
+	case 1:
+		return []int64{v[0], v[0]}
+	default:
+		return v
+	}
+}
+
+func intAttr(name string, v int64) *AttributeProto {
+	return &AttributeProto{Name: name, Type: AttributeInt, I: v}
+}
+
+func intsAttr(name string, v []int64) *AttributeProto {
+	return &AttributeProto{Name: name, Type: AttributeInts, Ints: v}
+}
+
+func floatAttr(name string, v float32) *AttributeProto {
+	return &AttributeProto{Name: name, Type: AttributeFloat, F: v}
+}
+
+var onnxDataTypes = map[gotch.DType]int32{
+	gotch.Bool:     DataTypeBool,
+	gotch.Uint8:    DataTypeUint8,
+	gotch.Int8:     DataTypeInt8,
+	gotch.Int16:    DataTypeInt16,
+	gotch.Int:      DataTypeInt32,
+	gotch.Int64:    DataTypeInt64,
+	gotch.Half:     DataTypeFloat16,
+	gotch.BFloat16: DataTypeBFloat16,
+	gotch.Float:    DataTypeFloat,
+	gotch.Double:   DataTypeDouble,
+}
+
+func dataTypeOf(dtype gotch.DType) (int32, error) {
+	dt, ok := onnxDataTypes[dtype]
+	if !ok {
+		err := fmt.Errorf("unsupported ONNX dtype: %v", dtype)
+		return DataTypeUndefined, err
+	}
+
+	return dt, nil
+}
+
+func dtypeOf(dataType int32) (gotch.DType, error) {
+	for dtype, dt := range onnxDataTypes {
+		if dt == dataType {
+			return dtype, nil
+		}
+	}
+
+	err := fmt.Errorf("unsupported ONNX data type: %d", dataType)
+	return gotch.Invalid, err
+}
@@ -0,0 +1,170 @@
+package onnx_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/onnx"
+	"github.com/sugarme/gotch/ts"
+)
+
+func convNet(vs *nn.VarStore) *nn.SequentialT {
+	root := vs.Root()
+	seq := nn.SeqT()
+	seq.Add(nn.NewConv2D(root.Sub("conv"), 1, 4, 3, nn.DefaultConv2DConfig()))
+	seq.Add(nn.BatchNorm2D(root.Sub("bn"), 4, nn.DefaultBatchNormConfig()))
+	seq.AddFn(onnx.MustFunc("relu"))
+	seq.Add(nn.NewMaxPool2D([]int64{2, 2}))
+	seq.AddFn(onnx.MustFunc("flatten"))
+	seq.Add(nn.NewDropout(0.25))
+	seq.Add(nn.NewLinear(root.Sub("fc"), 4*3*3, 10, nn.DefaultLinearConfig()))
+
+	return seq
+}
+
+func TestExportT_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.onnx")
+
+	vs := nn.NewVarStore(gotch.CPU)
+	model := convNet(vs)
+	input := ts.MustRandn([]int64{2, 1, 8, 8}, gotch.Float, gotch.CPU)
+
+	if err := onnx.ExportT(vs, model, input, path); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := onnx.ReadModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opTypes []string
+	for _, n := range m.Graph.Node {
+		opTypes = append(opTypes, n.OpType)
+	}
+	wantOpTypes := []string{"Conv", "BatchNormalization", "Relu", "MaxPool", "Flatten", "Dropout", "Gemm"}
+	if !reflect.DeepEqual(wantOpTypes, opTypes) {
+		t.Errorf("want op types: %v\n", wantOpTypes)
+		t.Errorf("got op types: %v\n", opTypes)
+	}
+
+	if got := m.Graph.Output[0].Shape; !reflect.DeepEqual(got, []int64{2, 10}) {
+		t.Errorf("want output shape [2 10], got %v\n", got)
+	}
+
+	vs1 := nn.NewVarStore(gotch.CPU)
+	model1 := convNet(vs1)
+	if err := onnx.Load(vs1, path); err != nil {
+		t.Fatal(err)
+	}
+
+	want := model.ForwardT(input, false)
+	got := model1.ForwardT(input, false)
+	if !got.MustAllclose(want, 1e-5, 1e-8, false, false) {
+		t.Errorf("want output: %v\n", want)
+		t.Errorf("got output: %v\n", got)
+	}
+}
+
+func TestExport_LayerNormEmbedding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.onnx")
+
+	newModel := func(vs *nn.VarStore) *nn.Sequential {
+		seq := nn.Seq()
+		seq.Add(nn.NewEmbedding(vs.Root().Sub("emb"), 20, 8, nn.DefaultEmbeddingConfig()))
+		seq.Add(nn.NewLayerNorm(vs.Root().Sub("ln"), []int64{8}, nn.DefaultLayerNormConfig()))
+		seq.Add(nn.NewLinear(vs.Root().Sub("proj"), 8, 4, nn.DefaultLinearConfig()))
+		return seq
+	}
+
+	vs := nn.NewVarStore(gotch.CPU)
+	model := newModel(vs)
+	input := ts.MustRandint(20, []int64{2, 5}, gotch.Int64, gotch.CPU)
+
+	if err := onnx.Export(vs, model, input, path); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := onnx.ReadModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opTypes []string
+	for _, n := range m.Graph.Node {
+		opTypes = append(opTypes, n.OpType)
+	}
+	wantOpTypes := []string{"Gather", "LayerNormalization", "Transpose", "MatMul", "Add"}
+	if !reflect.DeepEqual(wantOpTypes, opTypes) {
+		t.Errorf("want op types: %v\n", wantOpTypes)
+		t.Errorf("got op types: %v\n", opTypes)
+	}
+
+	vs1 := nn.NewVarStore(gotch.CPU)
+	model1 := newModel(vs1)
+	if err := onnx.Load(vs1, path); err != nil {
+		t.Fatal(err)
+	}
+
+	want := model.Forward(input)
+	got := model1.Forward(input)
+	if !got.MustAllclose(want, 1e-5, 1e-8, false, false) {
+		t.Errorf("want output: %v\n", want)
+		t.Errorf("got output: %v\n", got)
+	}
+}
+
+func TestExport_UnnamedFunc(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	seq := nn.Seq()
+	seq.Add(nn.NewLinear(vs.Root(), 2, 2, nn.DefaultLinearConfig()))
+	seq.AddFn(nn.NewFunc(func(xs *ts.Tensor) *ts.Tensor { return xs.MustRelu(false) }))
+
+	input := ts.MustOnes([]int64{1, 2}, gotch.Float, gotch.CPU)
+	err := onnx.Export(vs, seq, input, filepath.Join(t.TempDir(), "model.onnx"))
+	if err == nil {
+		t.Errorf("want error when exporting unnamed nn.Func, got nil")
+	}
+}
+
+func TestModelProto_MarshalUnmarshal(t *testing.T) {
+	want := &onnx.ModelProto{
+		IRVersion:    onnx.IRVersion,
+		OpsetImport:  []*onnx.OperatorSetIdProto{{Version: onnx.OpsetVersion}},
+		ProducerName: onnx.ProducerName,
+		Graph: &onnx.GraphProto{
+			Name: "g",
+			Node: []*onnx.NodeProto{{
+				Name:   "Conv_0",
+				OpType: "Conv",
+				Input:  []string{"x", "w"},
+				Output: []string{"y"},
+				Attribute: []*onnx.AttributeProto{
+					{Name: "pads", Type: onnx.AttributeInts, Ints: []int64{1, 1, 1, 1}},
+					{Name: "axis", Type: onnx.AttributeInt, I: -1},
+					{Name: "epsilon", Type: onnx.AttributeFloat, F: 1e-5},
+				},
+			}},
+			Initializer: []*onnx.TensorProto{{
+				Name:     "w",
+				Dims:     []int64{1, 2},
+				DataType: onnx.DataTypeFloat,
+				RawData:  []byte{0, 0, 128, 63, 0, 0, 0, 64},
+			}},
+			Input:  []*onnx.ValueInfoProto{{Name: "x", ElemType: onnx.DataTypeFloat, Shape: []int64{-1, 2}}},
+			Output: []*onnx.ValueInfoProto{{Name: "y", ElemType: onnx.DataTypeFloat, Shape: []int64{-1, 1}}},
+		},
+	}
+
+	got := new(onnx.ModelProto)
+	if err := got.Unmarshal(want.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %+v\n", want.Graph)
+		t.Errorf("got: %+v\n", got.Graph)
+	}
+}
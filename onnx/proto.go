@@ -0,0 +1,657 @@
+package onnx
+
+// This file implements a minimal subset of ONNX protobuf messages and the
+// protobuf wire format needed to write and read model files without any
+// external protobuf dependency.
+//
+// Ref.
+// - https://github.com/onnx/onnx/blob/main/onnx/onnx.proto
+// - https://protobuf.dev/programming-guides/encoding/
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  int = 0
+	wireFixed64 int = 1
+	wireBytes   int = 2
+	wireFixed32 int = 5
+)
+
+// TensorProto data types.
+const (
+	DataTypeUndefined int32 = 0
+	DataTypeFloat     int32 = 1
+	DataTypeUint8     int32 = 2
+	DataTypeInt8      int32 = 3
+	DataTypeUint16    int32 = 4
+	DataTypeInt16     int32 = 5
+	DataTypeInt32     int32 = 6
+	DataTypeInt64     int32 = 7
+	DataTypeString    int32 = 8
+	DataTypeBool      int32 = 9
+	DataTypeFloat16   int32 = 10
+	DataTypeDouble    int32 = 11
+	DataTypeUint32    int32 = 12
+	DataTypeUint64    int32 = 13
+	DataTypeBFloat16  int32 = 16
+)
+
+// AttributeProto types.
+const (
+	AttributeUndefined int32 = 0
+	AttributeFloat     int32 = 1
+	AttributeInt       int32 = 2
+	AttributeString    int32 = 3
+	AttributeTensor    int32 = 4
+	AttributeFloats    int32 = 6
+	AttributeInts      int32 = 7
+	AttributeStrings   int32 = 8
+)
+
+// ModelProto is the top-level ONNX message.
+type ModelProto struct {
+	IRVersion       int64
+	OpsetImport     []*OperatorSetIdProto
+	ProducerName    string
+	ProducerVersion string
+	Domain          string
+	ModelVersion    int64
+	DocString       string
+	Graph           *GraphProto
+}
+
+// OperatorSetIdProto identifies an operator set.
+type OperatorSetIdProto struct {
+	Domain  string
+	Version int64
+}
+
+// GraphProto defines a computation graph.
+type GraphProto struct {
+	Node        []*NodeProto
+	Name        string
+	Initializer []*TensorProto
+	DocString   string
+	Input       []*ValueInfoProto
+	Output      []*ValueInfoProto
+	ValueInfo   []*ValueInfoProto
+}
+
+// NodeProto is a computation node of the graph.
+type NodeProto struct {
+	Input     []string
+	Output    []string
+	Name      string
+	OpType    string
+	Domain    string
+	Attribute []*AttributeProto
+	DocString string
+}
+
+// AttributeProto is a named attribute of a node.
+type AttributeProto struct {
+	Name    string
+	Type    int32
+	F       float32
+	I       int64
+	S       []byte
+	T       *TensorProto
+	Floats  []float32
+	Ints    []int64
+	Strings [][]byte
+}
+
+// TensorProto is a serialized tensor.
+//
+// NOTE. Data are written to `RawData` (little-endian). When reading, typed
+// data fields (`FloatData`, `Int32Data`, ...) written by other tools are
+// supported as well.
+type TensorProto struct {
+	Dims       []int64
+	DataType   int32
+	Name       string
+	RawData    []byte
+	FloatData  []float32
+	Int32Data  []int32
+	Int64Data  []int64
+	DoubleData []float64
+	Uint64Data []uint64
+}
+
+// ValueInfoProto describes a graph input/output.
+type ValueInfoProto struct {
+	Name     string
+	ElemType int32
+	// Shape of the value. Unknown (symbolic) dimensions are -1.
+	Shape []int64
+}
+
+// Encoder:
+// ========
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) varint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) tag(field int, wireType int) {
+	e.varint(uint64(field<<3 | wireType))
+}
+
+func (e *encoder) int64Field(field int, v int64) {
+	e.tag(field, wireVarint)
+	e.varint(uint64(v))
+}
+
+// optInt64Field writes a field only if it is non-zero.
+func (e *encoder) optInt64Field(field int, v int64) {
+	if v != 0 {
+		e.int64Field(field, v)
+	}
+}
+
+func (e *encoder) float32Field(field int, v float32) {
+	e.tag(field, wireFixed32)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, math.Float32bits(v))
+}
+
+func (e *encoder) bytesField(field int, v []byte) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// stringField writes a string field if it is not empty.
+func (e *encoder) stringField(field int, v string) {
+	if v != "" {
+		e.bytesField(field, []byte(v))
+	}
+}
+
+func (e *encoder) messageField(field int, m interface{ marshal(*encoder) }) {
+	var sub encoder
+	m.marshal(&sub)
+	e.bytesField(field, sub.buf)
+}
+
+// Marshal encodes model to protobuf binary format.
+func (m *ModelProto) Marshal() []byte {
+	var e encoder
+	m.marshal(&e)
+	return e.buf
+}
+
+func (m *ModelProto) marshal(e *encoder) {
+	e.optInt64Field(1, m.IRVersion)
+	e.stringField(2, m.ProducerName)
+	e.stringField(3, m.ProducerVersion)
+	e.stringField(4, m.Domain)
+	e.optInt64Field(5, m.ModelVersion)
+	e.stringField(6, m.DocString)
+	if m.Graph != nil {
+		e.messageField(7, m.Graph)
+	}
+	for _, o := range m.OpsetImport {
+		e.messageField(8, o)
+	}
+}
+
+func (o *OperatorSetIdProto) marshal(e *encoder) {
+	e.stringField(1, o.Domain)
+	e.int64Field(2, o.Version)
+}
+
+func (g *GraphProto) marshal(e *encoder) {
+	for _, n := range g.Node {
+		e.messageField(1, n)
+	}
+	e.stringField(2, g.Name)
+	for _, t := range g.Initializer {
+		e.messageField(5, t)
+	}
+	e.stringField(10, g.DocString)
+	for _, v := range g.Input {
+		e.messageField(11, v)
+	}
+	for _, v := range g.Output {
+		e.messageField(12, v)
+	}
+	for _, v := range g.ValueInfo {
+		e.messageField(13, v)
+	}
+}
+
+func (n *NodeProto) marshal(e *encoder) {
+	for _, s := range n.Input {
+		e.bytesField(1, []byte(s))
+	}
+	for _, s := range n.Output {
+		e.bytesField(2, []byte(s))
+	}
+	e.stringField(3, n.Name)
+	e.stringField(4, n.OpType)
+	for _, a := range n.Attribute {
+		e.messageField(5, a)
+	}
+	e.stringField(6, n.DocString)
+	e.stringField(7, n.Domain)
+}
+
+func (a *AttributeProto) marshal(e *encoder) {
+	e.stringField(1, a.Name)
+	switch a.Type {
+	case AttributeFloat:
+		e.float32Field(2, a.F)
+	case AttributeInt:
+		e.int64Field(3, a.I)
+	case AttributeString:
+		e.bytesField(4, a.S)
+	case AttributeTensor:
+		e.messageField(5, a.T)
+	case AttributeFloats:
+		for _, v := range a.Floats {
+			e.float32Field(7, v)
+		}
+	case AttributeInts:
+		for _, v := range a.Ints {
+			e.int64Field(8, v)
+		}
+	case AttributeStrings:
+		for _, v := range a.Strings {
+			e.bytesField(9, v)
+		}
+	}
+	e.int64Field(20, int64(a.Type))
+}
+
+func (t *TensorProto) marshal(e *encoder) {
+	for _, d := range t.Dims {
+		e.int64Field(1, d)
+	}
+	e.int64Field(2, int64(t.DataType))
+	e.stringField(8, t.Name)
+	if t.RawData != nil {
+		e.bytesField(9, t.RawData)
+	}
+}
+
+func (v *ValueInfoProto) marshal(e *encoder) {
+	e.stringField(1, v.Name)
+
+	// TypeProto{tensor_type: TypeProto.Tensor{elem_type, shape}}
+	var shape encoder
+	for _, d := range v.Shape {
+		var dim encoder
+		if d >= 0 {
+			dim.int64Field(1, d)
+		} else {
+			dim.stringField(2, "?")
+		}
+		shape.bytesField(1, dim.buf)
+	}
+	var tensorType encoder
+	tensorType.int64Field(1, int64(v.ElemType))
+	if v.Shape != nil {
+		tensorType.bytesField(2, shape.buf)
+	}
+	var typeProto encoder
+	typeProto.bytesField(1, tensorType.buf)
+
+	e.bytesField(2, typeProto.buf)
+}
+
+// Decoder:
+// ========
+
+// field is a decoded protobuf field. For varint and fixed fields, value is
+// stored in `v`. For length-delimited fields, data is stored in `data`.
+type field struct {
+	num      int
+	wireType int
+	v        uint64
+	data     []byte
+}
+
+// walkFields calls fn for every field of a protobuf message in order.
+func walkFields(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			err := fmt.Errorf("invalid field key")
+			return err
+		}
+		b = b[n:]
+
+		f := field{num: int(key >> 3), wireType: int(key & 7)}
+		switch f.wireType {
+		case wireVarint:
+			f.v, n = binary.Uvarint(b)
+			if n <= 0 {
+				err := fmt.Errorf("invalid varint at field %d", f.num)
+				return err
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				err := fmt.Errorf("truncated fixed64 at field %d", f.num)
+				return err
+			}
+			f.v = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				err := fmt.Errorf("truncated fixed32 at field %d", f.num)
+				return err
+			}
+			f.v = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				err := fmt.Errorf("invalid length at field %d", f.num)
+				return err
+			}
+			f.data = b[n : n+int(l)]
+			b = b[n+int(l):]
+		default:
+			err := fmt.Errorf("unsupported wire type %d at field %d", f.wireType, f.num)
+			return err
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// varints returns values of a repeated varint field which may be packed or not.
+func (f field) varints() ([]uint64, error) {
+	if f.wireType == wireVarint {
+		return []uint64{f.v}, nil
+	}
+
+	var vals []uint64
+	b := f.data
+	for len(b) > 0 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			err := fmt.Errorf("invalid packed varint at field %d", f.num)
+			return nil, err
+		}
+		vals = append(vals, v)
+		b = b[n:]
+	}
+
+	return vals, nil
+}
+
+// fixed returns values of a repeated fixed32/fixed64 field which may be packed or not.
+func (f field) fixed(size int) ([]uint64, error) {
+	if f.wireType != wireBytes {
+		return []uint64{f.v}, nil
+	}
+
+	if len(f.data)%size != 0 {
+		err := fmt.Errorf("invalid packed fixed%d at field %d", size*8, f.num)
+		return nil, err
+	}
+	vals := make([]uint64, 0, len(f.data)/size)
+	for i := 0; i < len(f.data); i += size {
+		if size == 4 {
+			vals = append(vals, uint64(binary.LittleEndian.Uint32(f.data[i:])))
+		} else {
+			vals = append(vals, binary.LittleEndian.Uint64(f.data[i:]))
+		}
+	}
+
+	return vals, nil
+}
+
+// Unmarshal decodes model from protobuf binary format.
+func (m *ModelProto) Unmarshal(b []byte) error {
+	return walkFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			m.IRVersion = int64(f.v)
+		case 2:
+			m.ProducerName = string(f.data)
+		case 3:
+			m.ProducerVersion = string(f.data)
+		case 4:
+			m.Domain = string(f.data)
+		case 5:
+			m.ModelVersion = int64(f.v)
+		case 6:
+			m.DocString = string(f.data)
+		case 7:
+			m.Graph = new(GraphProto)
+			return m.Graph.unmarshal(f.data)
+		case 8:
+			o := new(OperatorSetIdProto)
+			m.OpsetImport = append(m.OpsetImport, o)
+			return o.unmarshal(f.data)
+		}
+		return nil
+	})
+}
+
+func (o *OperatorSetIdProto) unmarshal(b []byte) error {
+	return walkFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			o.Domain = string(f.data)
+		case 2:
+			o.Version = int64(f.v)
+		}
+		return nil
+	})
+}
+
+func (g *GraphProto) unmarshal(b []byte) error {
+	return walkFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			n := new(NodeProto)
+			g.Node = append(g.Node, n)
+			return n.unmarshal(f.data)
+		case 2:
+			g.Name = string(f.data)
+		case 5:
+			t := new(TensorProto)
+			g.Initializer = append(g.Initializer, t)
+			return t.unmarshal(f.data)
+		case 10:
+			g.DocString = string(f.data)
+		case 11, 12, 13:
+			v := new(ValueInfoProto)
+			switch f.num {
+			case 11:
+				g.Input = append(g.Input, v)
+			case 12:
+				g.Output = append(g.Output, v)
+			case 13:
+				g.ValueInfo = append(g.ValueInfo, v)
+			}
+			return v.unmarshal(f.data)
+		}
+		return nil
+	})
+}
+
+func (n *NodeProto) unmarshal(b []byte) error {
+	return walkFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			n.Input = append(n.Input, string(f.data))
+		case 2:
+			n.Output = append(n.Output, string(f.data))
+		case 3:
+			n.Name = string(f.data)
+		case 4:
+			n.OpType = string(f.data)
+		case 5:
+			a := new(AttributeProto)
+			n.Attribute = append(n.Attribute, a)
+			return a.unmarshal(f.data)
+		case 6:
+			n.DocString = string(f.data)
+		case 7:
+			n.Domain = string(f.data)
+		}
+		return nil
+	})
+}
+
+func (a *AttributeProto) unmarshal(b []byte) error {
+	return walkFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			a.Name = string(f.data)
+		case 2:
+			a.F = math.Float32frombits(uint32(f.v))
+		case 3:
+			a.I = int64(f.v)
+		case 4:
+			a.S = f.data
+		case 5:
+			a.T = new(TensorProto)
+			return a.T.unmarshal(f.data)
+		case 7:
+			vals, err := f.fixed(4)
+			if err != nil {
+				return err
+			}
+			for _, v := range vals {
+				a.Floats = append(a.Floats, math.Float32frombits(uint32(v)))
+			}
+		case 8:
+			vals, err := f.varints()
+			if err != nil {
+				return err
+			}
+			for _, v := range vals {
+				a.Ints = append(a.Ints, int64(v))
+			}
+		case 9:
+			a.Strings = append(a.Strings, f.data)
+		case 20:
+			a.Type = int32(f.v)
+		}
+		return nil
+	})
+}
+
+func (t *TensorProto) unmarshal(b []byte) error {
+	return walkFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			vals, err := f.varints()
+			if err != nil {
+				return err
+			}
+			for _, v := range vals {
+				t.Dims = append(t.Dims, int64(v))
+			}
+		case 2:
+			t.DataType = int32(f.v)
+		case 4:
+			vals, err := f.fixed(4)
+			if err != nil {
+				return err
+			}
+			for _, v := range vals {
+				t.FloatData = append(t.FloatData, math.Float32frombits(uint32(v)))
+			}
+		case 5:
+			vals, err := f.varints()
+			if err != nil {
+				return err
+			}
+			for _, v := range vals {
+				t.Int32Data = append(t.Int32Data, int32(v))
+			}
+		case 7:
+			vals, err := f.varints()
+			if err != nil {
+				return err
+			}
+			for _, v := range vals {
+				t.Int64Data = append(t.Int64Data, int64(v))
+			}
+		case 8:
+			t.Name = string(f.data)
+		case 9:
+			t.RawData = f.data
+		case 10:
+			vals, err := f.fixed(8)
+			if err != nil {
+				return err
+			}
+			for _, v := range vals {
+				t.DoubleData = append(t.DoubleData, math.Float64frombits(v))
+			}
+		case 11:
+			vals, err := f.varints()
+			if err != nil {
+				return err
+			}
+			t.Uint64Data = append(t.Uint64Data, vals...)
+		}
+		return nil
+	})
+}
+
+func (v *ValueInfoProto) unmarshal(b []byte) error {
+	return walkFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			v.Name = string(f.data)
+		case 2:
+			// TypeProto
+			return walkFields(f.data, func(f field) error {
+				if f.num != 1 { // tensor_type only
+					return nil
+				}
+				return v.unmarshalTensorType(f.data)
+			})
+		}
+		return nil
+	})
+}
+
+func (v *ValueInfoProto) unmarshalTensorType(b []byte) error {
+	return walkFields(b, func(f field) error {
+		switch f.num {
+		case 1:
+			v.ElemType = int32(f.v)
+		case 2:
+			v.Shape = []int64{}
+			// TensorShapeProto
+			return walkFields(f.data, func(f field) error {
+				if f.num != 1 {
+					return nil
+				}
+				var d int64 = -1
+				err := walkFields(f.data, func(f field) error {
+					if f.num == 1 {
+						d = int64(f.v)
+					}
+					return nil
+				})
+				v.Shape = append(v.Shape, d)
+				return err
+			})
+		}
+		return nil
+	})
+}
@@ -0,0 +1,218 @@
+package onnx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+// ReadModel reads an ONNX model from file.
+func ReadModel(path string) (*ModelProto, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	model := new(ModelProto)
+	if err := model.Unmarshal(data); err != nil {
+		err = fmt.Errorf("onnx.ReadModel() failed: invalid model file %q: %w", path, err)
+		return nil, err
+	}
+	if model.Graph == nil {
+		err = fmt.Errorf("onnx.ReadModel() failed: model file %q has no graph", path)
+		return nil, err
+	}
+
+	return model, nil
+}
+
+// Weights holds initializers of an ONNX graph. It implements `nn.TensorLoader`
+// so that weights can be loaded to a var store with `vs.LoadFrom()`.
+//
+// NOTE. Weights of `Gemm` (transB=0) and `MatMul` nodes are stored as
+// [inDim, outDim] by some exporters. They are transposed to [outDim, inDim]
+// which is the layout of `nn.Linear` weight.
+type Weights struct {
+	names      []string
+	tensors    map[string]*TensorProto
+	transposed map[string]bool
+}
+
+var _ nn.TensorLoader = &Weights{}
+
+// NewWeights creates Weights from graph initializers of a model.
+func NewWeights(model *ModelProto) *Weights {
+	w := &Weights{
+		tensors:    make(map[string]*TensorProto),
+		transposed: make(map[string]bool),
+	}
+	if model.Graph == nil {
+		return w
+	}
+
+	for _, t := range model.Graph.Initializer {
+		if _, ok := w.tensors[t.Name]; !ok {
+			w.names = append(w.names, t.Name)
+		}
+		w.tensors[t.Name] = t
+	}
+
+	for _, n := range model.Graph.Node {
+		if len(n.Input) < 2 {
+			continue
+		}
+		switch n.OpType {
+		case "Gemm":
+			var transB int64 = 0
+			for _, a := range n.Attribute {
+				if a.Name == "transB" {
+					transB = a.I
+				}
+			}
+			if transB == 0 {
+				w.markTransposed(n.Input[1])
+			}
+		case "MatMul":
+			w.markTransposed(n.Input[1])
+		}
+	}
+
+	return w
+}
+
+func (w *Weights) markTransposed(name string) {
+	if t, ok := w.tensors[name]; ok && len(t.Dims) == 2 {
+		w.transposed[name] = true
+	}
+}
+
+// Names returns names of initializers in graph order.
+func (w *Weights) Names() []string {
+	return w.names
+}
+
+// Shape returns shape of initializer with given name and whether it exists.
+func (w *Weights) Shape(name string) ([]int64, bool) {
+	t, ok := w.tensors[name]
+	if !ok {
+		return nil, false
+	}
+
+	if w.transposed[name] {
+		return []int64{t.Dims[1], t.Dims[0]}, true
+	}
+
+	return t.Dims, true
+}
+
+// Tensor creates tensor of initializer with given name on a device.
+func (w *Weights) Tensor(name string, device gotch.Device) (*ts.Tensor, error) {
+	t, ok := w.tensors[name]
+	if !ok {
+		err := fmt.Errorf("Weights.Tensor() failed: initializer %q not found", name)
+		return nil, err
+	}
+
+	x, err := NewTensor(t)
+	if err != nil {
+		err = fmt.Errorf("Weights.Tensor() failed at %q: %w", name, err)
+		return nil, err
+	}
+
+	if w.transposed[name] {
+		xT := x.MustT(true)
+		x = xT.MustContiguous(true)
+	}
+
+	return x.To(device, true)
+}
+
+// NewTensor creates a CPU tensor from a TensorProto.
+func NewTensor(t *TensorProto) (*ts.Tensor, error) {
+	dtype, err := dtypeOf(t.DataType)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := rawData(t, dtype)
+	if err != nil {
+		return nil, err
+	}
+
+	shape := t.Dims
+	if shape == nil {
+		shape = []int64{}
+	}
+
+	return ts.OfDataSize(data, shape, dtype, ts.WithName(t.Name))
+}
+
+// rawData returns little-endian data of a TensorProto. Typed data fields are
+// converted if raw data is not available.
+func rawData(t *TensorProto, dtype gotch.DType) ([]byte, error) {
+	numel := int64(1)
+	for _, d := range t.Dims {
+		numel *= d
+	}
+	nbytes := numel * int64(dtype.Size())
+
+	var data []byte
+	switch {
+	case t.RawData != nil:
+		data = t.RawData
+	case t.DataType == DataTypeFloat:
+		for _, v := range t.FloatData {
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+		}
+	case t.DataType == DataTypeDouble:
+		for _, v := range t.DoubleData {
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+		}
+	case t.DataType == DataTypeInt64:
+		for _, v := range t.Int64Data {
+			data = binary.LittleEndian.AppendUint64(data, uint64(v))
+		}
+	default:
+		// int32, int16, int8, uint8, bool, float16 and bfloat16 are stored in int32_data.
+		size := dtype.Size()
+		for _, v := range t.Int32Data {
+			var buf [4]byte
+			binary.LittleEndian.PutUint32(buf[:], uint32(v))
+			data = append(data, buf[:size]...)
+		}
+	}
+
+	if int64(len(data)) != nbytes {
+		err := fmt.Errorf("invalid data size of tensor %q: expected %v bytes, got %v", t.Name, nbytes, len(data))
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Load loads weights of an ONNX model file to var store. All variables of var
+// store must exist in model file with the same shape.
+func Load(vs *nn.VarStore, path string) error {
+	model, err := ReadModel(path)
+	if err != nil {
+		return err
+	}
+
+	return vs.LoadFrom(NewWeights(model))
+}
+
+// LoadPartial loads weights of an ONNX model file to var store. It returns
+// names of variables missing in model file.
+func LoadPartial(vs *nn.VarStore, path string) ([]string, error) {
+	model, err := ReadModel(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return vs.LoadPartialFrom(NewWeights(model))
+}
@@ -0,0 +1,212 @@
+package pickle
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+// NameFilter decides whether a tensor with given name should be loaded.
+type NameFilter func(name string) bool
+
+// PrefixFilter selects tensors whose name starts with one of the given prefixes.
+func PrefixFilter(prefixes ...string) NameFilter {
+	return func(name string) bool {
+		for _, p := range prefixes {
+			if strings.HasPrefix(name, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RegexpFilter selects tensors whose name matches the given regular expression.
+func RegexpFilter(re *regexp.Regexp) NameFilter {
+	return func(name string) bool {
+		return re.MatchString(name)
+	}
+}
+
+// Checkpoint is a Pytorch checkpoint (state dict) opened for lazy loading.
+//
+// Tensor metadata (name, dtype, shape) are available right after opening,
+// but storage data are only read from the zip archive when `Tensor()` is called.
+// Legacy (non-zip) checkpoints are decoded eagerly.
+type Checkpoint struct {
+	zr      *zip.ReadCloser
+	names   []string
+	tensors map[string]*StorageTensor
+	decoded map[string]*ts.Tensor // legacy format
+}
+
+var _ nn.TensorLoader = &Checkpoint{}
+
+// OpenCheckpoint opens a checkpoint created by `torch.save()`. Only tensors
+// whose name passes all filters are visible.
+//
+// NOTE. `Close()` should be called when done.
+func OpenCheckpoint(filename string, filters ...NameFilter) (*Checkpoint, error) {
+	accept := func(name string) bool {
+		for _, f := range filters {
+			if !f(name) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if !isZipFile(filename) {
+		weights, err := Decode(filename)
+		if err != nil {
+			err = fmt.Errorf("OpenCheckpoint() failed: %w", err)
+			return nil, err
+		}
+		c := &Checkpoint{decoded: make(map[string]*ts.Tensor)}
+		for name, x := range weights {
+			if !accept(name) {
+				x.MustDrop()
+				continue
+			}
+			c.names = append(c.names, name)
+			c.decoded[name] = x
+		}
+		return c, nil
+	}
+
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	newUnpickler := func(r io.Reader) Unpickler {
+		return NewUnpickler(r)
+	}
+	result, err := loadZipRecords(&zr.Reader, newUnpickler, true)
+	if err != nil {
+		zr.Close()
+		err = fmt.Errorf("OpenCheckpoint() failed: %w", err)
+		return nil, err
+	}
+	storageTensors, err := storageTensorsOf(result)
+	if err != nil {
+		zr.Close()
+		err = fmt.Errorf("OpenCheckpoint() failed: %w", err)
+		return nil, err
+	}
+
+	c := &Checkpoint{
+		zr:      zr,
+		tensors: make(map[string]*StorageTensor),
+	}
+	for _, name := range storageTensors.names {
+		sx := storageTensors.tensors[name]
+		// Dealing with Pytorch `..._tracked` variables.
+		if storageLen(sx.Source) == 0 || !accept(name) {
+			continue
+		}
+		c.names = append(c.names, name)
+		c.tensors[name] = sx
+	}
+
+	return c, nil
+}
+
+// Names returns names of tensors in checkpoint (in file order for zip format).
+func (c *Checkpoint) Names() []string {
+	return c.names
+}
+
+// Shape returns shape of tensor with given name and whether it exists.
+func (c *Checkpoint) Shape(name string) ([]int64, bool) {
+	if c.decoded != nil {
+		x, ok := c.decoded[name]
+		if !ok {
+			return nil, false
+		}
+		return x.MustSize(), true
+	}
+
+	sx, ok := c.tensors[name]
+	if !ok {
+		return nil, false
+	}
+
+	return storageTensorShape(sx), true
+}
+
+// DType returns dtype of tensor with given name and whether it exists.
+func (c *Checkpoint) DType(name string) (gotch.DType, bool) {
+	if c.decoded != nil {
+		x, ok := c.decoded[name]
+		if !ok {
+			return gotch.Invalid, false
+		}
+		return x.DType(), true
+	}
+
+	sx, ok := c.tensors[name]
+	if !ok {
+		return gotch.Invalid, false
+	}
+
+	return sx.Source.DType(), true
+}
+
+// Tensor reads tensor with given name to a device.
+//
+// Storage data are read from archive and released after the tensor is created,
+// so that at most one storage is kept in Go memory at a time.
+func (c *Checkpoint) Tensor(name string, device gotch.Device) (*ts.Tensor, error) {
+	if c.decoded != nil {
+		x, ok := c.decoded[name]
+		if !ok {
+			err := fmt.Errorf("Checkpoint.Tensor() failed: tensor %q not found", name)
+			return nil, err
+		}
+		return x.To(device, false)
+	}
+
+	sx, ok := c.tensors[name]
+	if !ok {
+		err := fmt.Errorf("Checkpoint.Tensor() failed: tensor %q not found", name)
+		return nil, err
+	}
+
+	if ls, ok := sx.Source.(*LazyStorage); ok {
+		if err := ls.Load(); err != nil {
+			err = fmt.Errorf("Checkpoint.Tensor() failed at %q: %w", name, err)
+			return nil, err
+		}
+		defer ls.Release()
+	}
+
+	x, err := rebuildTensor(sx)
+	if err != nil {
+		err = fmt.Errorf("Checkpoint.Tensor() failed at %q: %w", name, err)
+		return nil, err
+	}
+
+	return x.To(device, true)
+}
+
+// Close closes underlying zip archive and drops eagerly decoded tensors.
+func (c *Checkpoint) Close() error {
+	for _, x := range c.decoded {
+		x.MustDrop()
+	}
+	c.decoded = nil
+
+	if c.zr == nil {
+		return nil
+	}
+	err := c.zr.Close()
+	c.zr = nil
+
+	return err
+}
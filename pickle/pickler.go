@@ -0,0 +1,425 @@
+package pickle
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// This file implements pickling part of Python Pickle Machinery.
+//
+// Only a subset of opcodes needed to serialize Go values that have Python
+// equivalents (and Pytorch state dicts) are implemented. See `Pickler.Dump()`
+// for supported types.
+// Ref. https://github.com/python/cpython/blob/main/Lib/pickle.py#L407
+
+// PytorchProtocol is the pickle protocol used by `torch.save()`.
+const PytorchProtocol byte = 2
+
+// batchSize is number of items written per MARK ... APPENDS/SETITEMS batch.
+const batchSize int = 1000
+
+// Reduce represents a Python object to be rebuilt at unpickling time by
+// calling `Callable(*Args)`, i.e. the REDUCE opcode.
+type Reduce struct {
+	Callable *GenericClass
+	Args     *Tuple
+}
+
+// NewReduce creates a Reduce of a global `module.name` callable.
+func NewReduce(module, name string, args ...interface{}) *Reduce {
+	return &Reduce{
+		Callable: NewGenericClass(module, name),
+		Args:     NewTupleFromSlice(args),
+	}
+}
+
+// Pickling Machinery:
+// ===================
+
+type Pickler struct {
+	proto  byte          // protocol version of the pickle
+	writer *bufio.Writer // binary file writer
+
+	// PersistentId returns a persistent id of an object if it should be
+	// stored outside of the pickle stream (e.g. tensor storages). The second
+	// returned value is false if the object should be pickled as usual.
+	PersistentId func(obj interface{}) (interface{}, bool)
+}
+
+// NewPickler creates a new Pickler using Pytorch protocol.
+func NewPickler(w io.Writer) Pickler {
+	return NewPicklerWithProtocol(w, PytorchProtocol)
+}
+
+// NewPicklerWithProtocol creates a new Pickler using a specified protocol (2 - 4).
+func NewPicklerWithProtocol(w io.Writer, proto byte) Pickler {
+	return Pickler{
+		proto:  proto,
+		writer: bufio.NewWriter(w),
+	}
+}
+
+// Dump writes a pickled representation of obj.
+//
+// Supported Go types:
+// - nil, bool, signed/unsigned integers, *big.Int, float32, float64, string, []byte (protocol >= 3)
+// - *Tuple, *List, *Dict, *OrderedDict
+// - *GenericClass (written as a global reference)
+// - *Reduce
+// - values for which `PersistentId` returns true.
+func (p *Pickler) Dump(obj interface{}) error {
+	if p.proto < 2 || p.proto >= HighestProtocol {
+		err := fmt.Errorf("Pickler.Dump() failed: unsupported pickle protocol (%d)", p.proto)
+		return err
+	}
+
+	if err := p.writeOp(PROTO, p.proto); err != nil {
+		return err
+	}
+	if err := p.save(obj); err != nil {
+		err = fmt.Errorf("Pickler.Dump() failed: %w", err)
+		return err
+	}
+	if err := p.writeOp(STOP); err != nil {
+		return err
+	}
+
+	return p.writer.Flush()
+}
+
+// writeOp writes an opcode followed by optional argument bytes.
+func (p *Pickler) writeOp(op rune, args ...byte) error {
+	if err := p.writer.WriteByte(byte(op)); err != nil {
+		return err
+	}
+	_, err := p.writer.Write(args)
+	return err
+}
+
+func (p *Pickler) save(obj interface{}) error {
+	if p.PersistentId != nil {
+		if pid, ok := p.PersistentId(obj); ok {
+			return p.savePersId(pid)
+		}
+	}
+
+	switch v := obj.(type) {
+	case nil:
+		return p.writeOp(NONE)
+	case bool:
+		if v {
+			return p.writeOp(NEWTRUE)
+		}
+		return p.writeOp(NEWFALSE)
+	case int:
+		return p.saveInt(int64(v))
+	case int8:
+		return p.saveInt(int64(v))
+	case int16:
+		return p.saveInt(int64(v))
+	case int32:
+		return p.saveInt(int64(v))
+	case int64:
+		return p.saveInt(v)
+	case uint8:
+		return p.saveInt(int64(v))
+	case uint16:
+		return p.saveInt(int64(v))
+	case uint32:
+		return p.saveInt(int64(v))
+	case *big.Int:
+		return p.saveLong(v)
+	case float32:
+		return p.saveFloat(float64(v))
+	case float64:
+		return p.saveFloat(v)
+	case string:
+		return p.saveString(v)
+	case []byte:
+		return p.saveBytes(v)
+	case *Tuple:
+		return p.saveTuple(*v)
+	case *List:
+		return p.saveList(*v)
+	case *Dict:
+		return p.saveDict(v)
+	case *OrderedDict:
+		return p.saveOrderedDict(v)
+	case *GenericClass:
+		return p.saveGlobal(v.Module, v.Name)
+	case *Reduce:
+		return p.saveReduce(v)
+	default:
+		err := picklingError(fmt.Sprintf("unsupported type %v", reflect.TypeOf(obj)))
+		return err
+	}
+}
+
+func (p *Pickler) savePersId(pid interface{}) error {
+	if err := p.save(pid); err != nil {
+		return err
+	}
+
+	return p.writeOp(BINPERSID)
+}
+
+func (p *Pickler) saveInt(v int64) error {
+	switch {
+	case v >= 0 && v <= math.MaxUint8:
+		return p.writeOp(BININT1, byte(v))
+	case v >= 0 && v <= math.MaxUint16:
+		buf := make([]byte, 2)
+		binary.LittleEndian.PutUint16(buf, uint16(v))
+		return p.writeOp(BININT2, buf...)
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		buf := make([]byte, 4)
+		binary.LittleEndian.PutUint32(buf, uint32(int32(v)))
+		return p.writeOp(BININT, buf...)
+	default:
+		return p.saveLong(big.NewInt(v))
+	}
+}
+
+// saveLong writes an integer as LONG1, i.e. little-endian two's complement bytes.
+func (p *Pickler) saveLong(v *big.Int) error {
+	data := encodeLong(v)
+	if len(data) > math.MaxUint8 {
+		err := picklingError(fmt.Sprintf("integer too large: %v", v))
+		return err
+	}
+
+	return p.writeOp(LONG1, append([]byte{byte(len(data))}, data...)...)
+}
+
+// encodeLong encodes an integer to little-endian two's complement bytes. It
+// is the reverse of `decodeLong()`.
+func encodeLong(v *big.Int) []byte {
+	if v.Sign() == 0 {
+		return []byte{}
+	}
+
+	nbytes := v.BitLen()/8 + 1
+	x := new(big.Int).Set(v)
+	if v.Sign() < 0 {
+		// two's complement: 2^(8*nbytes) + v
+		x.Add(x, new(big.Int).Lsh(big.NewInt(1), uint(8*nbytes)))
+	}
+
+	be := x.FillBytes(make([]byte, nbytes))
+	le := make([]byte, nbytes)
+	for i := range be {
+		le[i] = be[nbytes-1-i]
+	}
+
+	return le
+}
+
+func (p *Pickler) saveFloat(v float64) error {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, math.Float64bits(v))
+	return p.writeOp(BINFLOAT, buf...)
+}
+
+func (p *Pickler) saveString(v string) error {
+	n := len(v)
+	if p.proto >= 4 && n <= math.MaxUint8 {
+		return p.writeOp(SHORT_BINUNICODE, append([]byte{byte(n)}, v...)...)
+	}
+
+	if uint64(n) > math.MaxUint32 {
+		err := picklingError("string too large")
+		return err
+	}
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, uint32(n))
+
+	return p.writeOp(BINUNICODE, append(buf, v...)...)
+}
+
+func (p *Pickler) saveBytes(v []byte) error {
+	if p.proto < 3 {
+		err := picklingError("bytes require pickle protocol >= 3")
+		return err
+	}
+
+	n := len(v)
+	if n <= math.MaxUint8 {
+		return p.writeOp(SHORT_BINBYTES, append([]byte{byte(n)}, v...)...)
+	}
+	if uint64(n) > math.MaxUint32 {
+		err := picklingError("bytes too large")
+		return err
+	}
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, uint32(n))
+
+	return p.writeOp(BINBYTES, append(buf, v...)...)
+}
+
+func (p *Pickler) saveTuple(t Tuple) error {
+	if len(t) < len(tuplesize2code) {
+		for _, item := range t {
+			if err := p.save(item); err != nil {
+				return err
+			}
+		}
+		return p.writeOp(tuplesize2code[len(t)])
+	}
+
+	if err := p.writeOp(MARK); err != nil {
+		return err
+	}
+	for _, item := range t {
+		if err := p.save(item); err != nil {
+			return err
+		}
+	}
+
+	return p.writeOp(TUPLE)
+}
+
+func (p *Pickler) saveList(l List) error {
+	if err := p.writeOp(EMPTY_LIST); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(l); start += batchSize {
+		end := start + batchSize
+		if end > len(l) {
+			end = len(l)
+		}
+		if err := p.writeOp(MARK); err != nil {
+			return err
+		}
+		for _, item := range l[start:end] {
+			if err := p.save(item); err != nil {
+				return err
+			}
+		}
+		if err := p.writeOp(APPENDS); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Pickler) saveDict(d *Dict) error {
+	if err := p.writeOp(EMPTY_DICT); err != nil {
+		return err
+	}
+
+	var keys, values []interface{}
+	for _, entry := range *d {
+		keys = append(keys, entry.Key)
+		values = append(values, entry.Value)
+	}
+
+	return p.saveSetItems(keys, values)
+}
+
+// saveOrderedDict writes OrderedDict as `collections.OrderedDict()` followed
+// by its items and `__dict__` attributes if any.
+func (p *Pickler) saveOrderedDict(o *OrderedDict) error {
+	if err := p.saveReduce(NewReduce("collections", "OrderedDict")); err != nil {
+		return err
+	}
+
+	var keys, values []interface{}
+	for e := o.List.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*OrderedDictEntry)
+		keys = append(keys, entry.Key)
+		values = append(values, entry.Value)
+	}
+	if err := p.saveSetItems(keys, values); err != nil {
+		return err
+	}
+
+	if len(o.PyDict) == 0 {
+		return nil
+	}
+
+	// state: BUILD with a dict updates `__dict__`
+	var attrs []string
+	for k := range o.PyDict {
+		attrs = append(attrs, k)
+	}
+	sort.Strings(attrs)
+	state := NewDict()
+	for _, k := range attrs {
+		state.Set(k, o.PyDict[k])
+	}
+	if err := p.saveDict(state); err != nil {
+		return err
+	}
+
+	return p.writeOp(BUILD)
+}
+
+func (p *Pickler) saveSetItems(keys, values []interface{}) error {
+	for start := 0; start < len(keys); start += batchSize {
+		end := start + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := p.writeOp(MARK); err != nil {
+			return err
+		}
+		for i := start; i < end; i++ {
+			if err := p.save(keys[i]); err != nil {
+				return err
+			}
+			if err := p.save(values[i]); err != nil {
+				return err
+			}
+		}
+		if err := p.writeOp(SETITEMS); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Pickler) saveGlobal(module, name string) error {
+	if err := p.writeOp(GLOBAL); err != nil {
+		return err
+	}
+	_, err := p.writer.WriteString(module + "\n" + name + "\n")
+
+	return err
+}
+
+func (p *Pickler) saveReduce(r *Reduce) error {
+	if err := p.saveGlobal(r.Callable.Module, r.Callable.Name); err != nil {
+		return err
+	}
+
+	args := r.Args
+	if args == nil {
+		args = NewTupleFromSlice([]interface{}{})
+	}
+	if err := p.saveTuple(*args); err != nil {
+		return err
+	}
+
+	return p.writeOp(REDUCE)
+}
+
+// Dumps returns pickled representation of obj as a string.
+func Dumps(obj interface{}) (string, error) {
+	var buf bytes.Buffer
+	p := NewPickler(&buf)
+	if err := p.Dump(obj); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
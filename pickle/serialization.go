@@ -10,8 +10,11 @@ package pickle
 import (
 	"archive/tar"
 	"archive/zip"
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"log"
 	"math/big"
@@ -34,10 +37,128 @@ var ErrInvalidProtocolVersion = errors.New("invalid pytorch protocol version")
 // Encode encodes model using pickling machinery.
 // Output pickled model can be loads with Python Pytorch as `torch.load("pytorch_model.bin")`
 //
-// TODO. implement pickling part so that model can be exported and load with Python Pytorch.
-// See https://github.com/python/cpython/blob/b0de6299a840a397d4fe3e6c98159d9f258d3295/Lib/pickle.py#L407
+// NOTE. model should expose its weights with `NamedParameters() ([]ts.NamedTensor, error)`
+// method (e.g. `*ts.CModule`). For `nn.VarStore`, use `Save()` instead.
 func Encode(model ts.Module, outputFile string) error {
-	panic("NotImplementedError")
+	m, ok := model.(interface {
+		NamedParameters() ([]ts.NamedTensor, error)
+	})
+	if !ok {
+		err := fmt.Errorf("Encode() failed: model of type %T does not expose its named parameters", model)
+		return err
+	}
+
+	namedTensors, err := m.NamedParameters()
+	if err != nil {
+		err = fmt.Errorf("Encode() failed: %w", err)
+		return err
+	}
+
+	return EncodeTensors(namedTensors, outputFile)
+}
+
+// EncodeTensors writes named tensors as a Pytorch state dict (`OrderedDict`
+// of tensors) to a zip archive that can be loaded with `torch.load()`.
+//
+// Archive layout (the same as `torch.save()`):
+// - archive/data.pkl: pickled state dict
+// - archive/data/<key>: raw data of tensor storages, 64-byte aligned
+// - archive/version, archive/byteorder
+//
+// NOTE. all tensors are saved with "cpu" location.
+func EncodeTensors(namedTensors []ts.NamedTensor, outputFile string) error {
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cw := &countWriter{w: f}
+	zw := zip.NewWriter(cw)
+
+	stateDict := NewOrderedDict()
+	var storages []*storageRef
+	for i, nt := range namedTensors {
+		className, err := storageClassName(nt.Tensor.DType())
+		if err != nil {
+			err = fmt.Errorf("EncodeTensors() failed at tensor %q: %w", nt.Name, err)
+			return err
+		}
+		size, err := nt.Tensor.Size()
+		if err != nil {
+			return err
+		}
+		storage := &storageRef{
+			key:       fmt.Sprintf("%d", i),
+			className: className,
+			numel:     int64(nt.Tensor.Numel()),
+			tensor:    nt.Tensor,
+		}
+		storages = append(storages, storage)
+
+		sizeTuple := make([]interface{}, len(size))
+		strideTuple := make([]interface{}, len(size))
+		for i, stride := range contiguousStride(size) {
+			sizeTuple[i] = size[i]
+			strideTuple[i] = stride
+		}
+		x := NewReduce("torch._utils", "_rebuild_tensor_v2",
+			storage,                                 // storage
+			0,                                       // storage offset
+			NewTupleFromSlice(sizeTuple),            // size
+			NewTupleFromSlice(strideTuple),          // stride
+			false,                                   // requires_grad
+			NewReduce("collections", "OrderedDict"), // backward hooks
+		)
+		stateDict.Set(nt.Name, x)
+	}
+
+	var pkl bytes.Buffer
+	p := NewPickler(&pkl)
+	p.PersistentId = func(obj interface{}) (interface{}, bool) {
+		s, ok := obj.(*storageRef)
+		if !ok {
+			return nil, false
+		}
+		pid := NewTupleFromSlice([]interface{}{"storage", NewGenericClass("torch", s.className), s.key, "cpu", s.numel})
+		return pid, true
+	}
+	if err := p.Dump(stateDict); err != nil {
+		return err
+	}
+
+	if err := writeZipRecord(zw, cw, archiveName+"/data.pkl", pkl.Bytes()); err != nil {
+		return err
+	}
+	if err := writeZipRecord(zw, cw, archiveName+"/byteorder", []byte("little")); err != nil {
+		return err
+	}
+	for _, s := range storages {
+		data, err := s.tensor.Bytes()
+		if err != nil {
+			return err
+		}
+		if err := writeZipRecord(zw, cw, archiveName+"/data/"+s.key, data); err != nil {
+			return err
+		}
+	}
+	if err := writeZipRecord(zw, cw, archiveName+"/version", []byte("3\n")); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// Save saves all parameters and persistent buffers of a VarStore to a file
+// that can be loaded with Python Pytorch `torch.load()`.
+func Save(vs *nn.VarStore, modelFile string) error {
+	err := EncodeTensors(vs.NamedTensors(), modelFile)
+	if err != nil {
+		err = fmt.Errorf("Save() failed: %w", err)
+		return err
+	}
+
+	return nil
 }
 
 // Decode decodes pickled data created by 'torch.save()' with Python Pytorch
@@ -52,95 +173,139 @@ func Decode(filename string) (map[string]*ts.Tensor, error) {
 		return nil, err
 	}
 
-	dtype := reflect.TypeOf(result).String()
+	storageTensors, err := storageTensorsOf(result)
+	if err != nil {
+		err := fmt.Errorf("Decode() failed: %w", err)
+		return nil, err
+	}
 
 	// Rebuild tensors from Storage tensors
 	namedTensors := make(map[string]*ts.Tensor)
-	switch dtype {
-	case "*pickle.Dict":
-		dictResult := *result.(*Dict)
-		for _, item := range dictResult {
-			name := item.Key
-			sx, isStorageTensor := item.Value.(*StorageTensor)
-			if !isStorageTensor {
-				err := fmt.Errorf("Decode() failed: expected 'StorageTensor' type, got %v\n", reflect.TypeOf(item.Value).String())
-				return nil, err
-			}
+	for _, name := range storageTensors.names {
+		sx := storageTensors.tensors[name]
 
-			data := sx.Source.GetData()
-			size := sx.Size
-			dtype := sx.Source.DType()
+		// Dealing with Pytorch `..._tracked` variables.
+		if storageLen(sx.Source) == 0 {
+			log.Printf("INFO: skip weight %q with zero data length.\n", name)
+			continue
+		}
 
-			device := sx.Source.Device()
-			stride := sx.Stride
-			storageOffset := sx.StorageOffset
+		x, err := rebuildTensor(sx)
+		if err != nil {
+			err = fmt.Errorf("Decode() failed at %q: %w", name, err)
+			return nil, err
+		}
 
-			// log.Printf("%q - %q - shape: %v - stride: %v - storageOffset: %v\n", name, sx.Source.Device().Name, sx.Size, sx.Stride, storageOffset)
-			// log.Printf("data: %v\n", data)
+		namedTensors[name] = x
+	}
 
-			// Dealing with Pytorch `..._tracked` variables.
-			if reflect.ValueOf(data).Len() == 0 {
-				log.Printf("INFO: skip weight %q with zero data length.\n", name.(string))
-				continue
-			}
+	return namedTensors, nil
+}
 
-			// TODO. should we just skip them?
-			if reflect.ValueOf(data).Len() == 1 && len(size) == 0 {
-				size = []int64{1}
-				stride = []int64{1}
-			}
+// namedStorageTensors keeps storage tensors of a state dict in file order.
+type namedStorageTensors struct {
+	names   []string
+	tensors map[string]*StorageTensor
+}
 
-			x := ts.MustOfSlice(data, ts.WithDType(dtype)).MustAsStrided(size, stride, []int64{storageOffset}, true).MustTotype(dtype, true).MustTo(device, true)
-			if sx.RequiresGrad {
-				x.MustRequiresGrad_(sx.RequiresGrad)
-			}
+// storageTensorsOf collects storage tensors from an unpickled state dict.
+func storageTensorsOf(result interface{}) (*namedStorageTensors, error) {
+	st := &namedStorageTensors{tensors: make(map[string]*StorageTensor)}
+	add := func(key, value interface{}) error {
+		name, ok := key.(string)
+		if !ok {
+			err := fmt.Errorf("expected 'string' key, got %v", reflect.TypeOf(key))
+			return err
+		}
+		sx, isStorageTensor := value.(*StorageTensor)
+		if !isStorageTensor {
+			err := fmt.Errorf("expected 'StorageTensor' type, got %v\n", reflect.TypeOf(value))
+			return err
+		}
+		st.names = append(st.names, name)
+		st.tensors[name] = sx
+		return nil
+	}
 
-			namedTensors[name.(string)] = x
+	switch r := result.(type) {
+	case *Dict:
+		for _, item := range *r {
+			if err := add(item.Key, item.Value); err != nil {
+				return nil, err
+			}
 		}
-	case "*pickle.OrderedDict":
-		dictResult := result.(*OrderedDict)
-		for name, item := range dictResult.Map {
-			sx, isStorageTensor := item.Value.(*StorageTensor)
-			if !isStorageTensor {
-				err := fmt.Errorf("Decode() failed: expected 'StorageTensor' type, got %v\n", reflect.TypeOf(item.Value).String())
+	case *OrderedDict:
+		for e := r.List.Front(); e != nil; e = e.Next() {
+			item := e.Value.(*OrderedDictEntry)
+			if err := add(item.Key, item.Value); err != nil {
 				return nil, err
 			}
+		}
+	default:
+		err := fmt.Errorf("expected '*pickle.OrderedDict' or '*pickle.Dict' type, got %v\n", reflect.TypeOf(result))
+		return nil, err
+	}
 
-			data := sx.Source.GetData()
-			size := sx.Size
-			dtype := sx.Source.DType()
-			device := sx.Source.Device()
-			stride := sx.Stride
-			storageOffset := sx.StorageOffset
+	return st, nil
+}
 
-			// log.Printf("%q - %q - shape: %v - stride: %v - storageOffset: %v\n", name, sx.Source.Device().Name, sx.Size, sx.Stride, storageOffset)
-			// log.Printf("data: %v\n", data)
+// storageLen returns number of elements of a storage.
+func storageLen(s Storage) int {
+	if ls, ok := s.(*LazyStorage); ok {
+		return ls.Size
+	}
 
-			// Dealing with Pytorch `..._tracked` variables.
-			if reflect.ValueOf(data).Len() == 0 {
-				log.Printf("INFO: skip weigth %q with zero data length.\n", name.(string))
-				continue
-			}
+	return reflect.ValueOf(s.GetData()).Len()
+}
 
-			// TODO. should we just skip them?
-			if reflect.ValueOf(data).Len() == 1 && len(size) == 0 {
-				size = []int64{1}
-				stride = []int64{1}
-			}
+// storageTensorShape returns shape of tensor to be rebuilt from a storage tensor.
+func storageTensorShape(sx *StorageTensor) []int64 {
+	// TODO. should we just skip them?
+	if storageLen(sx.Source) == 1 && len(sx.Size) == 0 {
+		return []int64{1}
+	}
 
-			x := ts.MustOfSlice(data).MustAsStrided(size, stride, []int64{storageOffset}, true).MustTotype(dtype, true).MustTo(device, true)
-			if sx.RequiresGrad {
-				x.MustRequiresGrad_(sx.RequiresGrad)
-			}
+	return sx.Size
+}
 
-			namedTensors[name.(string)] = x
-		}
-	default:
-		err := fmt.Errorf("Decode() failed: expected '*pickle.OrderedDict' or '*pickle.Dict' type, got %v\n", dtype)
+// rebuildTensor creates a tensor from a storage tensor.
+func rebuildTensor(sx *StorageTensor) (*ts.Tensor, error) {
+	data := sx.Source.GetData()
+	size := sx.Size
+	dtype := sx.Source.DType()
+	device := sx.Source.Device()
+	stride := sx.Stride
+	storageOffset := sx.StorageOffset
+
+	// log.Printf("%q - %q - shape: %v - stride: %v - storageOffset: %v\n", name, sx.Source.Device().Name, sx.Size, sx.Stride, storageOffset)
+	// log.Printf("data: %v\n", data)
+
+	if reflect.ValueOf(data).Len() == 1 && len(size) == 0 {
+		size = []int64{1}
+		stride = []int64{1}
+	}
+
+	x, err := ts.OfSlice(data, ts.WithDType(dtype))
+	if err != nil {
+		return nil, err
+	}
+	x, err = x.AsStrided(size, stride, []int64{storageOffset}, true)
+	if err != nil {
 		return nil, err
 	}
+	x, err = x.Totype(dtype, true)
+	if err != nil {
+		return nil, err
+	}
+	x, err = x.To(device, true)
+	if err != nil {
+		return nil, err
+	}
+	if sx.RequiresGrad {
+		x.MustRequiresGrad_(sx.RequiresGrad)
+	}
 
-	return namedTensors, nil
+	return x, nil
 }
 
 // LoadWithUnpickler is like Load, but it accepts a newUnpickler function which
@@ -160,6 +325,15 @@ func loadZipFile(filename string, newUnpickler func(r io.Reader) Unpickler) (int
 	}
 	defer r.Close()
 
+	return loadZipRecords(&r.Reader, newUnpickler, false)
+}
+
+// loadZipRecords unpickles "data.pkl" record of a Pytorch zip archive.
+//
+// If lazy is true, storages are returned as `*LazyStorage` handles which only
+// read their data from the archive when requested. The archive should be kept
+// open until then.
+func loadZipRecords(r *zip.Reader, newUnpickler func(r io.Reader) Unpickler, lazy bool) (interface{}, error) {
 	fileRecords := make(map[string]*zip.File, len(r.File))
 	for _, f := range r.File {
 		_, recordName := path.Split(f.Name)
@@ -209,7 +383,11 @@ func loadZipFile(filename string, newUnpickler func(r io.Reader) Unpickler) (int
 
 		storage, storageExists := loadedStorages[key]
 		if !storageExists {
-			storage, err = loadTensor(dataType, size, location, key, fileRecords)
+			if lazy {
+				storage, err = newLazyStorage(dataType, size, location, key, fileRecords)
+			} else {
+				storage, err = loadTensor(dataType, size, location, key, fileRecords)
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -464,6 +642,8 @@ func makePickleFindClass(fallback func(module, name string) (interface{}, error)
 			return &ByteStorageClass{}, nil
 		case "torch.BoolStorage":
 			return &BoolStorageClass{}, nil
+		case "torch.BFloat16Storage":
+			return &BFloat16StorageClass{}, nil
 		case "torch.nn.backends.thnn._get_thnn_function_backend":
 			// this is for historical pickle deserilaization, it is not used otherwise
 			return getThnnFunctionBackend{}, nil
@@ -478,66 +658,35 @@ func makePickleFindClass(fallback func(module, name string) (interface{}, error)
 
 // LoadAll finds and loads all weights from varstore.
 // It will throw err if one of weights from varstore cannot find from loaded pretrained model.
-func LoadAll(vs *nn.VarStore, modelFile string) error {
-	weights, err := Decode(modelFile)
+//
+// Tensors are read lazily from the checkpoint, i.e. one at a time when copying
+// to its variable. Optional filters select which tensors of checkpoint are used.
+func LoadAll(vs *nn.VarStore, modelFile string, filters ...NameFilter) error {
+	c, err := OpenCheckpoint(modelFile, filters...)
 	if err != nil {
 		err = fmt.Errorf("LoadAll() failed: %w", err)
 		return err
 	}
+	defer c.Close()
 
-	var namedTensors []ts.NamedTensor
-	for n, x := range weights {
-		namedTs := ts.NamedTensor{
-			Name:   n,
-			Tensor: x,
-		}
-
-		namedTensors = append(namedTensors, namedTs)
-	}
-
-	err = vs.LoadWeights(namedTensors)
-	if err != nil {
-		return err
-	}
-
-	for _, x := range weights {
-		x.MustDrop()
-	}
-
-	return nil
+	return vs.LoadWeightsFrom(c)
 }
 
 // LoadPartial finds and loads weights for varstore.
 // It returns list of unfound weight names.
-func LoadPartial(vs *nn.VarStore, modelFile string) ([]string, error) {
-	weights, err := Decode(modelFile)
+//
+// Tensors are read lazily from the checkpoint. Optional filters select which
+// tensors of checkpoint are used, e.g. `PrefixFilter("backbone.")` loads only
+// backbone weights out of a full model checkpoint.
+func LoadPartial(vs *nn.VarStore, modelFile string, filters ...NameFilter) ([]string, error) {
+	c, err := OpenCheckpoint(modelFile, filters...)
 	if err != nil {
 		err = fmt.Errorf("LoadPartial() failed: %w", err)
 		return nil, err
 	}
+	defer c.Close()
 
-	var namedTensors []ts.NamedTensor
-	for n, x := range weights {
-		namedTs := ts.NamedTensor{
-			Name:   n,
-			Tensor: x,
-		}
-
-		namedTensors = append(namedTensors, namedTs)
-	}
-
-	var missingVariables []string
-
-	missingVariables, err = vs.LoadWeightsPartial(namedTensors)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, x := range weights {
-		x.MustDrop()
-	}
-
-	return missingVariables, nil
+	return vs.LoadPartialFrom(c)
 }
 
 type ModelInfor struct {
@@ -587,20 +736,21 @@ func (m *ModelInfor) Parameters() int {
 
 // LoadInfo loads pretrained weights and prints out name and shape of weights.
 func LoadModelInfo(modelFile string) (*ModelInfor, error) {
-	weights, err := Decode(modelFile)
+	c, err := OpenCheckpoint(modelFile)
 	if err != nil {
 		err = fmt.Errorf("LoadInfo() failed: %w", err)
 		return nil, err
 	}
+	defer c.Close()
 
 	w := make(map[string][]int64)
 	var dtype gotch.DType
 	isFirst := true
-	for n, x := range weights {
-		w[n] = x.MustSize()
+	for _, n := range c.Names() {
+		w[n], _ = c.Shape(n)
 
 		if isFirst {
-			dtype = x.DType()
+			dtype, _ = c.DType(n)
 			isFirst = false
 		}
 	}
@@ -611,3 +761,82 @@ func LoadModelInfo(modelFile string) (*ModelInfor, error) {
 
 	return m, nil
 }
+
+// Pytorch zip archive writing:
+// ============================
+
+// archiveName is the root directory of records in archive written by `EncodeTensors()`.
+const archiveName = "archive"
+
+// zipAlignment is data alignment of records in Pytorch zip archive.
+// Ref. https://github.com/pytorch/pytorch/blob/main/caffe2/serialize/inline_container.cc
+const zipAlignment = 64
+
+// storageRef is a tensor storage to be written as a persistent id in data.pkl
+// and its data as a separate record in zip archive.
+type storageRef struct {
+	key       string
+	className string
+	numel     int64
+	tensor    *ts.Tensor
+}
+
+// countWriter keeps track of number of bytes written.
+type countWriter struct {
+	w     io.Writer
+	count int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.count += int64(n)
+	return n, err
+}
+
+// writeZipRecord writes an uncompressed record to zip archive. Record data is
+// aligned to `zipAlignment` by padding local file header "extra" field as Pytorch does.
+func writeZipRecord(zw *zip.Writer, cw *countWriter, name string, data []byte) error {
+	if err := zw.Flush(); err != nil {
+		return err
+	}
+
+	// local file header: 30 bytes + name + extra (4 bytes extra header + padding)
+	const localHeaderLen = 30
+	const extraHeaderLen = 4
+	offset := cw.count + localHeaderLen + int64(len(name)) + extraHeaderLen
+	padLen := (zipAlignment - offset%zipAlignment) % zipAlignment
+	extra := make([]byte, extraHeaderLen+padLen)
+	binary.LittleEndian.PutUint16(extra[0:2], 0x4246) // "FB"
+	binary.LittleEndian.PutUint16(extra[2:4], uint16(padLen))
+	for i := extraHeaderLen; i < len(extra); i++ {
+		extra[i] = 'Z'
+	}
+
+	fh := &zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(data),
+		CompressedSize64:   uint64(len(data)),
+		UncompressedSize64: uint64(len(data)),
+		Extra:              extra,
+	}
+	w, err := zw.CreateRaw(fh)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+
+	return err
+}
+
+// contiguousStride returns strides of a contiguous tensor of given shape.
+func contiguousStride(shape []int64) []int64 {
+	stride := make([]int64, len(shape))
+	var s int64 = 1
+	for i := len(shape) - 1; i >= 0; i-- {
+		stride[i] = s
+		s *= shape[i]
+	}
+
+	return stride
+}
@@ -0,0 +1,181 @@
+package pickle_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/pickle"
+	"github.com/sugarme/gotch/ts"
+)
+
+func TestPickler_DumpsLoads(t *testing.T) {
+	d := pickle.NewOrderedDict()
+	d.Set("a", 1)
+	d.Set("b", pickle.NewTupleFromSlice([]interface{}{"x", 70000, -3, true, nil}))
+	d.Set("c", 1.5)
+
+	s, err := pickle.Dumps(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := pickle.Loads(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := obj.(*pickle.OrderedDict)
+	if !ok {
+		t.Fatalf("want *pickle.OrderedDict, got %T", obj)
+	}
+
+	if got.MustGet("a") != 1 || got.MustGet("c") != 1.5 {
+		t.Errorf("want a=1, c=1.5. Got a=%v, c=%v\n", got.MustGet("a"), got.MustGet("c"))
+	}
+
+	wantB := []interface{}{"x", 70000, -3, true, nil}
+	gotB := []interface{}(*got.MustGet("b").(*pickle.Tuple))
+	if !reflect.DeepEqual(wantB, gotB) {
+		t.Errorf("want b: %v\n", wantB)
+		t.Errorf("got b: %v\n", gotB)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	modelFile := filepath.Join(t.TempDir(), "model.pt")
+
+	x := ts.MustOfSlice([]float32{1, 2, 3, 4, 5, 6}).MustView([]int64{2, 3}, true)
+	dtypes := []gotch.DType{gotch.Float, gotch.Double, gotch.Half, gotch.BFloat16, gotch.Int64, gotch.Int, gotch.Int16, gotch.Int8, gotch.Uint8, gotch.Bool}
+
+	var namedTensors []ts.NamedTensor
+	for _, dtype := range dtypes {
+		namedTensors = append(namedTensors, ts.NamedTensor{
+			Name:   dtype.String(),
+			Tensor: x.MustTotype(dtype, false),
+		})
+	}
+	// transposed (non-contiguous) tensor
+	namedTensors = append(namedTensors, ts.NamedTensor{
+		Name:   "transposed",
+		Tensor: x.MustT(false),
+	})
+
+	err := pickle.EncodeTensors(namedTensors, modelFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	weights, err := pickle.Decode(modelFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(weights) != len(namedTensors) {
+		t.Errorf("want %v tensors, got %v\n", len(namedTensors), len(weights))
+	}
+
+	for _, nt := range namedTensors {
+		got, ok := weights[nt.Name]
+		if !ok {
+			t.Errorf("missing tensor %q\n", nt.Name)
+			continue
+		}
+
+		if got.DType() != nt.Tensor.DType() {
+			t.Errorf("%q: want dtype %v, got %v\n", nt.Name, nt.Tensor.DType(), got.DType())
+		}
+		if !reflect.DeepEqual(nt.Tensor.MustSize(), got.MustSize()) {
+			t.Errorf("%q: want shape %v, got %v\n", nt.Name, nt.Tensor.MustSize(), got.MustSize())
+		}
+		want := nt.Tensor.MustTotype(gotch.Double, false).Float64Values()
+		gotVals := got.MustTotype(gotch.Double, false).Float64Values()
+		if !reflect.DeepEqual(want, gotVals) {
+			t.Errorf("%q: want values %v\n", nt.Name, want)
+			t.Errorf("%q: got values %v\n", nt.Name, gotVals)
+		}
+	}
+}
+
+func TestSaveLoadAll(t *testing.T) {
+	modelFile := filepath.Join(t.TempDir(), "varstore.pt")
+
+	vs1 := nn.NewVarStore(gotch.CPU)
+	l1 := nn.NewLinear(vs1.Root().Sub("fc"), 4, 3, nn.DefaultLinearConfig())
+	vs2 := nn.NewVarStore(gotch.CPU)
+	l2 := nn.NewLinear(vs2.Root().Sub("fc"), 4, 3, nn.DefaultLinearConfig())
+
+	err := pickle.Save(vs1, modelFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pickle.LoadAll(vs2, modelFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := l1.Ws.Float64Values()
+	got := l2.Ws.Float64Values()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want weight: %v\n", want)
+		t.Errorf("got weight: %v\n", got)
+	}
+}
+
+func TestCheckpoint_Lazy(t *testing.T) {
+	modelFile := filepath.Join(t.TempDir(), "model.pt")
+
+	vs1 := nn.NewVarStore(gotch.CPU)
+	backbone1 := nn.NewLinear(vs1.Root().Sub("backbone"), 4, 3, nn.DefaultLinearConfig())
+	_ = nn.NewLinear(vs1.Root().Sub("head"), 3, 2, nn.DefaultLinearConfig())
+	err := pickle.Save(vs1, modelFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := pickle.OpenCheckpoint(modelFile, pickle.PrefixFilter("backbone."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantNames := []string{"backbone.bias", "backbone.weight"}
+	if !reflect.DeepEqual(wantNames, c.Names()) {
+		t.Errorf("want names: %v\n", wantNames)
+		t.Errorf("got names: %v\n", c.Names())
+	}
+	shape, ok := c.Shape("backbone.weight")
+	if !ok || !reflect.DeepEqual([]int64{3, 4}, shape) {
+		t.Errorf("want shape [3 4], got %v (%v)\n", shape, ok)
+	}
+	if _, ok := c.Shape("head.weight"); ok {
+		t.Errorf("want 'head.weight' filtered out\n")
+	}
+	c.Close()
+
+	// A different model with the same backbone.
+	vs2 := nn.NewVarStore(gotch.CPU)
+	backbone2 := nn.NewLinear(vs2.Root().Sub("backbone"), 4, 3, nn.DefaultLinearConfig())
+	_ = nn.NewLinear(vs2.Root().Sub("head"), 3, 5, nn.DefaultLinearConfig())
+
+	missing, err := pickle.LoadPartial(vs2, modelFile, pickle.RegexpFilter(regexp.MustCompile(`^backbone\.`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(missing)
+	wantMissing := []string{"head.bias", "head.weight"}
+	if !reflect.DeepEqual(wantMissing, missing) {
+		t.Errorf("want missing: %v\n", wantMissing)
+		t.Errorf("got missing: %v\n", missing)
+	}
+
+	want := backbone1.Ws.Float64Values()
+	got := backbone2.Ws.Float64Values()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want backbone weight: %v\n", want)
+		t.Errorf("got backbone weight: %v\n", got)
+	}
+}
@@ -1,9 +1,11 @@
 package pickle
 
 import (
+	"archive/zip"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"log"
 	"math"
 
 	"github.com/sugarme/gotch"
@@ -602,7 +604,7 @@ func (s *BoolStorage) GetData() interface{} {
 }
 
 func (s *BoolStorage) DType() gotch.DType {
-	return gotch.Float
+	return gotch.Bool
 }
 
 func (s *BoolStorage) Device() gotch.Device {
@@ -614,6 +616,109 @@ func (s *BoolStorage) Device() gotch.Device {
 	}
 }
 
+// LazyStorage:
+// ============
+
+// LazyStorage is a storage whose data is only read from Pytorch zip archive
+// when requested, i.e. on `Load()` or `GetData()`.
+type LazyStorage struct {
+	Storage // typed storage, its data is nil until loaded.
+	BaseStorage
+
+	class  StorageClass
+	file   *zip.File
+	loaded bool
+}
+
+var _ Storage = &LazyStorage{}
+
+func newLazyStorage(
+	dataType StorageClass,
+	size int,
+	location, key string,
+	zipFileRecords map[string]*zip.File,
+) (*LazyStorage, error) {
+	file, fileOk := zipFileRecords[key]
+	if !fileOk {
+		return nil, fmt.Errorf("cannot find zip record '%s'", key)
+	}
+
+	return &LazyStorage{
+		Storage:     dataType.New(size, location),
+		BaseStorage: BaseStorage{Size: size, Location: location},
+		class:       dataType,
+		file:        file,
+	}, nil
+}
+
+// Load reads storage data from zip archive if not loaded yet.
+func (s *LazyStorage) Load() error {
+	if s.loaded {
+		return nil
+	}
+
+	f, err := s.file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := s.Storage.SetFromFileWithSize(f, s.Size); err != nil {
+		return err
+	}
+	s.loaded = true
+
+	return nil
+}
+
+// Release drops loaded data. It will be read again on next `Load()`.
+func (s *LazyStorage) Release() {
+	s.Storage = s.class.New(s.Size, s.Location)
+	s.loaded = false
+}
+
+// GetData loads (if needed) and returns storage data.
+//
+// NOTE. it returns nil if loading failed. Use `Load()` to get the error.
+func (s *LazyStorage) GetData() interface{} {
+	if err := s.Load(); err != nil {
+		log.Printf("WARNING: LazyStorage.GetData() failed: %v\n", err)
+		return nil
+	}
+
+	return s.Storage.GetData()
+}
+
+// storageClassName returns Pytorch storage class name (in "torch" module)
+// of a given dtype.
+func storageClassName(dtype gotch.DType) (string, error) {
+	switch dtype {
+	case gotch.Half:
+		return "HalfStorage", nil
+	case gotch.BFloat16:
+		return "BFloat16Storage", nil
+	case gotch.Float:
+		return "FloatStorage", nil
+	case gotch.Double:
+		return "DoubleStorage", nil
+	case gotch.Int8:
+		return "CharStorage", nil
+	case gotch.Int16:
+		return "ShortStorage", nil
+	case gotch.Int:
+		return "IntStorage", nil
+	case gotch.Int64:
+		return "LongStorage", nil
+	case gotch.Uint8:
+		return "ByteStorage", nil
+	case gotch.Bool:
+		return "BoolStorage", nil
+	default:
+		err := fmt.Errorf("unsupported storage dtype: %v", dtype)
+		return "", err
+	}
+}
+
 func setFromFile(s Storage, r io.Reader) error {
 	sizeBuf := make([]byte, 8)
 	_, err := r.Read(sizeBuf)
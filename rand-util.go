@@ -0,0 +1,109 @@
+package gotch
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	lib "github.com/sugarme/gotch/libtch"
+)
+
+var (
+	defaultRandSource *RandSource = NewRandSource(time.Now().UnixNano())
+	defaultRand       *rand.Rand  = rand.New(defaultRandSource)
+)
+
+// ManualSeed sets seed of libtorch random generator and of gotch default Go
+// random generator `DefaultRand()`, which is used by samplers, iterators and
+// augmentations unless another generator is specified.
+//
+// NOTE. Two runs calling `ManualSeed()` with the same seed produce the same
+// initial weights, batches and augmentations (on CPU).
+func ManualSeed(seed int64) {
+	lib.AtManualSeed(seed)
+	defaultRandSource.Seed(seed)
+}
+
+// DefaultRand returns gotch default Go random generator. It is safe for
+// concurrent use except for `Read()` method.
+func DefaultRand() *rand.Rand {
+	return defaultRand
+}
+
+// DefaultRandSource returns source of `DefaultRand()`, e.g. to be saved in a
+// training checkpoint.
+func DefaultRandSource() *RandSource {
+	return defaultRandSource
+}
+
+// RandSource is a seeded `math/rand` source which keeps track of number of
+// values drawn from it so that its state can be saved and restored, e.g.
+// to resume training.
+//
+// It is safe for concurrent use.
+//
+// NOTE. state of `rand.Rand.Read()` buffer is not tracked.
+type RandSource struct {
+	mu    sync.Mutex
+	seed  int64
+	count uint64
+	src   rand.Source64
+}
+
+// NewRandSource creates a RandSource with given seed.
+func NewRandSource(seed int64) *RandSource {
+	return &RandSource{
+		seed: seed,
+		src:  rand.NewSource(seed).(rand.Source64),
+	}
+}
+
+// Int63 implements rand.Source interface.
+func (s *RandSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.count += 1
+	return s.src.Int63()
+}
+
+// Uint64 implements rand.Source64 interface.
+func (s *RandSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.count += 1
+	return s.src.Uint64()
+}
+
+// Seed implements rand.Source interface. It resets the number of drawn values.
+func (s *RandSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seed = seed
+	s.count = 0
+	s.src.Seed(seed)
+}
+
+// State returns seed and number of values drawn since seeding.
+func (s *RandSource) State() (seed int64, count uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.seed, s.count
+}
+
+// SetState restores state returned by `State()` by reseeding and drawing
+// count values.
+func (s *RandSource) SetState(seed int64, count uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seed = seed
+	s.count = count
+	s.src.Seed(seed)
+	for i := uint64(0); i < count; i++ {
+		s.src.Uint64()
+	}
+}
@@ -0,0 +1,104 @@
+package ts
+
+// Automatic mixed precision (autocast).
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+
+	"github.com/sugarme/gotch"
+	lib "github.com/sugarme/gotch/libtch"
+)
+
+func autocastDevice(device gotch.Device) int {
+	if device.IsCuda() {
+		return 1
+	}
+	return 0
+}
+
+// AutocastEnabled returns whether autocast is enabled for device type (CPU
+// or CUDA) in current OS thread.
+func AutocastEnabled(device gotch.Device) bool {
+	if device.IsCuda() {
+		return lib.AtAutocastIsEnabled()
+	}
+	return lib.AtAutocastIsCPUEnabled() == 1
+}
+
+func autocastSetEnabled(device gotch.Device, b bool) bool {
+	if device.IsCuda() {
+		return lib.AtAutocastSetEnabled(b)
+	}
+
+	cbool := 0
+	if b {
+		cbool = 1
+	}
+	return lib.AtAutocastSetCPUEnabled(cbool) == 1
+}
+
+// Autocast runs a closure with automatic mixed precision on device type of
+// `device`, similar to `with torch.autocast(...)` in Python.
+//
+// Inside the closure, eligible ops (e.g. matmul, linear, convolution) run in
+// `dtype` (`gotch.BFloat16` or `gotch.Half`) while precision-sensitive ops
+// (e.g. reductions, softmax, losses) run in float32. Variables (master
+// weights) keep their own dtype; their low precision casts are cached until
+// the outermost Autocast returns. Gradients flow back to variables in their
+// own dtype.
+//
+// NOTE. autocast state is thread-local in libtorch, hence the calling
+// goroutine is locked to its OS thread while the closure runs and ops must
+// run in the calling goroutine.
+//
+// Example:
+//
+//	err := ts.Autocast(gotch.CPU, gotch.BFloat16, func() {
+//		logits = model.ForwardT(x, true)
+//		loss = nn.CrossEntropyLoss(logits, y)
+//	})
+func Autocast(device gotch.Device, dtype gotch.DType, fn func()) error {
+	if dtype != gotch.Half && dtype != gotch.BFloat16 {
+		err := fmt.Errorf("Autocast() failed: unsupported dtype %v. Expected Half or BFloat16", dtype)
+		return err
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cuda := autocastDevice(device)
+	prevEnabled := autocastSetEnabled(device, true)
+	if err := TorchErr(); err != nil {
+		err = fmt.Errorf("Autocast() failed: %w", err)
+		return err
+	}
+	prevDType := lib.AtAutocastSetDType(cuda, int(dtype.CKind()))
+	if err := TorchErr(); err != nil {
+		autocastSetEnabled(device, prevEnabled)
+		err = fmt.Errorf("Autocast() failed: %w", err)
+		return err
+	}
+	lib.AtAutocastIncrementNesting()
+
+	defer func() {
+		if lib.AtAutocastDecrementNesting() == 0 {
+			lib.AtAutocastClearCache()
+		}
+		lib.AtAutocastSetDType(cuda, prevDType)
+		autocastSetEnabled(device, prevEnabled)
+	}()
+
+	fn()
+
+	return TorchErr()
+}
+
+// MustAutocast runs a closure with automatic mixed precision. It panics if error occurred.
+func MustAutocast(device gotch.Device, dtype gotch.DType, fn func()) {
+	err := Autocast(device, dtype, fn)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
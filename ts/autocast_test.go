@@ -0,0 +1,53 @@
+package ts_test
+
+import (
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+func TestAutocast(t *testing.T) {
+	a := ts.MustRandn([]int64{4, 8}, gotch.Float, gotch.CPU)
+	a.MustRequiresGrad_(true)
+	b := ts.MustRandn([]int64{8, 2}, gotch.Float, gotch.CPU)
+
+	if ts.AutocastEnabled(gotch.CPU) {
+		t.Fatalf("Expect autocast disabled by default")
+	}
+
+	var (
+		y       *ts.Tensor
+		enabled bool
+	)
+	err := ts.Autocast(gotch.CPU, gotch.BFloat16, func() {
+		enabled = ts.AutocastEnabled(gotch.CPU)
+		y = a.MustMatmul(b, false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !enabled || ts.AutocastEnabled(gotch.CPU) {
+		t.Errorf("Expect autocast enabled only inside closure")
+	}
+	if y.DType() != gotch.BFloat16 {
+		t.Errorf("Want matmul output in BFloat16, got %v", y.DType())
+	}
+
+	// Gradient flows back to input in its own dtype.
+	y.MustSum(gotch.Float, true).MustBackward()
+	grad := a.MustGrad(false)
+	if grad.DType() != gotch.Float {
+		t.Errorf("Want gradient in Float, got %v", grad.DType())
+	}
+
+	// Outside autocast, op runs in input dtype.
+	y1 := a.MustMatmul(b, false)
+	if y1.DType() != gotch.Float {
+		t.Errorf("Want matmul output in Float, got %v", y1.DType())
+	}
+
+	if err := ts.Autocast(gotch.CPU, gotch.Float, func() {}); err == nil {
+		t.Errorf("Expect error for unsupported autocast dtype")
+	}
+}
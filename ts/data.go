@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"os"
 	"path/filepath"
 
@@ -23,6 +24,7 @@ type Iter2 struct {
 	totalSize            int64
 	device               gotch.Device
 	returnSmallLastBatch bool
+	rng                  *rand.Rand
 }
 
 // NewIter2 returns a new iterator.
@@ -102,8 +104,21 @@ func MustNewIter2(xs, ys *Tensor, batchSize int64) *Iter2 {
 //
 // The iterator would still run over the whole dataset but the order in
 // which elements are grouped in mini-batches is randomized.
+//
+// NOTE. Permutation is drawn from Go random generator if set by `SetRand()`.
+// Otherwise, libtorch random generator is used.
 func (it *Iter2) Shuffle() {
-	index := MustRandperm(it.totalSize, gotch.Int64, gotch.CPU)
+	var index *Tensor
+	if it.rng != nil {
+		perm := it.rng.Perm(int(it.totalSize))
+		indices := make([]int64, len(perm))
+		for i, v := range perm {
+			indices[i] = int64(v)
+		}
+		index = MustOfSlice(indices)
+	} else {
+		index = MustRandperm(it.totalSize, gotch.Int64, gotch.CPU)
+	}
 
 	it.xs = it.xs.MustIndexSelect(0, index, true)
 	it.ys = it.ys.MustIndexSelect(0, index, true)
@@ -111,6 +126,12 @@ func (it *Iter2) Shuffle() {
 	index.MustDrop()
 }
 
+// SetRand sets Go random generator used by `Shuffle()`.
+func (it *Iter2) SetRand(r *rand.Rand) *Iter2 {
+	it.rng = r
+	return it
+}
+
 // ToDevice transfers the mini-batches to a specified device.
 func (it *Iter2) ToDevice(device gotch.Device) *Iter2 {
 	it.device = device
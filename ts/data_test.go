@@ -4,6 +4,7 @@ import (
 	// "fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"path/filepath"
 	"reflect"
 	"testing"
@@ -112,3 +113,34 @@ func TestTextDataIter(t *testing.T) {
 		t.Logf("sum: %v\n", vals)
 	}
 }
+
+func TestIter2_Shuffle_Seed(t *testing.T) {
+	xs := ts.MustArange(ts.IntScalar(10), gotch.Int64, gotch.CPU)
+	ys := ts.MustArange(ts.IntScalar(10), gotch.Int64, gotch.CPU)
+
+	shuffle := func(iter *ts.Iter2) []int64 {
+		iter.Shuffle()
+		item, ok := iter.Next()
+		if !ok {
+			t.Fatal("want a batch, got none")
+		}
+		return item.Data.Int64Values()
+	}
+
+	want := shuffle(ts.MustNewIter2(xs, ys, 10).SetRand(rand.New(rand.NewSource(42))))
+	got := shuffle(ts.MustNewIter2(xs, ys, 10).SetRand(rand.New(rand.NewSource(42))))
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v\n", want)
+		t.Errorf("got: %v\n", got)
+	}
+
+	// libtorch generator seeded by gotch.ManualSeed
+	gotch.ManualSeed(42)
+	want = shuffle(ts.MustNewIter2(xs, ys, 10))
+	gotch.ManualSeed(42)
+	got = shuffle(ts.MustNewIter2(xs, ys, 10))
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v\n", want)
+		t.Errorf("got: %v\n", got)
+	}
+}
@@ -0,0 +1,147 @@
+package ts
+
+// Gradient hooks.
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	lib "github.com/sugarme/gotch/libtch"
+)
+
+type gradHook struct {
+	tensor *Tensor // shallow clone of hooked tensor
+	fn     func(grad *Tensor)
+}
+
+func (h *gradHook) hasGrad() bool {
+	grad := h.tensor.MustGrad(false)
+	defined := grad.MustDefined()
+	grad.MustDrop()
+
+	return defined
+}
+
+var (
+	gradHooksMu sync.Mutex
+	gradHooks   []*gradHook
+
+	// backwardMu serializes backward passes while hooks are pending, so that
+	// a gradient which becomes defined during a pass belongs to that pass.
+	backwardMu sync.Mutex
+)
+
+// RetainGrad enables gradient of a non-leaf tensor to be kept after backward
+// pass, so that it can be read with `Grad()`.
+func (ts *Tensor) RetainGrad() error {
+	lib.AtRetainGrad(ts.ctensor)
+	if err := TorchErr(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ts *Tensor) MustRetainGrad() {
+	if err := ts.RetainGrad(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// RegisterGradHook registers a function to be called with gradient of the
+// tensor when the first `Backward()` which reaches the tensor completes.
+// Backward passes of other graphs (e.g. another model trained concurrently)
+// neither call nor remove the hook.
+//
+// NOTE. Unlike `register_hook` in Pytorch, hook is called once, after (not
+// during) the backward pass, hence it can observe but not modify gradient.
+// Gradient tensor is deleted after the hook returns; use `MustShallowClone()`
+// to keep it. Hook is not called if the tensor already has gradient when
+// registered. Hooks of tensors which are never backwarded are kept until
+// `ClearGradHooks()`. Backward passes are serialized while hooks are pending.
+func (ts *Tensor) RegisterGradHook(fn func(grad *Tensor)) error {
+	if !ts.MustRequiresGrad() {
+		err := fmt.Errorf("RegisterGradHook() failed: tensor does not require grad")
+		return err
+	}
+	if err := ts.RetainGrad(); err != nil {
+		return err
+	}
+
+	x, err := ts.ShallowClone()
+	if err != nil {
+		return err
+	}
+	gradHooksMu.Lock()
+	gradHooks = append(gradHooks, &gradHook{tensor: x, fn: fn})
+	gradHooksMu.Unlock()
+
+	return nil
+}
+
+func (ts *Tensor) MustRegisterGradHook(fn func(grad *Tensor)) {
+	if err := ts.RegisterGradHook(fn); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ClearGradHooks removes gradient hooks which have not been called.
+func ClearGradHooks() {
+	gradHooksMu.Lock()
+	hooks := gradHooks
+	gradHooks = nil
+	gradHooksMu.Unlock()
+
+	for _, h := range hooks {
+		h.tensor.MustDrop()
+	}
+}
+
+// backwardWithGradHooks runs backward pass and then calls and removes hooks of
+// tensors which gradients are defined by it.
+func backwardWithGradHooks(backward func() error) error {
+	gradHooksMu.Lock()
+	pending := len(gradHooks)
+	gradHooksMu.Unlock()
+	if pending == 0 {
+		return backward()
+	}
+
+	backwardMu.Lock()
+	gradHooksMu.Lock()
+	waiting := make(map[*gradHook]bool)
+	for _, h := range gradHooks {
+		if !h.hasGrad() {
+			waiting[h] = true
+		}
+	}
+	gradHooksMu.Unlock()
+
+	if err := backward(); err != nil {
+		backwardMu.Unlock()
+		return err
+	}
+
+	var reached, kept []*gradHook
+	gradHooksMu.Lock()
+	for _, h := range gradHooks {
+		if waiting[h] && h.hasGrad() {
+			reached = append(reached, h)
+		} else {
+			kept = append(kept, h)
+		}
+	}
+	gradHooks = kept
+	gradHooksMu.Unlock()
+	backwardMu.Unlock()
+
+	for _, h := range reached {
+		grad := h.tensor.MustGrad(false)
+		h.fn(grad)
+		grad.MustDrop()
+		h.tensor.MustDrop()
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package ts_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+func TestRegisterGradHook(t *testing.T) {
+	x := ts.MustOnes([]int64{3}, gotch.Float, gotch.CPU)
+	x.MustRequiresGrad_(true)
+	y := x.MustMulScalar(ts.FloatScalar(2), false)
+
+	var calls int
+	var grad []float64
+	y.MustRegisterGradHook(func(g *ts.Tensor) {
+		calls++
+		grad = g.Float64Values()
+	})
+
+	loss := y.MustMul(y, false).MustSum(gotch.Float, true)
+	loss.MustBackward()
+	// d(y^2)/dy = 2y = 4
+	if want := []float64{4, 4, 4}; !reflect.DeepEqual(grad, want) {
+		t.Errorf("Want gradient %v, got %v", want, grad)
+	}
+
+	// Hook is called once.
+	loss = y.MustSum(gotch.Float, false)
+	loss.MustBackward()
+	if calls != 1 {
+		t.Errorf("Want hook called once, got %v", calls)
+	}
+
+	z := ts.MustOnes([]int64{3}, gotch.Float, gotch.CPU)
+	if err := z.RegisterGradHook(func(g *ts.Tensor) {}); err == nil {
+		t.Errorf("Expect error registering hook of tensor which does not require grad")
+	}
+}
+
+// model is a single layer y = w * x with a gradient hook on its output.
+type model struct {
+	w     *ts.Tensor
+	calls int
+	grad  []float64
+}
+
+func newModel(w float64) *model {
+	x := ts.MustOfSlice([]float64{w, w}).MustTotype(gotch.Float, true)
+	x.MustRequiresGrad_(true)
+	return &model{w: x}
+}
+
+func (m *model) step(backward bool) *ts.Tensor {
+	x := ts.MustOnes([]int64{2}, gotch.Float, gotch.CPU)
+	y := m.w.MustMul(x, false)
+	x.MustDrop()
+	y.MustRegisterGradHook(func(g *ts.Tensor) {
+		m.calls++
+		m.grad = g.Float64Values()
+	})
+	loss := y.MustMul(y, false).MustSum(gotch.Float, true)
+	y.MustDrop()
+	if backward {
+		loss.MustBackward()
+	}
+
+	return loss
+}
+
+func TestRegisterGradHook_IndependentModels(t *testing.T) {
+	defer ts.ClearGradHooks()
+
+	m1, m2 := newModel(1), newModel(3)
+	loss1 := m1.step(false)
+	loss2 := m2.step(false)
+
+	// Backward of model 1 neither calls nor removes hook of model 2.
+	loss1.MustBackward()
+	if m1.calls != 1 || m2.calls != 0 {
+		t.Errorf("Want hooks called (1, 0) times, got (%v, %v)", m1.calls, m2.calls)
+	}
+	loss2.MustBackward()
+	if m1.calls != 1 || m2.calls != 1 {
+		t.Errorf("Want hooks called (1, 1) times, got (%v, %v)", m1.calls, m2.calls)
+	}
+	// d(y^2)/dy = 2y
+	if want := []float64{2, 2}; !reflect.DeepEqual(m1.grad, want) {
+		t.Errorf("Want model 1 gradient %v, got %v", want, m1.grad)
+	}
+	if want := []float64{6, 6}; !reflect.DeepEqual(m2.grad, want) {
+		t.Errorf("Want model 2 gradient %v, got %v", want, m2.grad)
+	}
+	loss1.MustDrop()
+	loss2.MustDrop()
+
+	// Concurrent training
+	var wg sync.WaitGroup
+	for _, m := range []*model{m1, m2} {
+		wg.Add(1)
+		go func(m *model) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				m.step(true).MustDrop()
+			}
+		}(m)
+	}
+	wg.Wait()
+	if m1.calls != 11 || m2.calls != 11 {
+		t.Errorf("Want hooks called (11, 11) times, got (%v, %v)", m1.calls, m2.calls)
+	}
+}
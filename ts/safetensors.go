@@ -0,0 +1,378 @@
+package ts
+
+// Ref.
+// https://github.com/huggingface/safetensors
+//
+// File layout:
+// - 8 bytes: N, unsigned little-endian 64-bit integer containing the size of the header.
+// - N bytes: a JSON UTF-8 string representing the header. It is a map of tensor name
+// to {"dtype": ..., "shape": [...], "data_offsets": [begin, end]} plus an optional
+// "__metadata__" map of string to string.
+// - Rest of the file: byte buffer. Offsets in header are relative to start of this buffer.
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"syscall"
+
+	"github.com/sugarme/gotch"
+)
+
+const (
+	SafetensorsSuffix string = ".safetensors"
+
+	safetensorsMetadataKey string = "__metadata__"
+	// maximum header size accepted (100MB) to guard against corrupted files.
+	safetensorsMaxHeaderSize uint64 = 100 * 1024 * 1024
+)
+
+var safetensorsDTypes map[string]gotch.DType = map[string]gotch.DType{
+	"BOOL": gotch.Bool,
+	"U8":   gotch.Uint8,
+	"I8":   gotch.Int8,
+	"I16":  gotch.Int16,
+	"I32":  gotch.Int,
+	"I64":  gotch.Int64,
+	"F16":  gotch.Half,
+	"BF16": gotch.BFloat16,
+	"F32":  gotch.Float,
+	"F64":  gotch.Double,
+}
+
+func safetensorsDTypeName(dtype gotch.DType) (string, error) {
+	for name, dt := range safetensorsDTypes {
+		if dt == dtype {
+			return name, nil
+		}
+	}
+
+	err := fmt.Errorf("unsupported safetensors dtype: %v", dtype)
+	return "", err
+}
+
+// SafetensorsInfo holds header information of a tensor stored in safetensors file.
+type SafetensorsInfo struct {
+	DType       string   `json:"dtype"`
+	Shape       []int64  `json:"shape"`
+	DataOffsets [2]int64 `json:"data_offsets"`
+}
+
+// Kind returns gotch DType of the stored tensor.
+func (i *SafetensorsInfo) Kind() (gotch.DType, error) {
+	dtype, ok := safetensorsDTypes[i.DType]
+	if !ok {
+		err := fmt.Errorf("unsupported safetensors dtype: %q", i.DType)
+		return gotch.Invalid, err
+	}
+
+	return dtype, nil
+}
+
+// SafetensorsFile is a memory-mapped safetensors file.
+//
+// Tensor data are read lazily, i.e. only when `Tensor()` is called, bytes of
+// the requested tensor are paged in from the file.
+type SafetensorsFile struct {
+	path     string
+	names    []string
+	infos    map[string]SafetensorsInfo
+	metadata map[string]string
+	mmap     []byte // whole file
+	data     []byte // byte buffer after header
+}
+
+// OpenSafetensors memory-maps a safetensors file and parses its header.
+//
+// NOTE. `Close()` should be called when done to unmap the file.
+func OpenSafetensors(path string) (*SafetensorsFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if size < 8 {
+		err = fmt.Errorf("OpenSafetensors() failed: file %q too small (%v bytes)", path, size)
+		return nil, err
+	}
+
+	mmap, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		err = fmt.Errorf("OpenSafetensors() failed: mmap %q: %w", path, err)
+		return nil, err
+	}
+
+	sf := &SafetensorsFile{path: path, mmap: mmap}
+	if err := sf.parseHeader(); err != nil {
+		sf.Close()
+		return nil, err
+	}
+
+	return sf, nil
+}
+
+func (sf *SafetensorsFile) parseHeader() error {
+	n := binary.LittleEndian.Uint64(sf.mmap[:8])
+	if n > safetensorsMaxHeaderSize || n > uint64(len(sf.mmap)-8) {
+		err := fmt.Errorf("invalid safetensors header size (%v) in file %q", n, sf.path)
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(sf.mmap[8:8+n], &raw); err != nil {
+		err = fmt.Errorf("invalid safetensors header in file %q: %w", sf.path, err)
+		return err
+	}
+
+	sf.data = sf.mmap[8+n:]
+	sf.infos = make(map[string]SafetensorsInfo, len(raw))
+	for name, msg := range raw {
+		if name == safetensorsMetadataKey {
+			if err := json.Unmarshal(msg, &sf.metadata); err != nil {
+				err = fmt.Errorf("invalid safetensors metadata in file %q: %w", sf.path, err)
+				return err
+			}
+			continue
+		}
+
+		var info SafetensorsInfo
+		if err := json.Unmarshal(msg, &info); err != nil {
+			err = fmt.Errorf("invalid safetensors header for tensor %q: %w", name, err)
+			return err
+		}
+
+		dtype, err := info.Kind()
+		if err != nil {
+			return err
+		}
+
+		begin, end := info.DataOffsets[0], info.DataOffsets[1]
+		nbytes := int64(ElementCount(info.Shape)) * int64(dtype.Size())
+		if begin < 0 || end < begin || end > int64(len(sf.data)) || end-begin != nbytes {
+			err := fmt.Errorf("invalid data offsets %v for tensor %q (dtype: %v, shape: %v)", info.DataOffsets, name, dtype, info.Shape)
+			return err
+		}
+
+		sf.infos[name] = info
+		sf.names = append(sf.names, name)
+	}
+
+	sort.Strings(sf.names)
+
+	return nil
+}
+
+// Close unmaps the file.
+func (sf *SafetensorsFile) Close() error {
+	if sf.mmap == nil {
+		return nil
+	}
+	err := syscall.Munmap(sf.mmap)
+	sf.mmap = nil
+	sf.data = nil
+
+	return err
+}
+
+// Names returns sorted names of all tensors stored in the file.
+func (sf *SafetensorsFile) Names() []string {
+	return sf.names
+}
+
+// Metadata returns the free-form "__metadata__" map if any.
+func (sf *SafetensorsFile) Metadata() map[string]string {
+	return sf.metadata
+}
+
+// Info returns header information of a tensor by name.
+func (sf *SafetensorsFile) Info(name string) (SafetensorsInfo, bool) {
+	info, ok := sf.infos[name]
+	return info, ok
+}
+
+// Shape returns shape of a tensor by name and whether it exists.
+func (sf *SafetensorsFile) Shape(name string) ([]int64, bool) {
+	info, ok := sf.infos[name]
+	if !ok {
+		return nil, false
+	}
+
+	return info.Shape, true
+}
+
+// Tensor reads tensor with given name from file and puts it on specified device.
+func (sf *SafetensorsFile) Tensor(name string, device gotch.Device) (*Tensor, error) {
+	if sf.mmap == nil {
+		err := fmt.Errorf("SafetensorsFile.Tensor() failed: file %q already closed", sf.path)
+		return nil, err
+	}
+
+	info, ok := sf.infos[name]
+	if !ok {
+		err := fmt.Errorf("SafetensorsFile.Tensor() failed: tensor %q not found in %q", name, sf.path)
+		return nil, err
+	}
+	dtype, err := info.Kind()
+	if err != nil {
+		return nil, err
+	}
+
+	data := sf.data[info.DataOffsets[0]:info.DataOffsets[1]]
+	shape := info.Shape
+	if shape == nil {
+		shape = []int64{}
+	}
+
+	x, err := OfDataSize(data, shape, dtype, WithName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	if device == gotch.CPU {
+		return x, nil
+	}
+
+	return x.To(device, true)
+}
+
+// MustTensor reads tensor with given name from file. It panics if error occurred.
+func (sf *SafetensorsFile) MustTensor(name string, device gotch.Device) *Tensor {
+	x, err := sf.Tensor(name, device)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return x
+}
+
+// ReadSafetensors reads all tensors from a safetensors file to CPU.
+func ReadSafetensors(path string) ([]NamedTensor, error) {
+	return ReadSafetensorsWithDevice(path, gotch.CPU)
+}
+
+// ReadSafetensorsWithDevice reads all tensors from a safetensors file to a given device.
+func ReadSafetensorsWithDevice(path string, device gotch.Device) ([]NamedTensor, error) {
+	sf, err := OpenSafetensors(path)
+	if err != nil {
+		return nil, err
+	}
+	defer sf.Close()
+
+	var namedTensors []NamedTensor
+	for _, name := range sf.Names() {
+		x, err := sf.Tensor(name, device)
+		if err != nil {
+			for _, nt := range namedTensors {
+				nt.Tensor.MustDrop()
+			}
+			return nil, err
+		}
+
+		namedTensors = append(namedTensors, NamedTensor{name, x})
+	}
+
+	return namedTensors, nil
+}
+
+// WriteSafetensors writes named tensors to a safetensors file.
+//
+// Tensors are moved to CPU and made contiguous before writing. Optional metadata
+// are stored in "__metadata__" header field.
+func WriteSafetensors(namedTensors []NamedTensor, path string, metadataOpt ...map[string]string) error {
+	sorted := make([]NamedTensor, len(namedTensors))
+	copy(sorted, namedTensors)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	header := make(map[string]interface{}, len(sorted)+1)
+	if len(metadataOpt) > 0 && len(metadataOpt[0]) > 0 {
+		header[safetensorsMetadataKey] = metadataOpt[0]
+	}
+
+	var offset int64 = 0
+	for _, nt := range sorted {
+		if _, ok := header[nt.Name]; ok {
+			err := fmt.Errorf("WriteSafetensors() failed: duplicated tensor name %q", nt.Name)
+			return err
+		}
+		dtypeName, err := safetensorsDTypeName(nt.Tensor.DType())
+		if err != nil {
+			return err
+		}
+		shape, err := nt.Tensor.Size()
+		if err != nil {
+			return err
+		}
+		nbytes := nt.Tensor.nbytes()
+		header[nt.Name] = SafetensorsInfo{
+			DType:       dtypeName,
+			Shape:       shape,
+			DataOffsets: [2]int64{offset, offset + nbytes},
+		}
+		offset += nbytes
+	}
+
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	// pad header with spaces so that byte buffer is 8-byte aligned.
+	if pad := len(headerBytes) % 8; pad != 0 {
+		for i := 0; i < 8-pad; i++ {
+			headerBytes = append(headerBytes, ' ')
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], uint64(len(headerBytes)))
+	if _, err := f.Write(n[:]); err != nil {
+		return err
+	}
+	if _, err := f.Write(headerBytes); err != nil {
+		return err
+	}
+
+	for _, nt := range sorted {
+		if err := writeTensorBytes(f, nt.Tensor); err != nil {
+			err = fmt.Errorf("WriteSafetensors() failed at tensor %q: %w", nt.Name, err)
+			return err
+		}
+	}
+
+	return f.Sync()
+}
+
+// MustWriteSafetensors writes named tensors to a safetensors file. It panics if error occurred.
+func MustWriteSafetensors(namedTensors []NamedTensor, path string, metadataOpt ...map[string]string) {
+	err := WriteSafetensors(namedTensors, path, metadataOpt...)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func writeTensorBytes(w io.Writer, x *Tensor) error {
+	data, err := x.Bytes()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+
+	return err
+}
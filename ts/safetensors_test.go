@@ -0,0 +1,95 @@
+package ts_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/ts"
+)
+
+func TestSafetensors_WriteRead(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "test.safetensors")
+
+	x1 := ts.MustOfSlice([]float32{1, 2, 3, 4, 5, 6}).MustView([]int64{2, 3}, true)
+	x2 := ts.MustOfSlice([]int64{3, 1, 4})
+	namedTensors := []ts.NamedTensor{
+		{Name: "b.weight", Tensor: x1},
+		{Name: "a.index", Tensor: x2},
+	}
+	metadata := map[string]string{"format": "pt"}
+
+	err := ts.WriteSafetensors(namedTensors, path, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sf, err := ts.OpenSafetensors(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close()
+
+	wantNames := []string{"a.index", "b.weight"}
+	if !reflect.DeepEqual(wantNames, sf.Names()) {
+		t.Errorf("want names: %v\n", wantNames)
+		t.Errorf("got names: %v\n", sf.Names())
+	}
+
+	if !reflect.DeepEqual(metadata, sf.Metadata()) {
+		t.Errorf("want metadata: %v\n", metadata)
+		t.Errorf("got metadata: %v\n", sf.Metadata())
+	}
+
+	info, ok := sf.Info("b.weight")
+	if !ok {
+		t.Fatalf("missing tensor 'b.weight'")
+	}
+	wantInfo := ts.SafetensorsInfo{DType: "F32", Shape: []int64{2, 3}, DataOffsets: [2]int64{24, 48}}
+	if !reflect.DeepEqual(wantInfo, info) {
+		t.Errorf("want info: %+v\n", wantInfo)
+		t.Errorf("got info: %+v\n", info)
+	}
+
+	loaded, err := ts.ReadSafetensors(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]*ts.Tensor)
+	for _, nt := range loaded {
+		got[nt.Name] = nt.Tensor
+	}
+
+	gotX1 := got["b.weight"]
+	if gotX1.DType() != gotch.Float || !reflect.DeepEqual(gotX1.MustSize(), []int64{2, 3}) {
+		t.Errorf("got 'b.weight' with dtype %v and shape %v\n", gotX1.DType(), gotX1.MustSize())
+	}
+	if !reflect.DeepEqual(x1.Float64Values(), gotX1.Float64Values()) {
+		t.Errorf("want 'b.weight': %v\n", x1.Float64Values())
+		t.Errorf("got 'b.weight': %v\n", gotX1.Float64Values())
+	}
+
+	gotX2 := got["a.index"]
+	if !reflect.DeepEqual(x2.Vals(), gotX2.Vals()) {
+		t.Errorf("want 'a.index': %v\n", x2.Vals())
+		t.Errorf("got 'a.index': %v\n", gotX2.Vals())
+	}
+}
+
+func TestSafetensors_InvalidHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.safetensors")
+	// header size larger than file size
+	data := []byte{0xff, 0, 0, 0, 0, 0, 0, 0, '{', '}'}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ts.OpenSafetensors(path)
+	if err == nil {
+		t.Errorf("want error for invalid header size, got nil")
+	}
+}
@@ -808,13 +808,13 @@ func (ts *Tensor) ZeroGrad() {
 // which gradients are tracked.
 //
 // Gradients tracking can be turned on via `SetRequiresGrad`.
+// Registered gradient hooks of tensors reached by the backward pass (see
+// `RegisterGradHook()`) are called afterward.
 func (ts *Tensor) Backward() error {
-	lib.AtBackward(ts.ctensor, 0, 0)
-	if err := TorchErr(); err != nil {
-		return err
-	}
-
-	return nil
+	return backwardWithGradHooks(func() error {
+		lib.AtBackward(ts.ctensor, 0, 0)
+		return TorchErr()
+	})
 }
 
 func (ts *Tensor) MustBackward() {
@@ -942,6 +942,43 @@ func (ts *Tensor) MustCopyData(dst interface{}, numel uint) {
 	}
 }
 
+// Bytes returns raw data of tensor in native byte order (row-major, contiguous).
+func (ts *Tensor) Bytes() ([]byte, error) {
+	nbytes := ts.nbytes()
+	if nbytes == 0 {
+		return []byte{}, nil
+	}
+
+	x, err := ts.To(gotch.CPU, false)
+	if err != nil {
+		return nil, err
+	}
+	defer x.MustDrop()
+	cx, err := x.Contiguous(false)
+	if err != nil {
+		return nil, err
+	}
+	defer cx.MustDrop()
+
+	buf := make([]byte, nbytes)
+	lib.AtCopyData(cx.ctensor, unsafe.Pointer(&buf[0]), cx.Numel(), cx.DType().Size())
+	if err := TorchErr(); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// MustBytes returns raw data of tensor. It panics if error occurred.
+func (ts *Tensor) MustBytes() []byte {
+	data, err := ts.Bytes()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return data
+}
+
 // Numel returns the total number of elements stored in a tensor.
 func (ts *Tensor) Numel() uint {
 	if !ts.MustDefined() {
@@ -1255,6 +1292,23 @@ func MustGradSetEnabled(b bool) bool {
 	return state
 }
 
+// GetRngState returns state of libtorch default CPU random generator as a uint8 tensor.
+func GetRngState() (*Tensor, error) {
+	ctensor := lib.AtGetRngState()
+	if err := TorchErr(); err != nil {
+		return nil, err
+	}
+
+	return newTensor(ctensor), nil
+}
+
+// SetRngState sets state of libtorch default CPU random generator from a
+// tensor returned by `GetRngState()`.
+func SetRngState(state *Tensor) error {
+	lib.AtSetRngState(state.ctensor)
+	return TorchErr()
+}
+
 // NoGrad runs a closure without keeping track of gradients.
 func NoGrad(fn func()) {
 	// Switch off Grad
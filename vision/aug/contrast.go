@@ -11,6 +11,7 @@ import (
 // - p (float): probability of the image being autocontrasted. Default value is 0.5
 type RandomAutocontrast struct {
 	pvalue float64
+	randomizer
 }
 
 func newRandomAutocontrast(pOpt ...float64) *RandomAutocontrast {
@@ -19,13 +20,13 @@ func newRandomAutocontrast(pOpt ...float64) *RandomAutocontrast {
 		p = pOpt[0]
 	}
 
-	return &RandomAutocontrast{p}
+	return &RandomAutocontrast{pvalue: p}
 }
 
 func (rac *RandomAutocontrast) Forward(x *ts.Tensor) *ts.Tensor {
 	fx := Byte2FloatImage(x)
 
-	r := randPvalue()
+	r := rac.randP()
 	var out *ts.Tensor
 	switch {
 	case r < rac.pvalue:
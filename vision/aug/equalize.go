@@ -13,6 +13,7 @@ import (
 // Ref. https://en.wikipedia.org/wiki/Histogram_equalization
 type RandomEqualize struct {
 	pvalue float64
+	randomizer
 }
 
 func newRandomEqualize(pOpt ...float64) *RandomEqualize {
@@ -21,12 +22,12 @@ func newRandomEqualize(pOpt ...float64) *RandomEqualize {
 		p = pOpt[0]
 	}
 
-	return &RandomEqualize{p}
+	return &RandomEqualize{pvalue: p}
 }
 
 // NOTE. input image MUST be uint8 dtype otherwise panic!
 func (re *RandomEqualize) Forward(x *ts.Tensor) *ts.Tensor {
-	r := randPvalue()
+	r := re.randP()
 	var out *ts.Tensor
 	switch {
 	case r < re.pvalue:
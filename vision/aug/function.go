@@ -5,7 +5,6 @@ import (
 	"log"
 	"math"
 	"math/rand"
-	"time"
 
 	"github.com/sugarme/gotch"
 	"github.com/sugarme/gotch/ts"
@@ -1094,13 +1093,33 @@ func genAffineGrid(theta *ts.Tensor, w, h, ow, oh int64) *ts.Tensor {
 	return outputGrid
 }
 
-// randPvalue generates a random propability value [0, 1]
+// randPvalue generates a random propability value [0, 1] from gotch default random generator.
 func randPvalue() float64 {
-	rand.Seed(time.Now().UnixNano())
-	var min, max float64 = 0.0, 1.0
+	return gotch.DefaultRand().Float64()
+}
+
+// randomizer holds Go random generator of an augmentation module. It is
+// embedded in modules drawing random values in Go.
+type randomizer struct {
+	rng *rand.Rand
+}
+
+// setRand sets random generator. Nil means gotch default random generator.
+func (r *randomizer) setRand(rng *rand.Rand) {
+	r.rng = rng
+}
+
+func (r *randomizer) rand() *rand.Rand {
+	if r.rng == nil {
+		return gotch.DefaultRand()
+	}
+
+	return r.rng
+}
 
-	r := min + rand.Float64()*(max-min)
-	return r
+// randP generates a random propability value [0, 1].
+func (r *randomizer) randP() float64 {
+	return r.rand().Float64()
 }
 
 func getImageChanNum(x *ts.Tensor) int64 {
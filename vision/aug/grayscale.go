@@ -50,6 +50,7 @@ func newGrayscale(outChanOpt ...int64) *Grayscale {
 // - p (float): probability that image should be converted to grayscale.
 type RandomGrayscale struct {
 	pvalue float64
+	randomizer
 }
 
 func newRandomGrayscale(pvalueOpt ...float64) *RandomGrayscale {
@@ -57,12 +58,12 @@ func newRandomGrayscale(pvalueOpt ...float64) *RandomGrayscale {
 	if len(pvalueOpt) > 0 {
 		pvalue = pvalueOpt[0]
 	}
-	return &RandomGrayscale{pvalue}
+	return &RandomGrayscale{pvalue: pvalue}
 }
 
 func (rgs *RandomGrayscale) Forward(x *ts.Tensor) *ts.Tensor {
 	c := getImageChanNum(x)
-	r := randPvalue()
+	r := rgs.randP()
 	var out *ts.Tensor
 	switch {
 	case r < rgs.pvalue:
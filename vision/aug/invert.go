@@ -6,6 +6,7 @@ import (
 
 type RandomInvert struct {
 	pvalue float64
+	randomizer
 }
 
 func newRandomInvert(pOpt ...float64) *RandomInvert {
@@ -13,13 +14,13 @@ func newRandomInvert(pOpt ...float64) *RandomInvert {
 	if len(pOpt) > 0 {
 		p = pOpt[0]
 	}
-	return &RandomInvert{p}
+	return &RandomInvert{pvalue: p}
 }
 
 func (ri *RandomInvert) Forward(x *ts.Tensor) *ts.Tensor {
 	fx := Byte2FloatImage(x)
 
-	r := randPvalue()
+	r := ri.randP()
 	var out *ts.Tensor
 	switch {
 	case r < ri.pvalue:
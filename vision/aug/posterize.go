@@ -14,6 +14,7 @@ import (
 type RandomPosterize struct {
 	pvalue float64
 	bits   uint8
+	randomizer
 }
 
 type posterizeOptions struct {
@@ -56,7 +57,7 @@ func newRandomPosterize(opts ...PosterizeOption) *RandomPosterize {
 
 // NOTE. Input image must be uint8 dtype otherwise panic!
 func (rp *RandomPosterize) Forward(x *ts.Tensor) *ts.Tensor {
-	r := randPvalue()
+	r := rp.randP()
 	var out *ts.Tensor
 	switch {
 	case r < rp.pvalue:
@@ -5,7 +5,6 @@ import (
 	"log"
 	"math"
 	"math/rand"
-	"time"
 
 	"github.com/sugarme/gotch"
 	"github.com/sugarme/gotch/ts"
@@ -13,6 +12,11 @@ import (
 
 // RandomRotate randomly rotates a tensor image within a specifed angle range (degree).
 func RandomRotate(img *ts.Tensor, min, max float64) (*ts.Tensor, error) {
+	return randomRotate(img, min, max, gotch.DefaultRand())
+}
+
+// randomRotate rotates image by a random angle in range [min, max] drawn from rng.
+func randomRotate(img *ts.Tensor, min, max float64, rng *rand.Rand) (*ts.Tensor, error) {
 	if min > max {
 		tmp := min
 		min = max
@@ -24,8 +28,7 @@ func RandomRotate(img *ts.Tensor, min, max float64) (*ts.Tensor, error) {
 	}
 	// device := img.MustDevice()
 	dtype := gotch.Double
-	rand.Seed(time.Now().UnixNano())
-	angle := min + rand.Float64()*(max-min)
+	angle := min + rng.Float64()*(max-min)
 
 	theta := float64(angle) * (math.Pi / 180)
 	input := img.MustUnsqueeze(0, false).MustTotype(dtype, true)
@@ -91,17 +94,18 @@ func WithRotate(angle float64) Option {
 type RandRotateModule struct {
 	minAngle float64
 	maxAngle float64
+	randomizer
 }
 
 func newRandRotate(min, max float64) *RandRotateModule {
-	return &RandRotateModule{min, max}
+	return &RandRotateModule{minAngle: min, maxAngle: max}
 }
 
 // Forward implements ts.Module for RandRotateModule
 func (rr *RandRotateModule) Forward(x *ts.Tensor) *ts.Tensor {
 	fx := Byte2FloatImage(x)
 
-	out, err := RandomRotate(fx, rr.minAngle, rr.maxAngle)
+	out, err := randomRotate(fx, rr.minAngle, rr.maxAngle, rr.rand())
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -14,6 +14,7 @@ import (
 type RandomAdjustSharpness struct {
 	sharpnessFactor float64
 	pvalue          float64
+	randomizer
 }
 
 type sharpnessOptions struct {
@@ -55,7 +56,7 @@ func newRandomAdjustSharpness(opts ...SharpnessOption) *RandomAdjustSharpness {
 
 // NOTE. input img dtype shoule be `uint8` (Byte)
 func (ras *RandomAdjustSharpness) Forward(x *ts.Tensor) *ts.Tensor {
-	r := randPvalue()
+	r := ras.randP()
 	var out *ts.Tensor
 	switch {
 	case r < ras.pvalue:
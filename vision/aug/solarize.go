@@ -15,6 +15,7 @@ import (
 type RandomSolarize struct {
 	threshold float64
 	pvalue    float64
+	randomizer
 }
 
 type solarizeOptions struct {
@@ -59,7 +60,7 @@ func newRandomSolarize(opts ...SolarizeOption) *RandomSolarize {
 func (rs *RandomSolarize) Forward(x *ts.Tensor) *ts.Tensor {
 	fx := Byte2FloatImage(x)
 
-	r := randPvalue()
+	r := rs.randP()
 	var out *ts.Tensor
 	switch {
 	case r < rs.pvalue:
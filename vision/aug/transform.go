@@ -2,8 +2,8 @@ package aug
 
 import (
 	"math/rand"
-	"time"
 
+	"github.com/sugarme/gotch"
 	"github.com/sugarme/gotch/nn"
 	"github.com/sugarme/gotch/ts"
 )
@@ -48,6 +48,7 @@ type Options struct {
 	zoomIn                *ZoomIn
 	zoomOut               *ZoomOut
 	normalize             *Normalize
+	rng                   *rand.Rand
 }
 
 func defaultOption() *Options {
@@ -75,6 +76,7 @@ func defaultOption() *Options {
 		zoomIn:                nil,
 		zoomOut:               nil,
 		normalize:             nil,
+		rng:                   nil,
 	}
 }
 
@@ -183,9 +185,28 @@ func Compose(opts ...Option) (Transformer, error) {
 		augs.Add(augOpts.zoomOut)
 	}
 
+	if augOpts.rng != nil {
+		for _, m := range augs.Layers() {
+			if r, ok := m.(interface{ setRand(*rand.Rand) }); ok {
+				r.setRand(augOpts.rng)
+			}
+		}
+	}
+
 	return &Augment{augs}, nil
 }
 
+// WithRand sets Go random generator of augmentations which draw random values
+// in Go. Default is `gotch.DefaultRand()`.
+//
+// NOTE. Other augmentations draw random values from libtorch random generator
+// which can be seeded with `gotch.ManualSeed()`.
+func WithRand(r *rand.Rand) Option {
+	return func(o *Options) {
+		o.rng = r
+	}
+}
+
 // OneOf randomly return one transformer from list of transformers
 // with a specific p value.
 func OneOf(pvalue float64, tfOpts ...Option) Option {
@@ -199,8 +220,7 @@ func OneOf(pvalue float64, tfOpts ...Option) Option {
 		return nil
 	}
 
-	rand.Seed(time.Now().UnixNano())
-	idx := rand.Intn(tfsNum)
+	idx := gotch.DefaultRand().Intn(tfsNum)
 
 	return tfOpts[idx]
 }
@@ -0,0 +1,244 @@
+package vision
+
+// ConvNeXt implementation.
+//
+// See "A ConvNet for the 2020s", Liu et al. 2022
+// https://arxiv.org/abs/2201.03545
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+type cnStageConfig struct {
+	inChannels  int64
+	outChannels int64 // 0 if no downsampling after stage
+	numLayers   int64
+}
+
+func convnextLayerNormConfig() *nn.LayerNormConfig {
+	config := nn.DefaultLayerNormConfig()
+	config.Eps = 1e-6
+
+	return config
+}
+
+// layerNorm2d applies layer normalization over channel dimension of NCHW
+// input.
+func layerNorm2d(p *nn.Path, dim int64) ts.ModuleT {
+	ln := nn.NewLayerNorm(p, []int64{dim}, convnextLayerNormConfig())
+
+	return nn.NewFunc(func(xs *ts.Tensor) *ts.Tensor {
+		nhwc := xs.MustPermute([]int64{0, 2, 3, 1}, false)
+		norm := nhwc.Apply(ln)
+		nhwc.MustDrop()
+
+		return norm.MustPermute([]int64{0, 3, 1, 2}, true)
+	})
+}
+
+// stochasticDepth randomly drops whole samples ("row" mode) of a residual
+// branch with probability p in training mode and rescales kept samples.
+// Input tensor is not deleted.
+//
+// See "Deep Networks with Stochastic Depth" https://arxiv.org/abs/1603.09382
+func stochasticDepth(xs *ts.Tensor, p float64, train bool) *ts.Tensor {
+	if !train || p == 0 {
+		return xs.MustShallowClone()
+	}
+
+	survival := 1.0 - p
+	size := xs.MustSize()
+	shape := make([]int64, len(size))
+	for i := range shape {
+		shape[i] = 1
+	}
+	shape[0] = size[0]
+
+	noise := ts.MustOnes(shape, xs.DType(), xs.MustDevice()).MustBernoulliP(survival, true)
+	if survival > 0 {
+		noise = noise.MustDivScalar(ts.FloatScalar(survival), true)
+	}
+	res := xs.MustMul(noise, false)
+	noise.MustDrop()
+
+	return res
+}
+
+type cnBlock struct {
+	DwConv     *nn.Conv2D
+	Norm       *nn.LayerNorm
+	PwConv1    *nn.Linear
+	PwConv2    *nn.Linear
+	LayerScale *ts.Tensor
+	SdProb     float64 // stochastic depth probability
+}
+
+func newCNBlock(p *nn.Path, dim int64, layerScale, sdProb float64) *cnBlock {
+	bp := p.Sub("block")
+
+	dwConfig := nn.DefaultConv2DConfig()
+	dwConfig.Padding = []int64{3, 3}
+	dwConfig.Groups = dim
+
+	return &cnBlock{
+		DwConv:     nn.NewConv2D(bp.Sub("0"), dim, dim, 7, dwConfig),
+		Norm:       nn.NewLayerNorm(bp.Sub("2"), []int64{dim}, convnextLayerNormConfig()),
+		PwConv1:    nn.NewLinear(bp.Sub("3"), dim, 4*dim, nn.DefaultLinearConfig()),
+		PwConv2:    nn.NewLinear(bp.Sub("5"), 4*dim, dim, nn.DefaultLinearConfig()),
+		LayerScale: p.MustNewVar("layer_scale", []int64{dim, 1, 1}, nn.NewConstInit(layerScale)),
+		SdProb:     sdProb,
+	}
+}
+
+// ForwardT implements ModuleT for cnBlock.
+func (b *cnBlock) ForwardT(xs *ts.Tensor, train bool) *ts.Tensor {
+	dw := b.DwConv.ForwardT(xs, train)
+	nhwc := dw.MustPermute([]int64{0, 2, 3, 1}, true)
+	norm := b.Norm.Forward(nhwc)
+	nhwc.MustDrop()
+	fc1 := b.PwConv1.ForwardT(norm, train)
+	norm.MustDrop()
+	act := fc1.MustGelu("none", true)
+	fc2 := b.PwConv2.ForwardT(act, train)
+	act.MustDrop()
+	nchw := fc2.MustPermute([]int64{0, 3, 1, 2}, true)
+	scaled := nchw.MustMul(b.LayerScale, true)
+	sd := stochasticDepth(scaled, b.SdProb, train)
+	scaled.MustDrop()
+
+	return sd.MustAdd(xs, true)
+}
+
+func convnext(p *nn.Path, nclasses int64, settings []cnStageConfig, sdProb float64) ts.ModuleT {
+	fp := p.Sub("features")
+	features := nn.SeqT()
+
+	// Stem: patchify with 4x4 non-overlapping convolution.
+	stemConfig := nn.DefaultConv2DConfig()
+	stemConfig.Stride = []int64{4, 4}
+	stemConfig.Padding = []int64{0, 0}
+	stem := nn.SeqT()
+	stem.Add(nn.NewConv2D(fp.Sub("0").Sub("0"), 3, settings[0].inChannels, 4, stemConfig))
+	stem.Add(layerNorm2d(fp.Sub("0").Sub("1"), settings[0].inChannels))
+	features.Add(stem)
+
+	var totalBlocks int64
+	for _, s := range settings {
+		totalBlocks += s.numLayers
+	}
+
+	downConfig := nn.DefaultConv2DConfig()
+	downConfig.Stride = []int64{2, 2}
+	downConfig.Padding = []int64{0, 0}
+
+	layerId := 1
+	blockId := 0
+	for _, s := range settings {
+		stagePath := fp.Sub(fmt.Sprint(layerId))
+		stage := nn.SeqT()
+		for i := 0; i < int(s.numLayers); i++ {
+			// Stochastic depth probability increases linearly with block index.
+			prob := sdProb * float64(blockId) / (float64(totalBlocks) - 1.0)
+			stage.Add(newCNBlock(stagePath.Sub(fmt.Sprint(i)), s.inChannels, 1e-6, prob))
+			blockId += 1
+		}
+		features.Add(stage)
+		layerId += 1
+
+		if s.outChannels > 0 {
+			downPath := fp.Sub(fmt.Sprint(layerId))
+			down := nn.SeqT()
+			down.Add(layerNorm2d(downPath.Sub("0"), s.inChannels))
+			down.Add(nn.NewConv2D(downPath.Sub("1"), s.inChannels, s.outChannels, 2, downConfig))
+			features.Add(down)
+			layerId += 1
+		}
+	}
+
+	lastChannels := settings[len(settings)-1].inChannels
+	cp := p.Sub("classifier")
+	norm := layerNorm2d(cp.Sub("0"), lastChannels)
+
+	var fc *nn.Linear
+	if nclasses > 0 {
+		fc = nn.NewLinear(cp.Sub("2"), lastChannels, nclasses, nn.DefaultLinearConfig())
+	}
+
+	return nn.NewFuncT(func(xs *ts.Tensor, train bool) *ts.Tensor {
+		tmp1 := xs.ApplyT(features, train)
+		tmp2 := tmp1.MustAdaptiveAvgPool2d([]int64{1, 1}, true)
+		tmp3 := tmp2.ApplyT(norm, train)
+		tmp2.MustDrop()
+		tmp4 := tmp3.MustFlatten(1, -1, true)
+		if fc == nil {
+			return tmp4
+		}
+
+		res := tmp4.Apply(fc)
+		tmp4.MustDrop()
+		return res
+	})
+}
+
+func convnextTinySettings() []cnStageConfig {
+	return []cnStageConfig{{96, 192, 3}, {192, 384, 3}, {384, 768, 9}, {768, 0, 3}}
+}
+
+func convnextSmallSettings() []cnStageConfig {
+	return []cnStageConfig{{96, 192, 3}, {192, 384, 3}, {384, 768, 27}, {768, 0, 3}}
+}
+
+func convnextBaseSettings() []cnStageConfig {
+	return []cnStageConfig{{128, 256, 3}, {256, 512, 3}, {512, 1024, 27}, {1024, 0, 3}}
+}
+
+func convnextLargeSettings() []cnStageConfig {
+	return []cnStageConfig{{192, 384, 3}, {384, 768, 3}, {768, 1536, 27}, {1536, 0, 3}}
+}
+
+// ConvNeXtTiny creates a ConvNeXt-Tiny model.
+func ConvNeXtTiny(p *nn.Path, nclasses int64) ts.ModuleT {
+	return convnext(p, nclasses, convnextTinySettings(), 0.1)
+}
+
+// ConvNeXtTinyNoFinalLayer creates a ConvNeXt-Tiny model without final fully
+// connected layer.
+func ConvNeXtTinyNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return convnext(p, 0, convnextTinySettings(), 0.1)
+}
+
+// ConvNeXtSmall creates a ConvNeXt-Small model.
+func ConvNeXtSmall(p *nn.Path, nclasses int64) ts.ModuleT {
+	return convnext(p, nclasses, convnextSmallSettings(), 0.4)
+}
+
+// ConvNeXtSmallNoFinalLayer creates a ConvNeXt-Small model without final
+// fully connected layer.
+func ConvNeXtSmallNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return convnext(p, 0, convnextSmallSettings(), 0.4)
+}
+
+// ConvNeXtBase creates a ConvNeXt-Base model.
+func ConvNeXtBase(p *nn.Path, nclasses int64) ts.ModuleT {
+	return convnext(p, nclasses, convnextBaseSettings(), 0.5)
+}
+
+// ConvNeXtBaseNoFinalLayer creates a ConvNeXt-Base model without final fully
+// connected layer.
+func ConvNeXtBaseNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return convnext(p, 0, convnextBaseSettings(), 0.5)
+}
+
+// ConvNeXtLarge creates a ConvNeXt-Large model.
+func ConvNeXtLarge(p *nn.Path, nclasses int64) ts.ModuleT {
+	return convnext(p, nclasses, convnextLargeSettings(), 0.5)
+}
+
+// ConvNeXtLargeNoFinalLayer creates a ConvNeXt-Large model without final
+// fully connected layer.
+func ConvNeXtLargeNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return convnext(p, 0, convnextLargeSettings(), 0.5)
+}
@@ -0,0 +1,71 @@
+package vision_test
+
+import (
+	"testing"
+
+	"github.com/sugarme/gotch/vision"
+)
+
+func TestConvNeXt(t *testing.T) {
+	tests := []modelTest{
+		{
+			name:     "convnext_tiny",
+			model:    vision.ConvNeXtTiny,
+			noFinal:  vision.ConvNeXtTinyNoFinalLayer,
+			features: 768,
+			vars: map[string][]int64{
+				"features.0.0.weight":         {96, 3, 4, 4},
+				"features.0.1.weight":         {96},
+				"features.1.0.block.0.weight": {96, 1, 7, 7},
+				"features.1.0.block.2.weight": {96},
+				"features.1.0.block.3.weight": {384, 96},
+				"features.1.0.block.5.weight": {96, 384},
+				"features.1.0.layer_scale":    {96, 1, 1},
+				"features.2.0.weight":         {96},
+				"features.2.1.weight":         {192, 96, 2, 2},
+				"features.5.8.block.3.weight": {1536, 384},
+				"features.7.2.layer_scale":    {768, 1, 1},
+				"classifier.0.weight":         {768},
+				"classifier.2.weight":         {1000, 768},
+			},
+		},
+		{
+			name:     "convnext_small",
+			model:    vision.ConvNeXtSmall,
+			noFinal:  vision.ConvNeXtSmallNoFinalLayer,
+			features: 768,
+			vars: map[string][]int64{
+				"features.5.26.block.0.weight": {384, 1, 7, 7},
+				"features.6.1.weight":          {768, 384, 2, 2},
+				"classifier.2.weight":          {1000, 768},
+			},
+			large: true,
+		},
+		{
+			name:     "convnext_base",
+			model:    vision.ConvNeXtBase,
+			noFinal:  vision.ConvNeXtBaseNoFinalLayer,
+			features: 1024,
+			vars: map[string][]int64{
+				"features.0.0.weight":          {128, 3, 4, 4},
+				"features.5.26.block.0.weight": {512, 1, 7, 7},
+				"classifier.2.weight":          {1000, 1024},
+			},
+			large: true,
+		},
+		{
+			name:     "convnext_large",
+			model:    vision.ConvNeXtLarge,
+			noFinal:  vision.ConvNeXtLargeNoFinalLayer,
+			features: 1536,
+			vars: map[string][]int64{
+				"features.0.0.weight":          {192, 3, 4, 4},
+				"features.5.26.block.3.weight": {3072, 768},
+				"classifier.2.weight":          {1000, 1536},
+			},
+			large: true,
+		},
+	}
+
+	testModels(t, []int64{1, 3, 64, 64}, tests)
+}
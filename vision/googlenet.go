@@ -0,0 +1,129 @@
+package vision
+
+// GoogLeNet (Inception V1) implementation.
+//
+// See "Going Deeper with Convolutions", Szegedy et al. 2014
+// https://arxiv.org/abs/1409.4842
+
+import (
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+func glMaxPool2D(xs *ts.Tensor, ksize, stride, pad int64) *ts.Tensor {
+	return xs.MustMaxPool2d([]int64{ksize, ksize}, []int64{stride, stride}, []int64{pad, pad}, []int64{1, 1}, true, false)
+}
+
+func glMaxPool(ksize, stride int64) ts.ModuleT {
+	return nn.NewFunc(func(xs *ts.Tensor) *ts.Tensor {
+		return glMaxPool2D(xs, ksize, stride, 0)
+	})
+}
+
+// NOTE. Branch 3 uses 3x3 convolution instead of 5x5 as in torchvision
+// implementation which pretrained weights are trained with.
+func glInception(p *nn.Path, cIn, c1x1, c3x3Red, c3x3, c5x5Red, c5x5, cPool int64) ts.ModuleT {
+	b1 := convBn(p.Sub("branch1"), cIn, c1x1, 1, 0, 1)
+
+	b2 := nn.SeqT()
+	b2.Add(convBn(p.Sub("branch2").Sub("0"), cIn, c3x3Red, 1, 0, 1))
+	b2.Add(convBn(p.Sub("branch2").Sub("1"), c3x3Red, c3x3, 3, 1, 1))
+
+	b3 := nn.SeqT()
+	b3.Add(convBn(p.Sub("branch3").Sub("0"), cIn, c5x5Red, 1, 0, 1))
+	b3.Add(convBn(p.Sub("branch3").Sub("1"), c5x5Red, c5x5, 3, 1, 1))
+
+	b4 := convBn(p.Sub("branch4").Sub("1"), cIn, cPool, 1, 0, 1)
+
+	return nn.NewFuncT(func(xs *ts.Tensor, train bool) *ts.Tensor {
+		b1Ts := xs.ApplyT(b1, train)
+		b2Ts := xs.ApplyT(b2, train)
+		b3Ts := xs.ApplyT(b3, train)
+
+		b4Tmp := glMaxPool2D(xs, 3, 1, 1)
+		b4Ts := b4Tmp.ApplyT(b4, train)
+		b4Tmp.MustDrop()
+
+		res := ts.MustCat([]*ts.Tensor{b1Ts, b2Ts, b3Ts, b4Ts}, 1)
+		b1Ts.MustDrop()
+		b2Ts.MustDrop()
+		b3Ts.MustDrop()
+		b4Ts.MustDrop()
+
+		return res
+	})
+}
+
+// glTransformInput converts input normalized with ImageNet mean and standard
+// deviation to the (x - 0.5) / 0.5 normalization pretrained weights expect.
+func glTransformInput(xs *ts.Tensor) *ts.Tensor {
+	mean := []float64{0.485, 0.456, 0.406}
+	std := []float64{0.229, 0.224, 0.225}
+
+	channels := make([]*ts.Tensor, 3)
+	for i := 0; i < 3; i++ {
+		ch := xs.MustSelect(1, int64(i), false).MustUnsqueeze(1, true)
+		scaled := ch.MustMulScalar(ts.FloatScalar(std[i]/0.5), true)
+		channels[i] = scaled.MustAddScalar(ts.FloatScalar((mean[i]-0.5)/0.5), true)
+	}
+	res := ts.MustCat(channels, 1)
+	for _, ch := range channels {
+		ch.MustDrop()
+	}
+
+	return res
+}
+
+// NOTE. Auxiliary classifiers (aux1, aux2) are only used for training and
+// are not created. Their variables in pretrained weight files are ignored.
+func googlenet(p *nn.Path, nclasses int64) ts.ModuleT {
+	seq := nn.SeqT()
+
+	seq.AddFn(nn.NewFunc(glTransformInput))
+
+	seq.Add(convBn(p.Sub("conv1"), 3, 64, 7, 3, 2))
+	seq.AddFn(glMaxPool(3, 2))
+	seq.Add(convBn(p.Sub("conv2"), 64, 64, 1, 0, 1))
+	seq.Add(convBn(p.Sub("conv3"), 64, 192, 3, 1, 1))
+	seq.AddFn(glMaxPool(3, 2))
+
+	seq.Add(glInception(p.Sub("inception3a"), 192, 64, 96, 128, 16, 32, 32))
+	seq.Add(glInception(p.Sub("inception3b"), 256, 128, 128, 192, 32, 96, 64))
+	seq.AddFn(glMaxPool(3, 2))
+
+	seq.Add(glInception(p.Sub("inception4a"), 480, 192, 96, 208, 16, 48, 64))
+	seq.Add(glInception(p.Sub("inception4b"), 512, 160, 112, 224, 24, 64, 64))
+	seq.Add(glInception(p.Sub("inception4c"), 512, 128, 128, 256, 24, 64, 64))
+	seq.Add(glInception(p.Sub("inception4d"), 512, 112, 144, 288, 32, 64, 64))
+	seq.Add(glInception(p.Sub("inception4e"), 528, 256, 160, 320, 32, 128, 128))
+	seq.AddFn(glMaxPool(2, 2))
+
+	seq.Add(glInception(p.Sub("inception5a"), 832, 256, 160, 320, 32, 128, 128))
+	seq.Add(glInception(p.Sub("inception5b"), 832, 384, 192, 384, 48, 128, 128))
+
+	seq.AddFn(nn.NewFunc(func(xs *ts.Tensor) *ts.Tensor {
+		tmp := xs.MustAdaptiveAvgPool2d([]int64{1, 1}, false)
+		return tmp.MustFlatten(1, -1, true)
+	}))
+
+	if nclasses > 0 {
+		seq.AddFnT(nn.NewFuncT(func(xs *ts.Tensor, train bool) *ts.Tensor {
+			return ts.MustDropout(xs, 0.2, train)
+		}))
+		seq.Add(nn.NewLinear(p.Sub("fc"), 1024, nclasses, nn.DefaultLinearConfig()))
+	}
+
+	return seq
+}
+
+// GoogLeNet creates a GoogLeNet model. Input is expected to be normalized
+// with ImageNet mean and standard deviation.
+func GoogLeNet(p *nn.Path, nclasses int64) ts.ModuleT {
+	return googlenet(p, nclasses)
+}
+
+// GoogLeNetNoFinalLayer creates a GoogLeNet model without final fully
+// connected layer. It outputs pooled features of shape [batch, 1024].
+func GoogLeNetNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return googlenet(p, 0)
+}
@@ -0,0 +1,31 @@
+package vision_test
+
+import (
+	"testing"
+
+	"github.com/sugarme/gotch/vision"
+)
+
+func TestGoogLeNet(t *testing.T) {
+	tests := []modelTest{
+		{
+			name:     "googlenet",
+			model:    vision.GoogLeNet,
+			noFinal:  vision.GoogLeNetNoFinalLayer,
+			features: 1024,
+			vars: map[string][]int64{
+				"conv1.conv.weight":                 {64, 3, 7, 7},
+				"conv1.bn.running_mean":             {64},
+				"conv3.conv.weight":                 {192, 64, 3, 3},
+				"inception3a.branch1.conv.weight":   {64, 192, 1, 1},
+				"inception3a.branch2.1.conv.weight": {128, 96, 3, 3},
+				"inception3a.branch3.1.conv.weight": {32, 16, 3, 3},
+				"inception3a.branch4.1.conv.weight": {32, 192, 1, 1},
+				"inception5b.branch1.conv.weight":   {384, 832, 1, 1},
+				"fc.weight":                         {1000, 1024},
+			},
+		},
+	}
+
+	testModels(t, []int64{1, 3, 64, 64}, tests)
+}
@@ -1317,6 +1317,11 @@ func (in *ImageNet) Classes() []string {
 	return imagenetClasses
 }
 
+// ImageNetClasses returns labels of the 1000 ImageNet classes.
+func ImageNetClasses() []string {
+	return imagenetClasses
+}
+
 type TopItem struct {
 	Pvalue float64
 	Label  string
@@ -0,0 +1,195 @@
+package vision
+
+// MNASNet implementation.
+//
+// See "MnasNet: Platform-Aware Neural Architecture Search for Mobile", Tan et
+// al. 2018 https://arxiv.org/abs/1807.11626
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+// Paper suggests 0.9997 momentum (TensorFlow convention), i.e. 0.0003 in
+// PyTorch convention.
+const mnasnetBnMomentum = 0.0003
+
+func mnasnetBn(p *nn.Path, c int64) *nn.BatchNorm {
+	config := nn.DefaultBatchNormConfig()
+	config.Momentum = mnasnetBnMomentum
+
+	return nn.BatchNorm2D(p, c, config)
+}
+
+func mnasnetConv(p *nn.Path, cIn, cOut, ks, stride, g int64) *nn.Conv2D {
+	config := nn.DefaultConv2DConfig()
+	config.Stride = []int64{stride, stride}
+	config.Padding = []int64{ks / 2, ks / 2}
+	config.Groups = g
+	config.Bias = false
+
+	return nn.NewConv2D(p, cIn, cOut, ks, config)
+}
+
+func mnasnetRelu() ts.ModuleT {
+	return nn.NewFunc(func(xs *ts.Tensor) *ts.Tensor {
+		return xs.MustRelu(false)
+	})
+}
+
+// Inverted residual block: pointwise expansion, depthwise convolution and
+// linear pointwise projection.
+func mnasnetInvRes(p *nn.Path, cIn, cOut, ks, stride, expansion int64) ts.ModuleT {
+	lp := p.Sub("layers")
+	cMid := cIn * expansion
+
+	seq := nn.SeqT()
+	seq.Add(mnasnetConv(lp.Sub("0"), cIn, cMid, 1, 1, 1))
+	seq.Add(mnasnetBn(lp.Sub("1"), cMid))
+	seq.Add(mnasnetRelu())
+	seq.Add(mnasnetConv(lp.Sub("3"), cMid, cMid, ks, stride, cMid))
+	seq.Add(mnasnetBn(lp.Sub("4"), cMid))
+	seq.Add(mnasnetRelu())
+	seq.Add(mnasnetConv(lp.Sub("6"), cMid, cOut, 1, 1, 1))
+	seq.Add(mnasnetBn(lp.Sub("7"), cOut))
+
+	residual := cIn == cOut && stride == 1
+
+	return nn.NewFuncT(func(xs *ts.Tensor, train bool) *ts.Tensor {
+		ys := xs.ApplyT(seq, train)
+		if residual {
+			return ys.MustAdd(xs, true)
+		}
+		return ys
+	})
+}
+
+// Stack of inverted residual blocks. Only the first block changes stride and
+// number of channels.
+func mnasnetStack(p *nn.Path, cIn, cOut, ks, stride, expansion, repeats int64) ts.ModuleT {
+	seq := nn.SeqT()
+	seq.Add(mnasnetInvRes(p.Sub("0"), cIn, cOut, ks, stride, expansion))
+	for i := 1; i < int(repeats); i++ {
+		seq.Add(mnasnetInvRes(p.Sub(fmt.Sprint(i)), cOut, cOut, ks, 1, expansion))
+	}
+
+	return seq
+}
+
+// mnasnetDepths scales channels of the stem and the stacks by alpha, rounded
+// to a multiple of 8.
+func mnasnetDepths(alpha float64) []int64 {
+	depths := []int64{32, 16, 24, 40, 80, 96, 192, 320}
+	res := make([]int64, len(depths))
+	for i, d := range depths {
+		res[i] = makeDivisible(float64(d)*alpha, 8)
+	}
+
+	return res
+}
+
+// NOTE. MNASNet 0.5 pretrained weights are from the first version of the
+// model whose stem is not scaled (32 and 16 channels). This version is used
+// here so that the weights load for all widths.
+func mnasnet(p *nn.Path, nclasses int64, alpha float64) ts.ModuleT {
+	depths := mnasnetDepths(alpha)
+	if alpha == 0.5 {
+		depths[0], depths[1] = 32, 16
+	}
+
+	lp := p.Sub("layers")
+	layers := nn.SeqT()
+
+	// Stem: regular convolution then separable depthwise convolution.
+	layers.Add(mnasnetConv(lp.Sub("0"), 3, depths[0], 3, 2, 1))
+	layers.Add(mnasnetBn(lp.Sub("1"), depths[0]))
+	layers.Add(mnasnetRelu())
+	layers.Add(mnasnetConv(lp.Sub("3"), depths[0], depths[0], 3, 1, depths[0]))
+	layers.Add(mnasnetBn(lp.Sub("4"), depths[0]))
+	layers.Add(mnasnetRelu())
+	layers.Add(mnasnetConv(lp.Sub("6"), depths[0], depths[1], 1, 1, 1))
+	layers.Add(mnasnetBn(lp.Sub("7"), depths[1]))
+
+	// kernel size, stride, expansion ratio, repeats
+	stacks := [][]int64{
+		{3, 2, 3, 3},
+		{5, 2, 3, 3},
+		{5, 2, 6, 3},
+		{3, 1, 6, 2},
+		{5, 2, 6, 4},
+		{3, 1, 6, 1},
+	}
+	for i, s := range stacks {
+		layers.Add(mnasnetStack(lp.Sub(fmt.Sprint(i+8)), depths[i+1], depths[i+2], s[0], s[1], s[2], s[3]))
+	}
+
+	layers.Add(mnasnetConv(lp.Sub("14"), depths[7], 1280, 1, 1, 1))
+	layers.Add(mnasnetBn(lp.Sub("15"), 1280))
+	layers.Add(mnasnetRelu())
+
+	var fc *nn.Linear
+	if nclasses > 0 {
+		fc = nn.NewLinear(p.Sub("classifier").Sub("1"), 1280, nclasses, nn.DefaultLinearConfig())
+	}
+
+	return nn.NewFuncT(func(xs *ts.Tensor, train bool) *ts.Tensor {
+		tmp1 := xs.ApplyT(layers, train)
+		tmp2 := tmp1.MustMeanDim([]int64{2, 3}, false, gotch.Float, true)
+		if fc == nil {
+			return tmp2
+		}
+
+		tmp3 := ts.MustDropout(tmp2, 0.2, train)
+		tmp2.MustDrop()
+		res := tmp3.Apply(fc)
+		tmp3.MustDrop()
+		return res
+	})
+}
+
+// MNASNet0_5 creates a MNASNet model with depth multiplier 0.5.
+func MNASNet0_5(p *nn.Path, nclasses int64) ts.ModuleT {
+	return mnasnet(p, nclasses, 0.5)
+}
+
+// MNASNet0_5NoFinalLayer creates a MNASNet 0.5 model without final fully
+// connected layer. It outputs pooled features of shape [batch, 1280].
+func MNASNet0_5NoFinalLayer(p *nn.Path) ts.ModuleT {
+	return mnasnet(p, 0, 0.5)
+}
+
+// MNASNet0_75 creates a MNASNet model with depth multiplier 0.75.
+func MNASNet0_75(p *nn.Path, nclasses int64) ts.ModuleT {
+	return mnasnet(p, nclasses, 0.75)
+}
+
+// MNASNet0_75NoFinalLayer creates a MNASNet 0.75 model without final fully
+// connected layer.
+func MNASNet0_75NoFinalLayer(p *nn.Path) ts.ModuleT {
+	return mnasnet(p, 0, 0.75)
+}
+
+// MNASNet1_0 creates a MNASNet model with depth multiplier 1.0.
+func MNASNet1_0(p *nn.Path, nclasses int64) ts.ModuleT {
+	return mnasnet(p, nclasses, 1.0)
+}
+
+// MNASNet1_0NoFinalLayer creates a MNASNet 1.0 model without final fully
+// connected layer.
+func MNASNet1_0NoFinalLayer(p *nn.Path) ts.ModuleT {
+	return mnasnet(p, 0, 1.0)
+}
+
+// MNASNet1_3 creates a MNASNet model with depth multiplier 1.3.
+func MNASNet1_3(p *nn.Path, nclasses int64) ts.ModuleT {
+	return mnasnet(p, nclasses, 1.3)
+}
+
+// MNASNet1_3NoFinalLayer creates a MNASNet 1.3 model without final fully
+// connected layer.
+func MNASNet1_3NoFinalLayer(p *nn.Path) ts.ModuleT {
+	return mnasnet(p, 0, 1.3)
+}
@@ -0,0 +1,69 @@
+package vision_test
+
+import (
+	"testing"
+
+	"github.com/sugarme/gotch/vision"
+)
+
+func TestMNASNet(t *testing.T) {
+	tests := []modelTest{
+		{
+			// Stem is not scaled as in first version of the model.
+			name:     "mnasnet0_5",
+			model:    vision.MNASNet0_5,
+			noFinal:  vision.MNASNet0_5NoFinalLayer,
+			features: 1280,
+			vars: map[string][]int64{
+				"layers.0.weight":             {32, 3, 3, 3},
+				"layers.3.weight":             {32, 1, 3, 3},
+				"layers.6.weight":             {16, 32, 1, 1},
+				"layers.7.running_mean":       {16},
+				"layers.8.0.layers.0.weight":  {48, 16, 1, 1},
+				"layers.8.0.layers.6.weight":  {16, 48, 1, 1},
+				"layers.13.0.layers.6.weight": {160, 576, 1, 1},
+				"layers.14.weight":            {1280, 160, 1, 1},
+				"classifier.1.weight":         {1000, 1280},
+			},
+		},
+		{
+			name:     "mnasnet0_75",
+			model:    vision.MNASNet0_75,
+			noFinal:  vision.MNASNet0_75NoFinalLayer,
+			features: 1280,
+			vars: map[string][]int64{
+				"layers.0.weight":  {24, 3, 3, 3},
+				"layers.6.weight":  {16, 24, 1, 1},
+				"layers.14.weight": {1280, 240, 1, 1},
+			},
+		},
+		{
+			name:     "mnasnet1_0",
+			model:    vision.MNASNet1_0,
+			noFinal:  vision.MNASNet1_0NoFinalLayer,
+			features: 1280,
+			vars: map[string][]int64{
+				"layers.0.weight":            {32, 3, 3, 3},
+				"layers.6.weight":            {16, 32, 1, 1},
+				"layers.8.0.layers.0.weight": {48, 16, 1, 1},
+				"layers.8.0.layers.3.weight": {48, 1, 3, 3},
+				"layers.9.0.layers.3.weight": {72, 1, 5, 5},
+				"layers.14.weight":           {1280, 320, 1, 1},
+				"classifier.1.weight":        {1000, 1280},
+			},
+		},
+		{
+			name:     "mnasnet1_3",
+			model:    vision.MNASNet1_3,
+			noFinal:  vision.MNASNet1_3NoFinalLayer,
+			features: 1280,
+			vars: map[string][]int64{
+				"layers.0.weight":  {40, 3, 3, 3},
+				"layers.6.weight":  {24, 40, 1, 1},
+				"layers.14.weight": {1280, 416, 1, 1},
+			},
+		},
+	}
+
+	testModels(t, []int64{1, 3, 64, 64}, tests)
+}
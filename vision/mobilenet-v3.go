@@ -0,0 +1,187 @@
+package vision
+
+// MobileNet V3 implementation.
+//
+// See "Searching for MobileNetV3", Howard et al. 2019
+// https://arxiv.org/abs/1905.02244
+
+import (
+	"fmt"
+
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+// Activation of MobileNet V3 layers.
+const (
+	mbv3None      = ""
+	mbv3ReLU      = "RE"
+	mbv3HardSwish = "HS"
+)
+
+// Conv2D + BatchNorm2D + optional ReLU or hard-swish activation.
+func mbv3ConvBn(p *nn.Path, cIn, cOut, ks, stride, g int64, activation string) ts.ModuleT {
+	config := nn.DefaultConv2DConfig()
+	config.Stride = []int64{stride, stride}
+	pad := (ks - 1) / 2
+	config.Padding = []int64{pad, pad}
+	config.Groups = g
+	config.Bias = false
+
+	bnConfig := nn.DefaultBatchNormConfig()
+	bnConfig.Eps = 0.001
+	bnConfig.Momentum = 0.01
+
+	seq := nn.SeqT()
+	seq.Add(nn.NewConv2D(p.Sub("0"), cIn, cOut, ks, config))
+	seq.Add(nn.BatchNorm2D(p.Sub("1"), cOut, bnConfig))
+
+	switch activation {
+	case mbv3ReLU:
+		seq.AddFn(nn.NewFunc(func(xs *ts.Tensor) *ts.Tensor {
+			return xs.MustRelu(false)
+		}))
+	case mbv3HardSwish:
+		seq.AddFn(nn.NewFunc(func(xs *ts.Tensor) *ts.Tensor {
+			return xs.MustHardswish(false)
+		}))
+	}
+
+	return seq
+}
+
+// mbv3BlockConfig is configuration of a MobileNet V3 inverted residual block.
+type mbv3BlockConfig struct {
+	cIn        int64
+	kernel     int64
+	cExpanded  int64
+	cOut       int64
+	useSE      bool
+	activation string
+	stride     int64
+}
+
+// Inverted residual block with optional squeeze-excitation.
+func mbv3Block(p *nn.Path, c mbv3BlockConfig) ts.ModuleT {
+	bp := p.Sub("block")
+	seq := nn.SeqT()
+
+	id := 0
+	if c.cExpanded != c.cIn {
+		seq.Add(mbv3ConvBn(bp.Sub(fmt.Sprint(id)), c.cIn, c.cExpanded, 1, 1, 1, c.activation))
+		id += 1
+	}
+
+	seq.Add(mbv3ConvBn(bp.Sub(fmt.Sprint(id)), c.cExpanded, c.cExpanded, c.kernel, c.stride, c.cExpanded, c.activation))
+	id += 1
+
+	if c.useSE {
+		cSqueeze := makeDivisible(float64(c.cExpanded/4), 8)
+		seq.Add(squeezeExcitation(bp.Sub(fmt.Sprint(id)), c.cExpanded, cSqueeze, true))
+		id += 1
+	}
+
+	seq.Add(mbv3ConvBn(bp.Sub(fmt.Sprint(id)), c.cExpanded, c.cOut, 1, 1, 1, mbv3None))
+
+	return nn.NewFuncT(func(xs *ts.Tensor, train bool) *ts.Tensor {
+		ys := xs.ApplyT(seq, train)
+		if c.stride == 1 && c.cIn == c.cOut {
+			return ys.MustAdd(xs, true)
+		}
+		return ys
+	})
+}
+
+var mobilenetV3LargeSettings = []mbv3BlockConfig{
+	{16, 3, 16, 16, false, mbv3ReLU, 1},
+	{16, 3, 64, 24, false, mbv3ReLU, 2},
+	{24, 3, 72, 24, false, mbv3ReLU, 1},
+	{24, 5, 72, 40, true, mbv3ReLU, 2},
+	{40, 5, 120, 40, true, mbv3ReLU, 1},
+	{40, 5, 120, 40, true, mbv3ReLU, 1},
+	{40, 3, 240, 80, false, mbv3HardSwish, 2},
+	{80, 3, 200, 80, false, mbv3HardSwish, 1},
+	{80, 3, 184, 80, false, mbv3HardSwish, 1},
+	{80, 3, 184, 80, false, mbv3HardSwish, 1},
+	{80, 3, 480, 112, true, mbv3HardSwish, 1},
+	{112, 3, 672, 112, true, mbv3HardSwish, 1},
+	{112, 5, 672, 160, true, mbv3HardSwish, 2},
+	{160, 5, 960, 160, true, mbv3HardSwish, 1},
+	{160, 5, 960, 160, true, mbv3HardSwish, 1},
+}
+
+var mobilenetV3SmallSettings = []mbv3BlockConfig{
+	{16, 3, 16, 16, true, mbv3ReLU, 2},
+	{16, 3, 72, 24, false, mbv3ReLU, 2},
+	{24, 3, 88, 24, false, mbv3ReLU, 1},
+	{24, 5, 96, 40, true, mbv3HardSwish, 2},
+	{40, 5, 240, 40, true, mbv3HardSwish, 1},
+	{40, 5, 240, 40, true, mbv3HardSwish, 1},
+	{40, 5, 120, 48, true, mbv3HardSwish, 1},
+	{48, 5, 144, 48, true, mbv3HardSwish, 1},
+	{48, 5, 288, 96, true, mbv3HardSwish, 2},
+	{96, 5, 576, 96, true, mbv3HardSwish, 1},
+	{96, 5, 576, 96, true, mbv3HardSwish, 1},
+}
+
+func mobilenetV3(p *nn.Path, nclasses int64, settings []mbv3BlockConfig, lastChannel int64) ts.ModuleT {
+	fp := p.Sub("features")
+	features := nn.SeqT()
+
+	features.Add(mbv3ConvBn(fp.Sub("0"), 3, settings[0].cIn, 3, 2, 1, mbv3HardSwish))
+	for i, c := range settings {
+		features.Add(mbv3Block(fp.Sub(fmt.Sprint(i+1)), c))
+	}
+	cIn := settings[len(settings)-1].cOut
+	cLast := 6 * cIn
+	features.Add(mbv3ConvBn(fp.Sub(fmt.Sprint(len(settings)+1)), cIn, cLast, 1, 1, 1, mbv3HardSwish))
+
+	var classifier *nn.SequentialT
+	if nclasses > 0 {
+		cp := p.Sub("classifier")
+		classifier = nn.SeqT()
+		classifier.Add(nn.NewLinear(cp.Sub("0"), cLast, lastChannel, nn.DefaultLinearConfig()))
+		classifier.AddFnT(nn.NewFuncT(func(xs *ts.Tensor, train bool) *ts.Tensor {
+			tmp := xs.MustHardswish(false)
+			res := ts.MustDropout(tmp, 0.2, train)
+			tmp.MustDrop()
+			return res
+		}))
+		classifier.Add(nn.NewLinear(cp.Sub("3"), lastChannel, nclasses, nn.DefaultLinearConfig()))
+	}
+
+	return nn.NewFuncT(func(xs *ts.Tensor, train bool) *ts.Tensor {
+		tmp1 := xs.ApplyT(features, train)
+		tmp2 := tmp1.MustAdaptiveAvgPool2d([]int64{1, 1}, true)
+		tmp3 := tmp2.MustFlatten(1, -1, true)
+		if classifier == nil {
+			return tmp3
+		}
+
+		res := tmp3.ApplyT(classifier, train)
+		tmp3.MustDrop()
+		return res
+	})
+}
+
+// MobileNetV3Large creates a MobileNet V3 Large model.
+func MobileNetV3Large(p *nn.Path, nclasses int64) ts.ModuleT {
+	return mobilenetV3(p, nclasses, mobilenetV3LargeSettings, 1280)
+}
+
+// MobileNetV3LargeNoFinalLayer creates a MobileNet V3 Large model without
+// classifier. It outputs pooled features of shape [batch, 960].
+func MobileNetV3LargeNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return mobilenetV3(p, 0, mobilenetV3LargeSettings, 1280)
+}
+
+// MobileNetV3Small creates a MobileNet V3 Small model.
+func MobileNetV3Small(p *nn.Path, nclasses int64) ts.ModuleT {
+	return mobilenetV3(p, nclasses, mobilenetV3SmallSettings, 1024)
+}
+
+// MobileNetV3SmallNoFinalLayer creates a MobileNet V3 Small model without
+// classifier. It outputs pooled features of shape [batch, 576].
+func MobileNetV3SmallNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return mobilenetV3(p, 0, mobilenetV3SmallSettings, 1024)
+}
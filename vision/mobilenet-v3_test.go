@@ -0,0 +1,48 @@
+package vision_test
+
+import (
+	"testing"
+
+	"github.com/sugarme/gotch/vision"
+)
+
+func TestMobileNetV3(t *testing.T) {
+	tests := []modelTest{
+		{
+			name:     "mobilenet_v3_large",
+			model:    vision.MobileNetV3Large,
+			noFinal:  vision.MobileNetV3LargeNoFinalLayer,
+			features: 960,
+			vars: map[string][]int64{
+				"features.0.0.weight":           {16, 3, 3, 3},
+				"features.0.1.running_var":      {16},
+				"features.1.block.0.0.weight":   {16, 1, 3, 3},
+				"features.1.block.1.0.weight":   {16, 16, 1, 1},
+				"features.4.block.0.0.weight":   {72, 24, 1, 1},
+				"features.4.block.1.0.weight":   {72, 1, 5, 5},
+				"features.4.block.2.fc1.weight": {24, 72, 1, 1},
+				"features.4.block.2.fc2.weight": {72, 24, 1, 1},
+				"features.4.block.3.0.weight":   {40, 72, 1, 1},
+				"features.16.0.weight":          {960, 160, 1, 1},
+				"classifier.0.weight":           {1280, 960},
+				"classifier.3.weight":           {1000, 1280},
+			},
+		},
+		{
+			name:     "mobilenet_v3_small",
+			model:    vision.MobileNetV3Small,
+			noFinal:  vision.MobileNetV3SmallNoFinalLayer,
+			features: 576,
+			vars: map[string][]int64{
+				"features.1.block.0.0.weight":   {16, 1, 3, 3},
+				"features.1.block.1.fc1.weight": {8, 16, 1, 1},
+				"features.1.block.2.0.weight":   {16, 16, 1, 1},
+				"features.12.0.weight":          {576, 96, 1, 1},
+				"classifier.0.weight":           {1024, 576},
+				"classifier.3.weight":           {1000, 1024},
+			},
+		},
+	}
+
+	testModels(t, []int64{1, 3, 64, 64}, tests)
+}
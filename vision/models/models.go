@@ -0,0 +1,235 @@
+// Package models provides a registry of pretrained vision models which ties
+// together model builders, pretrained weights and input preprocessing.
+//
+// Example:
+//
+//	m, err := models.Load("resnet50", gotch.CPU)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	img, err := m.LoadImage("koala.jpg")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	logits := m.Module.ForwardT(img.MustUnsqueeze(0, true), false)
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/pickle"
+	"github.com/sugarme/gotch/ts"
+	"github.com/sugarme/gotch/vision"
+)
+
+// Builder creates a model with variables at given path.
+type Builder func(p *nn.Path, nclasses int64) ts.ModuleT
+
+// Entry describes a pretrained model.
+type Entry struct {
+	Builder    Builder
+	URL        string // URL of pretrained weights
+	Checksum   string // SHA-256 hex digest (or its prefix) of weights file. Empty to skip verification.
+	NumClasses int64
+	InputSize  []int64   // expected input shape [channels, height, width]
+	Mean       []float64 // per channel normalization mean
+	Std        []float64 // per channel normalization standard deviation
+	Labels     []string  // class labels
+}
+
+var registryMu sync.RWMutex
+
+// Register adds a model entry to the registry. An existing entry with the
+// same name is replaced.
+func Register(name string, e *Entry) error {
+	if e == nil || e.Builder == nil {
+		err := fmt.Errorf("Register() failed: entry %q has no model builder", name)
+		return err
+	}
+	if e.URL == "" {
+		err := fmt.Errorf("Register() failed: entry %q has no weights URL", name)
+		return err
+	}
+
+	registryMu.Lock()
+	registry[name] = e
+	registryMu.Unlock()
+
+	return nil
+}
+
+// Lookup returns registered entry of given name.
+func Lookup(name string) (*Entry, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	e, ok := registry[name]
+	return e, ok
+}
+
+// Names returns sorted names of registered models.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Model is a pretrained model ready for inference with its preprocessing
+// settings.
+type Model struct {
+	Name      string
+	Module    ts.ModuleT
+	VarStore  *nn.VarStore
+	InputSize []int64
+	Mean      []float64
+	Std       []float64
+	Labels    []string
+}
+
+type LoadOptions struct {
+	Offline bool
+}
+
+type LoadOption func(*LoadOptions)
+
+func defaultLoadOptions() *LoadOptions {
+	return &LoadOptions{
+		Offline: gotch.Offline,
+	}
+}
+
+// WithOffline resolves weights only from local cache at `gotch.CachedDir`
+// and never downloads. Default=`gotch.Offline` (environment variable
+// "GOTCH_OFFLINE").
+func WithOffline(val bool) LoadOption {
+	return func(o *LoadOptions) {
+		o.Offline = val
+	}
+}
+
+// Load builds the registered model of given name on device and loads its
+// pretrained weights. Weights are downloaded to `gotch.CachedDir` if not
+// cached yet and verified against the entry checksum.
+func Load(name string, device gotch.Device, opts ...LoadOption) (*Model, error) {
+	options := defaultLoadOptions()
+	for _, o := range opts {
+		o(options)
+	}
+
+	e, ok := Lookup(name)
+	if !ok {
+		err := fmt.Errorf("Load() failed: unknown model %q", name)
+		return nil, err
+	}
+
+	weightsFile, err := resolveWeights(e, options.Offline)
+	if err != nil {
+		err = fmt.Errorf("Load() failed: model %q: %w", name, err)
+		return nil, err
+	}
+
+	vs := nn.NewVarStore(device)
+	module := e.Builder(vs.Root(), e.NumClasses)
+
+	c, err := pickle.OpenCheckpoint(weightsFile)
+	if err != nil {
+		vs.Destroy()
+		err = fmt.Errorf("Load() failed: model %q: %w", name, err)
+		return nil, err
+	}
+	defer c.Close()
+
+	if err := vs.LoadFrom(c); err != nil {
+		vs.Destroy()
+		err = fmt.Errorf("Load() failed: model %q: %w", name, err)
+		return nil, err
+	}
+	if err := vs.Freeze(); err != nil {
+		vs.Destroy()
+		err = fmt.Errorf("Load() failed: model %q: %w", name, err)
+		return nil, err
+	}
+
+	return &Model{
+		Name:      name,
+		Module:    module,
+		VarStore:  vs,
+		InputSize: e.InputSize,
+		Mean:      e.Mean,
+		Std:       e.Std,
+		Labels:    e.Labels,
+	}, nil
+}
+
+// MustLoad loads a pretrained model. It panics if error occurred.
+func MustLoad(name string, device gotch.Device, opts ...LoadOption) *Model {
+	m, err := Load(name, device, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+// resolveWeights returns path to verified weights file of an entry in cache,
+// downloading it if needed.
+func resolveWeights(e *Entry, offline bool) (string, error) {
+	checksum := e.Checksum
+	if checksum == "" {
+		checksum = gotch.NoChecksum
+	}
+
+	return gotch.CachedPathWithOffline(e.URL, checksum, offline)
+}
+
+// LoadImage loads an image file, resizes it to the model input size (center
+// crop preserving aspect ratio) and normalizes it with model mean and
+// standard deviation. It returns a float tensor of shape [channels, height,
+// width].
+func (m *Model) LoadImage(path string) (*ts.Tensor, error) {
+	img, err := vision.LoadAndResize(path, m.InputSize[2], m.InputSize[1])
+	if err != nil {
+		err = fmt.Errorf("Model.LoadImage() failed: %w", err)
+		return nil, err
+	}
+
+	return m.Normalize(img, true)
+}
+
+// Normalize converts an uint8 image tensor of shape [channels, height, width]
+// to float, scales it to [0, 1] and normalizes it with model mean and
+// standard deviation.
+func (m *Model) Normalize(img *ts.Tensor, del bool) (*ts.Tensor, error) {
+	device := img.MustDevice()
+	c := int64(len(m.Mean))
+	mean := ts.MustOfSlice(m.Mean).MustView([]int64{c, 1, 1}, true).MustTotype(gotch.Float, true).MustTo(device, true)
+	defer mean.MustDrop()
+	std := ts.MustOfSlice(m.Std).MustView([]int64{c, 1, 1}, true).MustTotype(gotch.Float, true).MustTo(device, true)
+	defer std.MustDrop()
+
+	x, err := img.Totype(gotch.Float, del)
+	if err != nil {
+		return nil, err
+	}
+	x, err = x.DivScalar(ts.FloatScalar(255.0), true)
+	if err != nil {
+		return nil, err
+	}
+	x, err = x.Sub(mean, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return x.Div(std, true)
+}
@@ -0,0 +1,140 @@
+package models_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/pickle"
+	"github.com/sugarme/gotch/ts"
+	"github.com/sugarme/gotch/vision/models"
+)
+
+func tinyBuilder(p *nn.Path, nclasses int64) ts.ModuleT {
+	return nn.NewLinear(p.Sub("fc"), 4, nclasses, nn.DefaultLinearConfig())
+}
+
+// saveTinyWeights saves weights of tiny model with all values 0.5 and
+// returns SHA-256 hex digest of the file.
+func saveTinyWeights(t *testing.T, filename string) string {
+	vs := nn.NewVarStore(gotch.CPU)
+	tinyBuilder(vs.Root(), 2)
+	for _, x := range vs.Variables() {
+		ts.NoGrad(func() {
+			x.MustFill_(ts.FloatScalar(0.5))
+		})
+	}
+	if err := pickle.Save(vs, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func setCachedDir(t *testing.T) string {
+	dir := t.TempDir()
+	cachedDir := gotch.CachedDir
+	gotch.CachedDir = dir
+	t.Cleanup(func() { gotch.CachedDir = cachedDir })
+
+	return dir
+}
+
+func register(t *testing.T, name, url, checksum string) {
+	err := models.Register(name, &models.Entry{
+		Builder:    tinyBuilder,
+		URL:        url,
+		Checksum:   checksum,
+		NumClasses: 2,
+		InputSize:  []int64{3, 8, 8},
+		Mean:       []float64{0.5, 0.5, 0.5},
+		Std:        []float64{0.5, 0.5, 0.5},
+		Labels:     []string{"cat", "dog"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkTinyModel(t *testing.T, m *models.Model) {
+	if !reflect.DeepEqual(m.Labels, []string{"cat", "dog"}) || !reflect.DeepEqual(m.InputSize, []int64{3, 8, 8}) {
+		t.Errorf("Unexpected model settings: labels %v, input size %v", m.Labels, m.InputSize)
+	}
+	for name, x := range m.VarStore.Variables() {
+		for _, v := range x.Float64Values() {
+			if v != 0.5 {
+				t.Fatalf("Want loaded %q values 0.5, got %v", name, v)
+			}
+		}
+	}
+}
+
+func TestLoadOffline(t *testing.T) {
+	dir := setCachedDir(t)
+	digest := saveTinyWeights(t, filepath.Join(dir, "tiny-offline.pth"))
+
+	register(t, "tiny_offline", "http://localhost/models/tiny-offline.pth", digest[:8])
+	m, err := models.Load("tiny_offline", gotch.CPU, models.WithOffline(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTinyModel(t, m)
+
+	// Not in cache
+	register(t, "tiny_missing", "http://localhost/models/tiny-missing.pth", "")
+	if _, err := models.Load("tiny_missing", gotch.CPU, models.WithOffline(true)); err == nil {
+		t.Errorf("Expect error loading uncached weights in offline mode")
+	}
+
+	// Checksum mismatched
+	register(t, "tiny_corrupted", "http://localhost/models/tiny-offline.pth", "00000000")
+	if _, err := models.Load("tiny_corrupted", gotch.CPU, models.WithOffline(true)); err == nil {
+		t.Errorf("Expect error loading weights of mismatched checksum")
+	}
+}
+
+func TestLoadDownload(t *testing.T) {
+	srcDir := t.TempDir()
+	digest := saveTinyWeights(t, filepath.Join(srcDir, "tiny-download.pth"))
+	server := httptest.NewServer(http.FileServer(http.Dir(srcDir)))
+	defer server.Close()
+
+	dir := setCachedDir(t)
+	register(t, "tiny_download", server.URL+"/tiny-download.pth", digest)
+	m, err := models.Load("tiny_download", gotch.CPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTinyModel(t, m)
+
+	if _, err := os.Stat(filepath.Join(dir, "tiny-download.pth")); err != nil {
+		t.Errorf("Expect downloaded weights in cache: %v", err)
+	}
+}
+
+func TestNames(t *testing.T) {
+	names := models.Names()
+	for _, name := range []string{"resnet18", "mobilenet_v3_large", "vit_b_16"} {
+		e, ok := models.Lookup(name)
+		if !ok {
+			t.Errorf("Want %q in registry %v", name, names)
+			continue
+		}
+		if e.URL != gotch.ModelUrls[name] || len(e.Labels) != int(e.NumClasses) {
+			t.Errorf("Unexpected %q entry: URL %q, %v labels", name, e.URL, len(e.Labels))
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+	"github.com/sugarme/gotch/vision"
+)
+
+var (
+	imagenetMean = []float64{0.485, 0.456, 0.406}
+	imagenetStd  = []float64{0.229, 0.224, 0.225}
+
+	// InceptionV3 is built without input transform of torchvision so that
+	// input is expected in [-1, 1] as for the original TensorFlow weights.
+	inceptionMean = []float64{0.5, 0.5, 0.5}
+	inceptionStd  = []float64{0.5, 0.5, 0.5}
+)
+
+// imagenetEntry creates an entry of a model pretrained on ImageNet with
+// weights at `gotch.ModelUrls[name]`. Checksum is the SHA-256 prefix in
+// torchvision weights filename.
+func imagenetEntry(name string, builder Builder, checksum string, size int64, mean, std []float64) *Entry {
+	return &Entry{
+		Builder:    builder,
+		URL:        gotch.ModelUrls[name],
+		Checksum:   checksum,
+		NumClasses: 1000,
+		InputSize:  []int64{3, size, size},
+		Mean:       mean,
+		Std:        std,
+		Labels:     vision.ImageNetClasses(),
+	}
+}
+
+// NOTE. DenseNet and EfficientNet are not registered as variable names of
+// their builders differ from torchvision pretrained weights.
+var registry = map[string]*Entry{
+	"alexnet":             imagenetEntry("alexnet", vision.AlexNet, "7be5be79", 224, imagenetMean, imagenetStd),
+	"convnext_tiny":       imagenetEntry("convnext_tiny", vision.ConvNeXtTiny, "983f1562", 224, imagenetMean, imagenetStd),
+	"convnext_small":      imagenetEntry("convnext_small", vision.ConvNeXtSmall, "0c510722", 224, imagenetMean, imagenetStd),
+	"convnext_base":       imagenetEntry("convnext_base", vision.ConvNeXtBase, "6075fbad", 224, imagenetMean, imagenetStd),
+	"convnext_large":      imagenetEntry("convnext_large", vision.ConvNeXtLarge, "ea097f82", 224, imagenetMean, imagenetStd),
+	"googlenet":           imagenetEntry("googlenet", vision.GoogLeNet, "1378be20", 224, imagenetMean, imagenetStd),
+	"inception_v3_google": imagenetEntry("inception_v3_google", vision.InceptionV3, "0cc3c7bd", 299, inceptionMean, inceptionStd),
+	"mnasnet0_5":          imagenetEntry("mnasnet0_5", vision.MNASNet0_5, "3ffadce67e", 224, imagenetMean, imagenetStd),
+	"mnasnet1_0":          imagenetEntry("mnasnet1_0", vision.MNASNet1_0, "f206786ef8", 224, imagenetMean, imagenetStd),
+	"mobilenet_v2":        imagenetEntry("mobilenet_v2", vision.MobileNetV2, "b0353104", 224, imagenetMean, imagenetStd),
+	"mobilenet_v3_large":  imagenetEntry("mobilenet_v3_large", vision.MobileNetV3Large, "8738ca79", 224, imagenetMean, imagenetStd),
+	"mobilenet_v3_small":  imagenetEntry("mobilenet_v3_small", vision.MobileNetV3Small, "047dcff4", 224, imagenetMean, imagenetStd),
+	"regnet_y_400mf":      imagenetEntry("regnet_y_400mf", vision.RegNetY400MF, "c65dace8", 224, imagenetMean, imagenetStd),
+	"regnet_y_800mf":      imagenetEntry("regnet_y_800mf", vision.RegNetY800MF, "1b27b58c", 224, imagenetMean, imagenetStd),
+	"regnet_y_1_6gf":      imagenetEntry("regnet_y_1_6gf", vision.RegNetY1_6GF, "b11a554e", 224, imagenetMean, imagenetStd),
+	"regnet_y_3_2gf":      imagenetEntry("regnet_y_3_2gf", vision.RegNetY3_2GF, "b5a9779c", 224, imagenetMean, imagenetStd),
+	"regnet_y_8gf":        imagenetEntry("regnet_y_8gf", vision.RegNetY8GF, "d0d0e4a8", 224, imagenetMean, imagenetStd),
+	"regnet_y_16gf":       imagenetEntry("regnet_y_16gf", vision.RegNetY16GF, "9e6ed7dd", 224, imagenetMean, imagenetStd),
+	"regnet_y_32gf":       imagenetEntry("regnet_y_32gf", vision.RegNetY32GF, "4dee3f7a", 224, imagenetMean, imagenetStd),
+	"regnet_x_400mf":      imagenetEntry("regnet_x_400mf", vision.RegNetX400MF, "adf1edd5", 224, imagenetMean, imagenetStd),
+	"regnet_x_800mf":      imagenetEntry("regnet_x_800mf", vision.RegNetX800MF, "ad17e45c", 224, imagenetMean, imagenetStd),
+	"regnet_x_1_6gf":      imagenetEntry("regnet_x_1_6gf", vision.RegNetX1_6GF, "e3633e7f", 224, imagenetMean, imagenetStd),
+	"regnet_x_3_2gf":      imagenetEntry("regnet_x_3_2gf", vision.RegNetX3_2GF, "f342aeae", 224, imagenetMean, imagenetStd),
+	"regnet_x_8gf":        imagenetEntry("regnet_x_8gf", vision.RegNetX8GF, "03ceed89", 224, imagenetMean, imagenetStd),
+	"regnet_x_16gf":       imagenetEntry("regnet_x_16gf", vision.RegNetX16GF, "2007eb11", 224, imagenetMean, imagenetStd),
+	"regnet_x_32gf":       imagenetEntry("regnet_x_32gf", vision.RegNetX32GF, "9d47f8d0", 224, imagenetMean, imagenetStd),
+	"resnet18": imagenetEntry("resnet18", func(p *nn.Path, nclasses int64) ts.ModuleT {
+		return vision.ResNet18(p, nclasses)
+	}, "f37072fd", 224, imagenetMean, imagenetStd),
+	"resnet34": imagenetEntry("resnet34", func(p *nn.Path, nclasses int64) ts.ModuleT {
+		return vision.ResNet34(p, nclasses)
+	}, "b627a593", 224, imagenetMean, imagenetStd),
+	"resnet50":         imagenetEntry("resnet50", vision.ResNet50, "0676ba61", 224, imagenetMean, imagenetStd),
+	"resnet101":        imagenetEntry("resnet101", vision.ResNet101, "63fe2227", 224, imagenetMean, imagenetStd),
+	"resnet152":        imagenetEntry("resnet152", vision.ResNet152, "394f9c45", 224, imagenetMean, imagenetStd),
+	"resnext50_32x4d":  imagenetEntry("resnext50_32x4d", vision.ResNeXt50_32x4d, "7cdf4587", 224, imagenetMean, imagenetStd),
+	"resnext101_32x8d": imagenetEntry("resnext101_32x8d", vision.ResNeXt101_32x8d, "8ba56ff5", 224, imagenetMean, imagenetStd),
+	"wide_resnet50_2":  imagenetEntry("wide_resnet50_2", vision.WideResNet50_2, "95faca4d", 224, imagenetMean, imagenetStd),
+	"wide_resnet101_2": imagenetEntry("wide_resnet101_2", vision.WideResNet101_2, "32ee1156", 224, imagenetMean, imagenetStd),
+	"squeezenet1_0":    imagenetEntry("squeezenet1_0", vision.SqueezeNetV1_0, "b66bff10", 224, imagenetMean, imagenetStd),
+	"squeezenet1_1":    imagenetEntry("squeezenet1_1", vision.SqueezeNetV1_1, "b8a52dc0", 224, imagenetMean, imagenetStd),
+	"vgg11": imagenetEntry("vgg11", func(p *nn.Path, nclasses int64) ts.ModuleT {
+		return vision.VGG11(p, nclasses)
+	}, "8a719046", 224, imagenetMean, imagenetStd),
+	"vgg11_bn": imagenetEntry("vgg11_bn", func(p *nn.Path, nclasses int64) ts.ModuleT {
+		return vision.VGG11BN(p, nclasses)
+	}, "6002323d", 224, imagenetMean, imagenetStd),
+	"vgg13": imagenetEntry("vgg13", func(p *nn.Path, nclasses int64) ts.ModuleT {
+		return vision.VGG13(p, nclasses)
+	}, "19584684", 224, imagenetMean, imagenetStd),
+	"vgg13_bn": imagenetEntry("vgg13_bn", func(p *nn.Path, nclasses int64) ts.ModuleT {
+		return vision.VGG13BN(p, nclasses)
+	}, "abd245e5", 224, imagenetMean, imagenetStd),
+	"vgg16": imagenetEntry("vgg16", func(p *nn.Path, nclasses int64) ts.ModuleT {
+		return vision.VGG16(p, nclasses)
+	}, "397923af", 224, imagenetMean, imagenetStd),
+	"vgg16_bn": imagenetEntry("vgg16_bn", func(p *nn.Path, nclasses int64) ts.ModuleT {
+		return vision.VGG16BN(p, nclasses)
+	}, "6c64b313", 224, imagenetMean, imagenetStd),
+	"vgg19": imagenetEntry("vgg19", func(p *nn.Path, nclasses int64) ts.ModuleT {
+		return vision.VGG19(p, nclasses)
+	}, "dcbb9e9d", 224, imagenetMean, imagenetStd),
+	"vgg19_bn": imagenetEntry("vgg19_bn", func(p *nn.Path, nclasses int64) ts.ModuleT {
+		return vision.VGG19BN(p, nclasses)
+	}, "c79401a0", 224, imagenetMean, imagenetStd),
+	"vit_b_16": imagenetEntry("vit_b_16", vision.ViTB16, "c867db91", 224, imagenetMean, imagenetStd),
+	"vit_b_32": imagenetEntry("vit_b_32", vision.ViTB32, "d86f8d99", 224, imagenetMean, imagenetStd),
+	"vit_l_16": imagenetEntry("vit_l_16", vision.ViTL16, "852ce7e3", 224, imagenetMean, imagenetStd),
+	"vit_l_32": imagenetEntry("vit_l_32", vision.ViTL32, "c7638314", 224, imagenetMean, imagenetStd),
+}
@@ -0,0 +1,391 @@
+package vision
+
+// RegNet implementation.
+//
+// See "Designing Network Design Spaces", Radosavovic et al. 2020
+// https://arxiv.org/abs/2003.13678
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+// regnetParams are parameters of a RegNet design space: depth, initial width
+// w0, width slope wa, width multiplier wm, group width and squeeze-excitation
+// ratio (0 for RegNetX).
+type regnetParams struct {
+	depth      int64
+	w0         int64
+	wa         float64
+	wm         float64
+	groupWidth int64
+	seRatio    float64
+}
+
+type regnetStage struct {
+	width      int64
+	depth      int64
+	groupWidth int64
+}
+
+// makeDivisible rounds v to nearest multiple of divisor, not going down by
+// more than 10%.
+func makeDivisible(v float64, divisor int64) int64 {
+	newV := int64(v+float64(divisor)/2) / divisor * divisor
+	if newV < divisor {
+		newV = divisor
+	}
+	if float64(newV) < 0.9*v {
+		newV += divisor
+	}
+
+	return newV
+}
+
+// stages generates per-stage widths and depths from quantized linear
+// function of block widths.
+func (rp *regnetParams) stages() []regnetStage {
+	const quant = 8
+
+	// Per-block widths
+	widths := make([]int64, rp.depth)
+	for i := range widths {
+		wCont := float64(i)*rp.wa + float64(rp.w0)
+		capacity := math.RoundToEven(math.Log(wCont/float64(rp.w0)) / math.Log(rp.wm))
+		widths[i] = int64(math.RoundToEven(float64(rp.w0)*math.Pow(rp.wm, capacity)/quant)) * quant
+	}
+
+	// Consecutive blocks of same width form a stage.
+	var stages []regnetStage
+	for _, w := range widths {
+		if len(stages) > 0 && stages[len(stages)-1].width == w {
+			stages[len(stages)-1].depth += 1
+			continue
+		}
+		stages = append(stages, regnetStage{width: w, depth: 1})
+	}
+
+	// Adjust compatibility of stage widths and group widths.
+	for i, s := range stages {
+		g := rp.groupWidth
+		if s.width < g {
+			g = s.width
+		}
+		stages[i].groupWidth = g
+		stages[i].width = makeDivisible(float64(s.width), g)
+	}
+
+	return stages
+}
+
+// conv2dNormActivation creates Conv2D (without bias) + BatchNorm2D with
+// optional ReLU.
+func conv2dNormActivation(p *nn.Path, cIn, cOut, ks, stride, groups int64, relu bool) ts.ModuleT {
+	config := nn.DefaultConv2DConfig()
+	config.Stride = []int64{stride, stride}
+	pad := (ks - 1) / 2
+	config.Padding = []int64{pad, pad}
+	config.Groups = groups
+	config.Bias = false
+
+	seq := nn.SeqT()
+	seq.Add(nn.NewConv2D(p.Sub("0"), cIn, cOut, ks, config))
+	seq.Add(nn.BatchNorm2D(p.Sub("1"), cOut, nn.DefaultBatchNormConfig()))
+	if relu {
+		seq.AddFn(nn.NewFunc(func(xs *ts.Tensor) *ts.Tensor {
+			return xs.MustRelu(false)
+		}))
+	}
+
+	return seq
+}
+
+// squeezeExcitation scales channels of input by sigmoid (or hard sigmoid) of
+// fc2(relu(fc1(avgpool(x)))).
+func squeezeExcitation(p *nn.Path, cIn, cSqueeze int64, hardSigmoid bool) ts.ModuleT {
+	fc1 := nn.NewConv2D(p.Sub("fc1"), cIn, cSqueeze, 1, nn.DefaultConv2DConfig())
+	fc2 := nn.NewConv2D(p.Sub("fc2"), cSqueeze, cIn, 1, nn.DefaultConv2DConfig())
+
+	return nn.NewFunc(func(xs *ts.Tensor) *ts.Tensor {
+		tmp1 := xs.MustAdaptiveAvgPool2d([]int64{1, 1}, false)
+		tmp2 := tmp1.Apply(fc1)
+		tmp1.MustDrop()
+		tmp3 := tmp2.MustRelu(true)
+		tmp4 := tmp3.Apply(fc2)
+		tmp3.MustDrop()
+		var scale *ts.Tensor
+		if hardSigmoid {
+			scale = tmp4.MustHardsigmoid(true)
+		} else {
+			scale = tmp4.MustSigmoid(true)
+		}
+		res := xs.MustMul(scale, false)
+		scale.MustDrop()
+
+		return res
+	})
+}
+
+type resBottleneckBlock struct {
+	Proj ts.ModuleT // nil if input and output shapes are same
+	F    *nn.SequentialT
+}
+
+func newResBottleneckBlock(p *nn.Path, wIn, wOut, stride, groupWidth int64, seRatio float64) *resBottleneckBlock {
+	var proj ts.ModuleT
+	if wIn != wOut || stride != 1 {
+		proj = conv2dNormActivation(p.Sub("proj"), wIn, wOut, 1, stride, 1, false)
+	}
+
+	// Bottleneck multiplier is 1.0 in all pretrained models.
+	wB := wOut
+	g := wB / groupWidth
+
+	fp := p.Sub("f")
+	f := nn.SeqT()
+	f.Add(conv2dNormActivation(fp.Sub("a"), wIn, wB, 1, 1, 1, true))
+	f.Add(conv2dNormActivation(fp.Sub("b"), wB, wB, 3, stride, g, true))
+	if seRatio > 0 {
+		wSe := int64(math.RoundToEven(seRatio * float64(wIn)))
+		f.Add(squeezeExcitation(fp.Sub("se"), wB, wSe, false))
+	}
+	f.Add(conv2dNormActivation(fp.Sub("c"), wB, wOut, 1, 1, 1, false))
+
+	return &resBottleneckBlock{
+		Proj: proj,
+		F:    f,
+	}
+}
+
+// ForwardT implements ModuleT for resBottleneckBlock.
+func (b *resBottleneckBlock) ForwardT(xs *ts.Tensor, train bool) *ts.Tensor {
+	f := b.F.ForwardT(xs, train)
+	var add *ts.Tensor
+	if b.Proj != nil {
+		proj := b.Proj.ForwardT(xs, train)
+		add = proj.MustAdd(f, true)
+	} else {
+		add = f.MustAdd(xs, false)
+	}
+	f.MustDrop()
+
+	return add.MustRelu(true)
+}
+
+func regnet(p *nn.Path, nclasses int64, rp *regnetParams) ts.ModuleT {
+	const stemWidth int64 = 32
+	stem := conv2dNormActivation(p.Sub("stem"), 3, stemWidth, 3, 2, 1, true)
+
+	tp := p.Sub("trunk_output")
+	trunk := nn.SeqT()
+	width := stemWidth
+	for i, s := range rp.stages() {
+		stageIdx := i + 1
+		sp := tp.Sub(fmt.Sprintf("block%d", stageIdx))
+		stage := nn.SeqT()
+		for j := 0; j < int(s.depth); j++ {
+			wIn, stride := s.width, int64(1)
+			if j == 0 {
+				wIn, stride = width, 2
+			}
+			stage.Add(newResBottleneckBlock(sp.Sub(fmt.Sprintf("block%d-%d", stageIdx, j)), wIn, s.width, stride, s.groupWidth, rp.seRatio))
+		}
+		trunk.Add(stage)
+		width = s.width
+	}
+
+	var fc *nn.Linear
+	if nclasses > 0 {
+		fc = nn.NewLinear(p.Sub("fc"), width, nclasses, nn.DefaultLinearConfig())
+	}
+
+	return nn.NewFuncT(func(xs *ts.Tensor, train bool) *ts.Tensor {
+		tmp1 := xs.ApplyT(stem, train)
+		tmp2 := tmp1.ApplyT(trunk, train)
+		tmp1.MustDrop()
+		tmp3 := tmp2.MustAdaptiveAvgPool2d([]int64{1, 1}, true)
+		tmp4 := tmp3.MustFlatten(1, -1, true)
+		if fc == nil {
+			return tmp4
+		}
+
+		res := tmp4.Apply(fc)
+		tmp4.MustDrop()
+		return res
+	})
+}
+
+var (
+	regnetY400MF = &regnetParams{depth: 16, w0: 48, wa: 27.89, wm: 2.09, groupWidth: 8, seRatio: 0.25}
+	regnetY800MF = &regnetParams{depth: 14, w0: 56, wa: 38.84, wm: 2.4, groupWidth: 16, seRatio: 0.25}
+	regnetY1_6GF = &regnetParams{depth: 27, w0: 48, wa: 20.71, wm: 2.65, groupWidth: 24, seRatio: 0.25}
+	regnetY3_2GF = &regnetParams{depth: 21, w0: 80, wa: 42.63, wm: 2.66, groupWidth: 24, seRatio: 0.25}
+	regnetY8GF   = &regnetParams{depth: 17, w0: 192, wa: 76.82, wm: 2.19, groupWidth: 56, seRatio: 0.25}
+	regnetY16GF  = &regnetParams{depth: 18, w0: 200, wa: 106.23, wm: 2.48, groupWidth: 112, seRatio: 0.25}
+	regnetY32GF  = &regnetParams{depth: 20, w0: 232, wa: 115.89, wm: 2.53, groupWidth: 232, seRatio: 0.25}
+
+	regnetX400MF = &regnetParams{depth: 22, w0: 24, wa: 24.48, wm: 2.54, groupWidth: 16}
+	regnetX800MF = &regnetParams{depth: 16, w0: 56, wa: 35.73, wm: 2.28, groupWidth: 16}
+	regnetX1_6GF = &regnetParams{depth: 18, w0: 80, wa: 34.01, wm: 2.25, groupWidth: 24}
+	regnetX3_2GF = &regnetParams{depth: 25, w0: 88, wa: 26.31, wm: 2.25, groupWidth: 48}
+	regnetX8GF   = &regnetParams{depth: 23, w0: 80, wa: 49.56, wm: 2.88, groupWidth: 120}
+	regnetX16GF  = &regnetParams{depth: 22, w0: 216, wa: 55.59, wm: 2.1, groupWidth: 128}
+	regnetX32GF  = &regnetParams{depth: 23, w0: 320, wa: 69.86, wm: 2.0, groupWidth: 168}
+)
+
+// RegNetY400MF creates a RegNetY-400MF model.
+func RegNetY400MF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetY400MF)
+}
+
+// RegNetY400MFNoFinalLayer creates a RegNetY-400MF model without final
+// fully connected layer.
+func RegNetY400MFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetY400MF)
+}
+
+// RegNetY800MF creates a RegNetY-800MF model.
+func RegNetY800MF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetY800MF)
+}
+
+// RegNetY800MFNoFinalLayer creates a RegNetY-800MF model without final
+// fully connected layer.
+func RegNetY800MFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetY800MF)
+}
+
+// RegNetY1_6GF creates a RegNetY-1.6GF model.
+func RegNetY1_6GF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetY1_6GF)
+}
+
+// RegNetY1_6GFNoFinalLayer creates a RegNetY-1.6GF model without final
+// fully connected layer.
+func RegNetY1_6GFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetY1_6GF)
+}
+
+// RegNetY3_2GF creates a RegNetY-3.2GF model.
+func RegNetY3_2GF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetY3_2GF)
+}
+
+// RegNetY3_2GFNoFinalLayer creates a RegNetY-3.2GF model without final
+// fully connected layer.
+func RegNetY3_2GFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetY3_2GF)
+}
+
+// RegNetY8GF creates a RegNetY-8GF model.
+func RegNetY8GF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetY8GF)
+}
+
+// RegNetY8GFNoFinalLayer creates a RegNetY-8GF model without final
+// fully connected layer.
+func RegNetY8GFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetY8GF)
+}
+
+// RegNetY16GF creates a RegNetY-16GF model.
+func RegNetY16GF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetY16GF)
+}
+
+// RegNetY16GFNoFinalLayer creates a RegNetY-16GF model without final
+// fully connected layer.
+func RegNetY16GFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetY16GF)
+}
+
+// RegNetY32GF creates a RegNetY-32GF model.
+func RegNetY32GF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetY32GF)
+}
+
+// RegNetY32GFNoFinalLayer creates a RegNetY-32GF model without final
+// fully connected layer.
+func RegNetY32GFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetY32GF)
+}
+
+// RegNetX400MF creates a RegNetX-400MF model.
+func RegNetX400MF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetX400MF)
+}
+
+// RegNetX400MFNoFinalLayer creates a RegNetX-400MF model without final
+// fully connected layer.
+func RegNetX400MFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetX400MF)
+}
+
+// RegNetX800MF creates a RegNetX-800MF model.
+func RegNetX800MF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetX800MF)
+}
+
+// RegNetX800MFNoFinalLayer creates a RegNetX-800MF model without final
+// fully connected layer.
+func RegNetX800MFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetX800MF)
+}
+
+// RegNetX1_6GF creates a RegNetX-1.6GF model.
+func RegNetX1_6GF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetX1_6GF)
+}
+
+// RegNetX1_6GFNoFinalLayer creates a RegNetX-1.6GF model without final
+// fully connected layer.
+func RegNetX1_6GFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetX1_6GF)
+}
+
+// RegNetX3_2GF creates a RegNetX-3.2GF model.
+func RegNetX3_2GF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetX3_2GF)
+}
+
+// RegNetX3_2GFNoFinalLayer creates a RegNetX-3.2GF model without final
+// fully connected layer.
+func RegNetX3_2GFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetX3_2GF)
+}
+
+// RegNetX8GF creates a RegNetX-8GF model.
+func RegNetX8GF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetX8GF)
+}
+
+// RegNetX8GFNoFinalLayer creates a RegNetX-8GF model without final
+// fully connected layer.
+func RegNetX8GFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetX8GF)
+}
+
+// RegNetX16GF creates a RegNetX-16GF model.
+func RegNetX16GF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetX16GF)
+}
+
+// RegNetX16GFNoFinalLayer creates a RegNetX-16GF model without final
+// fully connected layer.
+func RegNetX16GFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetX16GF)
+}
+
+// RegNetX32GF creates a RegNetX-32GF model.
+func RegNetX32GF(p *nn.Path, nclasses int64) ts.ModuleT {
+	return regnet(p, nclasses, regnetX32GF)
+}
+
+// RegNetX32GFNoFinalLayer creates a RegNetX-32GF model without final
+// fully connected layer.
+func RegNetX32GFNoFinalLayer(p *nn.Path) ts.ModuleT {
+	return regnet(p, 0, regnetX32GF)
+}
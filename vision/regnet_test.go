@@ -0,0 +1,67 @@
+package vision_test
+
+import (
+	"testing"
+
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+	"github.com/sugarme/gotch/vision"
+)
+
+func TestRegNet(t *testing.T) {
+	// Stage widths and group width of first stage as in torchvision.
+	specs := []struct {
+		name       string
+		model      func(p *nn.Path, nclasses int64) ts.ModuleT
+		noFinal    func(p *nn.Path) ts.ModuleT
+		widths     []int64
+		groupWidth int64
+		se         bool
+		large      bool
+	}{
+		{"regnet_y_400mf", vision.RegNetY400MF, vision.RegNetY400MFNoFinalLayer, []int64{48, 104, 208, 440}, 8, true, false},
+		{"regnet_y_800mf", vision.RegNetY800MF, vision.RegNetY800MFNoFinalLayer, []int64{64, 144, 320, 784}, 16, true, false},
+		{"regnet_y_1_6gf", vision.RegNetY1_6GF, vision.RegNetY1_6GFNoFinalLayer, []int64{48, 120, 336, 888}, 24, true, false},
+		{"regnet_y_3_2gf", vision.RegNetY3_2GF, vision.RegNetY3_2GFNoFinalLayer, []int64{72, 216, 576, 1512}, 24, true, false},
+		{"regnet_y_8gf", vision.RegNetY8GF, vision.RegNetY8GFNoFinalLayer, []int64{224, 448, 896, 2016}, 56, true, true},
+		{"regnet_y_16gf", vision.RegNetY16GF, vision.RegNetY16GFNoFinalLayer, []int64{224, 448, 1232, 3024}, 112, true, true},
+		{"regnet_y_32gf", vision.RegNetY32GF, vision.RegNetY32GFNoFinalLayer, []int64{232, 696, 1392, 3712}, 232, true, true},
+		{"regnet_x_400mf", vision.RegNetX400MF, vision.RegNetX400MFNoFinalLayer, []int64{32, 64, 160, 400}, 16, false, false},
+		{"regnet_x_800mf", vision.RegNetX800MF, vision.RegNetX800MFNoFinalLayer, []int64{64, 128, 288, 672}, 16, false, false},
+		{"regnet_x_1_6gf", vision.RegNetX1_6GF, vision.RegNetX1_6GFNoFinalLayer, []int64{72, 168, 408, 912}, 24, false, false},
+		{"regnet_x_3_2gf", vision.RegNetX3_2GF, vision.RegNetX3_2GFNoFinalLayer, []int64{96, 192, 432, 1008}, 48, false, false},
+		{"regnet_x_8gf", vision.RegNetX8GF, vision.RegNetX8GFNoFinalLayer, []int64{80, 240, 720, 1920}, 80, false, true},
+		{"regnet_x_16gf", vision.RegNetX16GF, vision.RegNetX16GFNoFinalLayer, []int64{256, 512, 896, 2048}, 128, false, true},
+		{"regnet_x_32gf", vision.RegNetX32GF, vision.RegNetX32GFNoFinalLayer, []int64{336, 672, 1344, 2520}, 168, false, true},
+	}
+
+	var tests []modelTest
+	for _, s := range specs {
+		w := s.widths
+		vars := map[string][]int64{
+			"stem.0.weight":       {32, 3, 3, 3},
+			"stem.1.running_mean": {32},
+			"trunk_output.block1.block1-0.proj.0.weight": {w[0], 32, 1, 1},
+			"trunk_output.block1.block1-0.f.a.0.weight":  {w[0], 32, 1, 1},
+			"trunk_output.block1.block1-0.f.b.0.weight":  {w[0], s.groupWidth, 3, 3},
+			"trunk_output.block1.block1-0.f.c.1.weight":  {w[0]},
+			"trunk_output.block4.block4-0.proj.0.weight": {w[3], w[2], 1, 1},
+			"fc.weight": {1000, w[3]},
+		}
+		if s.se {
+			// Squeeze width is a quarter of block input width.
+			vars["trunk_output.block1.block1-0.f.se.fc1.weight"] = []int64{8, w[0], 1, 1}
+			vars["trunk_output.block2.block2-0.f.se.fc2.weight"] = []int64{w[1], w[0] / 4, 1, 1}
+		}
+		tests = append(tests, modelTest{
+			name:     s.name,
+			model:    s.model,
+			noFinal:  s.noFinal,
+			features: w[3],
+			vars:     vars,
+			large:    s.large,
+		})
+	}
+
+	testModels(t, []int64{1, 3, 64, 64}, tests)
+}
@@ -297,3 +297,117 @@ func ResNet152(path *nn.Path, numClasses int64) ts.ModuleT {
 func ResNet150NoFinalLayer(path *nn.Path) ts.ModuleT {
 	return bottleneckResnet(path, 0, 3, 8, 36, 3)
 }
+
+// ResNeXt and Wide ResNet implementation.
+//
+// See "Aggregated Residual Transformation for Deep Neural Networks", Xie et al. 2016
+// https://arxiv.org/abs/1611.05431
+// and "Wide Residual Networks", Zagoruyko et al. 2016
+// https://arxiv.org/abs/1605.07146
+
+// newGroupedBottleneckBlock creates a bottleneck block which 3x3 convolution
+// has `groups` groups of `widthPerGroup` channels (scaled by cOut/64).
+func newGroupedBottleneckBlock(path *nn.Path, cIn, cOut, stride, groups, widthPerGroup int64) *bottleneckBlock {
+	width := cOut * widthPerGroup / 64 * groups
+	eDim := 4 * cOut
+
+	conv2Config := nn.DefaultConv2DConfig()
+	conv2Config.Bias = false
+	conv2Config.Stride = []int64{stride, stride}
+	conv2Config.Padding = []int64{1, 1}
+	conv2Config.Groups = groups
+
+	return &bottleneckBlock{
+		Conv1:      conv2dNoBias(path.Sub("conv1"), cIn, width, 1, 0, 1),
+		Bn1:        nn.BatchNorm2D(path.Sub("bn1"), width, nn.DefaultBatchNormConfig()),
+		Conv2:      nn.NewConv2D(path.Sub("conv2"), width, width, 3, conv2Config),
+		Bn2:        nn.BatchNorm2D(path.Sub("bn2"), width, nn.DefaultBatchNormConfig()),
+		Conv3:      conv2dNoBias(path.Sub("conv3"), width, eDim, 1, 0, 1),
+		Bn3:        nn.BatchNorm2D(path.Sub("bn3"), eDim, nn.DefaultBatchNormConfig()),
+		Downsample: downSample(path.Sub("downsample"), cIn, eDim, stride),
+	}
+}
+
+func groupedBottleneckLayer(path *nn.Path, cIn, cOut, stride, cnt, groups, widthPerGroup int64) ts.ModuleT {
+	layer := nn.SeqT()
+	layer.Add(newGroupedBottleneckBlock(path.Sub("0"), cIn, cOut, stride, groups, widthPerGroup))
+	for blockIndex := 1; blockIndex < int(cnt); blockIndex++ {
+		layer.Add(newGroupedBottleneckBlock(path.Sub(fmt.Sprint(blockIndex)), 4*cOut, cOut, 1, groups, widthPerGroup))
+	}
+
+	return layer
+}
+
+func groupedBottleneckResnet(path *nn.Path, nclasses int64, c1, c2, c3, c4, groups, widthPerGroup int64) ts.ModuleT {
+	seq := nn.SeqT()
+	seq.Add(layerZero(path))
+	seq.Add(groupedBottleneckLayer(path.Sub("layer1"), 64, 64, 1, c1, groups, widthPerGroup))
+	seq.Add(groupedBottleneckLayer(path.Sub("layer2"), 4*64, 128, 2, c2, groups, widthPerGroup))
+	seq.Add(groupedBottleneckLayer(path.Sub("layer3"), 4*128, 256, 2, c3, groups, widthPerGroup))
+	seq.Add(groupedBottleneckLayer(path.Sub("layer4"), 4*256, 512, 2, c4, groups, widthPerGroup))
+
+	var fc *nn.Linear
+	if nclasses > 0 {
+		fc = nn.NewLinear(path.Sub("fc"), 4*512, nclasses, nn.DefaultLinearConfig())
+	}
+
+	return nn.NewFuncT(func(x *ts.Tensor, train bool) *ts.Tensor {
+		output := seq.ForwardT(x, train)
+		avgpool := output.MustAdaptiveAvgPool2d([]int64{1, 1}, true)
+		fv := avgpool.FlatView()
+		avgpool.MustDrop()
+		if fc == nil {
+			return fv
+		}
+
+		retVal := fv.ApplyOpt(ts.WithModule(fc))
+		fv.MustDrop()
+
+		return retVal
+	})
+}
+
+// ResNeXt50_32x4d creates a ResNeXt-50 32x4d model.
+func ResNeXt50_32x4d(path *nn.Path, numClasses int64) ts.ModuleT {
+	return groupedBottleneckResnet(path, numClasses, 3, 4, 6, 3, 32, 4)
+}
+
+// ResNeXt50_32x4dNoFinalLayer creates a ResNeXt-50 32x4d model without final
+// fully connected layer.
+func ResNeXt50_32x4dNoFinalLayer(path *nn.Path) ts.ModuleT {
+	return groupedBottleneckResnet(path, 0, 3, 4, 6, 3, 32, 4)
+}
+
+// ResNeXt101_32x8d creates a ResNeXt-101 32x8d model.
+func ResNeXt101_32x8d(path *nn.Path, numClasses int64) ts.ModuleT {
+	return groupedBottleneckResnet(path, numClasses, 3, 4, 23, 3, 32, 8)
+}
+
+// ResNeXt101_32x8dNoFinalLayer creates a ResNeXt-101 32x8d model without
+// final fully connected layer.
+func ResNeXt101_32x8dNoFinalLayer(path *nn.Path) ts.ModuleT {
+	return groupedBottleneckResnet(path, 0, 3, 4, 23, 3, 32, 8)
+}
+
+// WideResNet50_2 creates a Wide ResNet-50-2 model, i.e. ResNet-50 with twice
+// as many channels in bottleneck 3x3 convolutions.
+func WideResNet50_2(path *nn.Path, numClasses int64) ts.ModuleT {
+	return groupedBottleneckResnet(path, numClasses, 3, 4, 6, 3, 1, 128)
+}
+
+// WideResNet50_2NoFinalLayer creates a Wide ResNet-50-2 model without final
+// fully connected layer.
+func WideResNet50_2NoFinalLayer(path *nn.Path) ts.ModuleT {
+	return groupedBottleneckResnet(path, 0, 3, 4, 6, 3, 1, 128)
+}
+
+// WideResNet101_2 creates a Wide ResNet-101-2 model.
+func WideResNet101_2(path *nn.Path, numClasses int64) ts.ModuleT {
+	return groupedBottleneckResnet(path, numClasses, 3, 4, 23, 3, 1, 128)
+}
+
+// WideResNet101_2NoFinalLayer creates a Wide ResNet-101-2 model without final
+// fully connected layer.
+func WideResNet101_2NoFinalLayer(path *nn.Path) ts.ModuleT {
+	return groupedBottleneckResnet(path, 0, 3, 4, 23, 3, 1, 128)
+}
@@ -0,0 +1,62 @@
+package vision_test
+
+import (
+	"testing"
+
+	"github.com/sugarme/gotch/vision"
+)
+
+func TestResNeXtWideResNet(t *testing.T) {
+	tests := []modelTest{
+		{
+			name:     "resnext50_32x4d",
+			model:    vision.ResNeXt50_32x4d,
+			noFinal:  vision.ResNeXt50_32x4dNoFinalLayer,
+			features: 2048,
+			vars: map[string][]int64{
+				"layer1.0.conv1.weight":        {128, 64, 1, 1},
+				"layer1.0.conv2.weight":        {128, 4, 3, 3},
+				"layer1.0.conv3.weight":        {256, 128, 1, 1},
+				"layer1.0.downsample.0.weight": {256, 64, 1, 1},
+				"layer4.2.conv2.weight":        {1024, 32, 3, 3},
+				"fc.weight":                    {1000, 2048},
+			},
+		},
+		{
+			name:     "resnext101_32x8d",
+			model:    vision.ResNeXt101_32x8d,
+			noFinal:  vision.ResNeXt101_32x8dNoFinalLayer,
+			features: 2048,
+			vars: map[string][]int64{
+				"layer1.0.conv2.weight":  {256, 8, 3, 3},
+				"layer3.22.conv2.weight": {1024, 32, 3, 3},
+			},
+			large: true,
+		},
+		{
+			name:     "wide_resnet50_2",
+			model:    vision.WideResNet50_2,
+			noFinal:  vision.WideResNet50_2NoFinalLayer,
+			features: 2048,
+			vars: map[string][]int64{
+				"layer1.0.conv1.weight": {128, 64, 1, 1},
+				"layer1.0.conv2.weight": {128, 128, 3, 3},
+				"layer1.0.conv3.weight": {256, 128, 1, 1},
+				"layer4.0.conv2.weight": {1024, 1024, 3, 3},
+				"fc.weight":             {1000, 2048},
+			},
+		},
+		{
+			name:     "wide_resnet101_2",
+			model:    vision.WideResNet101_2,
+			noFinal:  vision.WideResNet101_2NoFinalLayer,
+			features: 2048,
+			vars: map[string][]int64{
+				"layer3.22.conv2.weight": {512, 512, 3, 3},
+			},
+			large: true,
+		},
+	}
+
+	testModels(t, []int64{1, 3, 64, 64}, tests)
+}
@@ -0,0 +1,70 @@
+package vision_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+// modelTest describes a model builder and its variant without final layer.
+type modelTest struct {
+	name     string
+	model    func(p *nn.Path, nclasses int64) ts.ModuleT
+	noFinal  func(p *nn.Path) ts.ModuleT
+	features int64              // output size of model without final layer
+	vars     map[string][]int64 // sample of variable shapes as in torchvision state dict
+	large    bool               // skipped in short mode
+}
+
+// testModels checks variable shapes and output shapes of models for a random
+// input of given shape, with and without final layer.
+func testModels(t *testing.T, input []int64, tests []modelTest) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.large && testing.Short() {
+				t.Skip("large model in short mode")
+			}
+
+			vs := nn.NewVarStore(gotch.CPU)
+			defer vs.Destroy()
+			m := tt.model(vs.Root(), 1000)
+			vars := vs.Variables()
+			for name, want := range tt.vars {
+				x, ok := vars[name]
+				if !ok {
+					t.Errorf("Want variable %q", name)
+					continue
+				}
+				if got := x.MustSize(); !reflect.DeepEqual(got, want) {
+					t.Errorf("Want %q of shape %v, got %v", name, want, got)
+				}
+			}
+			checkOutput(t, m, input, []int64{1, 1000})
+
+			if tt.noFinal != nil {
+				vsNoFinal := nn.NewVarStore(gotch.CPU)
+				defer vsNoFinal.Destroy()
+				checkOutput(t, tt.noFinal(vsNoFinal.Root()), input, []int64{1, tt.features})
+			}
+		})
+	}
+}
+
+func checkOutput(t *testing.T, m ts.ModuleT, input, want []int64) {
+	t.Helper()
+
+	x := ts.MustRandn(input, gotch.Float, gotch.CPU)
+	defer x.MustDrop()
+	var y *ts.Tensor
+	ts.NoGrad(func() {
+		y = m.ForwardT(x, false)
+	})
+	defer y.MustDrop()
+
+	if got := y.MustSize(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Want output shape %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,240 @@
+package vision
+
+// Vision Transformer (ViT) implementation.
+//
+// See "An Image is Worth 16x16 Words: Transformers for Image Recognition at
+// Scale", Dosovitskiy et al. 2020
+// https://arxiv.org/abs/2010.11929
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/ts"
+)
+
+type vitConfig struct {
+	patchSize int64
+	numLayers int64
+	numHeads  int64
+	hiddenDim int64
+	mlpDim    int64
+	imageSize int64
+}
+
+func vitLayerNormConfig() *nn.LayerNormConfig {
+	config := nn.DefaultLayerNormConfig()
+	config.Eps = 1e-6
+
+	return config
+}
+
+// vitEncoderBlock is a transformer encoder block: pre-norm self-attention and
+// pre-norm MLP, each with residual connection.
+type vitEncoderBlock struct {
+	Ln1           *nn.LayerNorm
+	SelfAttention *nn.MultiheadAttention
+	Ln2           *nn.LayerNorm
+	Linear1       *nn.Linear
+	Linear2       *nn.Linear
+}
+
+// NOTE. MLP variables are named "mlp.linear_1", "mlp.linear_2" as in
+// torchvision pretrained weight files.
+func newVitEncoderBlock(p *nn.Path, c *vitConfig) *vitEncoderBlock {
+	attnConfig := nn.DefaultMultiheadAttentionConfig()
+	attnConfig.BatchFirst = true
+
+	mp := p.Sub("mlp")
+	return &vitEncoderBlock{
+		Ln1:           nn.NewLayerNorm(p.Sub("ln_1"), []int64{c.hiddenDim}, vitLayerNormConfig()),
+		SelfAttention: nn.NewMultiheadAttention(p.Sub("self_attention"), c.hiddenDim, c.numHeads, attnConfig),
+		Ln2:           nn.NewLayerNorm(p.Sub("ln_2"), []int64{c.hiddenDim}, vitLayerNormConfig()),
+		Linear1:       nn.NewLinear(mp.Sub("linear_1"), c.hiddenDim, c.mlpDim, nn.DefaultLinearConfig()),
+		Linear2:       nn.NewLinear(mp.Sub("linear_2"), c.mlpDim, c.hiddenDim, nn.DefaultLinearConfig()),
+	}
+}
+
+// ForwardT implements ModuleT for vitEncoderBlock.
+func (b *vitEncoderBlock) ForwardT(xs *ts.Tensor, train bool) *ts.Tensor {
+	norm1 := b.Ln1.Forward(xs)
+	attn, _ := b.SelfAttention.ForwardT(norm1, norm1, norm1, train)
+	norm1.MustDrop()
+	x := attn.MustAdd(xs, true)
+
+	norm2 := b.Ln2.Forward(x)
+	fc1 := b.Linear1.ForwardT(norm2, train)
+	norm2.MustDrop()
+	act := fc1.MustGelu("none", true)
+	fc2 := b.Linear2.ForwardT(act, train)
+	act.MustDrop()
+	res := fc2.MustAdd(x, true)
+	x.MustDrop()
+
+	return res
+}
+
+type vit struct {
+	ConvProj     *nn.Conv2D
+	ClassToken   *ts.Tensor // [1, 1, hiddenDim]
+	PosEmbedding *ts.Tensor // [1, numPatches + 1, hiddenDim]
+	Layers       *nn.SequentialT
+	Ln           *nn.LayerNorm
+	Head         *nn.Linear // nil without final layer
+	PatchSize    int64
+}
+
+func newVit(p *nn.Path, c *vitConfig, nclasses int64) *vit {
+	projConfig := nn.DefaultConv2DConfig()
+	projConfig.Stride = []int64{c.patchSize, c.patchSize}
+	projConfig.Padding = []int64{0, 0}
+
+	seqLen := (c.imageSize/c.patchSize)*(c.imageSize/c.patchSize) + 1
+
+	ep := p.Sub("encoder")
+	lp := ep.Sub("layers")
+	layers := nn.SeqT()
+	for i := 0; i < int(c.numLayers); i++ {
+		layers.Add(newVitEncoderBlock(lp.Sub(fmt.Sprintf("encoder_layer_%d", i)), c))
+	}
+
+	var head *nn.Linear
+	if nclasses > 0 {
+		head = nn.NewLinear(p.Sub("heads").Sub("head"), c.hiddenDim, nclasses, nn.DefaultLinearConfig())
+	}
+
+	return &vit{
+		ConvProj:     nn.NewConv2D(p.Sub("conv_proj"), 3, c.hiddenDim, c.patchSize, projConfig),
+		ClassToken:   p.MustZeros("class_token", []int64{1, 1, c.hiddenDim}),
+		PosEmbedding: ep.MustNewVar("pos_embedding", []int64{1, seqLen, c.hiddenDim}, nn.NewRandnInit(0.0, 0.02)),
+		Layers:       layers,
+		Ln:           nn.NewLayerNorm(ep.Sub("ln"), []int64{c.hiddenDim}, vitLayerNormConfig()),
+		Head:         head,
+		PatchSize:    c.patchSize,
+	}
+}
+
+// posEmbedding returns position embedding for nh x nw patches. Embedding of
+// patches is bicubic interpolated if input resolution differs from the one
+// model is created with.
+func (m *vit) posEmbedding(nh, nw int64) *ts.Tensor {
+	size := m.PosEmbedding.MustSize()
+	seqLen, hiddenDim := size[1], size[2]
+	if nh*nw+1 == seqLen {
+		return m.PosEmbedding.MustShallowClone()
+	}
+
+	// Patches of embedding are on a square grid.
+	gridSize := int64(math.Sqrt(float64(seqLen - 1)))
+	if gridSize*gridSize != seqLen-1 {
+		log.Fatalf("ViT position embedding of %v patches is not square\n", seqLen-1)
+	}
+
+	clsPos := m.PosEmbedding.MustNarrow(1, 0, 1, false)
+	patchPos := m.PosEmbedding.MustNarrow(1, 1, seqLen-1, false)
+	grid := patchPos.MustPermute([]int64{0, 2, 1}, true).MustReshape([]int64{1, hiddenDim, gridSize, gridSize}, true)
+	resized := grid.MustUpsampleBicubic2d([]int64{nh, nw}, true, nil, nil, true)
+	newPatchPos := resized.MustReshape([]int64{1, hiddenDim, nh * nw}, true).MustPermute([]int64{0, 2, 1}, true)
+	res := ts.MustCat([]*ts.Tensor{clsPos, newPatchPos}, 1)
+	clsPos.MustDrop()
+	newPatchPos.MustDrop()
+
+	return res
+}
+
+// ForwardT implements ModuleT for vit.
+func (m *vit) ForwardT(xs *ts.Tensor, train bool) *ts.Tensor {
+	size := xs.MustSize()
+	n, h, w := size[0], size[2], size[3]
+	if h%m.PatchSize != 0 || w%m.PatchSize != 0 {
+		log.Fatalf("Expected input height and width divisible by patch size %v, got %v\n", m.PatchSize, size)
+	}
+
+	// [n, hiddenDim, nh, nw] -> [n, nh * nw, hiddenDim]
+	proj := m.ConvProj.ForwardT(xs, train)
+	patches := proj.MustFlatten(2, -1, true).MustPermute([]int64{0, 2, 1}, true)
+
+	cls := m.ClassToken.MustExpand([]int64{n, -1, -1}, false, false)
+	tokens := ts.MustCat([]*ts.Tensor{cls, patches}, 1)
+	cls.MustDrop()
+	patches.MustDrop()
+
+	pos := m.posEmbedding(h/m.PatchSize, w/m.PatchSize)
+	x := tokens.MustAdd(pos, true)
+	pos.MustDrop()
+
+	encoded := x.ApplyT(m.Layers, train)
+	x.MustDrop()
+	norm := m.Ln.Forward(encoded)
+	encoded.MustDrop()
+
+	// Class token
+	features := norm.MustSelect(1, 0, true)
+	if m.Head == nil {
+		return features
+	}
+
+	res := features.Apply(m.Head)
+	features.MustDrop()
+	return res
+}
+
+func vitB16Config() *vitConfig {
+	return &vitConfig{patchSize: 16, numLayers: 12, numHeads: 12, hiddenDim: 768, mlpDim: 3072, imageSize: 224}
+}
+
+func vitB32Config() *vitConfig {
+	return &vitConfig{patchSize: 32, numLayers: 12, numHeads: 12, hiddenDim: 768, mlpDim: 3072, imageSize: 224}
+}
+
+func vitL16Config() *vitConfig {
+	return &vitConfig{patchSize: 16, numLayers: 24, numHeads: 16, hiddenDim: 1024, mlpDim: 4096, imageSize: 224}
+}
+
+func vitL32Config() *vitConfig {
+	return &vitConfig{patchSize: 32, numLayers: 24, numHeads: 16, hiddenDim: 1024, mlpDim: 4096, imageSize: 224}
+}
+
+// ViTB16 creates a ViT-B/16 model for 224x224 input. Other input resolutions
+// (multiple of patch size) use interpolated position embeddings.
+func ViTB16(p *nn.Path, nclasses int64) ts.ModuleT {
+	return newVit(p, vitB16Config(), nclasses)
+}
+
+// ViTB16NoFinalLayer creates a ViT-B/16 model without classification head.
+// It outputs class token features of shape [batch, 768].
+func ViTB16NoFinalLayer(p *nn.Path) ts.ModuleT {
+	return newVit(p, vitB16Config(), 0)
+}
+
+// ViTB32 creates a ViT-B/32 model for 224x224 input.
+func ViTB32(p *nn.Path, nclasses int64) ts.ModuleT {
+	return newVit(p, vitB32Config(), nclasses)
+}
+
+// ViTB32NoFinalLayer creates a ViT-B/32 model without classification head.
+func ViTB32NoFinalLayer(p *nn.Path) ts.ModuleT {
+	return newVit(p, vitB32Config(), 0)
+}
+
+// ViTL16 creates a ViT-L/16 model for 224x224 input.
+func ViTL16(p *nn.Path, nclasses int64) ts.ModuleT {
+	return newVit(p, vitL16Config(), nclasses)
+}
+
+// ViTL16NoFinalLayer creates a ViT-L/16 model without classification head.
+func ViTL16NoFinalLayer(p *nn.Path) ts.ModuleT {
+	return newVit(p, vitL16Config(), 0)
+}
+
+// ViTL32 creates a ViT-L/32 model for 224x224 input.
+func ViTL32(p *nn.Path, nclasses int64) ts.ModuleT {
+	return newVit(p, vitL32Config(), nclasses)
+}
+
+// ViTL32NoFinalLayer creates a ViT-L/32 model without classification head.
+func ViTL32NoFinalLayer(p *nn.Path) ts.ModuleT {
+	return newVit(p, vitL32Config(), 0)
+}
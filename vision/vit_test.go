@@ -0,0 +1,82 @@
+package vision_test
+
+import (
+	"testing"
+
+	"github.com/sugarme/gotch"
+	"github.com/sugarme/gotch/nn"
+	"github.com/sugarme/gotch/vision"
+)
+
+func TestViT(t *testing.T) {
+	tests := []modelTest{
+		{
+			name:     "vit_b_16",
+			model:    vision.ViTB16,
+			noFinal:  vision.ViTB16NoFinalLayer,
+			features: 768,
+			vars: map[string][]int64{
+				"class_token":           {1, 1, 768},
+				"conv_proj.weight":      {768, 3, 16, 16},
+				"encoder.pos_embedding": {1, 197, 768},
+				"encoder.layers.encoder_layer_0.ln_1.weight":                    {768},
+				"encoder.layers.encoder_layer_0.self_attention.in_proj_weight":  {2304, 768},
+				"encoder.layers.encoder_layer_0.self_attention.out_proj.weight": {768, 768},
+				"encoder.layers.encoder_layer_0.mlp.linear_1.weight":            {3072, 768},
+				"encoder.layers.encoder_layer_11.mlp.linear_2.weight":           {768, 3072},
+				"encoder.ln.weight": {768},
+				"heads.head.weight": {1000, 768},
+			},
+		},
+		{
+			name:     "vit_b_32",
+			model:    vision.ViTB32,
+			noFinal:  vision.ViTB32NoFinalLayer,
+			features: 768,
+			vars: map[string][]int64{
+				"conv_proj.weight":      {768, 3, 32, 32},
+				"encoder.pos_embedding": {1, 50, 768},
+			},
+			large: true,
+		},
+		{
+			name:     "vit_l_16",
+			model:    vision.ViTL16,
+			noFinal:  vision.ViTL16NoFinalLayer,
+			features: 1024,
+			vars: map[string][]int64{
+				"encoder.pos_embedding":                               {1, 197, 1024},
+				"encoder.layers.encoder_layer_23.mlp.linear_1.weight": {4096, 1024},
+				"heads.head.weight":                                   {1000, 1024},
+			},
+			large: true,
+		},
+		{
+			name:     "vit_l_32",
+			model:    vision.ViTL32,
+			noFinal:  vision.ViTL32NoFinalLayer,
+			features: 1024,
+			vars: map[string][]int64{
+				"conv_proj.weight":      {1024, 3, 32, 32},
+				"encoder.pos_embedding": {1, 50, 1024},
+			},
+			large: true,
+		},
+	}
+
+	testModels(t, []int64{1, 3, 224, 224}, tests)
+}
+
+func TestViT_Interpolation(t *testing.T) {
+	vs := nn.NewVarStore(gotch.CPU)
+	defer vs.Destroy()
+	m := vision.ViTB16(vs.Root(), 1000)
+
+	// 10x12 patches instead of 14x14 of position embedding.
+	checkOutput(t, m, []int64{2, 3, 160, 192}, []int64{2, 1000})
+
+	pos := vs.Variables()["encoder.pos_embedding"]
+	if got := pos.MustSize(); got[1] != 197 {
+		t.Errorf("Want position embedding of 197 tokens unchanged, got %v", got)
+	}
+}